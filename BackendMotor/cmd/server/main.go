@@ -6,6 +6,7 @@ import (
 
 	"backendmotor/internal/config"   // pgxpool
 	"backendmotor/internal/database" // GORM
+	"backendmotor/internal/ejecucion"
 	"backendmotor/internal/monitoring"
 	"backendmotor/internal/routes"
 	"backendmotor/internal/scheduler"
@@ -27,6 +28,9 @@ func main() {
 	database.InitDB()
 	log.Println("✅ GORM listo (database.DB)")
 
+	// Retomar o abandonar las ejecuciones que quedaron a medias en la cola durable
+	ejecucion.RecuperarEjecucionesPendientes()
+
 	// Iniciar scheduler de tareas programadas
 	taskScheduler := scheduler.NuevoScheduler()
 	taskScheduler.Iniciar()
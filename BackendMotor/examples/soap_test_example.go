@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 
@@ -62,7 +63,7 @@ func main() {
 
 	// 4. Ejecutar conector SOAP
 	fmt.Println("🚀 Ejecutando conector SOAP...")
-	fullOutput, err := ejecutores.EjecutarSOAP(nodo, resultado, servidor, "test-proceso-id")
+	fullOutput, err := ejecutores.EjecutarSOAP(context.Background(), nodo, resultado, servidor, "test-proceso-id")
 
 	// 5. Mostrar resultados
 	fmt.Println()
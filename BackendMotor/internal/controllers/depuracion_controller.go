@@ -0,0 +1,104 @@
+package controllers
+
+import (
+	"backendmotor/internal/ejecucion"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Tiempo máximo que un request de depuración espera a que la ejecución vuelva a pausar o termine
+const esperaDepuracion = 30 * time.Second
+
+type requestDepuracion struct {
+	Variables   map[string]interface{} `json:"variables"`
+	Breakpoints []string               `json:"breakpoints"`
+}
+
+// iniciarDepuracion arranca la sesión y responde cuando llega a la primera pausa (o termina)
+func iniciarDepuracion(c *gin.Context, procesoID string, parametros map[string]interface{}, canal, trigger string, breakpoints []string, opciones *ejecucion.OpcionesEjecucion) {
+	sesion := ejecucion.IniciarDepuracion(procesoID, parametros, canal, trigger, breakpoints, opciones)
+	c.JSON(http.StatusOK, sesion.Esperar(c.Request.Context(), 0, esperaDepuracion))
+}
+
+// GET /depuracion/:id
+func GetSesionDepuracion(c *gin.Context) {
+	sesion, ok := ejecucion.ObtenerSesionDepuracion(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sesión de depuración no encontrada"})
+		return
+	}
+	c.JSON(http.StatusOK, sesion.Instantanea())
+}
+
+// POST /depuracion/:id/continuar
+func ContinuarDepuracion(c *gin.Context) {
+	comandoDepuracion(c, (*ejecucion.SesionDepuracion).Continuar)
+}
+
+// POST /depuracion/:id/paso
+func PasoDepuracion(c *gin.Context) {
+	comandoDepuracion(c, (*ejecucion.SesionDepuracion).Paso)
+}
+
+// PUT /depuracion/:id/variables
+func EditarVariablesDepuracion(c *gin.Context) {
+	comandoDepuracion(c, (*ejecucion.SesionDepuracion).EditarVariables)
+}
+
+// PUT /depuracion/:id/breakpoints
+func DefinirBreakpointsDepuracion(c *gin.Context) {
+	sesion, ok := ejecucion.ObtenerSesionDepuracion(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sesión de depuración no encontrada"})
+		return
+	}
+
+	var request requestDepuracion
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "JSON inválido: " + err.Error()})
+		return
+	}
+
+	sesion.DefinirBreakpoints(request.Breakpoints)
+	c.JSON(http.StatusOK, sesion.Instantanea())
+}
+
+// DELETE /depuracion/:id
+func AbortarDepuracion(c *gin.Context) {
+	sesion, ok := ejecucion.ObtenerSesionDepuracion(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sesión de depuración no encontrada"})
+		return
+	}
+
+	version := sesion.Version()
+	sesion.Abortar()
+	c.JSON(http.StatusOK, sesion.Esperar(c.Request.Context(), version, esperaDepuracion))
+}
+
+// comandoDepuracion envía una acción a la sesión pausada y responde con la siguiente pausa
+func comandoDepuracion(c *gin.Context, accion func(*ejecucion.SesionDepuracion, map[string]interface{}) error) {
+	sesion, ok := ejecucion.ObtenerSesionDepuracion(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sesión de depuración no encontrada"})
+		return
+	}
+
+	var request requestDepuracion
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "JSON inválido: " + err.Error()})
+			return
+		}
+	}
+
+	version := sesion.Version()
+	if err := accion(sesion, request.Variables); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "sesion": sesion.Instantanea()})
+		return
+	}
+
+	c.JSON(http.StatusOK, sesion.Esperar(c.Request.Context(), version, esperaDepuracion))
+}
@@ -0,0 +1,172 @@
+package controllers
+
+import (
+	"backendmotor/internal/database"
+	"backendmotor/internal/ejecucion"
+	"backendmotor/internal/models"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GET /ejecuciones/:id
+// Devuelve la ejecución con todos sus pasos en orden
+func GetEjecucion(c *gin.Context) {
+	id := c.Param("id")
+
+	var ejecucion models.Ejecucion
+	err := database.DBGORM.WithContext(c.Request.Context()).
+		Preload("Pasos", func(db *gorm.DB) *gorm.DB { return db.Order("secuencia") }).
+		First(&ejecucion, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ejecución no encontrada"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al consultar ejecución: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, ejecucion)
+}
+
+// GET /ejecuciones?proceso=&canal=&estado=&desde=&hasta=&limite=
+// Lista ejecuciones (sin pasos), las más recientes primero
+func GetEjecuciones(c *gin.Context) {
+	consulta := database.DBGORM.WithContext(c.Request.Context()).Model(&models.Ejecucion{})
+
+	if proceso := c.Query("proceso"); proceso != "" {
+		consulta = consulta.Where("proceso_id = ?", proceso)
+	}
+	if canal := c.Query("canal"); canal != "" {
+		consulta = consulta.Where("canal = ?", canal)
+	}
+	if estado := c.Query("estado"); estado != "" {
+		consulta = consulta.Where("estado = ?", estado)
+	}
+	if traceID := c.Query("traceId"); traceID != "" {
+		consulta = consulta.Where("trace_id = ?", traceID)
+	}
+	if desde := c.Query("desde"); desde != "" {
+		fecha, err := parsearFechaFiltro(desde, false)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Parámetro 'desde' inválido (use RFC3339 o AAAA-MM-DD)"})
+			return
+		}
+		consulta = consulta.Where("fecha_inicio >= ?", fecha)
+	}
+	if hasta := c.Query("hasta"); hasta != "" {
+		fecha, err := parsearFechaFiltro(hasta, true)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Parámetro 'hasta' inválido (use RFC3339 o AAAA-MM-DD)"})
+			return
+		}
+		consulta = consulta.Where("fecha_inicio < ?", fecha)
+	}
+
+	limite := 100
+	if l, err := strconv.Atoi(c.Query("limite")); err == nil && l > 0 && l <= 1000 {
+		limite = l
+	}
+
+	var ejecuciones []models.Ejecucion
+	if err := consulta.Order("fecha_inicio DESC").Limit(limite).Find(&ejecuciones).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al consultar ejecuciones: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, ejecuciones)
+}
+
+// parsearFechaFiltro acepta RFC3339 o solo fecha; con finDeDia, una fecha sola incluye el día completo
+func parsearFechaFiltro(valor string, finDeDia bool) (time.Time, error) {
+	if fecha, err := time.Parse(time.RFC3339, valor); err == nil {
+		return fecha, nil
+	}
+	fecha, err := time.ParseInLocation("2006-01-02", valor, time.Local)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if finDeDia {
+		fecha = fecha.AddDate(0, 0, 1)
+	}
+	return fecha, nil
+}
+
+// GET /ejecuciones/:id/estado
+// Estado y resultado de una ejecución asíncrona; si ya salió de memoria se arma desde la tabla ejecuciones
+func GetEstadoEjecucion(c *gin.Context) {
+	id := c.Param("id")
+
+	if estado, ok := ejecucion.ObtenerEstadoAsincrono(id); ok {
+		c.JSON(http.StatusOK, estado)
+		return
+	}
+
+	var registro models.Ejecucion
+	err := database.DBGORM.WithContext(c.Request.Context()).First(&registro, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ejecución no encontrada"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al consultar ejecución: " + err.Error()})
+		return
+	}
+
+	estado := ejecucion.EstadoAsincrono{
+		EjecucionID:   registro.ID,
+		ProcesoID:     registro.ProcesoID,
+		Canal:         registro.Canal,
+		Trigger:       registro.Trigger,
+		Estado:        ejecucion.AsincronaEjecutando,
+		FechaEncolado: registro.FechaInicio,
+		FechaInicio:   &registro.FechaInicio,
+		FechaFin:      registro.FechaFin,
+	}
+	switch registro.Estado {
+	case ejecucion.EstadoEjecucionEjecutando:
+	case ejecucion.EstadoEjecucionAbandonada:
+		estado.Estado = ejecucion.AsincronaAbandonada
+		estado.Error = registro.DetalleError
+	default:
+		estado.Estado = ejecucion.AsincronaCompletada
+		estado.Resultado = &ejecucion.ResultadoEjecucion{
+			Estado:      registro.CodigoResultado,
+			Mensaje:     registro.Mensaje,
+			Datos:       registro.Salida,
+			ProcesoID:   registro.ProcesoID,
+			Trigger:     registro.Trigger,
+			TraceID:     registro.TraceID,
+			EjecucionID: registro.ID,
+		}
+	}
+
+	c.JSON(http.StatusOK, estado)
+}
+
+// encolarEjecucion deja la ejecución en el pool asíncrono y responde 202 con su ID
+func encolarEjecucion(c *gin.Context, procesoID string, parametros map[string]interface{}, canal, trigger string, opciones *ejecucion.OpcionesEjecucion) {
+	id, err := ejecucion.EncolarEjecucion(procesoID, parametros, canal, trigger, opciones, ejecucion.CallbackSolicitado(c.Request))
+	if errors.Is(err, ejecucion.ErrColaAsincronaLlena) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	urlEstado := "/ejecuciones/" + id + "/estado"
+	c.Header("Location", urlEstado)
+	c.JSON(http.StatusAccepted, gin.H{
+		"ejecucionId": id,
+		"procesoId":   procesoID,
+		"estado":      ejecucion.AsincronaPendiente,
+		"urlEstado":   urlEstado,
+	})
+}
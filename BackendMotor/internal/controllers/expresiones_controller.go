@@ -0,0 +1,26 @@
+package controllers
+
+import (
+	"backendmotor/internal/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ValidarExpresionRequest es lo que envía el diseñador mientras el usuario escribe
+type ValidarExpresionRequest struct {
+	Expresion string   `json:"expresion"`
+	Variables []string `json:"variables"` // opcional: variables disponibles en el nodo
+}
+
+// POST /expresiones/validar
+// Revisa la sintaxis de una condición o asignación; responde 200 con la línea y columna del error
+func ValidarExpresion(c *gin.Context) {
+	var req ValidarExpresionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "JSON inválido: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.ValidarExpresion(req.Expresion, req.Variables))
+}
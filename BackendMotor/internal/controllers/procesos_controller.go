@@ -47,6 +47,11 @@ func CreateProceso(c *gin.Context) {
 		proceso.ID = uuid.New().String()
 	}
 
+	if validacion := ejecucion.ValidarProceso(c, proceso); flujoRechazado(c, validacion) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "El flujo tiene errores", "validacion": validacion})
+		return
+	}
+
 	_, err := config.DB.Exec(c, `
 		INSERT INTO procesos (id, codigo, nombre, descripcion, flujo)
 		VALUES ($1, $2, $3, $4, $5)
@@ -73,6 +78,11 @@ func UpdateProceso(c *gin.Context) {
 		return
 	}
 
+	if validacion := ejecucion.ValidarProceso(c, proceso); flujoRechazado(c, validacion) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "El flujo tiene errores", "validacion": validacion})
+		return
+	}
+
 	_, err := config.DB.Exec(c, `
 		UPDATE procesos 
 		SET codigo=$1, nombre=$2, descripcion=$3, flujo=$4
@@ -83,9 +93,33 @@ func UpdateProceso(c *gin.Context) {
 		return
 	}
 
+	// El flujo cambió: la próxima ejecución debe recompilarlo
+	ejecucion.InvalidarFlujo(id)
+
 	c.JSON(http.StatusOK, proceso)
 }
 
+// flujoRechazado decide si la validación impide guardar: siempre con errores estructurales (el
+// flujo no compila) y, con ?estricto=true, con cualquier error
+func flujoRechazado(c *gin.Context, validacion ejecucion.ResultadoValidacion) bool {
+	if c.Query("estricto") == "true" {
+		return !validacion.Valido
+	}
+	return !validacion.Guardable
+}
+
+// POST /procesos/validar
+// Revisa el flujo sin guardarlo; responde 200 con todos los problemas aunque haya errores
+func ValidarProceso(c *gin.Context) {
+	var proceso models.Proceso
+	if err := c.ShouldBindJSON(&proceso); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "JSON inválido: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, ejecucion.ValidarProceso(c, proceso))
+}
+
 // DELETE /procesos/:id
 func DeleteProceso(c *gin.Context) {
 	id := c.Param("id")
@@ -96,9 +130,18 @@ func DeleteProceso(c *gin.Context) {
 		return
 	}
 
+	ejecucion.InvalidarFlujo(id)
+
 	c.Status(http.StatusNoContent)
 }
 
+// DELETE /procesos-cache
+// Vacía la caché de flujos compilados (útil si los flujos se editaron directamente en la base)
+func LimpiarCacheFlujos(c *gin.Context) {
+	ejecucion.InvalidarTodosLosFlujos()
+	c.JSON(http.StatusOK, gin.H{"mensaje": "Caché de flujos vaciada"})
+}
+
 // POST /ejecutar-proceso
 func EjecutarProceso(c *gin.Context) {
 	var request struct {
@@ -106,6 +149,12 @@ func EjecutarProceso(c *gin.Context) {
 		Parametros map[string]interface{} `json:"parametros"`
 		Canal      string                 `json:"canal"`
 		Trigger    string                 `json:"trigger"`
+		// Modo depuración: la ejecución se pausa en los breakpoints y se controla con /depuracion/:id
+		Depuracion *struct {
+			Breakpoints []string `json:"breakpoints"`
+		} `json:"depuracion"`
+		// Ejecución en seco: los nodos proceso usan estas respuestas en lugar de llamar al servidor
+		Simulacion *ejecucion.Simulacion `json:"simulacion"`
 	}
 
 	if err := c.ShouldBindJSON(&request); err != nil {
@@ -118,13 +167,32 @@ func EjecutarProceso(c *gin.Context) {
 		request.Canal = "API"
 	}
 	
-	// Usar trigger por defecto si no se especifica
+	// Usar trigger por defecto si no se especifica (las simulaciones quedan identificadas en la traza)
 	if request.Trigger == "" {
 		request.Trigger = "api"
+		if request.Simulacion != nil {
+			request.Trigger = "simulacion"
+		}
+	}
+
+	var opciones *ejecucion.OpcionesEjecucion
+	if request.Simulacion != nil {
+		opciones = &ejecucion.OpcionesEjecucion{Simulacion: request.Simulacion}
+	}
+
+	if request.Depuracion != nil {
+		iniciarDepuracion(c, request.ProcesoID, request.Parametros, request.Canal, request.Trigger, request.Depuracion.Breakpoints, opciones)
+		return
+	}
+
+	// Modo asíncrono: se responde 202 con el ID y el resultado se consulta en /ejecuciones/:id/estado
+	if ejecucion.SolicitaEjecucionAsincrona(c.Request) {
+		encolarEjecucion(c, request.ProcesoID, request.Parametros, request.Canal, request.Trigger, opciones)
+		return
 	}
 
 	// Ejecutar el proceso usando el motor
-	resultado, err := ejecucion.EjecutarFlujo(request.ProcesoID, request.Parametros, request.Canal, request.Trigger)
+	resultado, err := ejecucion.EjecutarFlujoConOpciones(c.Request.Context(), request.ProcesoID, request.Parametros, request.Canal, request.Trigger, opciones)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Error ejecutando proceso: " + err.Error(),
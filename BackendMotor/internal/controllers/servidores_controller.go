@@ -2,7 +2,10 @@ package controllers
 
 import (
 	"backendmotor/internal/config"
+	"backendmotor/internal/ejecucion"
+	"backendmotor/internal/ejecucion/ejecutores"
 	"backendmotor/internal/models"
+	"fmt"
 	"net/http"
 	"time"
 
@@ -88,6 +91,11 @@ func UpdateServidor(c *gin.Context) {
 		return
 	}
 
+	// Las conexiones abiertas usan la configuración anterior: se arma el pool de nuevo
+	if err := ejecutores.ReconstruirPool(servidor); err != nil {
+		fmt.Printf("⚠️ No se pudo reconstruir el pool del servidor %s: %v\n", id, err)
+	}
+
 	c.JSON(http.StatusOK, servidor)
 }
 
@@ -101,5 +109,22 @@ func DeleteServidor(c *gin.Context) {
 		return
 	}
 
+	ejecutores.CerrarPool(id)
+
 	c.Status(http.StatusNoContent)
 }
+
+// GET /servidores-circuitos
+func GetCircuitosServidores(c *gin.Context) {
+	c.JSON(http.StatusOK, ejecucion.EstadoCircuitos())
+}
+
+// POST /servidores-circuitos/:id/reiniciar
+func ReiniciarCircuitoServidor(c *gin.Context) {
+	id := c.Param("id")
+	if !ejecucion.ReiniciarCircuito(id) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "El servidor no tiene circuito registrado"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"mensaje": "Circuito cerrado", "servidorId": id})
+}
@@ -4,7 +4,6 @@ import (
 	"backendmotor/internal/functions"
 	"backendmotor/internal/utils"
 	"fmt"
-	"time"
 )
 
 // TipoAsignacion define los tipos de asignación soportados
@@ -14,6 +13,7 @@ const (
 	AsignacionLiteral TipoAsignacion = "literal" // Valor fijo: "PENDIENTE"
 	AsignacionCampo   TipoAsignacion = "campo"   // Del input: cliente_id
 	AsignacionFuncion TipoAsignacion = "funcion" // Función del sistema: Ahora()
+	AsignacionSistema TipoAsignacion = "sistema" // Igual que funcion; así lo llaman proceso y salida
 	AsignacionTabla   TipoAsignacion = "tabla"   // Tabla local: Estados["01"].Descripcion
 )
 
@@ -49,13 +49,13 @@ func ResolverAsignacion(asig AsignacionAvanzada, ctx map[string]interface{}) (in
 
 	case AsignacionCampo:
 		// Valor desde el contexto/input
-		if val, exists := ctx[asig.Valor]; exists {
+		if val, exists := utils.ResolverRuta(ctx, asig.Valor); exists {
 			return val, nil
 		}
 		return nil, fmt.Errorf("campo '%s' no encontrado en el contexto", asig.Valor)
 
-	case AsignacionFuncion:
-		// Evaluar expresión/función del sistema
+	case AsignacionFuncion, AsignacionSistema:
+		// Evaluar expresión/función del sistema (cualquier valor, no solo booleanos)
 		return EvaluarFuncionDelSistema(asig.Valor, ctx)
 
 	case AsignacionTabla:
@@ -67,57 +67,13 @@ func ResolverAsignacion(asig AsignacionAvanzada, ctx map[string]interface{}) (in
 	}
 }
 
-// EvaluarFuncionDelSistema evalúa una función como Ahora(), UUID(), etc.
+// EvaluarFuncionDelSistema evalúa una función o expresión del sistema y devuelve su valor con el
+// tipo original: Ahora(), UUID() o SubTexto(cuenta, 0, 4) + "-" + TextoEnMayusculas(nombre)
 func EvaluarFuncionDelSistema(expresion string, ctx map[string]interface{}) (interface{}, error) {
-	// Para funciones simples sin comparaciones, podemos usar una evaluación directa
-	// Si es una expresión más compleja, usamos el evaluador completo
-	
-	// Funciones simples más comunes:
-	switch expresion {
-	case "Ahora()":
-		return utils.EvaluarExpresion("Ahora() == Ahora()", ctx) // Truco: evalúa y retorna el valor de Ahora()
-	case "UUID()":
-		return utils.EvaluarExpresion("UUID() != ''", ctx) // Similar truco
-	}
-
-	// Para expresiones más complejas, intentar evaluación completa
-	// Nota: esto es una simplificación. Una implementación más robusta 
-	// evaluaría directamente las funciones sin necesidad de comparaciones
-	
-	// Por ahora, intentamos evaluar como una expresión que siempre sea verdadera
-	// y extraer el resultado de la función
-	resultado, err := utils.EvaluarExpresion(fmt.Sprintf("(%s) != null", expresion), ctx)
+	resultado, err := utils.EjecutarFuncionSistema(expresion, ctx)
 	if err != nil {
 		return nil, fmt.Errorf("error evaluando función '%s': %w", expresion, err)
 	}
-	
-	// HACK temporal: ejecutar la función directamente según el tipo
-	if expresion == "Ahora()" {
-		fmt.Printf("🕐 Ejecutando Ahora() desde asignaciones.go\n")
-		return time.Now().Format("02/01/2006 15:04:05"), nil
-	}
-	if expresion == "Hoy()" {
-		fmt.Printf("📅 Ejecutando Hoy() desde asignaciones.go\n")
-		return time.Now().Format("02/01/2006"), nil
-	}
-	if expresion == "DiaSemana()" {
-		dia := int(time.Now().Weekday())
-		if dia == 0 {
-			dia = 7
-		}
-		fmt.Printf("📆 Ejecutando DiaSemana() desde asignaciones.go -> %d\n", dia)
-		return dia, nil
-	}
-	if expresion == "MesActual()" {
-		mes := int(time.Now().Month())
-		fmt.Printf("🗓️ Ejecutando MesActual() desde asignaciones.go -> %d\n", mes)
-		return mes, nil
-	}
-	if expresion == "UUID()" {
-		return utils.GenerarUUID(), nil
-	}
-	
-	// Para otras funciones, devolver el resultado de la evaluación
 	return resultado, nil
 }
 
@@ -0,0 +1,401 @@
+package ejecucion
+
+import (
+	"backendmotor/internal/utils"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Estados de una ejecución asíncrona
+const (
+	AsincronaPendiente  = "pendiente"  // en cola, esperando un worker
+	AsincronaEjecutando = "ejecutando" // un worker la está corriendo
+	AsincronaCompletada = "completada" // el flujo terminó (ver resultado.estado)
+	AsincronaError      = "error"      // el motor no pudo ejecutar el flujo
+	AsincronaAbandonada = "abandonada" // el motor se reinició y el proceso no se reanuda
+)
+
+const (
+	// TiempoRetencionAsincrona es cuánto queda en memoria el estado de una ejecución terminada;
+	// después se consulta desde la tabla ejecuciones
+	TiempoRetencionAsincrona = 1 * time.Hour
+
+	// Intentos y espera para avisar al callback cuando la ejecución termina
+	intentosCallback = 3
+	timeoutCallback  = 10 * time.Second
+)
+
+// ErrColaAsincronaLlena se devuelve cuando no hay lugar para encolar otra ejecución
+var ErrColaAsincronaLlena = errors.New("la cola de ejecuciones asíncronas está llena")
+
+// EstadoAsincrono es lo que devuelve GET /ejecuciones/:id/estado y lo que se envía al callback
+type EstadoAsincrono struct {
+	EjecucionID   string              `json:"ejecucionId"`
+	ProcesoID     string              `json:"procesoId"`
+	Canal         string              `json:"canal"`
+	Trigger       string              `json:"trigger"`
+	Estado        string              `json:"estado"`
+	FechaEncolado time.Time           `json:"fechaEncolado"`
+	FechaInicio   *time.Time          `json:"fechaInicio,omitempty"`
+	FechaFin      *time.Time          `json:"fechaFin,omitempty"`
+	Resultado     *ResultadoEjecucion `json:"resultado,omitempty"`
+	Error         string              `json:"error,omitempty"`
+	Callback      *EstadoCallback     `json:"callback,omitempty"`
+}
+
+// EstadoCallback registra el aviso al sistema que pidió la ejecución
+type EstadoCallback struct {
+	URL      string `json:"url"`
+	Estado   string `json:"estado"` // pendiente / enviado / fallido
+	Intentos int    `json:"intentos"`
+	Detalle  string `json:"detalle,omitempty"`
+}
+
+// trabajoAsincrono es una ejecución encolada
+type trabajoAsincrono struct {
+	id        string
+	procesoID string
+	input     map[string]interface{}
+	canal     string
+	trigger   string
+	opciones  *OpcionesEjecucion
+	callback  string
+
+	origen     string               // OrigenAsincrono u OrigenScheduler
+	referencia string               // ejecuciones_tareas.id si viene del scheduler
+	checkpoint *checkpointEjecucion // estado desde el que se reanuda tras un reinicio
+	recuperada bool                 // retomada de la cola durable al arrancar
+}
+
+// colaAsincrona es el pool de workers que corre las ejecuciones asíncronas
+var colaAsincrona = struct {
+	sync.Mutex
+	iniciar  sync.Once
+	trabajos chan trabajoAsincrono
+	estados  map[string]*EstadoAsincrono
+}{estados: make(map[string]*EstadoAsincrono)}
+
+// SolicitaEjecucionAsincrona indica si el request pidió modo asíncrono
+// (header X-Ejecucion-Asincrona o query ?asincrono=true)
+func SolicitaEjecucionAsincrona(r *http.Request) bool {
+	valor := r.Header.Get("X-Ejecucion-Asincrona")
+	if valor == "" {
+		valor = r.URL.Query().Get("asincrono")
+	}
+	activo, _ := strconv.ParseBool(strings.TrimSpace(valor))
+	return activo
+}
+
+// CallbackSolicitado devuelve la URL a la que avisar al terminar (header X-Callback-Url o query ?callbackUrl=)
+func CallbackSolicitado(r *http.Request) string {
+	if u := r.Header.Get("X-Callback-Url"); u != "" {
+		return strings.TrimSpace(u)
+	}
+	return strings.TrimSpace(r.URL.Query().Get("callbackUrl"))
+}
+
+// EncolarEjecucion registra la ejecución y la deja para el pool de workers; devuelve su ID,
+// que es también el de la fila en la tabla ejecuciones
+func EncolarEjecucion(procesoID string, input map[string]interface{}, canalCodigo, trigger string, opciones *OpcionesEjecucion, callbackURL string) (string, error) {
+	if callbackURL != "" {
+		if err := validarCallback(callbackURL); err != nil {
+			return "", err
+		}
+	}
+	colaAsincrona.iniciar.Do(iniciarWorkersAsincronos)
+
+	estado := &EstadoAsincrono{
+		EjecucionID:   uuid.New().String(),
+		ProcesoID:     procesoID,
+		Canal:         canalCodigo,
+		Trigger:       trigger,
+		Estado:        AsincronaPendiente,
+		FechaEncolado: time.Now(),
+	}
+	if callbackURL != "" {
+		estado.Callback = &EstadoCallback{URL: callbackURL, Estado: AsincronaPendiente}
+	}
+
+	colaAsincrona.Lock()
+	colaAsincrona.estados[estado.EjecucionID] = estado
+	colaAsincrona.Unlock()
+
+	trabajo := trabajoAsincrono{id: estado.EjecucionID, procesoID: procesoID, input: input, canal: canalCodigo, trigger: trigger, opciones: opciones, callback: callbackURL, origen: OrigenAsincrono}
+
+	// Queda escrita en la cola durable antes de que un worker la tome
+	if err := registrarEncolada(trabajo, AsincronaPendiente); err != nil {
+		colaAsincrona.Lock()
+		delete(colaAsincrona.estados, estado.EjecucionID)
+		colaAsincrona.Unlock()
+		return "", fmt.Errorf("no se pudo registrar la ejecución: %w", err)
+	}
+
+	select {
+	case colaAsincrona.trabajos <- trabajo:
+	default:
+		colaAsincrona.Lock()
+		delete(colaAsincrona.estados, estado.EjecucionID)
+		colaAsincrona.Unlock()
+		actualizarEncolada(estado.EjecucionID, map[string]interface{}{"estado": AsincronaError, "mensaje_error": ErrColaAsincronaLlena.Error()})
+		return "", ErrColaAsincronaLlena
+	}
+
+	fmt.Printf("📥 Ejecución asíncrona %s encolada para proceso %s\n", estado.EjecucionID, procesoID)
+	return estado.EjecucionID, nil
+}
+
+// ObtenerEstadoAsincrono devuelve una copia del estado si la ejecución sigue en memoria
+func ObtenerEstadoAsincrono(id string) (EstadoAsincrono, bool) {
+	colaAsincrona.Lock()
+	defer colaAsincrona.Unlock()
+	estado, ok := colaAsincrona.estados[id]
+	if !ok {
+		return EstadoAsincrono{}, false
+	}
+	copia := *estado
+	if estado.Callback != nil {
+		cb := *estado.Callback
+		copia.Callback = &cb
+	}
+	return copia, true
+}
+
+// iniciarWorkersAsincronos arranca el pool; el tamaño se toma de MOTOR_WORKERS_ASINCRONOS
+// y MOTOR_COLA_ASINCRONA (por defecto 4 workers y 100 ejecuciones en espera)
+func iniciarWorkersAsincronos() {
+	workers := enteroDeEntorno("MOTOR_WORKERS_ASINCRONOS", 4)
+	colaAsincrona.trabajos = make(chan trabajoAsincrono, enteroDeEntorno("MOTOR_COLA_ASINCRONA", 100))
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			for trabajo := range colaAsincrona.trabajos {
+				ejecutarTrabajoAsincrono(trabajo)
+			}
+		}()
+	}
+	fmt.Printf("⚙️ Pool de ejecuciones asíncronas iniciado con %d workers\n", workers)
+}
+
+// ejecutarTrabajoAsincrono corre el flujo sin request HTTP detrás (no se cancela por desconexión)
+func ejecutarTrabajoAsincrono(trabajo trabajoAsincrono) {
+	// 🧠 Paso 1: Marcar la ejecución como iniciada
+	inicio := time.Now()
+	actualizarEstadoAsincrono(trabajo.id, func(e *EstadoAsincrono) {
+		e.Estado = AsincronaEjecutando
+		e.FechaInicio = &inicio
+	})
+
+	// 🚀 Paso 2: Ejecutar con el ID ya entregado al cliente, con checkpoints en la cola durable
+	res, err := ejecutarDurable(context.Background(), trabajo)
+
+	// 📦 Paso 3: Guardar el resultado
+	fin := time.Now()
+	var final EstadoAsincrono
+	actualizarEstadoAsincrono(trabajo.id, func(e *EstadoAsincrono) {
+		e.FechaFin = &fin
+		if err != nil {
+			e.Estado = AsincronaError
+			e.Error = err.Error()
+		} else {
+			e.Estado = AsincronaCompletada
+			e.Resultado = &res
+		}
+		final = *e
+	})
+	fmt.Printf("📤 Ejecución asíncrona %s terminada (%s) en %v\n", trabajo.id, final.Estado, fin.Sub(inicio))
+
+	// 📣 Paso 4: Avisar al callback
+	if trabajo.callback != "" {
+		avisarCallback(trabajo, final)
+	}
+
+	time.AfterFunc(TiempoRetencionAsincrona, func() {
+		colaAsincrona.Lock()
+		delete(colaAsincrona.estados, trabajo.id)
+		colaAsincrona.Unlock()
+	})
+}
+
+// ejecutarFlujoProtegido evita que un panic en un flujo tire abajo al worker
+func ejecutarFlujoProtegido(ctx context.Context, trabajo trabajoAsincrono, contexto *ContextoSubproceso) (res ResultadoEjecucion, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic ejecutando el flujo: %v", r)
+		}
+	}()
+	return EjecutarFlujoConContexto(ctx, trabajo.procesoID, trabajo.input, trabajo.canal, trabajo.trigger, contexto)
+}
+
+// avisarCallback hace POST del estado final a la URL indicada, con reintentos y backoff
+func avisarCallback(trabajo trabajoAsincrono, final EstadoAsincrono) {
+	final.Callback = nil
+	cuerpo, _ := json.Marshal(final)
+	cliente := clienteCallback(trabajo.callback)
+
+	intentos := 0
+	var err error
+	for intentos < intentosCallback {
+		intentos++
+		if err = enviarCallback(cliente, trabajo.callback, cuerpo); err == nil {
+			break
+		}
+		if intentos < intentosCallback {
+			time.Sleep(time.Duration(intentos) * time.Second)
+		}
+	}
+
+	estadoCallback, detalle := "enviado", ""
+	if err != nil {
+		estadoCallback, detalle = "fallido", err.Error()
+		fmt.Printf("⚠️ Callback de la ejecución %s falló tras %d intentos: %v\n", trabajo.id, intentos, err)
+	}
+	actualizarEstadoAsincrono(trabajo.id, func(e *EstadoAsincrono) {
+		e.Callback.Estado = estadoCallback
+		e.Callback.Intentos = intentos
+		e.Callback.Detalle = detalle
+	})
+
+	utils.RegistrarEjecucionLog(utils.RegistroEjecucion{
+		Timestamp:    time.Now().Format(time.RFC3339),
+		ProcesoId:    trabajo.procesoID,
+		Canal:        trabajo.canal,
+		TipoObjeto:   "callback",
+		NombreObjeto: trabajo.callback,
+		TraceID:      trabajo.id,
+		Parametros:   map[string]interface{}{"ejecucionId": trabajo.id, "intentos": intentos},
+		Resultado:    map[string]interface{}{"estado": estadoCallback},
+		Estado:       estadoCallback,
+		DetalleError: detalle,
+	})
+}
+
+func enviarCallback(cliente *http.Client, destino string, cuerpo []byte) error {
+	resp, err := cliente.Post(destino, "application/json", bytes.NewReader(cuerpo))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("el callback respondió HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Los callbacks los indica quien llama: sin control, el motor haría POST a cualquier dirección a
+// la que él llega (metadata de la nube, servicios internos). Si MOTOR_CALLBACK_HOSTS_PERMITIDOS
+// tiene valor ("api.cliente.com,.partner.com", un punto inicial abarca los subdominios) solo se
+// aceptan esos hosts, que pueden ser internos; los demás deben resolver a direcciones públicas
+
+// validarCallback revisa la URL al encolar, resolviendo el host para rechazar direcciones internas
+func validarCallback(callbackURL string) error {
+	u, err := url.Parse(callbackURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("callbackUrl inválida: %s", callbackURL)
+	}
+	host := u.Hostname()
+	permitidos := hostsCallbackPermitidos()
+	if len(permitidos) > 0 {
+		if !hostPermitido(host, permitidos) {
+			return fmt.Errorf("callbackUrl inválida: el host %s no está en MOTOR_CALLBACK_HOSTS_PERMITIDOS", host)
+		}
+		return nil
+	}
+
+	ctx, cancelar := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelar()
+	direcciones, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return fmt.Errorf("callbackUrl inválida: no se pudo resolver %s: %w", host, err)
+	}
+	for _, d := range direcciones {
+		if direccionInterna(d.IP) {
+			return fmt.Errorf("callbackUrl inválida: %s resuelve a la dirección interna %s", host, d.IP)
+		}
+	}
+	return nil
+}
+
+// clienteCallback vuelve a comprobar la dirección al conectar (el DNS pudo cambiar desde que se
+// encoló) y no sigue redirecciones, que podrían llevar a un host interno
+func clienteCallback(destino string) *http.Client {
+	dialer := &net.Dialer{Timeout: timeoutCallback}
+	u, _ := url.Parse(destino)
+	if u == nil || !hostPermitido(u.Hostname(), hostsCallbackPermitidos()) {
+		dialer.Control = func(network, direccion string, _ syscall.RawConn) error {
+			host, _, _ := net.SplitHostPort(direccion)
+			if ip := net.ParseIP(host); ip == nil || direccionInterna(ip) {
+				return fmt.Errorf("conexión a la dirección interna %s bloqueada", host)
+			}
+			return nil
+		}
+	}
+	return &http.Client{
+		Timeout:   timeoutCallback,
+		Transport: &http.Transport{DialContext: dialer.DialContext},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func hostsCallbackPermitidos() []string {
+	var hosts []string
+	for _, h := range strings.Split(os.Getenv("MOTOR_CALLBACK_HOSTS_PERMITIDOS"), ",") {
+		if h = strings.ToLower(strings.TrimSpace(h)); h != "" {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
+}
+
+func hostPermitido(host string, permitidos []string) bool {
+	host = strings.ToLower(host)
+	for _, p := range permitidos {
+		if host == p || (strings.HasPrefix(p, ".") && strings.HasSuffix(host, p)) {
+			return true
+		}
+	}
+	return false
+}
+
+// direccionInterna indica si la IP es de loopback, enlace local, red privada o no enrutable
+func direccionInterna(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() || ip.IsPrivate() || ip.IsUnspecified() {
+		return true
+	}
+	// 100.64.0.0/10 (CGNAT) tampoco es una dirección pública
+	if ip4 := ip.To4(); ip4 != nil && ip4[0] == 100 && ip4[1]&0xc0 == 64 {
+		return true
+	}
+	return false
+}
+
+func actualizarEstadoAsincrono(id string, cambio func(*EstadoAsincrono)) {
+	colaAsincrona.Lock()
+	defer colaAsincrona.Unlock()
+	if estado, ok := colaAsincrona.estados[id]; ok {
+		cambio(estado)
+	}
+}
+
+func enteroDeEntorno(nombre string, porDefecto int) int {
+	if v, err := strconv.Atoi(os.Getenv(nombre)); err == nil && v > 0 {
+		return v
+	}
+	return porDefecto
+}
@@ -0,0 +1,291 @@
+package ejecucion
+
+import (
+	"backendmotor/internal/models"
+	"backendmotor/internal/monitoring"
+	"backendmotor/internal/utils"
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Estados del circuit breaker de un servidor
+const (
+	CircuitoCerrado     = "cerrado"     // las llamadas pasan normalmente
+	CircuitoAbierto     = "abierto"     // las llamadas fallan de inmediato sin contactar al servidor
+	CircuitoSemiabierto = "semiabierto" // se dejan pasar algunas sondas para ver si el servidor volvió
+)
+
+// CodigoErrorCircuitoAbierto es el codigoError que deja un nodo rechazado por el circuit breaker
+const CodigoErrorCircuitoAbierto = "CIRCUITO_ABIERTO"
+
+// ErrCircuitoAbierto se devuelve sin llamar al servidor mientras su circuito está abierto
+type ErrCircuitoAbierto struct {
+	ServidorID string
+	Hasta      time.Time
+}
+
+func (e *ErrCircuitoAbierto) Error() string {
+	return fmt.Sprintf("circuito abierto para el servidor %s hasta %s", e.ServidorID, e.Hasta.Format(time.RFC3339))
+}
+
+// configCircuito se lee de Servidor.Extras["circuitBreaker"] en cada llamada, así los cambios
+// hechos por el CRUD de servidores se aplican sin reiniciar el motor. El circuito es opcional:
+// solo se activa en los servidores que tienen el bloque (y no lo apagan con habilitado: false)
+type configCircuito struct {
+	Habilitado   bool
+	UmbralFallas int           // fallas consecutivas que abren el circuito
+	Apertura     time.Duration // cuánto queda abierto antes de pasar a semiabierto
+	Sondas       int           // llamadas de prueba exitosas necesarias para cerrarlo
+}
+
+func leerConfigCircuito(servidor models.Servidor) configCircuito {
+	cfg := configCircuito{
+		UmbralFallas: 5,
+		Apertura:     30 * time.Second,
+		Sondas:       1,
+	}
+	extras, ok := servidor.Extras["circuitBreaker"].(map[string]interface{})
+	if !ok {
+		return cfg
+	}
+	cfg.Habilitado = true
+	if v, ok := extras["habilitado"].(bool); ok {
+		cfg.Habilitado = v
+	}
+	if v, ok := utils.NumeroConfig(extras["umbralFallas"]); ok && v >= 1 {
+		cfg.UmbralFallas = int(v)
+	}
+	if v, ok := utils.NumeroConfig(extras["aperturaMs"]); ok && v > 0 {
+		cfg.Apertura = time.Duration(v) * time.Millisecond
+	}
+	if v, ok := utils.NumeroConfig(extras["sondasSemiabierto"]); ok && v >= 1 {
+		cfg.Sondas = int(v)
+	}
+	return cfg
+}
+
+// circuitoServidor es el estado compartido por todas las ejecuciones que usan un servidor
+type circuitoServidor struct {
+	mu                 sync.Mutex
+	servidorID         string
+	nombre             string
+	cfg                configCircuito
+	estado             string
+	fallasConsecutivas int
+	abiertoHasta       time.Time
+	sondasEnCurso      int
+	sondasExitosas     int
+	ultimoCambio       time.Time
+	rechazos           int64
+}
+
+// EstadoCircuito es la vista pública de un circuito para el endpoint de monitoreo
+type EstadoCircuito struct {
+	ServidorID         string     `json:"servidorId"`
+	Nombre             string     `json:"nombre"`
+	Estado             string     `json:"estado"`
+	FallasConsecutivas int        `json:"fallasConsecutivas"`
+	UmbralFallas       int        `json:"umbralFallas"`
+	AbiertoHasta       *time.Time `json:"abiertoHasta,omitempty"`
+	Rechazos           int64      `json:"rechazos"`
+	UltimoCambio       time.Time  `json:"ultimoCambio"`
+}
+
+var circuitos = struct {
+	sync.Mutex
+	porServidor map[string]*circuitoServidor
+}{porServidor: make(map[string]*circuitoServidor)}
+
+// circuitoPara obtiene (o crea) el circuito del servidor y actualiza su configuración
+func circuitoPara(servidor models.Servidor) *circuitoServidor {
+	circuitos.Lock()
+	c, ok := circuitos.porServidor[servidor.ID]
+	if !ok {
+		c = &circuitoServidor{servidorID: servidor.ID, estado: CircuitoCerrado, ultimoCambio: time.Now()}
+		circuitos.porServidor[servidor.ID] = c
+		monitoring.CircuitoEstado.WithLabelValues(servidor.ID).Set(0)
+	}
+	circuitos.Unlock()
+
+	c.mu.Lock()
+	c.nombre = servidor.Nombre
+	c.cfg = leerConfigCircuito(servidor)
+	c.mu.Unlock()
+	return c
+}
+
+// proteger envuelve la llamada al servidor: rechaza de inmediato con el circuito abierto y
+// registra el resultado de cada llamada que sí se hace. Si quien llama canceló o se quedó sin
+// tiempo, la llamada no dice nada del servidor: no se cuenta y la sonda se devuelve
+func (c *circuitoServidor) proteger(invocar func(context.Context) (string, error)) func(context.Context) (string, error) {
+	return func(ctx context.Context) (string, error) {
+		esSonda, err := c.permitir()
+		if err != nil {
+			return "", err
+		}
+		salida, errLlamada := invocar(ctx)
+		if ctx.Err() != nil {
+			c.liberarSonda(esSonda)
+			return salida, errLlamada
+		}
+		c.registrar(errLlamada, esSonda)
+		return salida, errLlamada
+	}
+}
+
+// liberarSonda devuelve el cupo de una sonda que no llegó a un resultado
+func (c *circuitoServidor) liberarSonda(esSonda bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if esSonda && c.sondasEnCurso > 0 {
+		c.sondasEnCurso--
+	}
+}
+
+// permitir decide si la llamada puede hacerse; en semiabierto la marca como sonda
+func (c *circuitoServidor) permitir() (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.cfg.Habilitado {
+		return false, nil
+	}
+
+	if c.estado == CircuitoAbierto && time.Now().After(c.abiertoHasta) {
+		c.cambiarEstado(CircuitoSemiabierto)
+	}
+
+	switch c.estado {
+	case CircuitoAbierto:
+		c.rechazar()
+		return false, &ErrCircuitoAbierto{ServidorID: c.servidorID, Hasta: c.abiertoHasta}
+	case CircuitoSemiabierto:
+		if c.sondasEnCurso+c.sondasExitosas >= c.cfg.Sondas {
+			c.rechazar()
+			return false, &ErrCircuitoAbierto{ServidorID: c.servidorID, Hasta: c.abiertoHasta}
+		}
+		c.sondasEnCurso++
+		return true, nil
+	}
+	return false, nil
+}
+
+// registrar cuenta solo las fallas de infraestructura (timeouts, conexión, 5xx, 429): un error de
+// negocio (SOAP Fault, error de SQL) significa que el servidor está respondiendo
+func (c *circuitoServidor) registrar(err error, esSonda bool) {
+	falla := ""
+	if err != nil {
+		falla = clasificarFalla(err)
+		if falla == FallaSOAPFault {
+			falla = ""
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if esSonda && c.sondasEnCurso > 0 {
+		c.sondasEnCurso--
+	}
+
+	if falla == "" {
+		c.fallasConsecutivas = 0
+		if c.estado == CircuitoSemiabierto && esSonda {
+			c.sondasExitosas++
+			if c.sondasExitosas >= c.cfg.Sondas {
+				c.cambiarEstado(CircuitoCerrado)
+			}
+		}
+		return
+	}
+
+	c.fallasConsecutivas++
+	if c.estado == CircuitoSemiabierto || (c.estado == CircuitoCerrado && c.cfg.Habilitado && c.fallasConsecutivas >= c.cfg.UmbralFallas) {
+		c.abiertoHasta = time.Now().Add(c.cfg.Apertura)
+		c.cambiarEstado(CircuitoAbierto)
+	}
+}
+
+// cambiarEstado requiere c.mu tomado
+func (c *circuitoServidor) cambiarEstado(estado string) {
+	if c.estado == estado {
+		return
+	}
+	fmt.Printf("⚡ Circuito del servidor %s: %s → %s\n", c.servidorID, c.estado, estado)
+	c.estado = estado
+	c.ultimoCambio = time.Now()
+	c.sondasEnCurso = 0
+	c.sondasExitosas = 0
+	if estado == CircuitoCerrado {
+		c.fallasConsecutivas = 0
+	}
+	monitoring.CircuitoEstado.WithLabelValues(c.servidorID).Set(valorMetricaCircuito(estado))
+}
+
+// rechazar requiere c.mu tomado
+func (c *circuitoServidor) rechazar() {
+	c.rechazos++
+	monitoring.CircuitoRechazosTotal.WithLabelValues(c.servidorID).Inc()
+}
+
+func valorMetricaCircuito(estado string) float64 {
+	switch estado {
+	case CircuitoAbierto:
+		return 2
+	case CircuitoSemiabierto:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// EstadoCircuitos devuelve el estado de todos los circuitos conocidos, ordenados por servidor
+func EstadoCircuitos() []EstadoCircuito {
+	circuitos.Lock()
+	lista := make([]*circuitoServidor, 0, len(circuitos.porServidor))
+	for _, c := range circuitos.porServidor {
+		lista = append(lista, c)
+	}
+	circuitos.Unlock()
+
+	estados := make([]EstadoCircuito, 0, len(lista))
+	for _, c := range lista {
+		c.mu.Lock()
+		e := EstadoCircuito{
+			ServidorID:         c.servidorID,
+			Nombre:             c.nombre,
+			Estado:             c.estado,
+			FallasConsecutivas: c.fallasConsecutivas,
+			UmbralFallas:       c.cfg.UmbralFallas,
+			Rechazos:           c.rechazos,
+			UltimoCambio:       c.ultimoCambio,
+		}
+		if c.estado == CircuitoAbierto {
+			hasta := c.abiertoHasta
+			e.AbiertoHasta = &hasta
+		}
+		c.mu.Unlock()
+		estados = append(estados, e)
+	}
+	sort.Slice(estados, func(i, j int) bool { return estados[i].ServidorID < estados[j].ServidorID })
+	return estados
+}
+
+// ReiniciarCircuito cierra a mano el circuito de un servidor; false si no existe
+func ReiniciarCircuito(servidorID string) bool {
+	circuitos.Lock()
+	c, ok := circuitos.porServidor[servidorID]
+	circuitos.Unlock()
+	if !ok {
+		return false
+	}
+
+	c.mu.Lock()
+	c.cambiarEstado(CircuitoCerrado)
+	c.fallasConsecutivas = 0
+	c.mu.Unlock()
+	return true
+}
@@ -0,0 +1,197 @@
+package ejecucion
+
+import (
+	"backendmotor/internal/ejecucion/ejecutores"
+	"backendmotor/internal/models"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+var errCaido = &ejecutores.ErrorHTTP{Codigo: 503, Estado: "503 Service Unavailable"}
+
+// circuitoDePrueba crea un circuito propio del test con el bloque circuitBreaker indicado
+func circuitoDePrueba(t *testing.T, bloque map[string]interface{}) *circuitoServidor {
+	t.Helper()
+	servidor := models.Servidor{ID: "circuito-" + t.Name(), Nombre: t.Name(), Extras: datatypes.JSONMap{}}
+	if bloque != nil {
+		servidor.Extras["circuitBreaker"] = bloque
+	}
+	t.Cleanup(func() {
+		circuitos.Lock()
+		delete(circuitos.porServidor, servidor.ID)
+		circuitos.Unlock()
+	})
+	return circuitoPara(servidor)
+}
+
+// llamar pasa una llamada por el circuito; devuelve si llegó al servidor y el error
+func llamar(c *circuitoServidor, err error) (bool, error) {
+	llego := false
+	_, errLlamada := c.proteger(func(context.Context) (string, error) {
+		llego = true
+		return "", err
+	})(context.Background())
+	return llego, errLlamada
+}
+
+func (c *circuitoServidor) estadoActual() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.estado
+}
+
+func TestLeerConfigCircuito(t *testing.T) {
+	casos := []struct {
+		nombre   string
+		extras   datatypes.JSONMap
+		esperado configCircuito
+	}{
+		{
+			nombre:   "sin bloque queda apagado",
+			extras:   datatypes.JSONMap{},
+			esperado: configCircuito{Habilitado: false, UmbralFallas: 5, Apertura: 30 * time.Second, Sondas: 1},
+		},
+		{
+			nombre:   "bloque vacío lo enciende con los valores por defecto",
+			extras:   datatypes.JSONMap{"circuitBreaker": map[string]interface{}{}},
+			esperado: configCircuito{Habilitado: true, UmbralFallas: 5, Apertura: 30 * time.Second, Sondas: 1},
+		},
+		{
+			nombre:   "apagado a mano",
+			extras:   datatypes.JSONMap{"circuitBreaker": map[string]interface{}{"habilitado": false, "umbralFallas": 2.0}},
+			esperado: configCircuito{Habilitado: false, UmbralFallas: 2, Apertura: 30 * time.Second, Sondas: 1},
+		},
+		{
+			nombre:   "valores propios",
+			extras:   datatypes.JSONMap{"circuitBreaker": map[string]interface{}{"umbralFallas": "3", "aperturaMs": 1500.0, "sondasSemiabierto": 2.0}},
+			esperado: configCircuito{Habilitado: true, UmbralFallas: 3, Apertura: 1500 * time.Millisecond, Sondas: 2},
+		},
+		{
+			nombre:   "valores inválidos se ignoran",
+			extras:   datatypes.JSONMap{"circuitBreaker": map[string]interface{}{"umbralFallas": 0.0, "aperturaMs": -1.0, "sondasSemiabierto": "x"}},
+			esperado: configCircuito{Habilitado: true, UmbralFallas: 5, Apertura: 30 * time.Second, Sondas: 1},
+		},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			if obtenido := leerConfigCircuito(models.Servidor{Extras: c.extras}); obtenido != c.esperado {
+				t.Fatalf("se esperaba %+v y se obtuvo %+v", c.esperado, obtenido)
+			}
+		})
+	}
+}
+
+func TestCircuitoApagadoNoRechaza(t *testing.T) {
+	c := circuitoDePrueba(t, nil)
+	for i := 0; i < 10; i++ {
+		if llego, _ := llamar(c, errCaido); !llego {
+			t.Fatalf("la llamada %d no llegó al servidor con el circuito apagado", i)
+		}
+	}
+	if c.estadoActual() != CircuitoCerrado {
+		t.Fatalf("el circuito apagado no debía abrirse y está %s", c.estadoActual())
+	}
+}
+
+func TestCircuitoCicloDeEstados(t *testing.T) {
+	c := circuitoDePrueba(t, map[string]interface{}{"umbralFallas": 2.0, "aperturaMs": 20.0})
+
+	// Un error de negocio no cuenta y corta la racha de fallas
+	llamar(c, errCaido)
+	llamar(c, &ejecutores.ErrorHTTP{Codigo: 404})
+	llamar(c, errCaido)
+	if c.estadoActual() != CircuitoCerrado {
+		t.Fatalf("el circuito debía seguir cerrado y está %s", c.estadoActual())
+	}
+
+	// cerrado → abierto al llegar al umbral de fallas seguidas
+	llamar(c, errCaido)
+	if c.estadoActual() != CircuitoAbierto {
+		t.Fatalf("el circuito debía abrirse y está %s", c.estadoActual())
+	}
+	var abierto *ErrCircuitoAbierto
+	if llego, err := llamar(c, nil); llego || !errors.As(err, &abierto) {
+		t.Fatalf("con el circuito abierto se esperaba un rechazo sin llamar al servidor (llegó=%v, err=%v)", llego, err)
+	}
+
+	// abierto → semiabierto cuando vence la apertura; una sonda fallida lo vuelve a abrir
+	time.Sleep(30 * time.Millisecond)
+	if llego, _ := llamar(c, errCaido); !llego {
+		t.Fatalf("vencida la apertura la sonda debía llegar al servidor")
+	}
+	if c.estadoActual() != CircuitoAbierto {
+		t.Fatalf("la sonda fallida debía reabrir el circuito y está %s", c.estadoActual())
+	}
+
+	// semiabierto → cerrado con una sonda exitosa
+	time.Sleep(30 * time.Millisecond)
+	if llego, err := llamar(c, nil); !llego || err != nil {
+		t.Fatalf("la sonda debía llegar al servidor (llegó=%v, err=%v)", llego, err)
+	}
+	if c.estadoActual() != CircuitoCerrado {
+		t.Fatalf("la sonda exitosa debía cerrar el circuito y está %s", c.estadoActual())
+	}
+}
+
+func TestCircuitoLimitaSondas(t *testing.T) {
+	c := circuitoDePrueba(t, map[string]interface{}{"umbralFallas": 1.0, "aperturaMs": 10.0, "sondasSemiabierto": 2.0})
+	llamar(c, errCaido)
+	time.Sleep(20 * time.Millisecond)
+
+	// Dos sondas en curso ocupan todos los cupos: la tercera llamada se rechaza
+	liberar := make(chan struct{})
+	enCurso := make(chan struct{}, 2)
+	terminadas := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := c.proteger(func(context.Context) (string, error) {
+				enCurso <- struct{}{}
+				<-liberar
+				return "", nil
+			})(context.Background())
+			terminadas <- err
+		}()
+	}
+	<-enCurso
+	<-enCurso
+	if llego, err := llamar(c, nil); llego || err == nil {
+		t.Fatalf("con los cupos de sonda ocupados la llamada debía rechazarse (llegó=%v, err=%v)", llego, err)
+	}
+
+	close(liberar)
+	for i := 0; i < 2; i++ {
+		if err := <-terminadas; err != nil {
+			t.Fatalf("error inesperado en la sonda: %v", err)
+		}
+	}
+	if c.estadoActual() != CircuitoCerrado {
+		t.Fatalf("con las dos sondas exitosas el circuito debía cerrarse y está %s", c.estadoActual())
+	}
+}
+
+func TestCircuitoSondaCanceladaDevuelveElCupo(t *testing.T) {
+	c := circuitoDePrueba(t, map[string]interface{}{"umbralFallas": 1.0, "aperturaMs": 10.0})
+	llamar(c, errCaido)
+	time.Sleep(20 * time.Millisecond)
+
+	// La sonda se cancela: no dice nada del servidor y el cupo queda libre para otra
+	ctx, cancelar := context.WithCancel(context.Background())
+	c.proteger(func(context.Context) (string, error) {
+		cancelar()
+		return "", context.Canceled
+	})(ctx)
+	if c.estadoActual() != CircuitoSemiabierto {
+		t.Fatalf("la sonda cancelada no debía cambiar el estado y está %s", c.estadoActual())
+	}
+	if llego, err := llamar(c, nil); !llego || err != nil {
+		t.Fatalf("el cupo de la sonda cancelada debía quedar libre (llegó=%v, err=%v)", llego, err)
+	}
+	if c.estadoActual() != CircuitoCerrado {
+		t.Fatalf("el circuito debía cerrarse y está %s", c.estadoActual())
+	}
+}
@@ -0,0 +1,494 @@
+package ejecucion
+
+import (
+	"backendmotor/internal/database"
+	"backendmotor/internal/models"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// Políticas de recuperación de un proceso (Flujo.Recuperacion) cuando el motor se reinicia
+const (
+	RecuperacionReanudar  = "reanudar"  // sigue desde el último nodo completado
+	RecuperacionAbandonar = "abandonar" // se marca como abandonada (por defecto)
+)
+
+// Origen de las ejecuciones de la cola durable
+const (
+	OrigenAsincrono = "asincrono"
+	OrigenScheduler = "scheduler"
+)
+
+// motivoAbandono es el mensaje que queda en las ejecuciones interrumpidas que no se reanudan
+const motivoAbandono = "El motor se reinició durante la ejecución y el proceso no se reanuda"
+
+// instanciaMotor identifica a este motor como propietario de las filas de la cola (MOTOR_INSTANCIA
+// o el hostname). Debe ser distinta en cada motor que comparte la base y estable entre reinicios:
+// al arrancar, lo que quedó a su nombre se da por interrumpido sin esperar a que venza el lease
+var instanciaMotor = nombreInstanciaMotor()
+
+func nombreInstanciaMotor() string {
+	if nombre := strings.TrimSpace(os.Getenv("MOTOR_INSTANCIA")); nombre != "" {
+		return nombre
+	}
+	if host, err := os.Hostname(); err == nil && host != "" {
+		return host
+	}
+	return "motor"
+}
+
+// duracionLease es cuánto vale la reserva de una fila sin renovarse (MOTOR_LEASE_COLA_SEGUNDOS);
+// el latido la renueva cada un tercio de ese tiempo
+func duracionLease() time.Duration {
+	return time.Duration(enteroDeEntorno("MOTOR_LEASE_COLA_SEGUNDOS", 60)) * time.Second
+}
+
+var latidoCola sync.Once
+
+// iniciarLatido renueva periódicamente el lease de todo lo que este motor tiene en la cola
+func iniciarLatido() {
+	latidoCola.Do(func() {
+		go func() {
+			for range time.Tick(duracionLease() / 3) {
+				err := database.DBGORM.Model(&models.EjecucionEncolada{}).
+					Where("propietario = ? AND estado IN ?", instanciaMotor, []string{AsincronaPendiente, AsincronaEjecutando}).
+					Update("lease_hasta", time.Now().Add(duracionLease())).Error
+				if err != nil {
+					fmt.Printf("⚠️ No se pudo renovar el lease de la cola durable: %v\n", err)
+				}
+			}
+		}()
+	})
+}
+
+// ejecucionDurable acompaña a la ejecución raíz de un trabajo de la cola
+type ejecucionDurable struct {
+	id         string
+	checkpoint *checkpointEjecucion // al reanudar: estado desde el que se sigue
+}
+
+// checkpointEjecucion es el estado del recorrido principal después de un nodo. Los bloques
+// (paralelo, iterar) y los subprocesos cuentan como un solo paso: al reanudar se repiten enteros
+type checkpointEjecucion struct {
+	NodoID         string                 `json:"nodoId"`
+	Secuencia      int                    `json:"secuencia"` // pasos de la traza ya guardados
+	Resultado      map[string]interface{} `json:"resultado"`
+	Asignaciones   map[string]interface{} `json:"asignaciones"`
+	Errores        map[string]bool        `json:"errores"`
+	RespuestaFinal map[string]interface{} `json:"respuestaFinal"`
+	Visitados      map[string]bool        `json:"visitados"`
+	Plan           estadoPlanificador     `json:"plan"`
+	Compensaciones []compensacionGuardada `json:"compensaciones,omitempty"`
+}
+
+// EjecutarFlujoDurable ejecuta en el momento (sin pasar por el pool) dejando la ejecución en la
+// cola durable; la usa el scheduler para que sus tareas sobrevivan a un reinicio del motor
+func EjecutarFlujoDurable(ctx context.Context, procesoID string, input map[string]interface{}, canalCodigo, trigger, tareaEjecucionID string) (ResultadoEjecucion, error) {
+	trabajo := trabajoAsincrono{
+		id:         uuid.New().String(),
+		procesoID:  procesoID,
+		input:      input,
+		canal:      canalCodigo,
+		trigger:    trigger,
+		origen:     OrigenScheduler,
+		referencia: tareaEjecucionID,
+	}
+	registrarEncolada(trabajo, AsincronaEjecutando)
+	return ejecutarDurable(ctx, trabajo)
+}
+
+// registrarEncolada escribe el trabajo en cola_ejecuciones antes de que empiece
+func registrarEncolada(trabajo trabajoAsincrono, estado string) error {
+	if database.DBGORM == nil {
+		return nil
+	}
+
+	iniciarLatido()
+
+	lease := time.Now().Add(duracionLease())
+	registro := models.EjecucionEncolada{
+		ID:            trabajo.id,
+		ProcesoID:     trabajo.procesoID,
+		Canal:         trabajo.canal,
+		Trigger:       trabajo.trigger,
+		Origen:        trabajo.origen,
+		CallbackURL:   trabajo.callback,
+		Entrada:       instantanea(trabajo.input),
+		Estado:        estado,
+		Propietario:   instanciaMotor,
+		LeaseHasta:    &lease,
+		FechaEncolado: time.Now(),
+	}
+	if trabajo.referencia != "" {
+		referencia := trabajo.referencia
+		registro.ReferenciaID = &referencia
+	}
+	if trabajo.opciones != nil && trabajo.opciones.Simulacion != nil {
+		if datos, err := json.Marshal(trabajo.opciones.Simulacion); err == nil {
+			registro.Simulacion = datatypes.JSON(datos)
+		}
+	}
+
+	if err := database.DBGORM.Create(&registro).Error; err != nil {
+		fmt.Printf("⚠️ No se pudo registrar la ejecución %s en la cola durable: %v\n", trabajo.id, err)
+		return err
+	}
+	return nil
+}
+
+// ejecutarDurable corre un trabajo de la cola: lo marca tomado, ejecuta con checkpoints y lo cierra
+func ejecutarDurable(ctx context.Context, trabajo trabajoAsincrono) (ResultadoEjecucion, error) {
+	// 🧠 Paso 1: Marcar el trabajo como tomado por este motor
+	actualizarEncolada(trabajo.id, map[string]interface{}{
+		"estado":       AsincronaEjecutando,
+		"intentos":     gorm.Expr("intentos + 1"),
+		"fecha_inicio": time.Now(),
+		"propietario":  instanciaMotor,
+		"lease_hasta":  time.Now().Add(duracionLease()),
+	})
+
+	// 🚀 Paso 2: Ejecutar con el ID ya entregado (y desde el checkpoint si se está reanudando)
+	contexto := nuevoContextoRaiz(trabajo.procesoID, trabajo.input)
+	contexto.EjecucionID = trabajo.id
+	contexto.Opciones = trabajo.opciones
+	contexto.durable = &ejecucionDurable{id: trabajo.id, checkpoint: trabajo.checkpoint}
+	if trabajo.checkpoint != nil {
+		fmt.Printf("♻️ Reanudando ejecución %s desde el nodo %s\n", trabajo.id, trabajo.checkpoint.NodoID)
+	}
+	res, err := ejecutarFlujoProtegido(ctx, trabajo, contexto)
+
+	// 📦 Paso 3: Cerrar el trabajo (el resultado completo queda en la tabla ejecuciones)
+	cierre := map[string]interface{}{"estado": AsincronaCompletada, "fecha_fin": time.Now(), "mensaje_error": ""}
+	if err != nil {
+		cierre["estado"] = AsincronaError
+		cierre["mensaje_error"] = err.Error()
+	}
+	actualizarEncolada(trabajo.id, cierre)
+
+	// Una tarea programada retomada tras un reinicio ya no tiene al scheduler esperándola
+	if trabajo.recuperada && trabajo.origen == OrigenScheduler && trabajo.referencia != "" {
+		cerrarTareaRecuperada(trabajo.referencia, res, err)
+	}
+	return res, err
+}
+
+// guardarCheckpoint persiste el estado del recorrido principal después de un nodo
+func (e *estadoFlujo) guardarCheckpoint(plan *planificador, nodoID string) {
+	if e.durable == nil || e.rama != "" || database.DBGORM == nil {
+		return
+	}
+	// Una transacción abierta muere con el motor: mientras haya alguna, el checkpoint se queda en
+	// el de antes del nodo iniciarTransaccion y al reanudar se repite desde ahí, en lugar de seguir
+	// con sentencias que ya no correrían dentro de la transacción
+	if e.transacciones.hayAbiertas() {
+		return
+	}
+	db := database.DBGORM.WithContext(context.WithoutCancel(e.ctx))
+
+	// Primero los pasos: al reanudar, la traza sigue desde la secuencia guardada
+	secuencia := 0
+	if e.traza != nil {
+		e.traza.guardarPasos(db)
+		e.traza.mu.Lock()
+		secuencia = e.traza.secuencia
+		e.traza.mu.Unlock()
+	}
+
+	cp := checkpointEjecucion{
+		NodoID:         nodoID,
+		Secuencia:      secuencia,
+		Resultado:      map[string]interface{}(instantanea(e.resultado)),
+		Asignaciones:   map[string]interface{}(instantanea(e.asignacionesAplicadas)),
+		Errores:        e.erroresPorNodo,
+		RespuestaFinal: map[string]interface{}(instantanea(e.respuestaFinal)),
+		Visitados:      e.visitados,
+		Plan:           plan.exportar(),
+		Compensaciones: e.compensaciones.guardadas(),
+	}
+	datos, err := json.Marshal(cp)
+	if err != nil {
+		fmt.Printf("⚠️ No se pudo serializar el checkpoint de %s en el nodo %s: %v\n", e.durable.id, nodoID, err)
+		return
+	}
+
+	actualizarEncolada(e.durable.id, map[string]interface{}{
+		"checkpoint":       datatypes.JSON(datos),
+		"nodo_checkpoint":  nodoID,
+		"fecha_checkpoint": time.Now(),
+	})
+}
+
+// restaurar deja el estado y el planificador como estaban en el checkpoint
+func (e *estadoFlujo) restaurar(cp *checkpointEjecucion, plan *planificador) error {
+	if err := plan.restaurar(cp.Plan); err != nil {
+		return fmt.Errorf("no se puede reanudar desde el nodo %s: %w", cp.NodoID, err)
+	}
+
+	e.resultado = mapaNoNulo(cp.Resultado)
+	e.asignacionesAplicadas = mapaNoNulo(cp.Asignaciones)
+	e.respuestaFinal = mapaNoNulo(cp.RespuestaFinal)
+	for id, v := range cp.Errores {
+		e.erroresPorNodo[id] = v
+	}
+	for id, v := range cp.Visitados {
+		e.visitados[id] = v
+	}
+	for _, c := range cp.Compensaciones {
+		if accion, ok := e.compilado.compensaciones[c.NodoID]; ok {
+			e.compensaciones.agregar(compensacionPendiente{nodo: c.NodoID, accion: accion, resultado: c.Resultado})
+		}
+	}
+	return nil
+}
+
+// RecuperarEjecucionesPendientes se llama al arrancar el motor: vuelve a encolar lo que no llegó a
+// empezar, reanuda o abandona (según la política del proceso) lo que quedó a medias y marca como
+// abandonadas las tareas programadas y trazas propias que quedaron en "ejecutando". Con varios
+// motores sobre la misma base, solo toma las filas propias o las de otro motor con el lease
+// vencido, y sigue revisando cada tanto por si otro motor se cae
+func RecuperarEjecucionesPendientes() {
+	if database.DBGORM == nil {
+		return
+	}
+	iniciarLatido()
+	recuperarCola(true)
+
+	go func() {
+		for range time.Tick(duracionLease()) {
+			recuperarCola(false)
+		}
+	}()
+}
+
+// recuperarCola retoma o abandona las filas sin un motor vivo detrás. Al arrancar también son
+// huérfanas las que quedaron a nombre de este motor
+func recuperarCola(arranque bool) {
+	db := database.DBGORM
+	ahora := time.Now()
+	vivas := []string{AsincronaPendiente, AsincronaEjecutando}
+
+	// 🧠 Paso 1: Tomar las filas huérfanas y decidir cuáles se retoman
+	retomar, abandonadas := reclamarHuerfanas(db, arranque, ahora)
+
+	// 🧹 Paso 2: Al arrancar, lo que quedó "ejecutando" sin una fila viva en la cola ya no va a
+	// terminar: las tareas programadas (pasado el lease, para no pisar una que otro motor está
+	// por encolar) y las trazas síncronas de este motor
+	if arranque {
+		colaViva := func(columna string) *gorm.DB {
+			return db.Model(&models.EjecucionEncolada{}).Select(columna).Where("estado IN ? AND "+columna+" IS NOT NULL", vivas)
+		}
+		tareas := db.Model(&models.EjecucionTarea{}).
+			Where("estado = ? AND fecha_ejecucion < ?", "ejecutando", ahora.Add(-duracionLease())).
+			Where("id NOT IN (?)", colaViva("referencia_id"))
+		if err := tareas.Updates(map[string]interface{}{"estado": EstadoEjecucionAbandonada, "mensaje_error": motivoAbandono}).Error; err != nil {
+			fmt.Printf("⚠️ No se pudieron cerrar las tareas programadas interrumpidas: %v\n", err)
+		}
+
+		trazas := db.Model(&models.Ejecucion{}).
+			Where("estado = ?", EstadoEjecucionEjecutando).
+			Where("propietario = ? OR propietario IS NULL OR propietario = ''", instanciaMotor).
+			Where("id NOT IN (?)", colaViva("id"))
+		if err := trazas.Updates(map[string]interface{}{"estado": EstadoEjecucionAbandonada, "detalle_error": motivoAbandono, "fecha_fin": ahora}).Error; err != nil {
+			fmt.Printf("⚠️ No se pudieron cerrar las ejecuciones interrumpidas: %v\n", err)
+		}
+	}
+
+	// 🚀 Paso 3: Devolver al pool lo que se retoma
+	if len(retomar) > 0 {
+		colaAsincrona.iniciar.Do(iniciarWorkersAsincronos)
+		colaAsincrona.Lock()
+		for _, t := range retomar {
+			colaAsincrona.estados[t.id] = &EstadoAsincrono{
+				EjecucionID:   t.id,
+				ProcesoID:     t.procesoID,
+				Canal:         t.canal,
+				Trigger:       t.trigger,
+				Estado:        AsincronaPendiente,
+				FechaEncolado: ahora,
+			}
+			if t.callback != "" {
+				colaAsincrona.estados[t.id].Callback = &EstadoCallback{URL: t.callback, Estado: AsincronaPendiente}
+			}
+		}
+		colaAsincrona.Unlock()
+
+		// El canal puede ser más chico que lo pendiente: se alimenta sin bloquear el arranque
+		go func() {
+			for _, t := range retomar {
+				colaAsincrona.trabajos <- t
+			}
+		}()
+	}
+
+	if arranque || len(retomar)+abandonadas > 0 {
+		fmt.Printf("♻️ Cola durable (%s): %d ejecuciones retomadas, %d abandonadas\n", instanciaMotor, len(retomar), abandonadas)
+	}
+}
+
+// reclamarHuerfanas toma a nombre de este motor las filas vivas sin un motor detrás: con el lease
+// vencido o, al arrancar, las que quedaron a su nombre. Lo que no llegó a empezar o tiene un
+// proceso que se reanuda se devuelve para retomarlo; lo demás se abandona
+func reclamarHuerfanas(db *gorm.DB, arranque bool, ahora time.Time) ([]trabajoAsincrono, int) {
+	vivas := []string{AsincronaPendiente, AsincronaEjecutando}
+	huerfanas := db.Where("lease_hasta IS NULL OR lease_hasta < ? OR propietario = ?", ahora, instanciaMotor)
+	if !arranque {
+		// Lo propio está en la memoria de este motor aunque el latido se haya atrasado
+		huerfanas = db.Where("lease_hasta IS NULL OR lease_hasta < ?", ahora).Where("propietario IS NULL OR propietario <> ?", instanciaMotor)
+	}
+
+	var registros []models.EjecucionEncolada
+	if err := db.Where("estado IN ?", vivas).Where(huerfanas).Order("fecha_encolado").Find(&registros).Error; err != nil {
+		fmt.Printf("❌ Error al leer la cola durable de ejecuciones: %v\n", err)
+		return nil, 0
+	}
+
+	// Si otro motor tomó la fila primero se deja; lo que se queda este motor se retoma o se abandona
+	var retomar []trabajoAsincrono
+	abandonadas := 0
+	for _, r := range registros {
+		tomada := db.Model(&models.EjecucionEncolada{}).
+			Where("id = ? AND estado = ?", r.ID, r.Estado).
+			Where("lease_hasta IS NULL OR lease_hasta < ? OR propietario = ?", ahora, instanciaMotor).
+			Updates(map[string]interface{}{"propietario": instanciaMotor, "lease_hasta": ahora.Add(duracionLease())})
+		if tomada.Error != nil || tomada.RowsAffected == 0 {
+			continue
+		}
+
+		trabajo, err := trabajoDesdeRegistro(r)
+		if err == nil && r.Estado == AsincronaEjecutando && politicaRecuperacion(r.ProcesoID) != RecuperacionReanudar {
+			err = fmt.Errorf("%s", motivoAbandono)
+		}
+		if err != nil {
+			abandonarEncolada(r, trabajo, err.Error())
+			abandonadas++
+			continue
+		}
+
+		retomar = append(retomar, trabajo)
+	}
+	return retomar, abandonadas
+}
+
+// trabajoDesdeRegistro reconstruye el trabajo (y su checkpoint) desde la fila de la cola
+func trabajoDesdeRegistro(r models.EjecucionEncolada) (trabajoAsincrono, error) {
+	trabajo := trabajoAsincrono{
+		id:         r.ID,
+		procesoID:  r.ProcesoID,
+		input:      map[string]interface{}(r.Entrada),
+		canal:      r.Canal,
+		trigger:    r.Trigger,
+		callback:   r.CallbackURL,
+		origen:     r.Origen,
+		recuperada: true,
+	}
+	if r.ReferenciaID != nil {
+		trabajo.referencia = *r.ReferenciaID
+	}
+
+	if len(r.Simulacion) > 0 && string(r.Simulacion) != "null" {
+		var sim Simulacion
+		if err := json.Unmarshal(r.Simulacion, &sim); err != nil {
+			return trabajo, fmt.Errorf("simulación guardada inválida: %w", err)
+		}
+		trabajo.opciones = &OpcionesEjecucion{Simulacion: &sim}
+	}
+
+	if len(r.Checkpoint) > 0 && string(r.Checkpoint) != "null" {
+		var cp checkpointEjecucion
+		if err := json.Unmarshal(r.Checkpoint, &cp); err != nil {
+			return trabajo, fmt.Errorf("checkpoint inválido: %w", err)
+		}
+		trabajo.checkpoint = &cp
+	}
+	return trabajo, nil
+}
+
+// politicaRecuperacion lee Flujo.Recuperacion del proceso; si no se puede leer se abandona
+func politicaRecuperacion(procesoID string) string {
+	compilado, err := obtenerFlujoCompilado(context.Background(), procesoID)
+	if err != nil {
+		return RecuperacionAbandonar
+	}
+	if strings.EqualFold(strings.TrimSpace(compilado.Flujo.Recuperacion), RecuperacionReanudar) {
+		return RecuperacionReanudar
+	}
+	return RecuperacionAbandonar
+}
+
+// abandonarEncolada cierra una ejecución interrumpida en la cola, en su traza y en la tarea
+// programada que la originó, y avisa al callback si lo había
+func abandonarEncolada(r models.EjecucionEncolada, trabajo trabajoAsincrono, motivo string) {
+	db := database.DBGORM
+	ahora := time.Now()
+	fmt.Printf("🪦 Ejecución %s (proceso %s) abandonada: %s\n", r.ID, r.ProcesoID, motivo)
+
+	actualizarEncolada(r.ID, map[string]interface{}{"estado": AsincronaAbandonada, "mensaje_error": motivo, "fecha_fin": ahora})
+	db.Model(&models.Ejecucion{}).Where("id = ? AND estado = ?", r.ID, EstadoEjecucionEjecutando).
+		Updates(map[string]interface{}{"estado": EstadoEjecucionAbandonada, "detalle_error": motivo, "fecha_fin": ahora})
+	if r.ReferenciaID != nil {
+		db.Model(&models.EjecucionTarea{}).Where("id = ?", *r.ReferenciaID).
+			Updates(map[string]interface{}{"estado": EstadoEjecucionAbandonada, "mensaje_error": motivo})
+	}
+
+	if r.CallbackURL != "" {
+		final := EstadoAsincrono{
+			EjecucionID:   r.ID,
+			ProcesoID:     r.ProcesoID,
+			Canal:         r.Canal,
+			Trigger:       r.Trigger,
+			Estado:        AsincronaAbandonada,
+			FechaEncolado: r.FechaEncolado,
+			FechaInicio:   r.FechaInicio,
+			FechaFin:      &ahora,
+			Error:         motivo,
+		}
+		go avisarCallback(trabajo, final)
+	}
+}
+
+// cerrarTareaRecuperada deja en ejecuciones_tareas el resultado de una tarea retomada
+func cerrarTareaRecuperada(tareaEjecucionID string, res ResultadoEjecucion, err error) {
+	if database.DBGORM == nil {
+		return
+	}
+	cambios := map[string]interface{}{"estado": "exitoso", "mensaje_error": ""}
+	if err != nil {
+		cambios["estado"] = "error"
+		cambios["mensaje_error"] = err.Error()
+	} else {
+		cambios["resultado"] = datatypes.JSONMap{
+			"estado":    res.Estado,
+			"mensaje":   res.Mensaje,
+			"datos":     res.Datos,
+			"procesoId": res.ProcesoID,
+		}
+	}
+	if errDB := database.DBGORM.Model(&models.EjecucionTarea{}).Where("id = ?", tareaEjecucionID).Updates(cambios).Error; errDB != nil {
+		fmt.Printf("⚠️ No se pudo cerrar la tarea programada %s: %v\n", tareaEjecucionID, errDB)
+	}
+}
+
+func actualizarEncolada(id string, cambios map[string]interface{}) {
+	if database.DBGORM == nil {
+		return
+	}
+	if err := database.DBGORM.Model(&models.EjecucionEncolada{}).Where("id = ?", id).Updates(cambios).Error; err != nil {
+		fmt.Printf("⚠️ No se pudo actualizar la ejecución %s en la cola durable: %v\n", id, err)
+	}
+}
+
+func mapaNoNulo(m map[string]interface{}) map[string]interface{} {
+	if m == nil {
+		return make(map[string]interface{})
+	}
+	return m
+}
@@ -0,0 +1,243 @@
+//go:build cgo
+
+package ejecucion
+
+import (
+	"backendmotor/internal/database"
+	"backendmotor/internal/estructuras"
+	"backendmotor/internal/models"
+	"database/sql"
+	"encoding/json"
+	"slices"
+	"sort"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"gorm.io/datatypes"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const instanciaDePrueba = "motor-prueba"
+
+// colaDePrueba deja en database.DBGORM una base SQLite en memoria con las tablas que toca la cola
+// durable, y a este motor como instanciaDePrueba
+func colaDePrueba(t *testing.T) *gorm.DB {
+	t.Helper()
+	sqlDB, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("no se pudo abrir SQLite: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("no se pudo abrir GORM: %v", err)
+	}
+	for _, ddl := range []string{
+		`CREATE TABLE cola_ejecuciones (id TEXT PRIMARY KEY, proceso_id TEXT, canal TEXT, "trigger" TEXT, origen TEXT,
+			referencia_id TEXT, callback_url TEXT, entrada TEXT, simulacion TEXT, estado TEXT, intentos INTEGER DEFAULT 0,
+			propietario TEXT, lease_hasta DATETIME, checkpoint TEXT, nodo_checkpoint TEXT, mensaje_error TEXT,
+			fecha_encolado DATETIME, fecha_inicio DATETIME, fecha_checkpoint DATETIME, fecha_fin DATETIME)`,
+		`CREATE TABLE ejecuciones (id TEXT PRIMARY KEY, estado TEXT, propietario TEXT, detalle_error TEXT, fecha_fin DATETIME)`,
+		`CREATE TABLE ejecuciones_tareas (id TEXT PRIMARY KEY, estado TEXT, mensaje_error TEXT)`,
+	} {
+		if err := db.Exec(ddl).Error; err != nil {
+			t.Fatalf("no se pudo crear la tabla: %v", err)
+		}
+	}
+
+	anteriorDB, anteriorInstancia := database.DBGORM, instanciaMotor
+	database.DBGORM, instanciaMotor = db, instanciaDePrueba
+	t.Cleanup(func() {
+		database.DBGORM, instanciaMotor = anteriorDB, anteriorInstancia
+		sqlDB.Close()
+	})
+	return db
+}
+
+// procesoEnCache deja compilado en la caché un proceso con la política de recuperación dada
+func procesoEnCache(t *testing.T, id string, recuperacion string) {
+	t.Helper()
+	flujo, _ := json.Marshal(estructuras.Flujo{Nodes: []estructuras.NodoGenerico{nodo("e", "entrada", nil)}, Recuperacion: recuperacion})
+	fc, err := CompilarFlujo(models.Proceso{ID: id, Nombre: id, Flujo: string(flujo)})
+	if err != nil {
+		t.Fatalf("error compilando el flujo: %v", err)
+	}
+	cacheFlujos.Lock()
+	cacheFlujos.flujos[id] = fc
+	cacheFlujos.Unlock()
+	t.Cleanup(func() { InvalidarFlujo(id) })
+}
+
+func encolada(id, estado, propietario string, lease time.Duration, procesoID string) models.EjecucionEncolada {
+	r := models.EjecucionEncolada{
+		ID:            id,
+		ProcesoID:     procesoID,
+		Origen:        OrigenAsincrono,
+		Estado:        estado,
+		Propietario:   propietario,
+		FechaEncolado: time.Now(),
+	}
+	if lease != 0 {
+		hasta := time.Now().Add(lease)
+		r.LeaseHasta = &hasta
+	}
+	return r
+}
+
+func TestReclamarHuerfanas(t *testing.T) {
+	casos := []struct {
+		nombre      string
+		arranque    bool
+		retomadas   []string
+		abandonadas []string
+	}{
+		{
+			// Lo propio sigue en la memoria de este motor aunque el lease esté vencido
+			nombre:      "revisión periódica",
+			arranque:    false,
+			retomadas:   []string{"reanudable", "sin-lease", "vencida"},
+			abandonadas: []string{"sin-reanudar"},
+		},
+		{
+			// Al arrancar, lo que quedó a nombre de este motor también es huérfano
+			nombre:      "arranque",
+			arranque:    true,
+			retomadas:   []string{"propia-vencida", "propia-vigente", "reanudable", "sin-lease", "vencida"},
+			abandonadas: []string{"sin-reanudar"},
+		},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			db := colaDePrueba(t)
+			procesoEnCache(t, "proc-reanudar", RecuperacionReanudar)
+			procesoEnCache(t, "proc-abandonar", RecuperacionAbandonar)
+
+			reanudable := encolada("reanudable", AsincronaEjecutando, "otro", -time.Minute, "proc-reanudar")
+			reanudable.Checkpoint = datatypes.JSON(`{"nodoId":"t","secuencia":3}`)
+			filas := []models.EjecucionEncolada{
+				encolada("vencida", AsincronaPendiente, "otro", -time.Minute, "proc-abandonar"),
+				encolada("vigente", AsincronaPendiente, "otro", time.Minute, "proc-abandonar"),
+				encolada("sin-lease", AsincronaPendiente, "", 0, "proc-abandonar"),
+				encolada("propia-vencida", AsincronaPendiente, instanciaDePrueba, -time.Minute, "proc-abandonar"),
+				encolada("propia-vigente", AsincronaPendiente, instanciaDePrueba, time.Minute, "proc-abandonar"),
+				encolada("cerrada", AsincronaCompletada, "otro", -time.Minute, "proc-abandonar"),
+				encolada("sin-reanudar", AsincronaEjecutando, "otro", -time.Minute, "proc-abandonar"),
+				reanudable,
+			}
+			if err := db.Create(&filas).Error; err != nil {
+				t.Fatalf("no se pudieron crear las filas: %v", err)
+			}
+
+			retomar, abandonadas := reclamarHuerfanas(db, c.arranque, time.Now())
+
+			var ids []string
+			for _, trabajo := range retomar {
+				ids = append(ids, trabajo.id)
+				if !trabajo.recuperada {
+					t.Fatalf("el trabajo %s debía marcarse como recuperado", trabajo.id)
+				}
+				if trabajo.id == "reanudable" && (trabajo.checkpoint == nil || trabajo.checkpoint.NodoID != "t") {
+					t.Fatalf("el trabajo reanudable debía traer su checkpoint y trae %+v", trabajo.checkpoint)
+				}
+			}
+			sort.Strings(ids)
+			if !slices.Equal(ids, c.retomadas) || abandonadas != len(c.abandonadas) {
+				t.Fatalf("se esperaban retomadas %v y %d abandonadas; se obtuvieron %v y %d", c.retomadas, len(c.abandonadas), ids, abandonadas)
+			}
+
+			var despues []models.EjecucionEncolada
+			db.Order("id").Find(&despues)
+			for _, r := range despues {
+				reclamada := slices.Contains(c.retomadas, r.ID) || slices.Contains(c.abandonadas, r.ID)
+				switch {
+				case reclamada && (r.Propietario != instanciaDePrueba || r.LeaseHasta == nil || !r.LeaseHasta.After(time.Now())):
+					t.Fatalf("%s: debía quedar a nombre de este motor con un lease nuevo (%s, %v)", r.ID, r.Propietario, r.LeaseHasta)
+				case (r.ID == "vigente" || r.ID == "cerrada") && r.Propietario != "otro":
+					t.Fatalf("%s: no debía quitársele a su motor y quedó a nombre de %s", r.ID, r.Propietario)
+				case slices.Contains(c.abandonadas, r.ID) && r.Estado != AsincronaAbandonada:
+					t.Fatalf("%s: debía quedar abandonada y está %s", r.ID, r.Estado)
+				case !reclamada && r.Estado != AsincronaPendiente && r.Estado != AsincronaCompletada:
+					t.Fatalf("%s: no debía cambiar de estado y está %s", r.ID, r.Estado)
+				}
+			}
+		})
+	}
+}
+
+// flujoConTransaccion arma e → ini → t y, si confirma, → c → u
+func flujoConTransaccion(confirma bool) ([]estructuras.NodoGenerico, []string) {
+	cuerpo := escribe("x", `"{{ 1 }}"`)
+	cuerpo.ID = "t"
+	nodos := []estructuras.NodoGenerico{
+		nodo("e", "entrada", nil),
+		nodo("ini", "iniciarTransaccion", map[string]interface{}{"servidorId": "srv"}),
+		cuerpo,
+	}
+	aristas := []string{"e>ini", "ini>t"}
+	if confirma {
+		nodos = append(nodos, nodo("c", "confirmarTransaccion", map[string]interface{}{"servidorId": "srv"}), nodo("u", "union", nil))
+		aristas = append(aristas, "t>c", "c>u")
+	}
+	return nodos, aristas
+}
+
+// checkpointDeEjecucion corre el flujo simulado como ejecución durable y lee el checkpoint guardado
+func checkpointDeEjecucion(t *testing.T, nodos []estructuras.NodoGenerico, aristas []string) *checkpointEjecucion {
+	t.Helper()
+	db := colaDePrueba(t)
+	fila := encolada("ejecucion", AsincronaEjecutando, instanciaDePrueba, time.Minute, "proceso-prueba")
+	if err := db.Create(&fila).Error; err != nil {
+		t.Fatalf("no se pudo crear la fila: %v", err)
+	}
+
+	e := estadoDePrueba(t, nodos, aristas, nil)
+	e.contexto.Opciones = &OpcionesEjecucion{Simulacion: &Simulacion{}}
+	e.durable = &ejecucionDurable{id: "ejecucion"}
+	if err := e.recorrerDesde("e"); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+
+	var r models.EjecucionEncolada
+	db.First(&r, "id = ?", "ejecucion")
+	trabajo, err := trabajoDesdeRegistro(r)
+	if err != nil || trabajo.checkpoint == nil {
+		t.Fatalf("no se pudo leer el checkpoint (err=%v)", err)
+	}
+	return trabajo.checkpoint
+}
+
+func TestCheckpointConTransaccionAbierta(t *testing.T) {
+	// Confirmada la transacción, el checkpoint vuelve a avanzar
+	nodos, aristas := flujoConTransaccion(true)
+	if cp := checkpointDeEjecucion(t, nodos, aristas); cp.NodoID != "u" {
+		t.Fatalf("se esperaba el checkpoint en u y quedó en %s", cp.NodoID)
+	}
+	// Si el motor se cae con la transacción abierta, el checkpoint quedó antes de abrirla
+	nodos, aristas = flujoConTransaccion(false)
+	if cp := checkpointDeEjecucion(t, nodos, aristas); cp.NodoID != "e" || cp.Visitados["ini"] {
+		t.Fatalf("se esperaba el checkpoint en e, antes de la transacción, y quedó en %s", cp.NodoID)
+	}
+}
+
+func TestReanudarRepiteLaTransaccion(t *testing.T) {
+	nodos, aristas := flujoConTransaccion(false)
+	cp := checkpointDeEjecucion(t, nodos, aristas)
+
+	// Al reanudar se repite la transacción entera desde el nodo que la abrió
+	e := estadoDePrueba(t, nodos, aristas, nil)
+	e.contexto.Opciones = &OpcionesEjecucion{Simulacion: &Simulacion{}}
+	plan := nuevoPlanificador(e.grafo, "e")
+	if err := e.restaurar(cp, plan); err != nil {
+		t.Fatalf("no se pudo restaurar: %v", err)
+	}
+	if err := e.recorrer(plan); err != nil {
+		t.Fatalf("error inesperado al reanudar: %v", err)
+	}
+	if tx := e.transacciones.abierta("srv"); tx == nil || tx.nodoInicio != "ini" || !e.visitados["t"] || e.resultado["x"] != 1.0 {
+		t.Fatalf("se esperaba volver a abrir la transacción en ini y correr t: %v", e.visitados)
+	}
+}
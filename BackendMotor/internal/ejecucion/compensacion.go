@@ -0,0 +1,176 @@
+package ejecucion
+
+import (
+	"backendmotor/internal/estructuras"
+	"backendmotor/internal/utils"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RamaCompensacion es la rama con la que quedan en la traza los pasos de compensación
+const RamaCompensacion = "compensacion"
+
+// sufijoCompensacion forma el ID del nodo sintético que deshace a un nodo proceso
+const sufijoCompensacion = ":compensacion"
+
+// ResultadoCompensacion resume una acción de compensación ejecutada al terminar en salidaError
+type ResultadoCompensacion struct {
+	NodoID  string `json:"nodoId"` // nodo proceso que se deshizo
+	Accion  string `json:"accion"` // "proceso" o "subproceso"
+	Estado  string `json:"estado"` // exitoso / error
+	Mensaje string `json:"mensaje,omitempty"`
+}
+
+// compensacionPendiente es un nodo proceso que terminó bien y sabe cómo deshacerse
+type compensacionPendiente struct {
+	nodo      string
+	accion    estructuras.NodoGenerico
+	resultado map[string]interface{} // variables tal como quedaron al terminar el nodo
+}
+
+// registroCompensaciones lo comparten el recorrido principal y sus ramas / iteraciones
+type registroCompensaciones struct {
+	mu         sync.Mutex
+	pendientes []compensacionPendiente
+}
+
+func (r *registroCompensaciones) agregar(p compensacionPendiente) {
+	r.mu.Lock()
+	r.pendientes = append(r.pendientes, p)
+	r.mu.Unlock()
+}
+
+// tomar devuelve las compensaciones registradas y vacía el registro
+func (r *registroCompensaciones) tomar() []compensacionPendiente {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	pendientes := r.pendientes
+	r.pendientes = nil
+	return pendientes
+}
+
+// compensacionGuardada es lo que se guarda de una compensación pendiente en un checkpoint
+type compensacionGuardada struct {
+	NodoID    string                 `json:"nodoId"`
+	Resultado map[string]interface{} `json:"resultado"`
+}
+
+// guardadas devuelve las compensaciones pendientes en forma serializable
+func (r *registroCompensaciones) guardadas() []compensacionGuardada {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	guardadas := make([]compensacionGuardada, 0, len(r.pendientes))
+	for _, p := range r.pendientes {
+		guardadas = append(guardadas, compensacionGuardada{NodoID: p.nodo, Resultado: map[string]interface{}(instantanea(p.resultado))})
+	}
+	return guardadas
+}
+
+// nodoCompensacion arma el nodo que ejecuta data.compensacion de un nodo proceso: con
+// subprocesoId se invoca ese proceso; si no, la compensación es otra configuración de proceso
+// (servidorId, objeto, tipoObjeto, parametrosEntrada...) que recibe las variables del flujo
+func nodoCompensacion(n estructuras.NodoGenerico) (estructuras.NodoGenerico, bool) {
+	raw, ok := n.Data["compensacion"].(map[string]interface{})
+	if !ok || len(raw) == 0 {
+		return estructuras.NodoGenerico{}, false
+	}
+
+	datos := copiarMapa(raw)
+	accion := estructuras.NodoGenerico{ID: n.ID + sufijoCompensacion, Type: "proceso", Data: datos, ProcesoID: n.ProcesoID}
+	if sub, ok := datos["subprocesoId"].(string); ok && sub != "" {
+		delete(datos, "subprocesoId")
+		datos["procesoId"] = sub
+		accion.Type = "subproceso"
+		return accion, true
+	}
+
+	// La compensación no es un nodo del flujo guardado: nunca se re-parsea ni se persiste
+	datos["parsearFullOutput"] = false
+	return accion, true
+}
+
+// registrarCompensable anota la compensación de un nodo proceso que acaba de terminar bien
+func (e *estadoFlujo) registrarCompensable(n estructuras.NodoGenerico) {
+	if e.compensaciones == nil {
+		return
+	}
+	accion, ok := e.compilado.compensaciones[n.ID]
+	if !ok {
+		return
+	}
+	e.compensaciones.agregar(compensacionPendiente{nodo: n.ID, accion: accion, resultado: copiarMapa(e.resultado)})
+}
+
+// compensar ejecuta, en orden inverso, las compensaciones de los nodos que terminaron bien.
+// Corre aunque se haya cancelado el contexto: lo ya escrito en otros sistemas debe deshacerse
+func (e *estadoFlujo) compensar() []ResultadoCompensacion {
+	if e.compensaciones == nil {
+		return nil
+	}
+	pendientes := e.compensaciones.tomar()
+	if len(pendientes) == 0 {
+		return nil
+	}
+
+	fmt.Printf("↩️ Flujo %s terminó en error: %d compensaciones pendientes\n", e.proc.ID, len(pendientes))
+	ctx := context.WithoutCancel(e.ctx)
+	resultados := make([]ResultadoCompensacion, 0, len(pendientes))
+
+	for i := len(pendientes) - 1; i >= 0; i-- {
+		p := pendientes[i]
+		inicio := time.Now()
+
+		// 🧪 Paso 1: Partir de las variables que dejó el nodo original, sin los errores posteriores
+		estadoComp := e.copiaParaRama()
+		estadoComp.ctx = ctx
+		estadoComp.rama = RamaCompensacion
+		estadoComp.compensaciones = nil
+		estadoComp.resultado = copiarMapa(p.resultado)
+		for _, k := range []string{"codigoError", "mensajeError", "detalleError"} {
+			delete(estadoComp.resultado, k)
+		}
+		antes := superficial(estadoComp.resultado)
+
+		// ↩️ Paso 2: Ejecutar la acción (proceso o subproceso)
+		_, _, err := estadoComp.ejecutarNodo(p.accion)
+		conError := err != nil || estadoComp.erroresPorNodo[p.accion.ID]
+		if err != nil {
+			estadoComp.resultado["detalleError"] = err.Error()
+		}
+
+		// 🗂️ Paso 3: Dejar constancia en la traza y en el log
+		e.traza.registrarPaso(p.accion, RamaCompensacion, antes, estadoComp.resultado, variablesEscritas(antes, estadoComp.resultado), nil, conError, inicio)
+
+		res := ResultadoCompensacion{NodoID: p.nodo, Accion: p.accion.Type, Estado: EstadoEjecucionExitoso}
+		estadoLog := "exito"
+		if conError {
+			res.Estado = EstadoEjecucionError
+			res.Mensaje = detalleErrorPaso(estadoComp.resultado)
+			estadoLog = "error"
+		}
+		resultados = append(resultados, res)
+		fmt.Printf("↩️ Compensación de %s (%s): %s\n", p.nodo, p.accion.Type, res.Estado)
+
+		utils.RegistrarEjecucionLog(utils.RegistroEjecucion{
+			Timestamp:     time.Now().Format(time.RFC3339),
+			ProcesoId:     e.proc.ID,
+			NombreProceso: e.proc.Nombre,
+			Canal:         e.canalCodigo,
+			TipoObjeto:    "compensacion",
+			NombreObjeto:  p.accion.ID,
+			TraceID:       e.contexto.TraceID,
+			Parametros:    map[string]interface{}{"nodoId": p.nodo, "accion": p.accion.Type},
+			Resultado:     map[string]interface{}{"estado": res.Estado, "mensaje": res.Mensaje},
+			Estado:        estadoLog,
+			DetalleError:  res.Mensaje,
+			DuracionMs:    time.Since(inicio).Milliseconds(),
+		})
+	}
+
+	return resultados
+}
@@ -0,0 +1,102 @@
+//go:build cgo
+
+package ejecucion
+
+import (
+	"backendmotor/internal/estructuras"
+	"backendmotor/internal/models"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// procesoCompensable arma un nodo proceso REST que se deshace llamando a otro endpoint; los
+// servidores que no están en la base hacen fallar la llamada
+func procesoCompensable(id, servidor, endpoint, servidorCompensacion, deshacer string) estructuras.NodoGenerico {
+	return nodo(id, "proceso", map[string]interface{}{
+		"servidorId":   servidor,
+		"objeto":       endpoint,
+		"compensacion": map[string]interface{}{"servidorId": servidorCompensacion, "objeto": deshacer},
+	})
+}
+
+// servidoresDePrueba arma la base de colaDePrueba con la tabla servidores y los servidores dados
+func servidoresDePrueba(t *testing.T, servidores ...models.Servidor) *gorm.DB {
+	t.Helper()
+	db := colaDePrueba(t)
+	if err := db.Exec(`CREATE TABLE servidores (id TEXT PRIMARY KEY, codigo TEXT, nombre TEXT, tipo TEXT, host TEXT,
+		puerto INTEGER, usuario TEXT, clave TEXT, fecha_creacion DATETIME, extras TEXT)`).Error; err != nil {
+		t.Fatalf("no se pudo crear la tabla: %v", err)
+	}
+	for _, servidor := range servidores {
+		if err := db.Create(&servidor).Error; err != nil {
+			t.Fatalf("no se pudo crear el servidor %s: %v", servidor.ID, err)
+		}
+	}
+	return db
+}
+
+func TestCompensarEnOrdenInversoTrasCancelar(t *testing.T) {
+	// El servidor anota cada endpoint invocado
+	var mu sync.Mutex
+	var llamadas []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		llamadas = append(llamadas, r.URL.Path)
+		mu.Unlock()
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer srv.Close()
+
+	db := servidoresDePrueba(t, models.Servidor{ID: "srv", Tipo: "rest", Host: srv.URL})
+
+	// e → a → b → c; c falla y sale por error hacia x (salidaError)
+	nodos := []estructuras.NodoGenerico{
+		nodo("e", "entrada", nil),
+		procesoCompensable("a", "srv", "crear", "srv", "borrar"),
+		procesoCompensable("b", "srv", "cobrar", "srv-caido", "reembolsar"),
+		procesoCompensable("c", "srv-caido", "enviar", "srv", "cancelarEnvio"),
+		nodo("x", "salidaError", nil),
+	}
+	e := estadoDePrueba(t, nodos, []string{"e>a", "a>b", "b>c", "c>x!"}, nil)
+	ctx, cancelar := context.WithCancel(context.Background())
+	e.ctx, e.db = ctx, db
+
+	if err := e.recorrerDesde("e"); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if !e.terminoEnError() {
+		t.Fatalf("el flujo debía terminar en salidaError: %v", e.visitados)
+	}
+
+	// La cancelación llega antes de compensar: lo ya escrito en el servidor se deshace igual
+	cancelar()
+	resultados := e.compensar()
+
+	// c falló y no se compensa; b se deshace antes que a y su falla no corta la de a
+	if esperadas := []string{"/crear", "/cobrar", "/borrar"}; !slices.Equal(llamadas, esperadas) {
+		t.Fatalf("se esperaban las llamadas %v y hubo %v", esperadas, llamadas)
+	}
+	esperados := []struct{ nodo, estado string }{
+		{"b", EstadoEjecucionError},
+		{"a", EstadoEjecucionExitoso},
+	}
+	if len(resultados) != len(esperados) {
+		t.Fatalf("se esperaban %d compensaciones y hubo %+v", len(esperados), resultados)
+	}
+	for i, esperado := range esperados {
+		if r := resultados[i]; r.NodoID != esperado.nodo || r.Estado != esperado.estado || r.Accion != "proceso" {
+			t.Fatalf("compensación %d: se esperaba %s (%s) y se obtuvo %+v", i, esperado.nodo, esperado.estado, r)
+		}
+	}
+
+	// El registro queda vacío: nada se compensa dos veces
+	if otra := e.compensar(); otra != nil {
+		t.Fatalf("no debía quedar nada por compensar y se obtuvo %+v", otra)
+	}
+}
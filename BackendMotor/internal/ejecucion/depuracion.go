@@ -0,0 +1,322 @@
+package ejecucion
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Estados de una sesión de depuración
+const (
+	DepuracionEjecutando = "ejecutando"
+	DepuracionPausada    = "pausada"
+	DepuracionTerminada  = "terminada"
+	DepuracionAbortada   = "abortada"
+)
+
+const (
+	// TiempoMaximoPausa aborta la ejecución si nadie la reanuda (evita goroutines colgadas)
+	TiempoMaximoPausa = 10 * time.Minute
+	// TiempoRetencionSesion es cuánto queda consultable una sesión después de terminar
+	TiempoRetencionSesion = 10 * time.Minute
+)
+
+// Acciones que acepta una sesión pausada
+const (
+	accionContinuar = "continuar" // seguir hasta el próximo breakpoint
+	accionPaso      = "paso"      // ejecutar solo el siguiente nodo
+	accionEditar    = "editar"    // cambiar variables sin reanudar
+)
+
+// InstantaneaDepuracion es lo que ve el cliente de una sesión: dónde está y con qué variables
+type InstantaneaDepuracion struct {
+	SesionID              string                 `json:"sesionId"`
+	Estado                string                 `json:"estado"`
+	NodoID                string                 `json:"nodoId,omitempty"` // nodo que se va a ejecutar
+	TipoNodo              string                 `json:"tipoNodo,omitempty"`
+	Rama                  string                 `json:"rama,omitempty"`
+	Resultado             map[string]interface{} `json:"resultado,omitempty"`
+	AsignacionesAplicadas map[string]interface{} `json:"asignacionesAplicadas,omitempty"`
+	Visitados             []string               `json:"visitados,omitempty"`
+	Breakpoints           []string               `json:"breakpoints"`
+	ResultadoFinal        *ResultadoEjecucion    `json:"resultadoFinal,omitempty"`
+	Error                 string                 `json:"error,omitempty"`
+}
+
+type comandoDepuracion struct {
+	accion    string
+	variables map[string]interface{}
+}
+
+// SesionDepuracion controla una ejecución que se detiene en breakpoints. El motor corre en su
+// propia goroutine y se bloquea en pausar(); los comandos llegan por canal y los aplica el
+// propio motor, así resultado nunca se modifica desde dos goroutines a la vez
+type SesionDepuracion struct {
+	ID string
+
+	mu          sync.Mutex
+	breakpoints map[string]bool
+	modoPaso    bool
+	instantanea InstantaneaDepuracion
+	version     int
+	aviso       chan struct{} // se cierra en cada cambio de estado
+
+	comandos chan comandoDepuracion
+	turno    sync.Mutex // las ramas paralelas pausan de a una
+	cancelar context.CancelFunc
+}
+
+var sesionesDepuracion = struct {
+	sync.Mutex
+	sesiones map[string]*SesionDepuracion
+}{sesiones: make(map[string]*SesionDepuracion)}
+
+// IniciarDepuracion lanza el flujo en segundo plano con los breakpoints indicados.
+// Con breakpoints vacío se ejecuta paso a paso desde el nodo de entrada. Las opciones permiten
+// combinar la depuración con otros modos (por ejemplo, simulación)
+func IniciarDepuracion(procesoID string, input map[string]interface{}, canalCodigo, trigger string, breakpoints []string, opciones *OpcionesEjecucion) *SesionDepuracion {
+	ctx, cancelar := context.WithCancel(context.Background())
+
+	s := &SesionDepuracion{
+		ID:          uuid.New().String(),
+		breakpoints: make(map[string]bool),
+		modoPaso:    len(breakpoints) == 0,
+		aviso:       make(chan struct{}),
+		comandos:    make(chan comandoDepuracion),
+		cancelar:    cancelar,
+	}
+	for _, id := range breakpoints {
+		s.breakpoints[id] = true
+	}
+	s.instantanea = InstantaneaDepuracion{SesionID: s.ID, Estado: DepuracionEjecutando, Breakpoints: s.listaBreakpoints()}
+
+	sesionesDepuracion.Lock()
+	sesionesDepuracion.sesiones[s.ID] = s
+	sesionesDepuracion.Unlock()
+
+	fmt.Printf("🐞 Sesión de depuración %s iniciada para proceso %s (breakpoints: %v)\n", s.ID, procesoID, breakpoints)
+
+	go func() {
+		defer cancelar()
+		if opciones == nil {
+			opciones = &OpcionesEjecucion{}
+		}
+		opciones.Depuracion = s
+		res, err := EjecutarFlujoConOpciones(ctx, procesoID, input, canalCodigo, trigger, opciones)
+		s.finalizar(res, err)
+
+		time.AfterFunc(TiempoRetencionSesion, func() {
+			sesionesDepuracion.Lock()
+			delete(sesionesDepuracion.sesiones, s.ID)
+			sesionesDepuracion.Unlock()
+		})
+	}()
+
+	return s
+}
+
+// ObtenerSesionDepuracion busca una sesión activa o recién terminada
+func ObtenerSesionDepuracion(id string) (*SesionDepuracion, bool) {
+	sesionesDepuracion.Lock()
+	defer sesionesDepuracion.Unlock()
+	s, ok := sesionesDepuracion.sesiones[id]
+	return s, ok
+}
+
+// Instantanea devuelve el estado actual de la sesión
+func (s *SesionDepuracion) Instantanea() InstantaneaDepuracion {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.instantanea
+}
+
+// Esperar bloquea hasta que la sesión quede pausada o terminada después de la versión indicada,
+// o hasta que venza el tiempo; devuelve la instantánea vigente en ese momento
+func (s *SesionDepuracion) Esperar(ctx context.Context, desdeVersion int, maximo time.Duration) InstantaneaDepuracion {
+	limite := time.NewTimer(maximo)
+	defer limite.Stop()
+
+	for {
+		s.mu.Lock()
+		if s.version > desdeVersion && s.instantanea.Estado != DepuracionEjecutando {
+			inst := s.instantanea
+			s.mu.Unlock()
+			return inst
+		}
+		aviso := s.aviso
+		s.mu.Unlock()
+
+		select {
+		case <-aviso:
+		case <-ctx.Done():
+			return s.Instantanea()
+		case <-limite.C:
+			return s.Instantanea()
+		}
+	}
+}
+
+// Version devuelve el contador de cambios, para esperar el próximo a partir de él
+func (s *SesionDepuracion) Version() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.version
+}
+
+// Continuar reanuda hasta el próximo breakpoint, aplicando antes las variables indicadas
+func (s *SesionDepuracion) Continuar(variables map[string]interface{}) error {
+	return s.enviar(comandoDepuracion{accion: accionContinuar, variables: variables})
+}
+
+// Paso ejecuta solo el siguiente nodo y vuelve a pausar
+func (s *SesionDepuracion) Paso(variables map[string]interface{}) error {
+	return s.enviar(comandoDepuracion{accion: accionPaso, variables: variables})
+}
+
+// EditarVariables cambia variables de resultado sin reanudar la ejecución
+func (s *SesionDepuracion) EditarVariables(variables map[string]interface{}) error {
+	return s.enviar(comandoDepuracion{accion: accionEditar, variables: variables})
+}
+
+// DefinirBreakpoints reemplaza la lista de breakpoints de la sesión
+func (s *SesionDepuracion) DefinirBreakpoints(breakpoints []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.breakpoints = make(map[string]bool)
+	for _, id := range breakpoints {
+		s.breakpoints[id] = true
+	}
+	s.instantanea.Breakpoints = s.listaBreakpoints()
+}
+
+// Abortar cancela la ejecución; el motor sale con "ejecución cancelada"
+func (s *SesionDepuracion) Abortar() {
+	s.cancelar()
+}
+
+func (s *SesionDepuracion) enviar(cmd comandoDepuracion) error {
+	s.mu.Lock()
+	estado := s.instantanea.Estado
+	s.mu.Unlock()
+	if estado != DepuracionPausada {
+		return fmt.Errorf("la sesión no está pausada (estado: %s)", estado)
+	}
+
+	select {
+	case s.comandos <- cmd:
+		return nil
+	case <-time.After(5 * time.Second):
+		return fmt.Errorf("la ejecución no respondió al comando %s", cmd.accion)
+	}
+}
+
+// debePausar indica si el motor debe detenerse antes de ejecutar el nodo
+func (s *SesionDepuracion) debePausar(nodoID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.modoPaso || s.breakpoints[nodoID]
+}
+
+// pausar detiene la goroutine del motor antes de ejecutar el nodo y atiende comandos hasta que
+// llegue continuar o paso; corre en la goroutine del flujo (o de la rama) que se está pausando
+func (s *SesionDepuracion) pausar(e *estadoFlujo, nodoID string) {
+	s.turno.Lock()
+	defer s.turno.Unlock()
+
+	n := e.grafo.nodos[nodoID]
+	fmt.Printf("⏸️ Depuración %s: pausa antes del nodo %s (%s)\n", s.ID, nodoID, n.Type)
+	s.publicar(e, n.ID, n.Type, DepuracionPausada)
+
+	espera := time.NewTimer(TiempoMaximoPausa)
+	defer espera.Stop()
+
+	for {
+		select {
+		case cmd := <-s.comandos:
+			for k, v := range cmd.variables {
+				e.resultado[k] = v
+			}
+			if cmd.accion == accionEditar {
+				s.publicar(e, n.ID, n.Type, DepuracionPausada)
+				continue
+			}
+			s.mu.Lock()
+			s.modoPaso = cmd.accion == accionPaso
+			s.mu.Unlock()
+			s.publicar(e, n.ID, n.Type, DepuracionEjecutando)
+			return
+
+		case <-e.ctx.Done():
+			return
+
+		case <-espera.C:
+			fmt.Printf("⌛ Depuración %s: pausa vencida, se aborta la ejecución\n", s.ID)
+			s.cancelar()
+			return
+		}
+	}
+}
+
+// publicar actualiza la instantánea y despierta a quien esté esperando un cambio
+func (s *SesionDepuracion) publicar(e *estadoFlujo, nodoID, tipoNodo, estado string) {
+	visitados := make([]string, 0, len(e.visitados))
+	for id := range e.visitados {
+		visitados = append(visitados, id)
+	}
+	sort.Slice(visitados, func(i, j int) bool { return e.grafo.orden[visitados[i]] < e.grafo.orden[visitados[j]] })
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.instantanea = InstantaneaDepuracion{
+		SesionID:              s.ID,
+		Estado:                estado,
+		NodoID:                nodoID,
+		TipoNodo:              tipoNodo,
+		Rama:                  e.rama,
+		Resultado:             copiarMapa(e.resultado),
+		AsignacionesAplicadas: copiarMapa(e.asignacionesAplicadas),
+		Visitados:             visitados,
+		Breakpoints:           s.listaBreakpoints(),
+	}
+	s.avisarCambio()
+}
+
+// finalizar registra el resultado de la ejecución y deja la sesión en estado terminal
+func (s *SesionDepuracion) finalizar(res ResultadoEjecucion, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.instantanea.Estado = DepuracionTerminada
+	s.instantanea.NodoID = ""
+	s.instantanea.TipoNodo = ""
+	s.instantanea.Rama = ""
+	s.instantanea.ResultadoFinal = &res
+	if err != nil {
+		s.instantanea.Estado = DepuracionAbortada
+		s.instantanea.Error = err.Error()
+	}
+	s.avisarCambio()
+
+	fmt.Printf("🐞 Sesión de depuración %s finalizada (%s)\n", s.ID, s.instantanea.Estado)
+}
+
+// avisarCambio requiere s.mu tomado
+func (s *SesionDepuracion) avisarCambio() {
+	s.version++
+	close(s.aviso)
+	s.aviso = make(chan struct{})
+}
+
+// listaBreakpoints requiere s.mu tomado
+func (s *SesionDepuracion) listaBreakpoints() []string {
+	lista := make([]string, 0, len(s.breakpoints))
+	for id := range s.breakpoints {
+		lista = append(lista, id)
+	}
+	sort.Strings(lista)
+	return lista
+}
@@ -0,0 +1,272 @@
+package ejecutores
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// consultaNombrada es una consulta escrita en el nodo con parámetros :nombre ya traducida a los
+// marcadores del driver ($1, $2 o ?). Los valores siempre viajan enlazados, nunca en el texto
+type consultaNombrada struct {
+	SQL     string
+	Nombres []string // nombre de cada marcador, en orden
+}
+
+// dialectoSQL es lo que cambia entre motores al leer una consulta: el marcador de parámetros y
+// si la barra invertida escapa comillas dentro de los textos
+type dialectoSQL struct {
+	marcador     func(posicion int) string
+	escapesBarra bool // MySQL lee 'it\'s' como un solo texto; PostgreSQL y SQLite no
+}
+
+var (
+	dialectoPostgreSQL = dialectoSQL{marcador: marcadorPostgreSQL}
+	dialectoMySQL      = dialectoSQL{marcador: marcadorMySQL, escapesBarra: true}
+	dialectoSQLite     = dialectoSQL{marcador: marcadorMySQL}
+)
+
+// ejecutarConsulta ejecuta una sentencia escrita en el nodo con parámetros :nombre. Cada :nombre
+// se envía enlazado con su valor de "valores"; nunca se pega en el texto. El FullOutput trae las
+// filas, las columnas y filasAfectadas
+func ejecutarConsulta(ctx context.Context, db consultorSQL, consulta string, valores map[string]interface{}, tipos map[string]string, dialecto dialectoSQL) (string, error) {
+	traducida := traducirConsultaNombrada(consulta, dialecto)
+	fullOutput := map[string]interface{}{
+		"consulta":   consulta,
+		"parametros": map[string]interface{}{},
+	}
+
+	argumentos := make([]interface{}, 0, len(traducida.Nombres))
+	usados := make(map[string]interface{}, len(traducida.Nombres))
+	for _, nombre := range traducida.Nombres {
+		valor, existe := valores[nombre]
+		if !existe {
+			return salidaConError(fullOutput, fmt.Errorf("el parámetro :%s de la consulta no existe en el resultado", nombre))
+		}
+		usados[nombre] = valor
+		argumentos = append(argumentos, valorParametroSQL(tipos[nombre], valor))
+	}
+	fullOutput["parametros"] = usados
+
+	// Sin RETURNING, un INSERT / UPDATE / DELETE va por Exec para saber cuántas filas tocó
+	if !devuelveFilas(traducida.SQL, dialecto) {
+		res, err := db.ExecContext(ctx, traducida.SQL, argumentos...)
+		if err != nil {
+			return salidaConError(fullOutput, err)
+		}
+		afectadas, _ := res.RowsAffected()
+		fullOutput["filas"] = []map[string]interface{}{}
+		fullOutput["columnas"] = []string{}
+		fullOutput["filasAfectadas"] = afectadas
+		fullOutput["resultado"] = afectadas
+		fullOutputJSON, _ := json.Marshal(fullOutput)
+		return string(fullOutputJSON), nil
+	}
+
+	rows, err := db.QueryContext(ctx, traducida.SQL, argumentos...)
+	if err != nil {
+		return salidaConError(fullOutput, err)
+	}
+	defer rows.Close()
+
+	filas, columnas, err := leerFilas(rows)
+	if err != nil {
+		return salidaConError(fullOutput, err)
+	}
+
+	fullOutput["filas"] = filas
+	fullOutput["columnas"] = columnas
+	fullOutput["filasAfectadas"] = len(filas)
+	fullOutput["resultado"] = valorResultado(filas, columnas)
+	aplanarFilaUnica(fullOutput, filas)
+
+	fullOutputJSON, _ := json.Marshal(fullOutput)
+	return string(fullOutputJSON), nil
+}
+
+// ParametrosConsulta devuelve los nombres :parametro que usa la consulta, sin repetir. El nodo no
+// sabe a qué motor va, así que los textos se leen como en PostgreSQL
+func ParametrosConsulta(sql string) []string {
+	c := traducirConsultaNombrada(sql, dialectoPostgreSQL)
+	vistos := make(map[string]bool)
+	var nombres []string
+	for _, n := range c.Nombres {
+		if !vistos[n] {
+			vistos[n] = true
+			nombres = append(nombres, n)
+		}
+	}
+	return nombres
+}
+
+// marcadorPostgreSQL numera los parámetros; un mismo nombre reutiliza su número
+func marcadorPostgreSQL(posicion int) string {
+	return fmt.Sprintf("$%d", posicion)
+}
+
+// traducirConsultaNombrada reemplaza :nombre por el marcador del driver. No toca textos entre
+// comillas, identificadores entre comillas dobles, comentarios, $$cuerpos$$ ni los casts ::tipo
+func traducirConsultaNombrada(sql string, dialecto dialectoSQL) consultaNombrada {
+	marcador := dialecto.marcador
+	r := []rune(sql)
+	var sb strings.Builder
+	var nombres []string
+	posiciones := make(map[string]int)
+	// Con marcadores numerados un nombre repetido usa el mismo número; con ? se repite el valor
+	numerados := marcador(1) != marcador(2)
+	i := 0
+
+	copiarHasta := func(fin int) {
+		if fin > len(r) {
+			fin = len(r)
+		}
+		sb.WriteString(string(r[i:fin]))
+		i = fin
+	}
+
+	for i < len(r) {
+		c := r[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			copiarHasta(dialecto.finTexto(r, i))
+
+		case finComentarioSQL(r, i) > i:
+			copiarHasta(finComentarioSQL(r, i))
+
+		case c == '$' && etiquetaDolar(r, i) != "":
+			etiqueta := []rune(etiquetaDolar(r, i))
+			copiarHasta(buscarRunas(r, i+len(etiqueta), etiqueta))
+
+		case c == ':' && i+1 < len(r) && r[i+1] == ':':
+			copiarHasta(i + 2)
+
+		case c == ':' && i+1 < len(r) && esInicioNombreSQL(r[i+1]) && (i == 0 || !esParteNombreSQL(r[i-1])):
+			fin := i + 1
+			for fin < len(r) && esParteNombreSQL(r[fin]) {
+				fin++
+			}
+			nombre := string(r[i+1 : fin])
+			posicion, existe := posiciones[nombre]
+			if !existe || !numerados {
+				nombres = append(nombres, nombre)
+				posicion = len(nombres)
+				posiciones[nombre] = posicion
+			}
+			sb.WriteString(marcador(posicion))
+			i = fin
+
+		default:
+			sb.WriteRune(c)
+			i++
+		}
+	}
+	return consultaNombrada{SQL: sb.String(), Nombres: nombres}
+}
+
+// finTexto devuelve la posición siguiente al cierre del texto o identificador que abre en r[i].
+// Dos comillas seguidas siempre escapan una; la barra invertida solo en los textos de MySQL y en
+// los E'...' de PostgreSQL
+func (d dialectoSQL) finTexto(r []rune, i int) int {
+	cierre := r[i]
+	textoE := cierre == '\'' && i > 0 && (r[i-1] == 'E' || r[i-1] == 'e') && (i == 1 || !esParteNombreSQL(r[i-2]))
+	barra := textoE || (d.escapesBarra && cierre != '`')
+	fin := i + 1
+	for fin < len(r) {
+		switch {
+		case barra && r[fin] == '\\':
+			fin += 2
+			continue
+		case r[fin] == cierre:
+			if fin+1 < len(r) && r[fin+1] == cierre {
+				fin += 2
+				continue
+			}
+			return fin + 1
+		}
+		fin++
+	}
+	return len(r)
+}
+
+// finComentarioSQL devuelve la posición siguiente a un comentario -- o /* */ que empieza en r[i],
+// o i si ahí no empieza un comentario
+func finComentarioSQL(r []rune, i int) int {
+	if i+1 >= len(r) {
+		return i
+	}
+	switch {
+	case r[i] == '-' && r[i+1] == '-':
+		fin := i
+		for fin < len(r) && r[fin] != '\n' {
+			fin++
+		}
+		return fin
+	case r[i] == '/' && r[i+1] == '*':
+		return buscarRunas(r, i+2, []rune("*/"))
+	}
+	return i
+}
+
+// etiquetaDolar reconoce el inicio de un texto $$...$$ o $etiqueta$...$etiqueta$ de PostgreSQL
+func etiquetaDolar(r []rune, i int) string {
+	fin := i + 1
+	for fin < len(r) && esParteNombreSQL(r[fin]) {
+		fin++
+	}
+	if fin < len(r) && r[fin] == '$' && (fin == i+1 || !(r[i+1] >= '0' && r[i+1] <= '9')) {
+		return string(r[i : fin+1])
+	}
+	return ""
+}
+
+// buscarRunas devuelve la posición siguiente al patrón buscado desde "desde", o el final si no está
+func buscarRunas(r []rune, desde int, patron []rune) int {
+	for i := desde; i+len(patron) <= len(r); i++ {
+		if string(r[i:i+len(patron)]) == string(patron) {
+			return i + len(patron)
+		}
+	}
+	return len(r)
+}
+
+func esInicioNombreSQL(c rune) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func esParteNombreSQL(c rune) bool {
+	return esInicioNombreSQL(c) || (c >= '0' && c <= '9')
+}
+
+// devuelveFilas indica si la sentencia produce filas (SELECT, WITH, VALUES o con RETURNING);
+// las demás se ejecutan con Exec para conocer las filas afectadas
+func devuelveFilas(sql string, dialecto dialectoSQL) bool {
+	limpio := strings.ToUpper(strings.TrimSpace(quitarTextosSQL(sql, dialecto)))
+	for _, inicio := range []string{"SELECT", "WITH", "VALUES", "TABLE", "SHOW", "EXPLAIN"} {
+		if strings.HasPrefix(limpio, inicio) {
+			return true
+		}
+	}
+	return strings.Contains(limpio, "RETURNING")
+}
+
+// quitarTextosSQL borra el contenido de los textos y los comentarios para buscar palabras clave;
+// un comentario queda como un espacio para no pegar las palabras que separaba
+func quitarTextosSQL(sql string, dialecto dialectoSQL) string {
+	var sb strings.Builder
+	r := []rune(sql)
+	for i := 0; i < len(r); {
+		switch {
+		case r[i] == '\'' || r[i] == '"' || r[i] == '`':
+			i = dialecto.finTexto(r, i)
+			sb.WriteString("''")
+		case finComentarioSQL(r, i) > i:
+			i = finComentarioSQL(r, i)
+			sb.WriteRune(' ')
+		default:
+			sb.WriteRune(r[i])
+			i++
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,143 @@
+package ejecutores
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTraducirConsultaNombrada(t *testing.T) {
+	casos := []struct {
+		nombre   string
+		dialecto dialectoSQL
+		consulta string
+		sql      string
+		nombres  []string
+	}{
+		{
+			nombre:   "parámetros simples",
+			dialecto: dialectoPostgreSQL,
+			consulta: "SELECT * FROM clientes WHERE id = :id AND estado = :estado",
+			sql:      "SELECT * FROM clientes WHERE id = $1 AND estado = $2",
+			nombres:  []string{"id", "estado"},
+		},
+		{
+			nombre:   "nombre repetido con marcadores numerados",
+			dialecto: dialectoPostgreSQL,
+			consulta: "SELECT :a, :b, :a",
+			sql:      "SELECT $1, $2, $1",
+			nombres:  []string{"a", "b"},
+		},
+		{
+			nombre:   "nombre repetido con ?",
+			dialecto: dialectoMySQL,
+			consulta: "SELECT :a, :b, :a",
+			sql:      "SELECT ?, ?, ?",
+			nombres:  []string{"a", "b", "a"},
+		},
+		{
+			nombre:   "? que ya estaba en la consulta no es un parámetro",
+			dialecto: dialectoSQLite,
+			consulta: "SELECT '?' AS signo, :x",
+			sql:      "SELECT '?' AS signo, ?",
+			nombres:  []string{"x"},
+		},
+		{
+			nombre:   "cast ::tipo",
+			dialecto: dialectoPostgreSQL,
+			consulta: "SELECT :fecha::date, valor::text FROM t",
+			sql:      "SELECT $1::date, valor::text FROM t",
+			nombres:  []string{"fecha"},
+		},
+		{
+			nombre:   "textos e identificadores",
+			dialecto: dialectoPostgreSQL,
+			consulta: `SELECT 'hora :x', "col:y", 'it''s :z', :w`,
+			sql:      `SELECT 'hora :x', "col:y", 'it''s :z', $1`,
+			nombres:  []string{"w"},
+		},
+		{
+			nombre:   "comentarios",
+			dialecto: dialectoPostgreSQL,
+			consulta: "/* :a */ SELECT :b -- :c\nFROM t",
+			sql:      "/* :a */ SELECT $1 -- :c\nFROM t",
+			nombres:  []string{"b"},
+		},
+		{
+			nombre:   "cuerpo $$",
+			dialecto: dialectoPostgreSQL,
+			consulta: "DO $$ BEGIN PERFORM :a; END $$; SELECT $cuerpo$ :b $cuerpo$, :c",
+			sql:      "DO $$ BEGIN PERFORM :a; END $$; SELECT $cuerpo$ :b $cuerpo$, $1",
+			nombres:  []string{"c"},
+		},
+		{
+			nombre:   "barra invertida en MySQL",
+			dialecto: dialectoMySQL,
+			consulta: `SELECT 'it\'s :x', "di \":y\"", :z`,
+			sql:      `SELECT 'it\'s :x', "di \":y\"", ?`,
+			nombres:  []string{"z"},
+		},
+		{
+			nombre:   "barra invertida al final de un texto de MySQL",
+			dialecto: dialectoMySQL,
+			consulta: `SELECT 'C:\\' AS ruta, :x`,
+			sql:      `SELECT 'C:\\' AS ruta, ?`,
+			nombres:  []string{"x"},
+		},
+		{
+			nombre:   "la barra no escapa en PostgreSQL",
+			dialecto: dialectoPostgreSQL,
+			consulta: `SELECT 'C:\', :x`,
+			sql:      `SELECT 'C:\', $1`,
+			nombres:  []string{"x"},
+		},
+		{
+			nombre:   "texto E'' de PostgreSQL",
+			dialecto: dialectoPostgreSQL,
+			consulta: `SELECT E'it\'s :x', :y`,
+			sql:      `SELECT E'it\'s :x', $1`,
+			nombres:  []string{"y"},
+		},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			obtenida := traducirConsultaNombrada(c.consulta, c.dialecto)
+			if obtenida.SQL != c.sql || !reflect.DeepEqual(obtenida.Nombres, c.nombres) {
+				t.Fatalf("se esperaba %q %v y se obtuvo %q %v", c.sql, c.nombres, obtenida.SQL, obtenida.Nombres)
+			}
+		})
+	}
+}
+
+func TestDevuelveFilas(t *testing.T) {
+	casos := []struct {
+		consulta string
+		dialecto dialectoSQL
+		esperado bool
+	}{
+		{"SELECT 1", dialectoPostgreSQL, true},
+		{"  with x as (select 1) select * from x", dialectoPostgreSQL, true},
+		{"/* hint */ SELECT 1", dialectoPostgreSQL, true},
+		{"/*+ MAX_EXECUTION_TIME(100) */SELECT 1", dialectoMySQL, true},
+		{"-- consulta\nSELECT 1", dialectoPostgreSQL, true},
+		{"INSERT INTO t VALUES (1) RETURNING id", dialectoPostgreSQL, true},
+		{"INSERT INTO t VALUES (1)", dialectoPostgreSQL, false},
+		{"UPDATE t SET nota = 'SELECT RETURNING'", dialectoPostgreSQL, false},
+		{"UPDATE t SET nota = 'it\\'s RETURNING'", dialectoMySQL, false},
+		{"DELETE FROM t /* RETURNING */", dialectoPostgreSQL, false},
+		{"UPDATE t SET x = 1 -- RETURNING", dialectoPostgreSQL, false},
+	}
+
+	for _, c := range casos {
+		if obtenido := devuelveFilas(c.consulta, c.dialecto); obtenido != c.esperado {
+			t.Errorf("%q: se esperaba %v y se obtuvo %v", c.consulta, c.esperado, obtenido)
+		}
+	}
+}
+
+func TestParametrosConsulta(t *testing.T) {
+	obtenidos := ParametrosConsulta("SELECT :a, :b::int, :a /* :c */")
+	if esperado := []string{"a", "b"}; !reflect.DeepEqual(obtenidos, esperado) {
+		t.Fatalf("se esperaba %v y se obtuvo %v", esperado, obtenidos)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build cgo
+
+package ejecutores
+
+// El driver de SQLite (mattn/go-sqlite3) está escrito en C: solo se incluye cuando el motor se
+// compila con cgo. Sin cgo se compila driver_sqlite_sincgo.go y los servidores sqlite dan error
+import _ "github.com/mattn/go-sqlite3"
+
+const sqliteDisponible = true
@@ -0,0 +1,6 @@
+//go:build !cgo
+
+package ejecutores
+
+// Compilado con CGO_ENABLED=0 el motor no incluye el driver de SQLite (ver driver_sqlite.go)
+const sqliteDisponible = false
@@ -0,0 +1,16 @@
+//go:build !cgo
+
+package ejecutores
+
+import (
+	"backendmotor/internal/models"
+	"strings"
+	"testing"
+)
+
+func TestSQLiteSinCgo(t *testing.T) {
+	_, err := PoolPara(models.Servidor{ID: "sqlite-sin-cgo", Tipo: "sqlite", Host: ":memory:"})
+	if err == nil || !strings.Contains(err.Error(), "sin cgo") {
+		t.Fatalf("se esperaba un error por compilar sin cgo y se obtuvo %v", err)
+	}
+}
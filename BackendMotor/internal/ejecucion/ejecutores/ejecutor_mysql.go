@@ -0,0 +1,219 @@
+package ejecutores
+
+import (
+	"backendmotor/internal/estructuras"
+	"backendmotor/internal/models"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// EjecutorMySQL atiende servidores mysql y mariadb. MySQL no tiene parámetros por nombre: todo
+// se envía por posición, en el orden de los parametrosEntrada
+type EjecutorMySQL struct {
+	servidor *models.Servidor
+	pool     *sql.DB
+	db       consultorSQL // el pool o, dentro de una transacción del flujo, el *sql.Tx
+}
+
+// NuevoEjecutorMySQL usa el pool compartido del servidor (ver pool_conexiones.go)
+func NuevoEjecutorMySQL(servidor *models.Servidor) (*EjecutorMySQL, error) {
+	db, err := PoolPara(*servidor)
+	if err != nil {
+		return nil, err
+	}
+	return &EjecutorMySQL{servidor: servidor, pool: db, db: db}, nil
+}
+
+// marcadorMySQL es el ? de MySQL y SQLite; un nombre repetido se enlaza una vez por aparición
+func marcadorMySQL(int) string {
+	return "?"
+}
+
+// EjecutarFuncionConParametros ejecuta SELECT nombre(?, ...) AS resultado; las funciones de
+// MySQL devuelven un solo valor
+func (e *EjecutorMySQL) EjecutarFuncionConParametros(ctx context.Context, nombre string, parametros []ParametroSQL) (string, error) {
+	return ejecutarFuncionEscalar(ctx, e.db, nombre, parametros)
+}
+
+// EjecutarProcedimientoConParametros ejecuta CALL nombre(...). Los OUT / INOUT van en variables
+// de sesión (@nombre) que se leen después del CALL en la misma conexión
+func (e *EjecutorMySQL) EjecutarProcedimientoConParametros(ctx context.Context, nombre string, parametros []ParametroSQL) (string, error) {
+	fullOutput := map[string]interface{}{
+		"procedimiento": nombre,
+		"parametros":    parametrosComoMapa(parametros),
+		"estado":        "ejecutado",
+	}
+
+	// 🔌 Paso 1: Las variables de sesión solo existen en una conexión: se toma una del pool
+	// (dentro de una transacción ya es siempre la misma)
+	conexion := e.db
+	if _, enTransaccion := e.db.(*sql.Tx); !enTransaccion {
+		c, err := e.pool.Conn(ctx)
+		if err != nil {
+			return salidaConError(fullOutput, err)
+		}
+		defer c.Close()
+		conexion = c
+	}
+
+	// 🧩 Paso 2: Armar los argumentos; los OUT arrancan en NULL y los INOUT con su valor
+	argumentos := make([]string, 0, len(parametros))
+	var valores []interface{}
+	var variables []string
+	for _, p := range parametros {
+		direccion := direccionParametro(p.Direccion)
+		if direccion == "in" {
+			argumentos = append(argumentos, "?")
+			valores = append(valores, valorParametroSQL(p.Tipo, p.Valor))
+			continue
+		}
+		if !esIdentificadorSQL(p.Nombre) {
+			return salidaConError(fullOutput, fmt.Errorf("nombre de parámetro inválido: %q", p.Nombre))
+		}
+		var inicial interface{}
+		if direccion == "inout" {
+			inicial = valorParametroSQL(p.Tipo, p.Valor)
+		}
+		if _, err := conexion.ExecContext(ctx, fmt.Sprintf("SET @%s = ?", p.Nombre), inicial); err != nil {
+			return salidaConError(fullOutput, err)
+		}
+		argumentos = append(argumentos, "@"+p.Nombre)
+		variables = append(variables, fmt.Sprintf("@%s AS `%s`", p.Nombre, p.Nombre))
+	}
+
+	// 🚀 Paso 3: CALL; si el procedimiento hace SELECT, el primer conjunto de filas queda en "filas"
+	rows, err := conexion.QueryContext(ctx, fmt.Sprintf("CALL %s(%s)", nombre, strings.Join(argumentos, ", ")), valores...)
+	if err != nil {
+		return salidaConError(fullOutput, err)
+	}
+	filas, _, err := leerFilas(rows)
+	for err == nil && rows.NextResultSet() {
+		// Los demás conjuntos se descartan para dejar libre la conexión
+		for rows.Next() {
+		}
+		err = rows.Err()
+	}
+	rows.Close()
+	if err != nil {
+		return salidaConError(fullOutput, err)
+	}
+	fullOutput["filas"] = filas
+
+	// 📥 Paso 4: Leer los OUT / INOUT
+	if len(variables) > 0 {
+		rows, err := conexion.QueryContext(ctx, "SELECT "+strings.Join(variables, ", "))
+		if err != nil {
+			return salidaConError(fullOutput, err)
+		}
+		salidas, _, err := leerFilas(rows)
+		rows.Close()
+		if err != nil {
+			return salidaConError(fullOutput, err)
+		}
+		if len(salidas) == 1 {
+			// Las variables de sesión no tienen tipo de columna: el texto llega como bytes
+			for k, v := range salidas[0] {
+				if b, ok := v.([]byte); ok {
+					salidas[0][k] = string(b)
+				}
+			}
+			fullOutput["salida"] = salidas[0]
+			aplanarFilaUnica(fullOutput, salidas)
+		}
+	}
+	aplanarFilaUnica(fullOutput, filas)
+
+	fullOutputJSON, _ := json.Marshal(fullOutput)
+	return string(fullOutputJSON), nil
+}
+
+// EjecutarConsulta ejecuta una sentencia escrita en el nodo con parámetros :nombre enlazados
+// como ? (ver consulta_sql.go)
+func (e *EjecutorMySQL) EjecutarConsulta(ctx context.Context, consulta string, valores map[string]interface{}, tipos map[string]string) (string, error) {
+	return ejecutarConsulta(ctx, e.db, consulta, valores, tipos, dialectoMySQL)
+}
+
+// ejecutarFuncionEscalar llama una función que devuelve un valor (MySQL y SQLite)
+func ejecutarFuncionEscalar(ctx context.Context, db consultorSQL, nombre string, parametros []ParametroSQL) (string, error) {
+	fullOutput := map[string]interface{}{
+		"funcion":    nombre,
+		"parametros": parametrosComoMapa(parametros),
+	}
+
+	marcadores := make([]string, 0, len(parametros))
+	valores := make([]interface{}, 0, len(parametros))
+	for _, p := range parametros {
+		if direccionParametro(p.Direccion) != "in" {
+			return salidaConError(fullOutput, fmt.Errorf("las funciones no admiten parámetros de salida (%s)", p.Nombre))
+		}
+		marcadores = append(marcadores, "?")
+		valores = append(valores, valorParametroSQL(p.Tipo, p.Valor))
+	}
+
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("SELECT %s(%s) AS resultado", nombre, strings.Join(marcadores, ", ")), valores...)
+	if err != nil {
+		return salidaConError(fullOutput, err)
+	}
+	defer rows.Close()
+
+	filas, columnas, err := leerFilas(rows)
+	if err != nil {
+		return salidaConError(fullOutput, err)
+	}
+
+	fullOutput["filas"] = filas
+	fullOutput["columnas"] = columnas
+	fullOutput["resultado"] = valorResultado(filas, columnas)
+
+	fullOutputJSON, _ := json.Marshal(fullOutput)
+	return string(fullOutputJSON), nil
+}
+
+func EjecutarMySQL(ctx context.Context, n estructuras.NodoGenerico, resultado map[string]interface{}, servidor models.Servidor) (string, error) {
+	ejecutor, err := NuevoEjecutorMySQL(&servidor)
+	if err != nil {
+		return "", fmt.Errorf("error al conectar a MySQL: %w", err)
+	}
+	if tx, ok := transaccionDe(ctx, servidor.ID); ok {
+		ejecutor.db = tx
+	}
+
+	objeto, _ := n.Data["objeto"].(string)
+	tipo, _ := n.Data["tipoObjeto"].(string)
+
+	// 🧾 Consulta escrita en el nodo: los :parametros se toman del resultado
+	if esConsultaSQL(tipo) {
+		consulta := ConsultaDelNodo(n)
+		if consulta == "" {
+			return "", fmt.Errorf("consulta no definida en el nodo")
+		}
+		return ejecutor.EjecutarConsulta(ctx, consulta, resultado, tiposParametros(n))
+	}
+
+	if objeto == "" || tipo == "" {
+		return "", fmt.Errorf("objeto o tipoObjeto no definidos en el nodo")
+	}
+
+	// 🎆 Filtrar solo parámetros que deben enviarse al servidor, en su orden
+	parametros := parametrosDelNodo(n, resultado, ModoParametrosPosicional)
+
+	var salida string
+	switch {
+	case esFuncionSQL(tipo):
+		salida, err = ejecutor.EjecutarFuncionConParametros(ctx, objeto, parametros)
+	case esProcedimientoSQL(tipo):
+		salida, err = ejecutor.EjecutarProcedimientoConParametros(ctx, objeto, parametros)
+	default:
+		return "", fmt.Errorf("tipo de objeto no soportado para MySQL: %s", tipo)
+	}
+	if err != nil {
+		return salida, err
+	}
+
+	// 📥 Los parámetros OUT / INOUT vuelven al resultado con el valor que devolvió el servidor
+	devolverSalidas(salida, parametros, resultado)
+	return salida, nil
+}
@@ -2,11 +2,12 @@ package ejecutores
 
 import (
 	"backendmotor/internal/estructuras"
+	"context"
 
 	"backendmotor/internal/models"
-	"database/sql"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 
 	_ "github.com/lib/pq"
@@ -14,14 +15,12 @@ import (
 
 type EjecutorPostgreSQL struct {
 	servidor *models.Servidor
-	db       *sql.DB
+	db       consultorSQL // el pool o, dentro de una transacción del flujo, el *sql.Tx
 }
 
+// NuevoEjecutorPostgreSQL usa el pool compartido del servidor (ver pool_conexiones.go)
 func NuevoEjecutorPostgreSQL(servidor *models.Servidor) (*EjecutorPostgreSQL, error) {
-	dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
-		servidor.Host, servidor.Puerto, servidor.Usuario, servidor.Clave, servidor.Extras["dbname"])
-
-	db, err := sql.Open("postgres", dsn)
+	db, err := PoolPara(*servidor)
 	if err != nil {
 		return nil, err
 	}
@@ -29,103 +28,264 @@ func NuevoEjecutorPostgreSQL(servidor *models.Servidor) (*EjecutorPostgreSQL, er
 	return &EjecutorPostgreSQL{servidor: servidor, db: db}, nil
 }
 
-// Ejecutar función fn_obtener_hora
-func (e *EjecutorPostgreSQL) EjecutarFuncion(nombre string, parametros map[string]interface{}) (string, error) {
-	query := fmt.Sprintf("SELECT %s()", nombre)
-	var resultado string
-	err := e.db.QueryRow(query).Scan(&resultado)
+// Modos de enviar los parámetros: posicional fn($1, $2) o nombrado fn(p_id => $1, p_nombre => $2)
+const (
+	ModoParametrosPosicional = "posicional"
+	ModoParametrosNombrado   = "nombrado"
+)
 
+// ParametroSQL es un parámetro ya resuelto, en el orden en que se envía
+type ParametroSQL struct {
+	Nombre    string
+	Tipo      string
+	Valor     interface{}
+	Direccion string // in (por defecto), out o inout
+}
+
+// EjecutarFuncion llama la función enviando el mapa de parámetros por nombre
+func (e *EjecutorPostgreSQL) EjecutarFuncion(ctx context.Context, nombre string, parametros map[string]interface{}) (string, error) {
+	return e.EjecutarFuncionConParametros(ctx, nombre, parametrosDesdeMapa(parametros), ModoParametrosNombrado)
+}
+
+// EjecutarProcedimiento llama el procedimiento enviando el mapa de parámetros por nombre
+func (e *EjecutorPostgreSQL) EjecutarProcedimiento(ctx context.Context, nombre string, parametros map[string]interface{}) (string, error) {
+	return e.EjecutarProcedimientoConParametros(ctx, nombre, parametrosDesdeMapa(parametros), ModoParametrosNombrado)
+}
+
+// EjecutarFuncionConParametros ejecuta SELECT * FROM nombre(...) con los parámetros enlazados.
+// Todas las filas quedan en "filas"; "resultado" es el valor si la función devuelve un escalar
+// y, con una sola fila, sus columnas también quedan al primer nivel del FullOutput
+func (e *EjecutorPostgreSQL) EjecutarFuncionConParametros(ctx context.Context, nombre string, parametros []ParametroSQL, modo string) (string, error) {
 	fullOutput := map[string]interface{}{
 		"funcion":    nombre,
-		"resultado":  resultado,
-		"parametros": parametros,
+		"parametros": parametrosComoMapa(parametros),
 	}
-	fullOutputJSON, _ := json.Marshal(fullOutput)
 
-	return string(fullOutputJSON), err
-}
+	argumentos, valores, err := argumentosSQL(parametros, modo, false)
+	if err != nil {
+		return salidaConError(fullOutput, err)
+	}
+	query := fmt.Sprintf("SELECT * FROM %s(%s)", nombre, argumentos)
 
-func (e *EjecutorPostgreSQL) EjecutarProcedimiento(nombre string, parametros map[string]interface{}) (string, error) {
-	paramDefs := []string{}
-	paramValues := []interface{}{}
-	i := 1
+	rows, err := e.db.QueryContext(ctx, query, valores...)
+	if err != nil {
+		return salidaConError(fullOutput, err)
+	}
+	defer rows.Close()
 
-	for k, v := range parametros {
-		paramDefs = append(paramDefs, fmt.Sprintf("%s := $%d", k, i))
-		paramValues = append(paramValues, v)
-		i++
+	filas, columnas, err := leerFilas(rows)
+	if err != nil {
+		return salidaConError(fullOutput, err)
 	}
 
-	query := fmt.Sprintf("CALL %s(%s)", nombre, joinStrings(paramDefs, ", "))
+	fullOutput["filas"] = filas
+	fullOutput["columnas"] = columnas
+	fullOutput["resultado"] = valorResultado(filas, columnas)
+	aplanarFilaUnica(fullOutput, filas)
 
-	_, err := e.db.Exec(query, paramValues...)
+	fullOutputJSON, _ := json.Marshal(fullOutput)
+	return string(fullOutputJSON), nil
+}
 
+// EjecutarProcedimientoConParametros ejecuta CALL nombre(...). Los parámetros OUT se envían como
+// NULL y sus valores (junto con los INOUT) se leen de la fila que devuelve el CALL
+func (e *EjecutorPostgreSQL) EjecutarProcedimientoConParametros(ctx context.Context, nombre string, parametros []ParametroSQL, modo string) (string, error) {
 	fullOutput := map[string]interface{}{
 		"procedimiento": nombre,
-		"parametros":    parametros,
+		"parametros":    parametrosComoMapa(parametros),
 		"estado":        "ejecutado",
 	}
 
+	argumentos, valores, err := argumentosSQL(parametros, modo, true)
 	if err != nil {
-		fullOutput["estado"] = "error"
-		fullOutput["detalleError"] = err.Error()
+		return salidaConError(fullOutput, err)
+	}
+	query := fmt.Sprintf("CALL %s(%s)", nombre, argumentos)
+
+	rows, err := e.db.QueryContext(ctx, query, valores...)
+	if err != nil {
+		return salidaConError(fullOutput, err)
+	}
+	defer rows.Close()
+
+	filas, _, err := leerFilas(rows)
+	if err != nil {
+		return salidaConError(fullOutput, err)
+	}
+
+	fullOutput["filas"] = filas
+	if len(filas) == 1 {
+		fullOutput["salida"] = filas[0]
 	}
+	aplanarFilaUnica(fullOutput, filas)
 
 	fullOutputJSON, _ := json.Marshal(fullOutput)
+	return string(fullOutputJSON), nil
+}
+
+// EjecutarConsulta ejecuta una sentencia escrita en el nodo (SELECT, INSERT, UPDATE o DELETE) con
+// parámetros :nombre enlazados como $1, $2... (ver consulta_sql.go)
+func (e *EjecutorPostgreSQL) EjecutarConsulta(ctx context.Context, consulta string, valores map[string]interface{}, tipos map[string]string) (string, error) {
+	return ejecutarConsulta(ctx, e.db, consulta, valores, tipos, dialectoPostgreSQL)
+}
+
+// argumentosSQL arma la lista de argumentos ($1, $2 o nombre => $1) y los valores a enlazar. En
+// funciones los OUT no se envían: vuelven como columnas del resultado
+func argumentosSQL(parametros []ParametroSQL, modo string, conSalidas bool) (string, []interface{}, error) {
+	var argumentos []string
+	var valores []interface{}
+	for _, p := range parametros {
+		direccion := direccionParametro(p.Direccion)
+		if direccion == "out" && !conSalidas {
+			continue
+		}
+
+		valor := valorParametroSQL(p.Tipo, p.Valor)
+		if direccion == "out" {
+			valor = nil
+		}
+		valores = append(valores, valor)
+		marcador := fmt.Sprintf("$%d", len(valores))
 
+		if modo == ModoParametrosNombrado {
+			if !esIdentificadorSQL(p.Nombre) {
+				return "", nil, fmt.Errorf("nombre de parámetro inválido para PostgreSQL: %q", p.Nombre)
+			}
+			marcador = p.Nombre + " => " + marcador
+		}
+		argumentos = append(argumentos, marcador)
+	}
+	return strings.Join(argumentos, ", "), valores, nil
+}
+
+// direccionParametro normaliza in / out / inout (también entrada / salida / entradaSalida)
+func direccionParametro(direccion string) string {
+	switch strings.ToLower(strings.TrimSpace(direccion)) {
+	case "out", "salida":
+		return "out"
+	case "inout", "in_out", "entradasalida", "entrada_salida":
+		return "inout"
+	}
+	return "in"
+}
+
+// valorResultado es el escalar de una función que devuelve un solo valor; si no, todas las filas
+func valorResultado(filas []map[string]interface{}, columnas []string) interface{} {
+	if len(filas) == 1 && len(columnas) == 1 {
+		return filas[0][columnas[0]]
+	}
+	if len(filas) == 0 {
+		return nil
+	}
+	return filas
+}
+
+// aplanarFilaUnica copia las columnas de una única fila al primer nivel, para que
+// parametrosSalida las encuentre por nombre; no pisa las claves propias del FullOutput
+func aplanarFilaUnica(fullOutput map[string]interface{}, filas []map[string]interface{}) {
+	if len(filas) != 1 {
+		return
+	}
+	for columna, valor := range filas[0] {
+		if _, existe := fullOutput[columna]; !existe {
+			fullOutput[columna] = valor
+		}
+	}
+}
+
+func salidaConError(fullOutput map[string]interface{}, err error) (string, error) {
+	fullOutput["estado"] = "error"
+	fullOutput["detalleError"] = err.Error()
+	fullOutputJSON, _ := json.Marshal(fullOutput)
 	return string(fullOutputJSON), err
 }
 
-// Función auxiliar simple para evitar importar strings innecesariamente
-func joinStrings(parts []string, sep string) string {
-	result := ""
-	for i, s := range parts {
-		if i > 0 {
-			result += sep
+func parametrosDesdeMapa(valores map[string]interface{}) []ParametroSQL {
+	nombres := make([]string, 0, len(valores))
+	for nombre := range valores {
+		nombres = append(nombres, nombre)
+	}
+	sort.Strings(nombres)
+
+	parametros := make([]ParametroSQL, 0, len(nombres))
+	for _, nombre := range nombres {
+		parametros = append(parametros, ParametroSQL{Nombre: nombre, Valor: valores[nombre]})
+	}
+	return parametros
+}
+
+func parametrosComoMapa(parametros []ParametroSQL) map[string]interface{} {
+	mapa := make(map[string]interface{}, len(parametros))
+	for _, p := range parametros {
+		if direccionParametro(p.Direccion) != "out" {
+			mapa[p.Nombre] = p.Valor
 		}
-		result += s
 	}
-	return result
+	return mapa
 }
-func EjecutarPostgreSQL(n estructuras.NodoGenerico, resultado map[string]interface{}, servidor models.Servidor) (string, error) {
+
+func EjecutarPostgreSQL(ctx context.Context, n estructuras.NodoGenerico, resultado map[string]interface{}, servidor models.Servidor) (string, error) {
 	ejecutor, err := NuevoEjecutorPostgreSQL(&servidor)
 	if err != nil {
 		return "", fmt.Errorf("error al conectar a PostgreSQL: %w", err)
 	}
+	if tx, ok := transaccionDe(ctx, servidor.ID); ok {
+		ejecutor.db = tx
+	}
 
 	objeto := fmt.Sprint(n.Data["objeto"])
 	tipo := fmt.Sprint(n.Data["tipoObjeto"])
+
+	// 🧾 Consulta escrita en el nodo: los :parametros se toman del resultado
+	if esConsultaSQL(tipo) {
+		consulta := ConsultaDelNodo(n)
+		if consulta == "" {
+			return "", fmt.Errorf("consulta no definida en el nodo")
+		}
+		return ejecutor.EjecutarConsulta(ctx, consulta, resultado, tiposParametros(n))
+	}
+
 	if objeto == "" || tipo == "" {
 		return "", fmt.Errorf("objeto o tipoObjeto no definidos en el nodo")
 	}
 
-	// 🎆 Filtrar solo parámetros que deben enviarse al servidor
-	parametrosFiltrados := getParametrosFiltradosYOrdenados(n)
-	
-	// Crear mapa de valores solo con parámetros filtrados
-	parametrosParaServidor := make(map[string]interface{})
-	for _, param := range parametrosFiltrados {
-		if val, existe := resultado[param.Nombre]; existe {
-			parametrosParaServidor[param.Nombre] = val
+	// 🎆 Filtrar solo parámetros que deben enviarse al servidor, en su orden
+	modo, _ := n.Data["modoParametros"].(string)
+	if modo == "" {
+		// Las funciones siempre se llamaron por posición y los procedimientos por nombre
+		modo = ModoParametrosPosicional
+		if esProcedimientoSQL(tipo) {
+			modo = ModoParametrosNombrado
 		}
 	}
+	parametros := parametrosDelNodo(n, resultado, modo)
 
-	switch strings.ToLower(tipo) {
-	case "funcion", "función", "plpgsql_function":
-		return ejecutor.EjecutarFuncion(objeto, parametrosParaServidor)
-	case "procedimiento", "plpgsql_procedure":
-		return ejecutor.EjecutarProcedimiento(objeto, parametrosParaServidor)
+	var salida string
+	switch {
+	case esFuncionSQL(tipo):
+		salida, err = ejecutor.EjecutarFuncionConParametros(ctx, objeto, parametros, modo)
+	case esProcedimientoSQL(tipo):
+		salida, err = ejecutor.EjecutarProcedimientoConParametros(ctx, objeto, parametros, modo)
 	default:
 		return "", fmt.Errorf("tipo de objeto no soportado para PostgreSQL: %s", tipo)
 	}
+	if err != nil {
+		return salida, err
+	}
+
+	// 📥 Los parámetros OUT / INOUT vuelven al resultado con el valor que devolvió el servidor
+	devolverSalidas(salida, parametros, resultado)
+	return salida, nil
 }
 
+
 // Estructura de parámetro para filtrado
 type ParametroFiltrado struct {
 	Nombre          string `json:"nombre"`
 	Tipo            string `json:"tipo"`
 	EnviarAServidor *bool  `json:"enviarAServidor,omitempty"`
 	Orden           *int   `json:"orden,omitempty"`
+	Direccion       string `json:"direccion,omitempty"` // in, out o inout (procedimientos y funciones)
 }
 
 // getParametrosFiltradosYOrdenados extrae y filtra parámetros del nodo
@@ -0,0 +1,142 @@
+//go:build cgo
+
+package ejecutores
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// consultorTraducido responde en SQLite las sentencias de PostgreSQL que el test conoce: cada
+// sentencia esperada se ejecuta como su equivalente, con los mismos argumentos, que quedan anotados
+type consultorTraducido struct {
+	t           *testing.T
+	db          *sql.DB
+	equivalente map[string]string
+	argumentos  []interface{}
+}
+
+func (c *consultorTraducido) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	c.t.Helper()
+	equivalente, ok := c.equivalente[query]
+	if !ok {
+		c.t.Fatalf("sentencia inesperada: %s", query)
+	}
+	c.argumentos = args
+	return c.db.QueryContext(ctx, equivalente, args...)
+}
+
+func (c *consultorTraducido) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	c.t.Fatalf("no se esperaba Exec: %s", query)
+	return nil, nil
+}
+
+func postgresqlDePrueba(t *testing.T, equivalente map[string]string) (*EjecutorPostgreSQL, *consultorTraducido) {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("error abriendo sqlite: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	consultor := &consultorTraducido{t: t, db: db, equivalente: equivalente}
+	return &EjecutorPostgreSQL{db: consultor}, consultor
+}
+
+func TestArgumentosSQL(t *testing.T) {
+	parametros := []ParametroSQL{
+		{Nombre: "p_id", Valor: 5.0},
+		{Nombre: "p_total", Direccion: "out", Valor: "ignorado"},
+		{Nombre: "p_saldo", Direccion: "inout", Valor: 3.0},
+	}
+
+	casos := []struct {
+		nombre     string
+		modo       string
+		conSalidas bool
+		argumentos string
+		valores    []interface{}
+	}{
+		{"función por posición", ModoParametrosPosicional, false, "$1, $2", []interface{}{5.0, 3.0}},
+		{"función por nombre", ModoParametrosNombrado, false, "p_id => $1, p_saldo => $2", []interface{}{5.0, 3.0}},
+		{"procedimiento por nombre", ModoParametrosNombrado, true, "p_id => $1, p_total => $2, p_saldo => $3", []interface{}{5.0, nil, 3.0}},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			argumentos, valores, err := argumentosSQL(parametros, c.modo, c.conSalidas)
+			if err != nil || argumentos != c.argumentos || !reflect.DeepEqual(valores, c.valores) {
+				t.Fatalf("se esperaba %q %v y se obtuvo %q %v (err=%v)", c.argumentos, c.valores, argumentos, valores, err)
+			}
+		})
+	}
+
+	if _, _, err := argumentosSQL([]ParametroSQL{{Nombre: "x; DROP TABLE t"}}, ModoParametrosNombrado, false); err == nil {
+		t.Fatalf("se esperaba un error por el nombre inválido")
+	}
+}
+
+func TestEjecutorPostgreSQLFuncionDevuelveFilas(t *testing.T) {
+	ejecutor, _ := postgresqlDePrueba(t, map[string]string{
+		"SELECT * FROM pedidos_de($1)": "SELECT 1 AS id, 'Ana' AS cliente, ?1 AS estado UNION ALL SELECT 2, 'Luis', ?1",
+		"SELECT * FROM total_de($1)":   "SELECT ?1 * 2 AS total_de",
+	})
+
+	// Una función que devuelve un conjunto deja todas las filas como objetos en resultado
+	salida, err := ejecutor.EjecutarFuncionConParametros(context.Background(), "pedidos_de", []ParametroSQL{{Nombre: "estado", Valor: "abierto"}}, ModoParametrosPosicional)
+	if err != nil {
+		t.Fatalf("error inesperado: %v (%s)", err, salida)
+	}
+	var conjunto map[string]interface{}
+	json.Unmarshal([]byte(salida), &conjunto)
+	filas := []interface{}{
+		map[string]interface{}{"id": 1.0, "cliente": "Ana", "estado": "abierto"},
+		map[string]interface{}{"id": 2.0, "cliente": "Luis", "estado": "abierto"},
+	}
+	if !reflect.DeepEqual(conjunto["filas"], filas) || !reflect.DeepEqual(conjunto["resultado"], filas) {
+		t.Fatalf("se esperaban las dos filas en filas y resultado y se obtuvo %s", salida)
+	}
+	if _, ok := conjunto["cliente"]; ok {
+		t.Fatalf("con varias filas las columnas no se copian al primer nivel: %s", salida)
+	}
+
+	// Un escalar queda como valor en resultado y al primer nivel
+	salida, err = ejecutor.EjecutarFuncionConParametros(context.Background(), "total_de", []ParametroSQL{{Nombre: "monto", Valor: 21}}, ModoParametrosPosicional)
+	if err != nil {
+		t.Fatalf("error inesperado: %v (%s)", err, salida)
+	}
+	var escalar map[string]interface{}
+	json.Unmarshal([]byte(salida), &escalar)
+	if escalar["resultado"] != 42.0 || escalar["total_de"] != 42.0 {
+		t.Fatalf("se esperaba el escalar 42 y se obtuvo %s", salida)
+	}
+}
+
+func TestEjecutorPostgreSQLProcedimientoDevuelveSalidas(t *testing.T) {
+	ejecutor, consultor := postgresqlDePrueba(t, map[string]string{
+		"CALL registrar_pago(p_monto => $1, p_id => $2, p_saldo => $3)": "SELECT 7 AS p_id, ?3 - ?1 AS p_saldo",
+	})
+	parametros := []ParametroSQL{
+		{Nombre: "p_monto", Valor: 30.0},
+		{Nombre: "p_id", Direccion: "out"},
+		{Nombre: "p_saldo", Direccion: "inout", Valor: 100.0},
+	}
+
+	salida, err := ejecutor.EjecutarProcedimientoConParametros(context.Background(), "registrar_pago", parametros, ModoParametrosNombrado)
+	if err != nil {
+		t.Fatalf("error inesperado: %v (%s)", err, salida)
+	}
+	// El OUT viaja como NULL y el INOUT con su valor
+	if esperados := []interface{}{30.0, nil, 100.0}; !reflect.DeepEqual(consultor.argumentos, esperados) {
+		t.Fatalf("se esperaban los argumentos %v y se enviaron %v", esperados, consultor.argumentos)
+	}
+
+	// Los OUT / INOUT vuelven al resultado; los de entrada no se tocan
+	resultado := map[string]interface{}{"p_monto": 30.0, "p_saldo": 100.0}
+	devolverSalidas(salida, parametros, resultado)
+	if esperado := map[string]interface{}{"p_monto": 30.0, "p_id": 7.0, "p_saldo": 70.0}; !reflect.DeepEqual(resultado, esperado) {
+		t.Fatalf("se esperaba %v y se obtuvo %v (%s)", esperado, resultado, salida)
+	}
+}
@@ -2,6 +2,7 @@ package ejecutores
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -13,9 +14,14 @@ import (
 	"backendmotor/internal/database"
 	"backendmotor/internal/estructuras"
 	"backendmotor/internal/models"
+	"backendmotor/internal/utils"
 )
 
-func EjecutarREST(nodo estructuras.NodoGenerico, resultado map[string]interface{}, servidor models.Servidor) (string, error) {
+// clienteHTTP se comparte entre ejecuciones para reutilizar conexiones; los tiempos
+// límite los impone el context.Context de cada llamada
+var clienteHTTP = &http.Client{}
+
+func EjecutarREST(ctx context.Context, nodo estructuras.NodoGenerico, resultado map[string]interface{}, servidor models.Servidor) (string, error) {
 	// 🧪 Preparar extras del servidor (headers, etc.)
 	extraHeaders := servidor.Extras
 
@@ -36,6 +42,17 @@ func EjecutarREST(nodo estructuras.NodoGenerico, resultado map[string]interface{
 		}
 	}
 
+	// 📦 data.cuerpo nombra una variable (por ejemplo la salida de un nodo transformar) que se
+	// envía completa como body, con su estructura anidada, en lugar de los parámetros sueltos
+	var cuerpo interface{}
+	if nombre, ok := nodo.Data["cuerpo"].(string); ok && nombre != "" {
+		valor, existe := utils.ResolverRuta(resultado, nombre)
+		if !existe {
+			return "", fmt.Errorf("la variable '%s' indicada como cuerpo no existe", nombre)
+		}
+		cuerpo = valor
+	}
+
 	metodo := strings.ToUpper(fmt.Sprint(nodo.Data["metodoHttp"]))
 	if metodo == "" || metodo == "<NIL>" {
 		metodo = "GET"
@@ -53,8 +70,12 @@ func EjecutarREST(nodo estructuras.NodoGenerico, resultado map[string]interface{
 	// 🧩 Preparar body (solo si no es GET) - usar payload filtrado
 	var body io.Reader
 	if metodo != "GET" {
-		// Usar solo los parámetros filtrados para el body
-		bodyBytes, err := json.Marshal(payloadParaServidor)
+		// Usar solo los parámetros filtrados para el body (o la variable de data.cuerpo)
+		var contenido interface{} = payloadParaServidor
+		if cuerpo != nil {
+			contenido = cuerpo
+		}
+		bodyBytes, err := json.Marshal(contenido)
 		if err != nil {
 			return "", fmt.Errorf("error serializando parámetros filtrados: %w", err)
 		}
@@ -74,13 +95,26 @@ func EjecutarREST(nodo estructuras.NodoGenerico, resultado map[string]interface{
 		}
 	}
 
+	// 🕒 Timeout si está definido en extras (nunca mayor al deadline de la ejecución)
+	timeout := 10 * time.Second
+	if tStr, ok := extraHeaders["timeout"].(string); ok && tStr != "" {
+		if tParsed, err := time.ParseDuration(tStr); err == nil {
+			timeout = tParsed
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
 	// 🧠 Preparar request
-	req, err := http.NewRequest(metodo, url, body)
+	req, err := http.NewRequestWithContext(ctx, metodo, url, body)
 	if err != nil {
 		return "", fmt.Errorf("error creando request: %w", err)
 	}
 
-	// 🧱 Headers desde extras del servidor
+	// 🧱 Headers desde extras del servidor (pueden reemplazar el Content-Type)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
 	for k, v := range extraHeaders {
 		vStr := strings.TrimSpace(fmt.Sprint(v))
 		if vStr == "" {
@@ -96,17 +130,8 @@ func EjecutarREST(nodo estructuras.NodoGenerico, resultado map[string]interface{
 		}
 	}
 
-	// 🕒 Timeout si está definido en extras
-	timeout := 10 * time.Second
-	if tStr, ok := extraHeaders["timeout"].(string); ok && tStr != "" {
-		if tParsed, err := time.ParseDuration(tStr); err == nil {
-			timeout = tParsed
-		}
-	}
-	client := &http.Client{Timeout: timeout}
-
 	// 🚀 Hacer la petición
-	resp, err := client.Do(req)
+	resp, err := clienteHTTP.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("error ejecutando request: %w", err)
 	}
@@ -146,6 +171,13 @@ func EjecutarREST(nodo estructuras.NodoGenerico, resultado map[string]interface{
 		}
 	}
 
+	// 🚨 Fallas del servidor (5xx) y limitación de tráfico (429) se informan como ErrorHTTP para el
+	// circuit breaker y los reintentos; el nodo solo falla si su política las reintenta (ver
+	// nodo_proceso.go), si no la respuesta queda en FullOutput como siempre
+	if resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests {
+		return fullOutput, &ErrorHTTP{Codigo: resp.StatusCode, Estado: resp.Status}
+	}
+
 	return fullOutput, nil
 }
 
@@ -2,6 +2,7 @@ package ejecutores
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"encoding/xml"
 	"errors"
@@ -38,7 +39,7 @@ type SOAPFault struct {
 }
 
 // EjecutarSOAP ejecuta una operación SOAP contra un servicio externo
-func EjecutarSOAP(nodo estructuras.NodoGenerico, resultado map[string]interface{}, servidor models.Servidor, procesoID string) (string, error) {
+func EjecutarSOAP(ctx context.Context, nodo estructuras.NodoGenerico, resultado map[string]interface{}, servidor models.Servidor, procesoID string) (string, error) {
 	// 🔍 Extraer configuración del nodo
 	objeto, ok := nodo.Data["objeto"].(string)
 	if !ok || objeto == "" {
@@ -122,8 +123,18 @@ func EjecutarSOAP(nodo estructuras.NodoGenerico, resultado map[string]interface{
 	// 📝 Log del XML generado
 	fmt.Printf("   📄 SOAP XML generado:\n%s\n", soapXML)
 
+	// 🕒 Timeout (nunca mayor al deadline de la ejecución)
+	timeoutDuration := 30 * time.Second // Timeout mayor para SOAP
+	if timeout != "" {
+		if tParsed, err := time.ParseDuration(timeout); err == nil {
+			timeoutDuration = tParsed
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeoutDuration)
+	defer cancel()
+
 	// 🧠 Preparar request HTTP
-	req, err := http.NewRequest("POST", serviceURL, bytes.NewReader([]byte(soapXML)))
+	req, err := http.NewRequestWithContext(ctx, "POST", serviceURL, bytes.NewReader([]byte(soapXML)))
 	if err != nil {
 		return "", fmt.Errorf("error creando request HTTP: %w", err)
 	}
@@ -161,18 +172,9 @@ func EjecutarSOAP(nodo estructuras.NodoGenerico, resultado map[string]interface{
 		}
 	}
 
-	// 🕒 Timeout
-	timeoutDuration := 30 * time.Second // Timeout mayor para SOAP
-	if timeout != "" {
-		if tParsed, err := time.ParseDuration(timeout); err == nil {
-			timeoutDuration = tParsed
-		}
-	}
-	client := &http.Client{Timeout: timeoutDuration}
-
 	// 🚀 Ejecutar petición SOAP
 	fmt.Printf("   🚀 Ejecutando POST a: %s\n", serviceURL)
-	resp, err := client.Do(req)
+	resp, err := clienteHTTP.Do(req)
 	if err != nil {
 		fmt.Printf("   ❌ Error en petición HTTP: %v\n", err)
 		return "", fmt.Errorf("error ejecutando petición SOAP: %w", err)
@@ -206,7 +208,7 @@ func EjecutarSOAP(nodo estructuras.NodoGenerico, resultado map[string]interface{
 		if err == nil {
 			fmt.Printf("   ❌ Fault Code: %s\n", fault.Code)
 			fmt.Printf("   ❌ Fault String: %s\n", fault.String)
-			return fullOutput, fmt.Errorf("SOAP Fault: %s - %s", fault.Code, fault.String)
+			return fullOutput, fault
 		}
 	}
 
@@ -217,7 +219,7 @@ func EjecutarSOAP(nodo estructuras.NodoGenerico, resultado map[string]interface{
 
 	// ✅ Verificar código HTTP
 	if resp.StatusCode != http.StatusOK {
-		return fullOutput, fmt.Errorf("HTTP error %d: %s", resp.StatusCode, resp.Status)
+		return fullOutput, &ErrorHTTP{Codigo: resp.StatusCode, Estado: resp.Status}
 	}
 
 	// 🧠 Si parsearFullOutput está activo → usar la misma lógica que REST
@@ -0,0 +1,70 @@
+package ejecutores
+
+import (
+	"backendmotor/internal/estructuras"
+	"backendmotor/internal/models"
+	"context"
+	"fmt"
+)
+
+// EjecutorSQLite atiende servidores sqlite, pensados para bases locales de desarrollo. SQLite no
+// tiene procedimientos almacenados: solo funciones (las propias del motor) y consultas
+type EjecutorSQLite struct {
+	servidor *models.Servidor
+	db       consultorSQL // el pool o, dentro de una transacción del flujo, el *sql.Tx
+}
+
+// NuevoEjecutorSQLite usa el pool compartido del servidor (ver pool_conexiones.go)
+func NuevoEjecutorSQLite(servidor *models.Servidor) (*EjecutorSQLite, error) {
+	db, err := PoolPara(*servidor)
+	if err != nil {
+		return nil, err
+	}
+	return &EjecutorSQLite{servidor: servidor, db: db}, nil
+}
+
+// EjecutarFuncionConParametros ejecuta SELECT nombre(?, ...) AS resultado
+func (e *EjecutorSQLite) EjecutarFuncionConParametros(ctx context.Context, nombre string, parametros []ParametroSQL) (string, error) {
+	return ejecutarFuncionEscalar(ctx, e.db, nombre, parametros)
+}
+
+// EjecutarConsulta ejecuta una sentencia escrita en el nodo con parámetros :nombre enlazados
+// como ? (ver consulta_sql.go)
+func (e *EjecutorSQLite) EjecutarConsulta(ctx context.Context, consulta string, valores map[string]interface{}, tipos map[string]string) (string, error) {
+	return ejecutarConsulta(ctx, e.db, consulta, valores, tipos, dialectoSQLite)
+}
+
+func EjecutarSQLite(ctx context.Context, n estructuras.NodoGenerico, resultado map[string]interface{}, servidor models.Servidor) (string, error) {
+	ejecutor, err := NuevoEjecutorSQLite(&servidor)
+	if err != nil {
+		return "", fmt.Errorf("error al abrir SQLite: %w", err)
+	}
+	if tx, ok := transaccionDe(ctx, servidor.ID); ok {
+		ejecutor.db = tx
+	}
+
+	objeto, _ := n.Data["objeto"].(string)
+	tipo, _ := n.Data["tipoObjeto"].(string)
+
+	// 🧾 Consulta escrita en el nodo: los :parametros se toman del resultado
+	if esConsultaSQL(tipo) {
+		consulta := ConsultaDelNodo(n)
+		if consulta == "" {
+			return "", fmt.Errorf("consulta no definida en el nodo")
+		}
+		return ejecutor.EjecutarConsulta(ctx, consulta, resultado, tiposParametros(n))
+	}
+
+	if objeto == "" || tipo == "" {
+		return "", fmt.Errorf("objeto o tipoObjeto no definidos en el nodo")
+	}
+
+	switch {
+	case esFuncionSQL(tipo):
+		// 🎆 Filtrar solo parámetros que deben enviarse al servidor, en su orden
+		return ejecutor.EjecutarFuncionConParametros(ctx, objeto, parametrosDelNodo(n, resultado, ModoParametrosPosicional))
+	case esProcedimientoSQL(tipo):
+		return "", fmt.Errorf("SQLite no tiene procedimientos almacenados: use tipoObjeto \"consulta\"")
+	}
+	return "", fmt.Errorf("tipo de objeto no soportado para SQLite: %s", tipo)
+}
@@ -0,0 +1,70 @@
+//go:build cgo
+
+package ejecutores
+
+import (
+	"backendmotor/internal/models"
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// sqliteDePrueba abre un servidor sqlite en memoria propio del test
+func sqliteDePrueba(t *testing.T) *EjecutorSQLite {
+	t.Helper()
+	servidor := &models.Servidor{ID: "sqlite-" + t.Name(), Tipo: "sqlite", Host: ":memory:"}
+	ejecutor, err := NuevoEjecutorSQLite(servidor)
+	if err != nil {
+		t.Fatalf("error abriendo sqlite: %v", err)
+	}
+	t.Cleanup(func() { CerrarPool(servidor.ID) })
+	return ejecutor
+}
+
+func TestEjecutorSQLiteEnlazaSegunTipo(t *testing.T) {
+	ejecutor := sqliteDePrueba(t)
+
+	valores := map[string]interface{}{
+		"cliente":   map[string]interface{}{"nombre": "Ana"},
+		"etiquetas": []interface{}{"a", "b"},
+		"lista":     []interface{}{"a", "b"},
+		"monto":     10.5,
+	}
+	tipos := map[string]string{"cliente": "json", "etiquetas": "array", "lista": "json", "monto": "numeric"}
+
+	salida, err := ejecutor.EjecutarConsulta(context.Background(),
+		"SELECT json_extract(:cliente, '$.nombre') AS nombre, :etiquetas AS etiquetas, json_array_length(:lista) AS largo, :monto * 2 AS doble",
+		valores, tipos)
+	if err != nil {
+		t.Fatalf("error inesperado: %v (%s)", err, salida)
+	}
+
+	var decodificada struct {
+		Filas []map[string]interface{} `json:"filas"`
+	}
+	if err := json.Unmarshal([]byte(salida), &decodificada); err != nil || len(decodificada.Filas) != 1 {
+		t.Fatalf("salida inesperada: %s", salida)
+	}
+	esperado := map[string]interface{}{"nombre": "Ana", "etiquetas": `{"a","b"}`, "largo": 2.0, "doble": 21.0}
+	if !reflect.DeepEqual(decodificada.Filas[0], esperado) {
+		t.Fatalf("se esperaba %v y se obtuvo %v", esperado, decodificada.Filas[0])
+	}
+}
+
+func TestEjecutorSQLiteFuncionEnlazaSegunTipo(t *testing.T) {
+	ejecutor := sqliteDePrueba(t)
+
+	salida, err := ejecutor.EjecutarFuncionConParametros(context.Background(), "json_array_length", []ParametroSQL{
+		{Nombre: "lista", Tipo: "json", Valor: []interface{}{1.0, 2.0, 3.0}},
+	})
+	if err != nil {
+		t.Fatalf("error inesperado: %v (%s)", err, salida)
+	}
+	var decodificada struct {
+		Resultado interface{} `json:"resultado"`
+	}
+	if err := json.Unmarshal([]byte(salida), &decodificada); err != nil || decodificada.Resultado != 3.0 {
+		t.Fatalf("se esperaba resultado 3 y se obtuvo %s", salida)
+	}
+}
@@ -0,0 +1,19 @@
+package ejecutores
+
+import "fmt"
+
+// ErrorHTTP indica que el servidor respondió con un código HTTP de error; el motor lo usa
+// para decidir si la llamada se puede reintentar (5xx, 429)
+type ErrorHTTP struct {
+	Codigo int
+	Estado string
+}
+
+func (e *ErrorHTTP) Error() string {
+	return fmt.Sprintf("HTTP error %d: %s", e.Codigo, e.Estado)
+}
+
+// Error permite devolver el SOAP Fault como error y consultar su faultcode con errors.As
+func (f *SOAPFault) Error() string {
+	return fmt.Sprintf("SOAP Fault: %s - %s", f.Code, f.String)
+}
@@ -0,0 +1,194 @@
+package ejecutores
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// leerFilas recorre el resultado completo y devuelve cada fila como objeto columna → valor, con
+// el tipo de la columna conservado (números, fechas, json, arreglos) en lugar de texto
+func leerFilas(rows *sql.Rows) ([]map[string]interface{}, []string, error) {
+	columnas, err := rows.Columns()
+	if err != nil {
+		return nil, nil, err
+	}
+	tipos, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	filas := []map[string]interface{}{}
+	for rows.Next() {
+		valores := make([]interface{}, len(columnas))
+		destinos := make([]interface{}, len(columnas))
+		for i := range valores {
+			destinos[i] = &valores[i]
+		}
+		if err := rows.Scan(destinos...); err != nil {
+			return nil, nil, err
+		}
+
+		fila := make(map[string]interface{}, len(columnas))
+		for i, columna := range columnas {
+			fila[columna] = convertirValorSQL(tipos[i].DatabaseTypeName(), valores[i])
+		}
+		filas = append(filas, fila)
+	}
+	return filas, columnas, rows.Err()
+}
+
+// convertirValorSQL pasa lo que entrega el driver (muchas veces []byte) al tipo que corresponde
+// según la columna. Lo que no se reconoce queda como texto
+func convertirValorSQL(tipoBD string, v interface{}) interface{} {
+	crudo, esBytes := v.([]byte)
+	if !esBytes {
+		// int64, float64, bool, time.Time, string o nil ya vienen con su tipo
+		return v
+	}
+	texto := string(crudo)
+	tipoBD = strings.TrimPrefix(strings.ToUpper(tipoBD), "UNSIGNED ")
+
+	switch tipoBD {
+	case "INT", "INTEGER", "TINYINT", "SMALLINT", "MEDIUMINT", "BIGINT", "YEAR":
+		// MySQL sin parámetros (protocolo de texto) entrega los enteros como texto
+		if entero, err := strconv.ParseInt(texto, 10, 64); err == nil {
+			return entero
+		}
+		return json.Number(texto)
+	case "FLOAT", "DOUBLE", "REAL":
+		if decimal, err := strconv.ParseFloat(texto, 64); err == nil {
+			return decimal
+		}
+		return texto
+	case "NUMERIC", "DECIMAL":
+		// json.Number conserva todos los decimales en el FullOutput
+		return json.Number(texto)
+	case "JSON", "JSONB":
+		var valor interface{}
+		if err := json.Unmarshal(crudo, &valor); err == nil {
+			return valor
+		}
+		return texto
+	case "BYTEA", "BLOB", "TINYBLOB", "MEDIUMBLOB", "LONGBLOB", "BINARY", "VARBINARY":
+		return crudo
+	}
+
+	// Arreglos de PostgreSQL: el driver informa _INT4, _TEXT, _NUMERIC...
+	if strings.HasPrefix(tipoBD, "_") {
+		if arreglo, ok := convertirArregloPG(strings.TrimPrefix(tipoBD, "_"), crudo); ok {
+			return arreglo
+		}
+	}
+	return texto
+}
+
+// convertirArregloPG decodifica arreglos de una dimensión; los multidimensionales quedan como texto
+func convertirArregloPG(tipoElemento string, crudo []byte) (interface{}, bool) {
+	switch tipoElemento {
+	case "INT2", "INT4", "INT8":
+		var a pq.Int64Array
+		if err := a.Scan(crudo); err != nil {
+			return nil, false
+		}
+		return []int64(a), true
+	case "FLOAT4", "FLOAT8", "NUMERIC":
+		var a pq.Float64Array
+		if err := a.Scan(crudo); err != nil {
+			return nil, false
+		}
+		return []float64(a), true
+	case "BOOL":
+		var a pq.BoolArray
+		if err := a.Scan(crudo); err != nil {
+			return nil, false
+		}
+		return []bool(a), true
+	case "TIMESTAMP", "TIMESTAMPTZ", "DATE":
+		var a pq.StringArray
+		if err := a.Scan(crudo); err != nil {
+			return nil, false
+		}
+		fechas := make([]interface{}, len(a))
+		for i, s := range a {
+			fechas[i] = s
+			for _, formato := range []string{"2006-01-02 15:04:05.999999999-07", "2006-01-02 15:04:05.999999999", "2006-01-02"} {
+				if f, err := time.Parse(formato, s); err == nil {
+					fechas[i] = f
+					break
+				}
+			}
+		}
+		return fechas, true
+	case "JSON", "JSONB":
+		var a pq.StringArray
+		if err := a.Scan(crudo); err != nil {
+			return nil, false
+		}
+		objetos := make([]interface{}, len(a))
+		for i, s := range a {
+			var valor interface{}
+			if err := json.Unmarshal([]byte(s), &valor); err != nil {
+				valor = s
+			}
+			objetos[i] = valor
+		}
+		return objetos, true
+	}
+
+	var a pq.StringArray
+	if err := a.Scan(crudo); err != nil {
+		return nil, false
+	}
+	return []string(a), true
+}
+
+// valorParametroSQL prepara un valor del flujo para enviarlo como parámetro: objetos y listas
+// viajan como JSON, salvo que el parámetro sea de tipo array (se envía como arreglo de PostgreSQL)
+func valorParametroSQL(tipo string, v interface{}) interface{} {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		b, _ := json.Marshal(x)
+		return string(b)
+	case []interface{}:
+		if strings.EqualFold(tipo, "array") {
+			elementos := make([]string, len(x))
+			for i, e := range x {
+				elementos[i] = textoSQL(e)
+			}
+			return pq.StringArray(elementos)
+		}
+		b, _ := json.Marshal(x)
+		return string(b)
+	}
+	return v
+}
+
+func textoSQL(v interface{}) string {
+	switch x := v.(type) {
+	case string:
+		return x
+	case nil:
+		return ""
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// esIdentificadorSQL acepta solo nombres simples (letras, dígitos, _), para los parámetros nombrados
+func esIdentificadorSQL(nombre string) bool {
+	if nombre == "" {
+		return false
+	}
+	for i, c := range nombre {
+		letra := c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+		if !letra && (i == 0 || c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,88 @@
+package ejecutores
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+func TestValorParametroSQL(t *testing.T) {
+	casos := []struct {
+		nombre   string
+		tipo     string
+		valor    interface{}
+		esperado interface{}
+	}{
+		{"objeto como JSON", "json", map[string]interface{}{"a": 1.0}, `{"a":1}`},
+		{"objeto sin tipo", "", map[string]interface{}{"a": "x"}, `{"a":"x"}`},
+		{"lista de tipo array", "array", []interface{}{"a", 2.0, nil}, pq.StringArray{"a", "2", ""}},
+		{"tipo array sin distinguir mayúsculas", "ARRAY", []interface{}{"a"}, pq.StringArray{"a"}},
+		{"lista de tipo json", "json", []interface{}{1.0, "b"}, `[1,"b"]`},
+		{"lista sin tipo", "", []interface{}{1.0}, `[1]`},
+		{"escalar tal cual", "integer", 5.0, 5.0},
+		{"texto tal cual", "array", "x", "x"},
+		{"nulo", "json", nil, nil},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			if obtenido := valorParametroSQL(c.tipo, c.valor); !reflect.DeepEqual(obtenido, c.esperado) {
+				t.Fatalf("se esperaba %#v y se obtuvo %#v", c.esperado, obtenido)
+			}
+		})
+	}
+}
+
+func TestConvertirValorSQL(t *testing.T) {
+	casos := []struct {
+		nombre   string
+		tipoBD   string
+		valor    interface{}
+		esperado interface{}
+	}{
+		{"entero del driver tal cual", "INT8", int64(7), int64(7)},
+		{"nulo", "TEXT", nil, nil},
+		{"entero como texto (MySQL)", "INT", []byte("42"), int64(42)},
+		{"entero sin signo que no entra en int64", "UNSIGNED BIGINT", []byte("18446744073709551615"), json.Number("18446744073709551615")},
+		{"decimal binario", "DOUBLE", []byte("1.5"), 1.5},
+		{"numeric conserva los dígitos", "NUMERIC", []byte("12345678901234567890.123"), json.Number("12345678901234567890.123")},
+		{"jsonb", "JSONB", []byte(`{"a":[1,2]}`), map[string]interface{}{"a": []interface{}{1.0, 2.0}}},
+		{"json inválido queda como texto", "JSON", []byte("{no"), "{no"},
+		{"bytea", "BYTEA", []byte{0, 1}, []byte{0, 1}},
+		{"arreglo de enteros", "_INT4", []byte("{1,2,3}"), []int64{1, 2, 3}},
+		{"arreglo numeric", "_NUMERIC", []byte("{1.5,2}"), []float64{1.5, 2}},
+		{"arreglo de booleanos", "_BOOL", []byte("{t,f}"), []bool{true, false}},
+		{"arreglo de texto", "_TEXT", []byte(`{a,"b c"}`), []string{"a", "b c"}},
+		{"arreglo jsonb", "_JSONB", []byte(`{"{\"a\":1}","x"}`), []interface{}{map[string]interface{}{"a": 1.0}, "x"}},
+		{"arreglo multidimensional queda como texto", "_INT4", []byte("{{1,2},{3,4}}"), "{{1,2},{3,4}}"},
+		{"tipo desconocido queda como texto", "VARCHAR", []byte("hola"), "hola"},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			if obtenido := convertirValorSQL(c.tipoBD, c.valor); !reflect.DeepEqual(obtenido, c.esperado) {
+				t.Fatalf("se esperaba %#v y se obtuvo %#v", c.esperado, obtenido)
+			}
+		})
+	}
+}
+
+func TestConvertirValorSQLArregloDeFechas(t *testing.T) {
+	obtenido, ok := convertirValorSQL("_TIMESTAMPTZ", []byte(`{"2024-01-02 03:04:05+00","2024-01-02","mañana"}`)).([]interface{})
+	if !ok || len(obtenido) != 3 {
+		t.Fatalf("se esperaban tres elementos y se obtuvo %#v", obtenido)
+	}
+	if f, ok := obtenido[0].(time.Time); !ok || !f.Equal(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Fatalf("se esperaba la fecha con zona y se obtuvo %#v", obtenido[0])
+	}
+	if f, ok := obtenido[1].(time.Time); !ok || f.Format(time.DateOnly) != "2024-01-02" {
+		t.Fatalf("se esperaba la fecha sin hora y se obtuvo %#v", obtenido[1])
+	}
+	// Lo que no es una fecha queda como texto
+	if obtenido[2] != "mañana" {
+		t.Fatalf("se esperaba el texto tal cual y se obtuvo %#v", obtenido[2])
+	}
+}
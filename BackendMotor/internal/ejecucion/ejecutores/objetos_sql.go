@@ -0,0 +1,97 @@
+package ejecutores
+
+import (
+	"backendmotor/internal/estructuras"
+	"encoding/json"
+	"strings"
+)
+
+// Lo que comparten los ejecutores de bases de datos (PostgreSQL, MySQL, SQLite): qué objeto
+// pide el nodo y con qué parámetros, y cómo vuelven los OUT / INOUT al resultado
+
+func esFuncionSQL(tipo string) bool {
+	switch strings.ToLower(tipo) {
+	case "funcion", "función", "plpgsql_function", "mysql_function":
+		return true
+	}
+	return false
+}
+
+func esProcedimientoSQL(tipo string) bool {
+	switch strings.ToLower(tipo) {
+	case "procedimiento", "plpgsql_procedure", "mysql_procedure":
+		return true
+	}
+	return false
+}
+
+func esConsultaSQL(tipo string) bool {
+	switch strings.ToLower(tipo) {
+	case "consulta", "sql":
+		return true
+	}
+	return false
+}
+
+// ConsultaDelNodo es el SQL de un nodo con tipoObjeto "consulta": Data["consulta"] o, si no está,
+// Data["objeto"]
+func ConsultaDelNodo(n estructuras.NodoGenerico) string {
+	for _, clave := range []string{"consulta", "objeto"} {
+		if consulta, ok := n.Data[clave].(string); ok && strings.TrimSpace(consulta) != "" {
+			return strings.TrimSpace(consulta)
+		}
+	}
+	return ""
+}
+
+// parametrosDelNodo toma los parametrosEntrada que se envían al servidor (enviarAServidor, orden)
+// con su valor del resultado. Por nombre se omiten los que faltan (el servidor usa su DEFAULT);
+// por posición se envía NULL para no correr los demás
+func parametrosDelNodo(n estructuras.NodoGenerico, resultado map[string]interface{}, modo string) []ParametroSQL {
+	filtrados := getParametrosFiltradosYOrdenados(n)
+	parametros := make([]ParametroSQL, 0, len(filtrados))
+	for _, param := range filtrados {
+		val, existe := resultado[param.Nombre]
+		if !existe && modo == ModoParametrosNombrado && direccionParametro(param.Direccion) != "out" {
+			continue
+		}
+		parametros = append(parametros, ParametroSQL{Nombre: param.Nombre, Tipo: param.Tipo, Valor: val, Direccion: param.Direccion})
+	}
+	return parametros
+}
+
+// tiposParametros relaciona cada parámetro de entrada con su tipo, para enlazar los de una consulta
+func tiposParametros(n estructuras.NodoGenerico) map[string]string {
+	tipos := make(map[string]string)
+	for _, param := range getParametrosFiltradosYOrdenados(n) {
+		tipos[param.Nombre] = param.Tipo
+	}
+	return tipos
+}
+
+// devolverSalidas copia al resultado los parámetros OUT / INOUT: de "salida" si el ejecutor la
+// armó o, si no, de la única fila devuelta
+func devolverSalidas(fullOutput string, parametros []ParametroSQL, resultado map[string]interface{}) {
+	var decodificada struct {
+		Filas  []map[string]interface{} `json:"filas"`
+		Salida map[string]interface{}   `json:"salida"`
+	}
+	if json.Unmarshal([]byte(fullOutput), &decodificada) != nil {
+		return
+	}
+	valores := decodificada.Salida
+	if valores == nil && len(decodificada.Filas) == 1 {
+		valores = decodificada.Filas[0]
+	}
+	if valores == nil {
+		return
+	}
+	for _, param := range parametros {
+		if direccionParametro(param.Direccion) == "in" {
+			continue
+		}
+		if val, ok := valores[param.Nombre]; ok {
+			resultado[param.Nombre] = val
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package ejecutores
+
+import (
+	"backendmotor/internal/estructuras"
+	"reflect"
+	"testing"
+)
+
+func TestDevolverSalidas(t *testing.T) {
+	parametros := []ParametroSQL{
+		{Nombre: "id", Direccion: "in"},
+		{Nombre: "total", Direccion: "out"},
+		{Nombre: "saldo", Direccion: "entradaSalida"},
+	}
+
+	casos := []struct {
+		nombre     string
+		fullOutput string
+		esperado   map[string]interface{}
+	}{
+		{
+			nombre:     "salida armada por el ejecutor",
+			fullOutput: `{"filas":[{"total":1},{"total":2}],"salida":{"id":99,"total":10,"saldo":5}}`,
+			esperado:   map[string]interface{}{"id": 1.0, "total": 10.0, "saldo": 5.0},
+		},
+		{
+			nombre:     "única fila devuelta",
+			fullOutput: `{"filas":[{"total":10,"saldo":5,"otra":"x"}]}`,
+			esperado:   map[string]interface{}{"id": 1.0, "total": 10.0, "saldo": 5.0},
+		},
+		{
+			nombre:     "varias filas sin salida",
+			fullOutput: `{"filas":[{"total":1},{"total":2}]}`,
+			esperado:   map[string]interface{}{"id": 1.0, "saldo": 0.0},
+		},
+		{
+			nombre:     "salida sin uno de los parámetros",
+			fullOutput: `{"salida":{"total":10}}`,
+			esperado:   map[string]interface{}{"id": 1.0, "total": 10.0, "saldo": 0.0},
+		},
+		{
+			nombre:     "FullOutput que no es JSON",
+			fullOutput: `error`,
+			esperado:   map[string]interface{}{"id": 1.0, "saldo": 0.0},
+		},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			// Los parámetros de entrada no se pisan aunque vuelvan en la salida
+			resultado := map[string]interface{}{"id": 1.0, "saldo": 0.0}
+			devolverSalidas(c.fullOutput, parametros, resultado)
+			if !reflect.DeepEqual(resultado, c.esperado) {
+				t.Fatalf("se esperaba %v y se obtuvo %v", c.esperado, resultado)
+			}
+		})
+	}
+}
+
+func TestParametrosDelNodo(t *testing.T) {
+	n := estructuras.NodoGenerico{ID: "p", Type: "proceso", Data: map[string]interface{}{
+		"parametrosEntrada": []interface{}{
+			map[string]interface{}{"nombre": "b", "tipo": "integer", "orden": 2.0},
+			map[string]interface{}{"nombre": "a", "tipo": "text", "orden": 1.0},
+			map[string]interface{}{"nombre": "falta", "orden": 3.0},
+			map[string]interface{}{"nombre": "total", "direccion": "out", "orden": 4.0},
+			map[string]interface{}{"nombre": "interno", "enviarAServidor": false},
+		},
+	}}
+	resultado := map[string]interface{}{"a": "x", "b": 2.0, "interno": true}
+
+	casos := []struct {
+		modo     string
+		esperado []ParametroSQL
+	}{
+		{
+			// Por posición el que falta va como NULL para no correr los siguientes
+			modo: ModoParametrosPosicional,
+			esperado: []ParametroSQL{
+				{Nombre: "a", Tipo: "text", Valor: "x"},
+				{Nombre: "b", Tipo: "integer", Valor: 2.0},
+				{Nombre: "falta"},
+				{Nombre: "total", Direccion: "out"},
+			},
+		},
+		{
+			// Por nombre se omite y el servidor usa su DEFAULT; los OUT siempre van
+			modo: ModoParametrosNombrado,
+			esperado: []ParametroSQL{
+				{Nombre: "a", Tipo: "text", Valor: "x"},
+				{Nombre: "b", Tipo: "integer", Valor: 2.0},
+				{Nombre: "total", Direccion: "out"},
+			},
+		},
+	}
+
+	for _, c := range casos {
+		t.Run(c.modo, func(t *testing.T) {
+			if obtenidos := parametrosDelNodo(n, resultado, c.modo); !reflect.DeepEqual(obtenidos, c.esperado) {
+				t.Fatalf("se esperaba %+v y se obtuvo %+v", c.esperado, obtenidos)
+			}
+		})
+	}
+}
@@ -0,0 +1,250 @@
+package ejecutores
+
+import (
+	"backendmotor/internal/models"
+	"backendmotor/internal/monitoring"
+	"backendmotor/internal/utils"
+	"database/sql"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// Cada servidor de base de datos tiene un único *sql.DB compartido por todas las ejecuciones.
+// Se arma la primera vez que se usa y se vuelve a armar si cambia la conexión o los límites
+// (el CRUD de servidores llama a ReconstruirPool / CerrarPool)
+
+// graciaCierrePool es lo que se espera antes de cerrar un pool reemplazado, para que terminen
+// las consultas que ya lo habían tomado
+const graciaCierrePool = 30 * time.Second
+
+// configPool se lee de Servidor.Extras["pool"]; sslmode, tls, dbname y archivo van al primer
+// nivel de Extras
+type configPool struct {
+	MaxAbiertas       int
+	MaxInactivas      int
+	VidaMaxima        time.Duration
+	InactividadMaxima time.Duration
+}
+
+func leerConfigPool(servidor models.Servidor) configPool {
+	cfg := configPool{
+		MaxAbiertas:       10,
+		MaxInactivas:      5,
+		VidaMaxima:        30 * time.Minute,
+		InactividadMaxima: 5 * time.Minute,
+	}
+	extras, ok := servidor.Extras["pool"].(map[string]interface{})
+	if !ok {
+		return cfg
+	}
+	if v, ok := utils.NumeroConfig(extras["maxAbiertas"]); ok && v >= 1 {
+		cfg.MaxAbiertas = int(v)
+	}
+	if v, ok := utils.NumeroConfig(extras["maxInactivas"]); ok && v >= 0 {
+		cfg.MaxInactivas = int(v)
+	}
+	if v, ok := utils.NumeroConfig(extras["vidaMaximaMs"]); ok && v >= 0 {
+		cfg.VidaMaxima = time.Duration(v) * time.Millisecond
+	}
+	if v, ok := utils.NumeroConfig(extras["inactividadMaximaMs"]); ok && v >= 0 {
+		cfg.InactividadMaxima = time.Duration(v) * time.Millisecond
+	}
+	if cfg.MaxInactivas > cfg.MaxAbiertas {
+		cfg.MaxInactivas = cfg.MaxAbiertas
+	}
+	return cfg
+}
+
+type poolServidor struct {
+	db    *sql.DB
+	firma string // driver + dsn + límites: si cambia, el pool se rearma
+	cfg   configPool
+}
+
+var pools = struct {
+	sync.Mutex
+	porServidor map[string]*poolServidor
+}{porServidor: make(map[string]*poolServidor)}
+
+var metricasPools sync.Once
+
+// PoolPara devuelve el pool de conexiones del servidor, creándolo si hace falta
+func PoolPara(servidor models.Servidor) (*sql.DB, error) {
+	driver, dsn, err := conexionServidor(servidor)
+	if err != nil {
+		return nil, err
+	}
+	cfg := leerConfigPool(servidor)
+	firma := fmt.Sprintf("%s|%s|%+v", driver, dsn, cfg)
+
+	pools.Lock()
+	defer pools.Unlock()
+
+	if p, ok := pools.porServidor[servidor.ID]; ok {
+		if p.firma == firma {
+			return p.db, nil
+		}
+		// La configuración cambió por fuera del CRUD (por ejemplo, directo en la tabla)
+		cerrarConGracia(servidor.ID, p.db)
+	}
+
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(cfg.MaxAbiertas)
+	db.SetMaxIdleConns(cfg.MaxInactivas)
+	db.SetConnMaxLifetime(cfg.VidaMaxima)
+	db.SetConnMaxIdleTime(cfg.InactividadMaxima)
+
+	pools.porServidor[servidor.ID] = &poolServidor{db: db, firma: firma, cfg: cfg}
+	monitoring.PoolConexionesMaximas.WithLabelValues(servidor.ID).Set(float64(cfg.MaxAbiertas))
+	fmt.Printf("🏊 Pool de conexiones creado para el servidor %s (%s, máx %d abiertas)\n", servidor.ID, driver, cfg.MaxAbiertas)
+
+	metricasPools.Do(func() { go refrescarMetricasPools() })
+	return db, nil
+}
+
+// ReconstruirPool descarta el pool del servidor y arma uno nuevo con la configuración actual;
+// si el servidor ya no es de base de datos solo lo cierra
+func ReconstruirPool(servidor models.Servidor) error {
+	CerrarPool(servidor.ID)
+	if !EsServidorSQL(servidor.Tipo) {
+		return nil
+	}
+	_, err := PoolPara(servidor)
+	return err
+}
+
+// CerrarPool cierra el pool del servidor (al eliminarlo o cambiarlo); no hace nada si no existe
+func CerrarPool(servidorID string) {
+	pools.Lock()
+	defer pools.Unlock()
+	if p, ok := pools.porServidor[servidorID]; ok {
+		cerrarConGracia(servidorID, p.db)
+	}
+}
+
+// cerrarConGracia saca el pool del registro y lo cierra después de graciaCierrePool; se llama
+// con pools bloqueado
+func cerrarConGracia(servidorID string, db *sql.DB) {
+	delete(pools.porServidor, servidorID)
+	borrarMetricasPool(servidorID)
+	time.AfterFunc(graciaCierrePool, func() {
+		if err := db.Close(); err != nil {
+			fmt.Printf("⚠️ Error cerrando el pool del servidor %s: %v\n", servidorID, err)
+		}
+	})
+	fmt.Printf("🏊 Pool de conexiones del servidor %s descartado\n", servidorID)
+}
+
+// EsServidorSQL indica si el tipo de servidor se atiende con un pool de database/sql
+func EsServidorSQL(tipo string) bool {
+	_, ok := driversSQL[strings.ToLower(tipo)]
+	return ok
+}
+
+// driversSQL relaciona el tipo de servidor con su driver de database/sql
+var driversSQL = map[string]string{
+	"postgresql": "postgres",
+	"mysql":      "mysql",
+	"mariadb":    "mysql",
+	"sqlite":     "sqlite3",
+}
+
+// conexionServidor arma el driver y el DSN según el tipo de servidor
+func conexionServidor(servidor models.Servidor) (string, string, error) {
+	driver, ok := driversSQL[strings.ToLower(servidor.Tipo)]
+	if !ok {
+		return "", "", fmt.Errorf("el servidor %s (%s) no es una base de datos", servidor.ID, servidor.Tipo)
+	}
+
+	switch driver {
+	case "postgres":
+		sslmode, _ := servidor.Extras["sslmode"].(string)
+		if sslmode == "" {
+			sslmode = "disable"
+		}
+		partes := []string{
+			"host=" + valorDSN(servidor.Host),
+			"port=" + strconv.FormatInt(servidor.Puerto, 10),
+			"user=" + valorDSN(servidor.Usuario),
+			"password=" + valorDSN(servidor.Clave),
+			"dbname=" + valorDSN(fmt.Sprint(servidor.Extras["dbname"])),
+			"sslmode=" + valorDSN(sslmode),
+		}
+		return driver, strings.Join(partes, " "), nil
+
+	case "mysql":
+		cfg := mysql.NewConfig()
+		cfg.User = servidor.Usuario
+		cfg.Passwd = servidor.Clave
+		cfg.Net = "tcp"
+		cfg.Addr = net.JoinHostPort(servidor.Host, strconv.FormatInt(servidor.Puerto, 10))
+		cfg.DBName, _ = servidor.Extras["dbname"].(string)
+		cfg.ParseTime = true
+		if tls, ok := servidor.Extras["tls"].(string); ok && tls != "" {
+			cfg.TLSConfig = tls
+		}
+		return driver, cfg.FormatDSN(), nil
+
+	case "sqlite3":
+		if !sqliteDisponible {
+			return "", "", fmt.Errorf("el servidor %s es sqlite, pero el motor se compiló sin cgo (CGO_ENABLED=0) y no incluye su driver", servidor.ID)
+		}
+		// El archivo va en Extras["archivo"] o, si no está, en Host
+		archivo, _ := servidor.Extras["archivo"].(string)
+		if archivo == "" {
+			archivo = servidor.Host
+		}
+		if archivo == "" {
+			return "", "", fmt.Errorf("el servidor %s (sqlite) no indica el archivo de la base", servidor.ID)
+		}
+		if archivo == ":memory:" {
+			// Cada conexión del pool abriría una base vacía distinta si no se comparte la caché
+			return driver, "file::memory:?cache=shared&_foreign_keys=on", nil
+		}
+		return driver, "file:" + archivo + "?_foreign_keys=on&_busy_timeout=5000", nil
+	}
+	return "", "", fmt.Errorf("driver no soportado: %s", driver)
+}
+
+// valorDSN pone entre comillas simples los valores con espacios, comillas o barras
+func valorDSN(v string) string {
+	if v != "" && !strings.ContainsAny(v, ` '\`) {
+		return v
+	}
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, `'`, `\'`)
+	return "'" + v + "'"
+}
+
+// refrescarMetricasPools publica el estado de cada pool cada pocos segundos
+func refrescarMetricasPools() {
+	for {
+		pools.Lock()
+		for id, p := range pools.porServidor {
+			stats := p.db.Stats()
+			monitoring.PoolConexionesAbiertas.WithLabelValues(id).Set(float64(stats.OpenConnections))
+			monitoring.PoolConexionesEnUso.WithLabelValues(id).Set(float64(stats.InUse))
+			monitoring.PoolConexionesInactivas.WithLabelValues(id).Set(float64(stats.Idle))
+			monitoring.PoolEsperas.WithLabelValues(id).Set(float64(stats.WaitCount))
+		}
+		pools.Unlock()
+		time.Sleep(5 * time.Second)
+	}
+}
+
+func borrarMetricasPool(servidorID string) {
+	monitoring.PoolConexionesAbiertas.DeleteLabelValues(servidorID)
+	monitoring.PoolConexionesEnUso.DeleteLabelValues(servidorID)
+	monitoring.PoolConexionesInactivas.DeleteLabelValues(servidorID)
+	monitoring.PoolConexionesMaximas.DeleteLabelValues(servidorID)
+	monitoring.PoolEsperas.DeleteLabelValues(servidorID)
+}
@@ -0,0 +1,181 @@
+package ejecutores
+
+import (
+	"backendmotor/internal/models"
+	"testing"
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// servidorPostgreSQLDePrueba arma un servidor propio del test; sql.Open no se conecta, así que
+// el pool se arma sin que haya un PostgreSQL escuchando
+func servidorPostgreSQLDePrueba(t *testing.T) models.Servidor {
+	t.Helper()
+	servidor := models.Servidor{ID: "pg-" + t.Name(), Tipo: "postgresql", Host: "localhost", Puerto: 5432, Usuario: "motor"}
+	t.Cleanup(func() { CerrarPool(servidor.ID) })
+	return servidor
+}
+
+func poolRegistrado(servidorID string) *poolServidor {
+	pools.Lock()
+	defer pools.Unlock()
+	return pools.porServidor[servidorID]
+}
+
+func TestPoolParaReutilizaPorServidor(t *testing.T) {
+	servidor := servidorPostgreSQLDePrueba(t)
+	primero, err := PoolPara(servidor)
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if segundo, _ := PoolPara(servidor); segundo != primero {
+		t.Fatalf("con la misma configuración se esperaba el mismo pool")
+	}
+
+	// Otro servidor con la misma conexión tiene su propio pool
+	otro := servidor
+	otro.ID += "-otro"
+	t.Cleanup(func() { CerrarPool(otro.ID) })
+	if delOtro, _ := PoolPara(otro); delOtro == primero {
+		t.Fatalf("cada servidor debía tener su propio pool")
+	}
+}
+
+func TestPoolParaSeRearmaAlCambiarLaConfiguracion(t *testing.T) {
+	casos := []struct {
+		nombre  string
+		cambiar func(s *models.Servidor)
+	}{
+		{"host", func(s *models.Servidor) { s.Host = "otro-host" }},
+		{"clave", func(s *models.Servidor) { s.Clave = "nueva" }},
+		{"base", func(s *models.Servidor) { s.Extras = datatypes.JSONMap{"dbname": "ventas"} }},
+		{"límites del pool", func(s *models.Servidor) {
+			s.Extras = datatypes.JSONMap{"pool": map[string]interface{}{"maxAbiertas": 3.0}}
+		}},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			servidor := servidorPostgreSQLDePrueba(t)
+			anterior, err := PoolPara(servidor)
+			if err != nil {
+				t.Fatalf("error inesperado: %v", err)
+			}
+
+			c.cambiar(&servidor)
+			nuevo, err := PoolPara(servidor)
+			if err != nil {
+				t.Fatalf("error inesperado: %v", err)
+			}
+			if nuevo == anterior {
+				t.Fatalf("al cambiar %s se esperaba un pool nuevo", c.nombre)
+			}
+			if p := poolRegistrado(servidor.ID); p == nil || p.db != nuevo {
+				t.Fatalf("el registro debía quedar con el pool nuevo")
+			}
+			if maximas := nuevo.Stats().MaxOpenConnections; maximas != leerConfigPool(servidor).MaxAbiertas {
+				t.Fatalf("el pool nuevo debía tomar los límites actuales y permite %d conexiones", maximas)
+			}
+		})
+	}
+}
+
+func TestReconstruirYCerrarPool(t *testing.T) {
+	servidor := servidorPostgreSQLDePrueba(t)
+	anterior, _ := PoolPara(servidor)
+
+	// Reconstruir arma otro pool aunque la configuración no haya cambiado
+	if err := ReconstruirPool(servidor); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	p := poolRegistrado(servidor.ID)
+	if p == nil || p.db == anterior {
+		t.Fatalf("se esperaba un pool nuevo después de ReconstruirPool")
+	}
+
+	// Si el servidor deja de ser una base de datos, solo se cierra
+	servidor.Tipo = "rest"
+	if err := ReconstruirPool(servidor); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if poolRegistrado(servidor.ID) != nil {
+		t.Fatalf("un servidor rest no debía quedar con pool")
+	}
+
+	servidor.Tipo = "postgresql"
+	PoolPara(servidor)
+	CerrarPool(servidor.ID)
+	if poolRegistrado(servidor.ID) != nil {
+		t.Fatalf("CerrarPool debía sacar el pool del registro")
+	}
+}
+
+func TestLeerConfigPool(t *testing.T) {
+	porDefecto := configPool{MaxAbiertas: 10, MaxInactivas: 5, VidaMaxima: 30 * time.Minute, InactividadMaxima: 5 * time.Minute}
+	casos := []struct {
+		nombre   string
+		pool     interface{}
+		esperado configPool
+	}{
+		{"sin configuración", nil, porDefecto},
+		{
+			"todos los límites",
+			map[string]interface{}{"maxAbiertas": 20.0, "maxInactivas": "4", "vidaMaximaMs": 60000.0, "inactividadMaximaMs": 0.0},
+			configPool{MaxAbiertas: 20, MaxInactivas: 4, VidaMaxima: time.Minute, InactividadMaxima: 0},
+		},
+		{
+			"inactivas no superan a las abiertas",
+			map[string]interface{}{"maxAbiertas": 2.0},
+			configPool{MaxAbiertas: 2, MaxInactivas: 2, VidaMaxima: 30 * time.Minute, InactividadMaxima: 5 * time.Minute},
+		},
+		{"valores inválidos se ignoran", map[string]interface{}{"maxAbiertas": 0.0, "maxInactivas": -1.0, "vidaMaximaMs": "x"}, porDefecto},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			servidor := models.Servidor{}
+			if c.pool != nil {
+				servidor.Extras = datatypes.JSONMap{"pool": c.pool}
+			}
+			if obtenido := leerConfigPool(servidor); obtenido != c.esperado {
+				t.Fatalf("se esperaba %+v y se obtuvo %+v", c.esperado, obtenido)
+			}
+		})
+	}
+}
+
+func TestConexionServidor(t *testing.T) {
+	casos := []struct {
+		nombre   string
+		servidor models.Servidor
+		driver   string
+		dsn      string
+	}{
+		{
+			"postgresql con valores a escapar",
+			models.Servidor{Tipo: "postgresql", Host: "db", Puerto: 5432, Usuario: "motor", Clave: `a b'c`, Extras: datatypes.JSONMap{"dbname": "ventas", "sslmode": "require"}},
+			"postgres",
+			`host=db port=5432 user=motor password='a b\'c' dbname=ventas sslmode=require`,
+		},
+		{
+			"mariadb usa el driver de mysql",
+			models.Servidor{Tipo: "MariaDB", Host: "db", Puerto: 3306, Usuario: "motor", Clave: "x", Extras: datatypes.JSONMap{"dbname": "ventas"}},
+			"mysql",
+			"motor:x@tcp(db:3306)/ventas?parseTime=true",
+		},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			driver, dsn, err := conexionServidor(c.servidor)
+			if err != nil || driver != c.driver || dsn != c.dsn {
+				t.Fatalf("se esperaba %s %q y se obtuvo %s %q (err=%v)", c.driver, c.dsn, driver, dsn, err)
+			}
+		})
+	}
+
+	if _, _, err := conexionServidor(models.Servidor{ID: "api", Tipo: "rest"}); err == nil {
+		t.Fatalf("un servidor rest no debía tener conexión de base de datos")
+	}
+}
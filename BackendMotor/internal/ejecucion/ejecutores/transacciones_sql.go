@@ -0,0 +1,62 @@
+package ejecutores
+
+import (
+	"backendmotor/internal/models"
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Una transacción abierta por un nodo iniciarTransaccion viaja en el context.Context de los nodos
+// proceso que corren dentro de su alcance: los ejecutores SQL la usan en lugar del pool
+
+// consultorSQL es lo que necesitan los ejecutores para consultar: lo cumplen *sql.DB y *sql.Tx
+type consultorSQL interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+type claveTransaccion struct{ servidorID string }
+
+// ConTransaccion devuelve un contexto en el que las consultas al servidor usan tx
+func ConTransaccion(ctx context.Context, servidorID string, tx *sql.Tx) context.Context {
+	return context.WithValue(ctx, claveTransaccion{servidorID}, tx)
+}
+
+// transaccionDe devuelve la transacción abierta para el servidor, si la hay
+func transaccionDe(ctx context.Context, servidorID string) (*sql.Tx, bool) {
+	tx, ok := ctx.Value(claveTransaccion{servidorID}).(*sql.Tx)
+	return tx, ok && tx != nil
+}
+
+// EnTransaccion indica si las consultas al servidor corren dentro de una transacción del flujo
+func EnTransaccion(ctx context.Context, servidorID string) bool {
+	_, ok := transaccionDe(ctx, servidorID)
+	return ok
+}
+
+// nivelesAislamiento son los valores aceptados en data.aislamiento del nodo iniciarTransaccion
+var nivelesAislamiento = map[string]sql.IsolationLevel{
+	"":                sql.LevelDefault,
+	"read_committed":  sql.LevelReadCommitted,
+	"repeatable_read": sql.LevelRepeatableRead,
+	"serializable":    sql.LevelSerializable,
+}
+
+// IniciarTransaccion abre una transacción en el pool del servidor. Si ctx se cancela antes del
+// commit, database/sql la deshace sola
+func IniciarTransaccion(ctx context.Context, servidor models.Servidor, aislamiento string, soloLectura bool) (*sql.Tx, error) {
+	if !EsServidorSQL(servidor.Tipo) {
+		return nil, fmt.Errorf("el servidor %s (%s) no admite transacciones", servidor.ID, servidor.Tipo)
+	}
+	nivel, ok := nivelesAislamiento[strings.ToLower(aislamiento)]
+	if !ok {
+		return nil, fmt.Errorf("nivel de aislamiento no soportado: %s", aislamiento)
+	}
+	db, err := PoolPara(servidor)
+	if err != nil {
+		return nil, err
+	}
+	return db.BeginTx(ctx, &sql.TxOptions{Isolation: nivel, ReadOnly: soloLectura})
+}
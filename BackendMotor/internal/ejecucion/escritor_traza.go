@@ -0,0 +1,126 @@
+package ejecucion
+
+import (
+	"backendmotor/internal/database"
+	"backendmotor/internal/models"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// escritorTraza escribe la traza en la base fuera del camino del request. Las escrituras se
+// encolan en un canal con buffer y una sola goroutine las aplica en orden, así la fila de una
+// ejecución siempre existe antes que sus pasos. Si el buffer se llena, quien encola espera: la
+// base marca el ritmo en lugar de acumular trazas en memoria sin límite
+type escritorTraza struct {
+	operaciones chan func()
+	pendientes  sync.WaitGroup
+	arranque    sync.Once
+}
+
+var escritorTrazas = nuevoEscritorTraza(enteroDeEntorno("MOTOR_TRAZA_BUFFER", 1000))
+
+func nuevoEscritorTraza(buffer int) *escritorTraza {
+	return &escritorTraza{operaciones: make(chan func(), buffer)}
+}
+
+// encolar agrega una escritura; la goroutine que escribe arranca con la primera
+func (w *escritorTraza) encolar(escritura func()) {
+	w.arranque.Do(func() {
+		go func() {
+			for op := range w.operaciones {
+				op()
+			}
+		}()
+	})
+	w.pendientes.Add(1)
+	w.operaciones <- func() {
+		defer w.pendientes.Done()
+		escritura()
+	}
+}
+
+// esperar bloquea hasta que se apliquen todas las escrituras encoladas
+func (w *escritorTraza) esperar() {
+	w.pendientes.Wait()
+}
+
+// limiteTraza es el tamaño máximo en bytes de las variables que se guardan en cada entrada o
+// salida de la traza (MOTOR_TRAZA_MAX_BYTES, 64 KB por defecto)
+func limiteTraza() int {
+	return enteroDeEntorno("MOTOR_TRAZA_MAX_BYTES", 64*1024)
+}
+
+// instantaneaTraza serializa las variables para la traza. Cada valor se guarda ya convertido a
+// JSON, sin copiarlo; las variables que no entran en el límite quedan solo con su tamaño
+func instantaneaTraza(valores map[string]interface{}) datatypes.JSONMap {
+	if valores == nil {
+		return nil
+	}
+	claves := make([]string, 0, len(valores))
+	for k := range valores {
+		claves = append(claves, k)
+	}
+	sort.Strings(claves)
+
+	limite := limiteTraza()
+	total := 0
+	copia := make(datatypes.JSONMap, len(valores))
+	for _, k := range claves {
+		b, err := json.Marshal(valores[k])
+		if err != nil {
+			b, _ = json.Marshal(fmt.Sprintf("%v", valores[k]))
+		}
+		if total+len(b) > limite {
+			copia[k] = fmt.Sprintf("[truncado: %d bytes]", len(b))
+			continue
+		}
+		total += len(b)
+		copia[k] = json.RawMessage(b)
+	}
+	return copia
+}
+
+// diasRetencionTraza son los días que se conservan los pasos de la traza
+// (MOTOR_TRAZA_RETENCION_DIAS, 30 por defecto; 0 los conserva siempre)
+func diasRetencionTraza() int {
+	if v, err := strconv.Atoi(os.Getenv("MOTOR_TRAZA_RETENCION_DIAS")); err == nil && v >= 0 {
+		return v
+	}
+	return 30
+}
+
+var retencionTraza sync.Once
+
+// iniciarRetencionTraza borra cada hora los pasos más viejos que la retención configurada; la
+// fila de la ejecución se conserva con su resultado
+func iniciarRetencionTraza() {
+	retencionTraza.Do(func() {
+		dias := diasRetencionTraza()
+		if dias == 0 {
+			return
+		}
+		go func() {
+			tick := time.Tick(time.Hour)
+			for {
+				purgarPasosTraza(time.Now().AddDate(0, 0, -dias))
+				<-tick
+			}
+		}()
+	})
+}
+
+func purgarPasosTraza(limite time.Time) {
+	res := database.DBGORM.Where("fecha_inicio < ?", limite).Delete(&models.EjecucionPaso{})
+	if res.Error != nil {
+		fmt.Printf("⚠️ No se pudieron purgar los pasos de traza anteriores a %s: %v\n", limite.Format(time.RFC3339), res.Error)
+	} else if res.RowsAffected > 0 {
+		fmt.Printf("🧹 Se purgaron %d pasos de traza anteriores a %s\n", res.RowsAffected, limite.Format(time.RFC3339))
+	}
+}
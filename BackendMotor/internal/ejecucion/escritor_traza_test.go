@@ -0,0 +1,117 @@
+package ejecucion
+
+import (
+	"backendmotor/internal/estructuras"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEscritorTrazaOrden(t *testing.T) {
+	// Con buffer 1 quien encola espera a la goroutine que escribe, y el orden se mantiene igual
+	w := nuevoEscritorTraza(1)
+	var aplicadas []int
+	for i := 0; i < 50; i++ {
+		i := i
+		w.encolar(func() { aplicadas = append(aplicadas, i) })
+	}
+	w.esperar()
+
+	if len(aplicadas) != 50 {
+		t.Fatalf("se esperaban 50 escrituras y hubo %d", len(aplicadas))
+	}
+	for i, v := range aplicadas {
+		if v != i {
+			t.Fatalf("las escrituras se aplicaron fuera de orden: %v", aplicadas)
+		}
+	}
+}
+
+func TestInstantaneaTraza(t *testing.T) {
+	t.Setenv("MOTOR_TRAZA_MAX_BYTES", "20")
+
+	casos := []struct {
+		nombre   string
+		valores  map[string]interface{}
+		esperado string
+	}{
+		{
+			nombre:   "dentro del límite",
+			valores:  map[string]interface{}{"a": "corto", "b": 1.5, "c": map[string]interface{}{"x": true}},
+			esperado: `{"a":"corto","b":1.5,"c":{"x":true}}`,
+		},
+		{
+			// Las variables se recorren en orden: las que ya no entran quedan con su tamaño
+			nombre:   "se trunca lo que no entra",
+			valores:  map[string]interface{}{"a": "corto", "b": strings.Repeat("x", 30), "c": 7.0},
+			esperado: `{"a":"corto","b":"[truncado: 32 bytes]","c":7}`,
+		},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			b, err := json.Marshal(instantaneaTraza(c.valores))
+			if err != nil {
+				t.Fatalf("la instantánea no se pudo serializar: %v", err)
+			}
+			if string(b) != c.esperado {
+				t.Fatalf("se esperaba %s y se obtuvo %s", c.esperado, b)
+			}
+		})
+	}
+
+	// Lo que no se puede convertir a JSON se guarda como texto
+	b, _ := json.Marshal(instantaneaTraza(map[string]interface{}{"canal": make(chan int)})["canal"])
+	if !strings.HasPrefix(string(b), `"`) {
+		t.Fatalf("se esperaba el canal como texto y se obtuvo %s", b)
+	}
+}
+
+func TestDiasRetencionTraza(t *testing.T) {
+	casos := map[string]int{"": 30, "7": 7, "0": 0, "-3": 30, "x": 30}
+	for valor, esperado := range casos {
+		t.Setenv("MOTOR_TRAZA_RETENCION_DIAS", valor)
+		if obtenido := diasRetencionTraza(); obtenido != esperado {
+			t.Fatalf("MOTOR_TRAZA_RETENCION_DIAS=%q: se esperaba %d y se obtuvo %d", valor, esperado, obtenido)
+		}
+	}
+}
+
+func TestRegistrarPasoGuardaSoloLoQueCambio(t *testing.T) {
+	nodos := []estructuras.NodoGenerico{
+		nodo("e", "entrada", nil),
+		nodo("t", "transformar", map[string]interface{}{"plantilla": `"{{ precio * 2 }}"`, "variableSalida": "precio"}),
+		nodo("u", "transformar", map[string]interface{}{"plantilla": `"listo"`, "variableSalida": "estado"}),
+	}
+	e := estadoDePrueba(t, nodos, []string{"e>t", "t>u"}, map[string]interface{}{"precio": 5.0, "cliente": map[string]interface{}{"nombre": "Ana"}})
+	e.traza = &trazaEjecucion{}
+	if err := e.recorrerDesde("e"); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+
+	esperados := []struct {
+		nodo    string
+		entrada string
+		salida  string
+	}{
+		{"e", `{}`, `{}`},
+		{"t", `{"precio":5}`, `{"precio":10}`},
+		{"u", `{}`, `{"estado":"listo"}`},
+	}
+	if len(e.traza.pasos) != len(esperados) {
+		t.Fatalf("se esperaban %d pasos y hay %d", len(esperados), len(e.traza.pasos))
+	}
+	for i, esperado := range esperados {
+		paso := e.traza.pasos[i]
+		entrada, _ := json.Marshal(paso.Entrada)
+		salida, _ := json.Marshal(paso.Salida)
+		obtenido := []string{paso.NodoID, string(entrada), string(salida)}
+		if !reflect.DeepEqual(obtenido, []string{esperado.nodo, esperado.entrada, esperado.salida}) {
+			t.Fatalf("paso %d: se esperaba %+v y se obtuvo %v", i, esperado, obtenido)
+		}
+		if paso.Secuencia != i+1 {
+			t.Fatalf("paso %d: secuencia %d", i, paso.Secuencia)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package ejecucion
+
+import (
+	"backendmotor/internal/estructuras"
+	"reflect"
+	"strings"
+)
+
+// Cada nodo deja, además de las variables planas de siempre, sus salidas agrupadas en
+// resultado[<id del nodo>]. Así dos nodos que devuelven "codigo" no se pisan y se puede leer
+// nodo_3.codigo o nodo_3.cliente.direcciones[0].ciudad desde asignaciones, condiciones y salidas
+
+// superficial copia solo el primer nivel del resultado, para comparar después de ejecutar un nodo
+func superficial(valores map[string]interface{}) map[string]interface{} {
+	copia := make(map[string]interface{}, len(valores))
+	for k, v := range valores {
+		copia[k] = v
+	}
+	return copia
+}
+
+// variablesEscritas devuelve las variables que un nodo agregó o reemplazó respecto de la foto
+// superficial tomada antes de ejecutarlo. Los nodos nunca modifican por dentro un mapa o arreglo
+// que ya estaba en el resultado: siempre escriben un valor nuevo en la variable. Por eso alcanza
+// con comparar la identidad del valor de primer nivel, sin recorrer su contenido
+func variablesEscritas(antes, despues map[string]interface{}) []string {
+	var escritas []string
+	for k, v := range despues {
+		if previo, ok := antes[k]; !ok || !mismoValor(previo, v) {
+			escritas = append(escritas, k)
+		}
+	}
+	return escritas
+}
+
+// mismoValor compara escalares por valor y mapas, arreglos y funciones por identidad
+func mismoValor(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if va.Type() != vb.Type() {
+		return false
+	}
+	switch va.Kind() {
+	case reflect.Map, reflect.Func, reflect.Pointer, reflect.Chan:
+		return va.Pointer() == vb.Pointer()
+	case reflect.Slice:
+		return va.Pointer() == vb.Pointer() && va.Len() == vb.Len()
+	}
+	if va.Type().Comparable() {
+		return a == b
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// publicarEspacio guarda en resultado[n.ID] las variables que el nodo escribió y, en nodos
+// proceso, sus parametrosSalida aunque el valor ya existiera. El espacio guarda los mismos valores
+// que las variables planas, sin copiarlos, y no repite el FullOutput: utils.ResolverRuta busca
+// nodo_3.campo en fullOutput_nodo_3 cuando el espacio no lo tiene
+func (e *estadoFlujo) publicarEspacio(n estructuras.NodoGenerico, escritas []string) {
+	espacio := make(map[string]interface{}, len(escritas))
+	for _, k := range escritas {
+		if e.esEspacioDeNodo(k) || strings.HasPrefix(k, "fullOutput_") {
+			continue
+		}
+		espacio[k] = e.resultado[k]
+	}
+
+	if n.Type == "proceso" {
+		for _, campo := range e.compilado.configProceso(n).CamposSalida {
+			if v, ok := e.resultado[campo.Nombre]; ok {
+				espacio[campo.Nombre] = v
+			}
+		}
+	}
+
+	if len(espacio) == 0 {
+		return
+	}
+	e.resultado[n.ID] = espacio
+}
+
+// esEspacioDeNodo indica si la variable es el espacio de otro nodo del flujo
+func (e *estadoFlujo) esEspacioDeNodo(nombre string) bool {
+	_, ok := e.grafo.nodos[nombre]
+	return ok
+}
@@ -0,0 +1,52 @@
+package ejecucion
+
+import (
+	"backendmotor/internal/estructuras"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestVariablesEscritas(t *testing.T) {
+	mapa := map[string]interface{}{"a": 1}
+	lista := []interface{}{1, 2}
+	antes := map[string]interface{}{"igual": "x", "numero": 1.0, "mapa": mapa, "lista": lista, "reemplazado": mapa, "recortada": lista}
+	despues := map[string]interface{}{
+		"igual":       "x",
+		"numero":      2.0,
+		"mapa":        mapa,
+		"lista":       lista,
+		"reemplazado": map[string]interface{}{"a": 1}, // mismo contenido, valor nuevo
+		"recortada":   lista[:1],
+		"nueva":       nil,
+	}
+
+	escritas := variablesEscritas(antes, despues)
+	sort.Strings(escritas)
+	if esperado := []string{"nueva", "numero", "recortada", "reemplazado"}; !reflect.DeepEqual(escritas, esperado) {
+		t.Fatalf("se esperaba %v y se obtuvo %v", esperado, escritas)
+	}
+}
+
+func TestPublicarEspacio(t *testing.T) {
+	nodos := []estructuras.NodoGenerico{
+		nodo("e", "entrada", nil),
+		nodo("t", "transformar", map[string]interface{}{"plantilla": `"{{ precio * 2 }}"`, "variableSalida": "doble"}),
+		nodo("u", "transformar", map[string]interface{}{"plantilla": `"{{ t.doble + 1 }}"`, "variableSalida": "siguiente"}),
+	}
+	e := estadoDePrueba(t, nodos, []string{"e>t", "t>u"}, map[string]interface{}{"precio": 5.0})
+	if err := e.recorrerDesde("e"); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+
+	// Solo queda lo que escribió cada nodo, no las variables que ya estaban
+	if esperado := map[string]interface{}{"doble": 10.0}; !reflect.DeepEqual(e.resultado["t"], esperado) {
+		t.Fatalf("espacio de t: se esperaba %v y se obtuvo %v", esperado, e.resultado["t"])
+	}
+	if esperado := map[string]interface{}{"siguiente": 11.0}; !reflect.DeepEqual(e.resultado["u"], esperado) {
+		t.Fatalf("espacio de u: se esperaba %v y se obtuvo %v", esperado, e.resultado["u"])
+	}
+	if _, ok := e.resultado["e"]; ok {
+		t.Fatalf("la entrada no escribió nada y no debía tener espacio")
+	}
+}
@@ -0,0 +1,153 @@
+package ejecucion
+
+import (
+	"backendmotor/internal/database"
+	"backendmotor/internal/estructuras"
+	"backendmotor/internal/models"
+	"backendmotor/internal/monitoring"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// TTLCacheFlujos limita cuánto vive un flujo compilado; cubre los cambios hechos en la base
+// por fuera del motor (por ejemplo desde el backend del diseñador)
+const TTLCacheFlujos = 60 * time.Second
+
+// FlujoCompilado es la representación lista para ejecutar de un proceso: el flujo ya
+// parseado, el grafo con sus adyacencias y las configuraciones de nodos pre-decodificadas
+type FlujoCompilado struct {
+	Proceso        models.Proceso
+	Flujo          estructuras.Flujo
+	NodoEntrada    estructuras.NodoGenerico
+	grafo          *grafoFlujo
+	procesos       map[string]*configNodoProceso       // configuración de cada nodo tipo proceso
+	compensaciones map[string]estructuras.NodoGenerico // acción que deshace cada nodo proceso (saga)
+	compiladoEn    time.Time
+}
+
+// configNodoProceso guarda decodificado lo que ejecutarNodoProceso antes sacaba de n.Data en cada llamada
+type configNodoProceso struct {
+	Nodo              NodoProceso
+	CamposSalida      []estructuras.Campo
+	Asignaciones      map[string][]AsignacionProceso
+	ParsearFullOutput bool
+	TipoRespuesta     string
+	TagPadre          string
+}
+
+var cacheFlujos = struct {
+	sync.RWMutex
+	flujos map[string]*FlujoCompilado
+}{flujos: make(map[string]*FlujoCompilado)}
+
+// obtenerFlujoCompilado devuelve el flujo desde la caché o lo carga y compila desde la base
+func obtenerFlujoCompilado(ctx context.Context, procesoID string) (*FlujoCompilado, error) {
+	cacheFlujos.RLock()
+	fc, ok := cacheFlujos.flujos[procesoID]
+	cacheFlujos.RUnlock()
+	if ok && time.Since(fc.compiladoEn) < TTLCacheFlujos {
+		monitoring.FlujoCacheTotal.WithLabelValues("hit").Inc()
+		return fc, nil
+	}
+	monitoring.FlujoCacheTotal.WithLabelValues("miss").Inc()
+
+	var proc models.Proceso
+	if err := database.DBGORM.WithContext(ctx).First(&proc, "id = ?", procesoID).Error; err != nil {
+		return nil, fmt.Errorf("error cargando proceso: %w", err)
+	}
+
+	fc, err := CompilarFlujo(proc)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheFlujos.Lock()
+	cacheFlujos.flujos[procesoID] = fc
+	cacheFlujos.Unlock()
+
+	return fc, nil
+}
+
+// CompilarFlujo parsea el JSON del proceso y arma la estructura tipada que usa el motor
+func CompilarFlujo(proc models.Proceso) (*FlujoCompilado, error) {
+	var flujo estructuras.Flujo
+	if err := json.Unmarshal([]byte(proc.Flujo), &flujo); err != nil {
+		return nil, fmt.Errorf("error parseando flujo: %w", err)
+	}
+
+	grafo, err := construirGrafoFlujo(flujo.Nodes, flujo.Edges)
+	if err != nil {
+		return nil, err
+	}
+
+	fc := &FlujoCompilado{
+		Proceso:        proc,
+		Flujo:          flujo,
+		grafo:          grafo,
+		procesos:       make(map[string]*configNodoProceso),
+		compensaciones: make(map[string]estructuras.NodoGenerico),
+		compiladoEn:    time.Now(),
+	}
+
+	for _, n := range flujo.Nodes {
+		if n.Type == "entrada" && fc.NodoEntrada.ID == "" {
+			fc.NodoEntrada = n
+		}
+		if n.Type == "proceso" {
+			fc.procesos[n.ID] = compilarConfigProceso(n)
+			if accion, ok := nodoCompensacion(n); ok {
+				fc.compensaciones[n.ID] = accion
+				if accion.Type == "proceso" {
+					fc.procesos[accion.ID] = compilarConfigProceso(accion)
+				}
+			}
+		}
+	}
+
+	return fc, nil
+}
+
+// compilarConfigProceso decodifica una sola vez los datos de un nodo tipo proceso
+func compilarConfigProceso(n estructuras.NodoGenerico) *configNodoProceso {
+	cfg := &configNodoProceso{}
+
+	if jsonBytes, err := json.Marshal(n.Data); err == nil {
+		_ = json.Unmarshal(jsonBytes, &cfg.Nodo)
+	}
+	if salidaRaw, ok := n.Data["parametrosSalida"]; ok {
+		if salidaBytes, err := json.Marshal(salidaRaw); err == nil {
+			_ = json.Unmarshal(salidaBytes, &cfg.CamposSalida)
+		}
+	}
+	cfg.Asignaciones = decodificarAsignacionesProceso(n)
+	cfg.ParsearFullOutput, _ = n.Data["parsearFullOutput"].(bool)
+	cfg.TipoRespuesta = fmt.Sprint(n.Data["tipoRespuesta"])
+	cfg.TagPadre = fmt.Sprint(n.Data["tagPadre"])
+
+	return cfg
+}
+
+// configProceso devuelve la configuración pre-decodificada de un nodo proceso
+func (fc *FlujoCompilado) configProceso(n estructuras.NodoGenerico) *configNodoProceso {
+	if cfg, ok := fc.procesos[n.ID]; ok {
+		return cfg
+	}
+	return compilarConfigProceso(n)
+}
+
+// InvalidarFlujo descarta el flujo compilado de un proceso (tras actualizarlo o eliminarlo)
+func InvalidarFlujo(procesoID string) {
+	cacheFlujos.Lock()
+	delete(cacheFlujos.flujos, procesoID)
+	cacheFlujos.Unlock()
+}
+
+// InvalidarTodosLosFlujos vacía la caché completa de flujos compilados
+func InvalidarTodosLosFlujos() {
+	cacheFlujos.Lock()
+	cacheFlujos.flujos = make(map[string]*FlujoCompilado)
+	cacheFlujos.Unlock()
+}
@@ -2,6 +2,7 @@ package ejecucion
 
 import (
 	"backendmotor/internal/database"
+	"context"
 
 	"backendmotor/internal/estructuras"
 	"backendmotor/internal/models"
@@ -10,284 +11,160 @@ import (
 	"fmt"
 
 	"time"
+
+	"gorm.io/gorm"
 )
 
-// EjecutarFlujo es el motor principal que interpreta y ejecuta el flujo de integración definido
-func EjecutarFlujo(procesoID string, input map[string]interface{}, canalCodigo string, trigger string) (ResultadoEjecucion, error) {
-	inicio := time.Now()
+// EjecutarFlujo es el motor principal que interpreta y ejecuta el flujo de integración definido.
+// La cancelación de ctx (cliente desconectado, deadline) detiene la ejecución y las llamadas en curso
+func EjecutarFlujo(ctx context.Context, procesoID string, input map[string]interface{}, canalCodigo string, trigger string) (ResultadoEjecucion, error) {
+	return EjecutarFlujoConContexto(ctx, procesoID, input, canalCodigo, trigger, nil)
+}
 
-	// 🧱 Paso 1: Cargar el proceso desde la base de datos
-	var proc models.Proceso
-	db := database.DBGORM
-	if err := db.First(&proc, "id = ?", procesoID).Error; err != nil {
-		return ResultadoEjecucion{}, fmt.Errorf("error cargando proceso: %w", err)
-	}
+// EjecutarFlujoConOpciones ejecuta el flujo raíz con opciones especiales (depuración, simulación)
+func EjecutarFlujoConOpciones(ctx context.Context, procesoID string, input map[string]interface{}, canalCodigo string, trigger string, opciones *OpcionesEjecucion) (ResultadoEjecucion, error) {
+	contexto := nuevoContextoRaiz(procesoID, input)
+	contexto.Opciones = opciones
+	return EjecutarFlujoConContexto(ctx, procesoID, input, canalCodigo, trigger, contexto)
+}
 
-	// 🧠 Paso 2: Parsear el JSON del flujo
-	var flujo struct {
-		Nodes []estructuras.NodoGenerico `json:"nodes"`
-		Edges []struct {
-			ID           string `json:"id"`
-			Source       string `json:"source"`
-			Target       string `json:"target"`
-			SourceHandle string `json:"sourceHandle"`
-			TargetHandle string `json:"targetHandle"`
-			Type         string `json:"type"`
-		} `json:"edges"`
+// nuevoContextoRaiz crea el contexto de anidación de una ejecución que no viene de un subproceso
+func nuevoContextoRaiz(procesoID string, input map[string]interface{}) *ContextoSubproceso {
+	return &ContextoSubproceso{
+		ProcesoID: procesoID,
+		Variables: input,
+		Globales:  copiarMapa(input), // Las variables de entrada son las globales del árbol
+		Depth:     0,
+		CallStack: []string{},
+		TraceID:   fmt.Sprintf("%s-%d", procesoID, time.Now().UnixNano()),
+		Inicio:    time.Now(),
 	}
-	if err := json.Unmarshal([]byte(proc.Flujo), &flujo); err != nil {
-		return ResultadoEjecucion{}, fmt.Errorf("error parseando flujo: %w", err)
+}
+
+// EjecutarFlujoConContexto ejecuta el flujo dentro de un contexto de anidación; con contexto nil
+// se crea uno raíz. Los subprocesos reciben la profundidad, la pila de llamadas, el TraceID,
+// las variables globales y el tiempo restante del proceso que los invoca
+func EjecutarFlujoConContexto(
+	ctx context.Context,
+	procesoID string,
+	input map[string]interface{},
+	canalCodigo string,
+	trigger string,
+	contexto *ContextoSubproceso,
+) (resultadoFinal ResultadoEjecucion, errFinal error) {
+	inicio := time.Now()
+
+	// 🧱 Paso 1: Obtener el flujo compilado (caché en memoria; se carga de la base si no está)
+	db := database.DBGORM
+	compilado, err := obtenerFlujoCompilado(ctx, procesoID)
+	if err != nil {
+		return ResultadoEjecucion{}, err
 	}
+	proc := compilado.Proceso
+	flujo := compilado.Flujo
 
-	// 🧩 Paso 3: Mapear nodos y conexiones
-	nodosPorID := make(map[string]estructuras.NodoGenerico)
-	siguientesPorID := make(map[string][]string)
-	for _, n := range flujo.Nodes {
-		nodosPorID[n.ID] = n
+	// 🧬 Paso 1.5: Preparar el contexto de anidación (raíz si no viene de un subproceso)
+	if contexto == nil {
+		contexto = nuevoContextoRaiz(proc.ID, input)
 	}
-	for _, e := range flujo.Edges {
-		siguientesPorID[e.Source] = append(siguientesPorID[e.Source], e.Target)
+	if contexto.Depth > 0 {
+		input = combinarConGlobales(input, contexto.Globales)
 	}
 
-	// 🔍 Paso 4: Identificar nodo tipo entrada
-	var nodoEntrada estructuras.NodoGenerico
-	for _, n := range flujo.Nodes {
-		if n.Type == "entrada" {
-			nodoEntrada = n
-			break
+	// 🗂️ Paso 1.6: Registrar la ejecución y cerrar su traza al terminar (cualquiera sea la salida)
+	traza := iniciarTraza(ctx, proc, contexto, input, canalCodigo, trigger)
+	contexto.EjecucionID = traza.id()
+	var estado *estadoFlujo
+	var compensaciones []ResultadoCompensacion
+	defer func() {
+		terminoEnError := estado != nil && estado.terminoEnError()
+		resultadoFinal.EjecucionID = traza.id()
+		resultadoFinal.Compensaciones = compensaciones
+		if contexto.simulacion() != nil {
+			resultadoFinal.Camino = traza.camino()
 		}
+		traza.finalizar(ctx, resultadoFinal, errFinal, terminoEnError)
+	}()
+
+	// ⏱️ Paso 2: Aplicar el deadline propio del proceso (si el flujo lo define)
+	if flujo.TimeoutMs > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(flujo.TimeoutMs)*time.Millisecond)
+		defer cancel()
 	}
+
+	// 🔍 Paso 3: Identificar nodo tipo entrada (el grafo ya viene indexado en el flujo compilado)
+	nodoEntrada := compilado.NodoEntrada
 	if nodoEntrada.ID == "" {
 		return ResultadoEjecucion{}, fmt.Errorf("no se encontró nodo de entrada")
 	}
 
-	// ▶️ Paso 5: Ejecutar nodo entrada
-	resultado, asignacionesAplicadas, err := ejecutarNodoEntrada(nodoEntrada, input)
-	if err != nil {
-		return ResultadoEjecucion{}, fmt.Errorf("error ejecutando nodo entrada: %w", err)
+	// ▶️ Paso 4: Ejecutar nodo entrada (al reanudar desde un checkpoint ya se ejecutó)
+	var checkpoint *checkpointEjecucion
+	if contexto.durable != nil {
+		checkpoint = contexto.durable.checkpoint
 	}
-
-	// 🧪 Paso 6: Preparar estado de ejecución
-	erroresPorNodo := make(map[string]bool)
-	respuestaFinal := make(map[string]interface{})
-	visitados := make(map[string]bool)
-	pendientes := make(map[string]bool)
-	
-	// Inicializar con nodo de entrada
-	pendientes[nodoEntrada.ID] = true
-
-	// 🔁 Paso 7: Recorrido optimizado y ejecución de nodos del flujo
-	for len(pendientes) > 0 {
-		// Obtener cualquier nodo pendiente (map no garantiza orden, pero está bien)
-		var nodoID string
-		for id := range pendientes {
-			nodoID = id
-			break
+	resultado := make(map[string]interface{})
+	asignacionesAplicadas := make(map[string]interface{})
+	if checkpoint == nil {
+		resultado, asignacionesAplicadas, err = ejecutarNodoEntrada(nodoEntrada, input)
+		if err != nil {
+			return ResultadoEjecucion{}, fmt.Errorf("error ejecutando nodo entrada: %w", err)
 		}
-		
-		// Remover de pendientes y marcar como visitado
-		delete(pendientes, nodoID)
-		visitados[nodoID] = true
-		
-		fmt.Printf("🔄 Procesando nodo %s (%s)\n", nodoID, nodosPorID[nodoID].Type)
-		n := nodosPorID[nodoID]
-
-		asignaciones := make(map[string]interface{})
-
-		switch n.Type {
-
-		case "proceso":
-			// ✅ Obtener el servidorId desde n.Data
-			servidorID, ok := n.Data["servidorId"].(string)
-			if !ok || servidorID == "" {
-				erroresPorNodo[n.ID] = true
-				resultado["codigoError"] = 98
-				resultado["mensajeError"] = "servidorId no definido en el nodo"
-				break
-			}
-
-			// 🧠 Ejecutar el nodo tipo proceso desde módulo central
-			newResultado, _, newAsignaciones, estado, _, err := ejecutarNodoProceso(n, resultado, input, db, canalCodigo, proc, inicio)
-			if err != nil {
-				erroresPorNodo[n.ID] = true
-			}
-			resultado = newResultado
-			for k, v := range newAsignaciones {
-				asignacionesAplicadas[k] = v
-			}
-			if estado == 99 {
-				erroresPorNodo[n.ID] = true
-			}
-
-		case "salida":
-			respuestaFinal, asignaciones, err = ejecutarNodoSalida(n, resultado)
-			if err != nil {
-				return ResultadoEjecucion{}, fmt.Errorf("error en nodo salida: %w", err)
-			}
-			for k, v := range asignaciones {
-				asignacionesAplicadas[k] = v
-			}
-
-		case "salidaError":
-			respuestaFinal, asignaciones = ejecutarNodoSalidaError(n, resultado)
-			for k, v := range asignaciones {
-				asignacionesAplicadas[k] = v
-			}
-
-		case "subproceso":
-			// Crear contexto si no existe
-			contexto := &ContextoSubproceso{
-				ProcesoID:       proc.ID,
-				ParentProcesoID: "",
-				Variables:       make(map[string]interface{}),
-				Globales:        input, // Pasar variables de entrada como globales
-				Depth:           0,
-				CallStack:       []string{},
-				TraceID:         fmt.Sprintf("%s-%d", proc.ID, time.Now().UnixNano()),
-				Timeout:         60 * time.Second,
-				Inicio:          inicio,
-			}
-			
-			newResultado, newAsignaciones, err := ejecutarNodoSubproceso(n, resultado, contexto, canalCodigo)
-			if err != nil {
-				erroresPorNodo[n.ID] = true
-				resultado["codigoError"] = "SUB_ERROR"
-				resultado["mensajeError"] = fmt.Sprintf("Error en subproceso: %v", err)
-			} else {
-				resultado = newResultado
-				for k, v := range newAsignaciones {
-					asignacionesAplicadas[k] = v
-				}
-				// Verificar si el subproceso retornó error
-				if codigo, ok := resultado["codigoError"]; ok && codigo != nil && codigo != "" {
-					erroresPorNodo[n.ID] = true
-				}
-			}
-			
-			// 🎯 RUTEO INTELIGENTE: Solo agregar el nodo de salida correcto según el resultado
-			for _, e := range flujo.Edges {
-				if e.Source != n.ID {
-					continue
-				}
-				
-				targetID := e.Target
-				if visitados[targetID] || pendientes[targetID] {
-					continue
-				}
-				
-				targetNode := nodosPorID[targetID]
-				
-				// Si hay error en subproceso, solo agregar salidaError
-				if erroresPorNodo[n.ID] && targetNode.Type == "salidaError" {
-					fmt.Printf("➡️ Agregando nodo %s (salidaError por error en subproceso)\n", targetID)
-					pendientes[targetID] = true
-				} 
-				// Si no hay error, solo agregar salida normal
-				if !erroresPorNodo[n.ID] && targetNode.Type == "salida" {
-					fmt.Printf("➡️ Agregando nodo %s (salida por éxito en subproceso)\n", targetID)
-					pendientes[targetID] = true
-				}
-				// Para otros tipos de nodos (proceso, condicion, etc.) agregarlos normalmente
-				if targetNode.Type != "salida" && targetNode.Type != "salidaError" {
-					if !erroresPorNodo[n.ID] { // Solo si no hay error
-						fmt.Printf("➡️ Agregando nodo %s (%s después de subproceso exitoso)\n", targetID, targetNode.Type)
-						pendientes[targetID] = true
-					}
-				}
-			}
-			continue // No ejecutar la lógica general de agregado
-
-		case "splitter":
-			newResultado, newAsignaciones, err := ejecutarNodoSplitter(n, resultado, canalCodigo)
-			if err != nil {
-				erroresPorNodo[n.ID] = true
-				resultado["codigoError"] = "SPLITTER_ERROR"
-				resultado["mensajeError"] = fmt.Sprintf("Error en splitter: %v", err)
-			} else {
-				resultado = newResultado
-				for k, v := range newAsignaciones {
-					asignacionesAplicadas[k] = v
-				}
-			}
+		resultado[nodoEntrada.ID] = superficial(resultado)
+	}
 
-		case "condicion":
-			cumple, err := ejecutarNodoCondicion(n, resultado, canalCodigo)
-			if err != nil {
-				return ResultadoEjecucion{}, fmt.Errorf("error en nodo condición: %w", err)
-			}
+	// 🧪 Paso 5: Preparar estado de ejecución
+	if contexto.Depth > 0 {
+		resultado = combinarConGlobales(resultado, contexto.Globales)
+	}
 
-			if cumple {
-				if salidaRaw, ok := n.Data["parametrosSalida"]; ok {
-					if salidaBytes, err := json.Marshal(salidaRaw); err == nil {
-						var camposSalida []estructuras.Campo
-
-						if err := json.Unmarshal(salidaBytes, &camposSalida); err == nil {
-							for _, campo := range camposSalida {
-								if val, ok := resultado[campo.Nombre]; ok {
-									asignacionesAplicadas[campo.Nombre] = val
-								}
-							}
-						}
-					}
-				}
-			} else {
-				if errorRaw, ok := n.Data["parametrosError"]; ok {
-					if errorBytes, err := json.Marshal(errorRaw); err == nil {
-						var camposError []estructuras.Campo
-						if err := json.Unmarshal(errorBytes, &camposError); err == nil {
-							for _, campo := range camposError {
-								if val, ok := resultado[campo.Nombre]; ok {
-									asignacionesAplicadas[campo.Nombre] = val
-								}
-							}
-						}
-					}
-				}
-			}
+	estado = &estadoFlujo{
+		ctx:                   ctx,
+		proc:                  proc,
+		contexto:              contexto,
+		input:                 input,
+		canalCodigo:           canalCodigo,
+		inicio:                inicio,
+		db:                    db,
+		compilado:             compilado,
+		grafo:                 compilado.grafo,
+		traza:                 traza,
+		compensaciones:        &registroCompensaciones{},
+		transacciones:         &registroTransacciones{abiertas: make(map[string]*transaccionFlujo)},
+		durable:               contexto.durable,
+		resultado:             resultado,
+		asignacionesAplicadas: asignacionesAplicadas,
+		erroresPorNodo:        make(map[string]bool),
+		respuestaFinal:        make(map[string]interface{}),
+		visitados:             make(map[string]bool),
+	}
 
-			// Agregar nodos siguientes según condición
-			for _, e := range flujo.Edges {
-				if e.Source != n.ID {
-					continue
-				}
-				
-				targetID := e.Target
-				// Verificar que no esté ya visitado o pendiente
-				if visitados[targetID] || pendientes[targetID] {
-					continue
-				}
-				
-				if (cumple && e.SourceHandle == "true") || (!cumple && e.SourceHandle == "false") {
-					fmt.Printf("➡️ Agregando nodo %s (condición %v desde %s)\n", targetID, cumple, n.ID)
-					pendientes[targetID] = true
-				}
-			}
-			continue
+	// 🔁 Paso 6: Recorrido en orden topológico; los nodos de unión esperan a todas sus ramas
+	plan := nuevoPlanificador(compilado.grafo, nodoEntrada.ID)
+	if checkpoint != nil {
+		if err := estado.restaurar(checkpoint, plan); err != nil {
+			return ResultadoEjecucion{}, err
 		}
+	}
+	err = estado.recorrer(plan)
 
-		// 🎯 ALGORITMO OPTIMIZADO: Solo agregar nodos NO visitados y NO pendientes
-		for _, e := range flujo.Edges {
-			if e.Source != n.ID {
-				continue
-			}
-			
-			targetID := e.Target
-			// Verificar que no esté ya visitado o pendiente
-			if visitados[targetID] || pendientes[targetID] {
-				continue
-			}
-			
-			// Agregar según el tipo de edge y estado del nodo
-			if erroresPorNodo[n.ID] && e.Type == "error" {
-				fmt.Printf("➡️ Agregando nodo %s (error desde %s)\n", targetID, n.ID)
-				pendientes[targetID] = true
-			} else if !erroresPorNodo[n.ID] && e.Type != "error" {
-				fmt.Printf("➡️ Agregando nodo %s (normal desde %s)\n", targetID, n.ID)
-				pendientes[targetID] = true
-			}
-		}
+	// 🔒 Paso 6.5: Lo que quedó abierto sin un confirmarTransaccion se deshace
+	estado.deshacerTransacciones("el flujo terminó sin confirmar la transacción")
+	if err != nil {
+		return ResultadoEjecucion{}, err
 	}
 
+	// ↩️ Paso 7: Si el flujo terminó en salidaError, deshacer en orden inverso lo ya hecho (saga)
+	if estado.terminoEnError() {
+		compensaciones = estado.compensar()
+	}
+
+	resultado = estado.resultado
+	respuestaFinal := estado.respuestaFinal
+	visitados := estado.visitados
+	erroresPorNodo := estado.erroresPorNodo
+
 	// ✅ Paso final: validar si se ejecutó algún nodo salida
 	if len(respuestaFinal) == 0 {
 		utils.RegistrarEjecucionLog(utils.RegistroEjecucion{
@@ -297,6 +174,7 @@ func EjecutarFlujo(procesoID string, input map[string]interface{}, canalCodigo s
 			Canal:         canalCodigo,
 			TipoObjeto:    "motor",
 			NombreObjeto:  "Validación final de respuesta",
+			TraceID:       contexto.TraceID,
 			Parametros: map[string]interface{}{
 				"visitados": visitados,
 				"errores":   erroresPorNodo,
@@ -321,6 +199,7 @@ func EjecutarFlujo(procesoID string, input map[string]interface{}, canalCodigo s
 			Datos:     nil,
 			ProcesoID: proc.ID,
 			Trigger:   trigger,
+			TraceID:   contexto.TraceID,
 		}, nil
 	}
 
@@ -331,6 +210,7 @@ func EjecutarFlujo(procesoID string, input map[string]interface{}, canalCodigo s
 		Canal:         canalCodigo,
 		TipoObjeto:    "motor",
 		NombreObjeto:  "Ejecución exitosa",
+		TraceID:       contexto.TraceID,
 		Parametros:    resultado,
 		Resultado:     respuestaFinal,
 		FullOutput: map[string]interface{}{
@@ -349,6 +229,7 @@ func EjecutarFlujo(procesoID string, input map[string]interface{}, canalCodigo s
 		Datos:     respuestaFinal,
 		ProcesoID: proc.ID,
 		Trigger:   trigger,
+		TraceID:   contexto.TraceID,
 	}, nil
 }
 
@@ -362,3 +243,284 @@ func nodosDeTipo(nodos []estructuras.NodoGenerico, tipo string) []string {
 	}
 	return encontrados
 }
+
+// estadoFlujo agrupa el estado mutable de una ejecución (o de una rama paralela)
+type estadoFlujo struct {
+	ctx                   context.Context
+	proc                  models.Proceso
+	contexto              *ContextoSubproceso
+	input                 map[string]interface{}
+	canalCodigo           string
+	inicio                time.Time
+	db                    *gorm.DB
+	compilado             *FlujoCompilado
+	grafo                 *grafoFlujo
+	traza                 *trazaEjecucion
+	compensaciones        *registroCompensaciones // nodos proceso ya hechos que saben deshacerse
+	transacciones         *registroTransacciones  // transacciones de base de datos abiertas por el flujo
+	durable               *ejecucionDurable       // checkpoints en la cola durable (solo recorrido raíz)
+	rama                  string                  // nombre de la rama paralela ("" en el recorrido principal)
+	resultado             map[string]interface{}
+	escritas              map[string]bool // variables que escribió la rama o iteración (nil en el recorrido principal)
+	asignacionesAplicadas map[string]interface{}
+	erroresPorNodo        map[string]bool
+	respuestaFinal        map[string]interface{}
+	visitados             map[string]bool
+}
+
+// recorrer ejecuta los nodos que el planificador va liberando hasta que no quede ninguno
+func (e *estadoFlujo) recorrer(plan *planificador) error {
+	for {
+		if err := e.ctx.Err(); err != nil {
+			return fmt.Errorf("ejecución cancelada: %w", err)
+		}
+
+		nodoID, ok := plan.siguiente()
+		if !ok {
+			return nil
+		}
+		e.visitados[nodoID] = true
+
+		n := e.grafo.nodos[nodoID]
+		fmt.Printf("🔄 Procesando nodo %s (%s)\n", nodoID, n.Type)
+
+		// 🐞 Modo depuración: detenerse en breakpoints (o en cada nodo si se va paso a paso)
+		if d := e.depuracion(); d != nil && d.debePausar(nodoID) {
+			d.pausar(e, nodoID)
+			if err := e.ctx.Err(); err != nil {
+				return fmt.Errorf("ejecución cancelada: %w", err)
+			}
+		}
+
+		inicioNodo := time.Now()
+		antes := superficial(e.resultado)
+
+		// Paralelo e iterar ejecutan su bloque completo y liberan el nodo de cierre en el plan
+		if n.Type == "paralelo" || n.Type == "iterar" {
+			ejecutarBloque := e.ejecutarParalelo
+			if n.Type == "iterar" {
+				ejecutarBloque = e.ejecutarIterar
+			}
+			if err := ejecutarBloque(n, plan); err != nil {
+				return err
+			}
+			escritas := variablesEscritas(antes, e.resultado)
+			e.anotarEscritas(escritas)
+			e.publicarEspacio(n, escritas)
+			e.traza.registrarPaso(n, e.rama, antes, e.resultado, escritas, nil, e.erroresPorNodo[n.ID], inicioNodo)
+			e.guardarCheckpoint(plan, nodoID)
+			continue
+		}
+
+		cumple, caso, err := e.ejecutarNodo(n)
+		if err != nil {
+			e.traza.registrarPaso(n, e.rama, antes, e.resultado, variablesEscritas(antes, e.resultado), nil, true, inicioNodo)
+			return err
+		}
+		escritas := variablesEscritas(antes, e.resultado)
+		e.anotarEscritas(escritas)
+		e.publicarEspacio(n, escritas)
+		if n.Type == "proceso" && !e.erroresPorNodo[n.ID] {
+			e.registrarCompensable(n)
+		}
+
+		// 🎯 Activar solo las conexiones que corresponden al resultado del nodo
+		var tomadas []aristaFlujo
+		if n.Type == "switch" {
+			tomadas = e.grafo.aristasDelCaso(n, e.erroresPorNodo[n.ID], caso)
+		} else {
+			tomadas = e.grafo.aristasTomadas(n, e.erroresPorNodo[n.ID], cumple)
+		}
+		e.traza.registrarPaso(n, e.rama, antes, e.resultado, escritas, tomadas, e.erroresPorNodo[n.ID], inicioNodo)
+
+		// 🔒 Salir por una conexión de error o llegar a salidaError deshace las transacciones abiertas
+		if e.saleConError(n, tomadas) {
+			e.deshacerTransacciones(fmt.Sprintf("el nodo %s salió por error", n.ID))
+		}
+		plan.completar(n.ID, tomadas)
+		e.guardarCheckpoint(plan, nodoID)
+	}
+}
+
+// anotarEscritas acumula las variables escritas por una rama o iteración, que es lo único que se
+// fusiona o recolecta al cerrar el bloque
+func (e *estadoFlujo) anotarEscritas(escritas []string) {
+	if e.escritas == nil {
+		return
+	}
+	for _, k := range escritas {
+		e.escritas[k] = true
+	}
+}
+
+// terminoEnError indica si el recorrido llegó a algún nodo salidaError
+func (e *estadoFlujo) terminoEnError() bool {
+	for _, id := range nodosDeTipo(e.compilado.Flujo.Nodes, "salidaError") {
+		if e.visitados[id] {
+			return true
+		}
+	}
+	return false
+}
+
+// depuracion devuelve la sesión de depuración activa; los subprocesos no se pausan porque
+// sus IDs de nodo pertenecen a otro flujo
+func (e *estadoFlujo) depuracion() *SesionDepuracion {
+	if e.contexto == nil || e.contexto.Depth > 0 || e.contexto.Opciones == nil {
+		return nil
+	}
+	return e.contexto.Opciones.Depuracion
+}
+
+// ejecutarNodo ejecuta un nodo según su tipo; devuelve el resultado lógico para nodos de
+// condición, el handle elegido en los switch y un error solo cuando la ejecución completa debe
+// abortarse
+func (e *estadoFlujo) ejecutarNodo(n estructuras.NodoGenerico) (bool, string, error) {
+	asignaciones := make(map[string]interface{})
+	cumple := false
+	caso := ""
+	var err error
+
+	switch n.Type {
+
+	case "proceso":
+		// ✅ Obtener el servidorId desde n.Data
+		servidorID, ok := n.Data["servidorId"].(string)
+		if !ok || servidorID == "" {
+			e.erroresPorNodo[n.ID] = true
+			e.resultado["codigoError"] = 98
+			e.resultado["mensajeError"] = "servidorId no definido en el nodo"
+			break
+		}
+
+		// 🔒 Dentro de una transacción del flujo el nodo usa su *sql.Tx
+		ctxNodo, _, liberar := e.contextoTransaccion(n, servidorID)
+
+		// 🧠 Ejecutar el nodo tipo proceso desde módulo central
+		newResultado, _, newAsignaciones, estado, _, err := ejecutarNodoProceso(ctxNodo, n, e.resultado, e.input, e.db, e.canalCodigo, e.proc, e.inicio, e.compilado.configProceso(n), e.contexto)
+		liberar()
+		if err != nil {
+			e.erroresPorNodo[n.ID] = true
+		}
+		e.resultado = newResultado
+		for k, v := range newAsignaciones {
+			e.asignacionesAplicadas[k] = v
+		}
+		if estado == 99 {
+			e.erroresPorNodo[n.ID] = true
+		}
+
+	case "salida":
+		e.respuestaFinal, asignaciones, err = ejecutarNodoSalida(n, e.resultado)
+		if err != nil {
+			return false, "", fmt.Errorf("error en nodo salida: %w", err)
+		}
+		for k, v := range asignaciones {
+			e.asignacionesAplicadas[k] = v
+		}
+
+	case "salidaError":
+		e.respuestaFinal, asignaciones = ejecutarNodoSalidaError(n, e.resultado)
+		for k, v := range asignaciones {
+			e.asignacionesAplicadas[k] = v
+		}
+
+	case "subproceso":
+		newResultado, newAsignaciones, err := ejecutarNodoSubproceso(e.ctx, n, e.resultado, e.contexto, e.canalCodigo)
+		if err != nil {
+			e.erroresPorNodo[n.ID] = true
+			e.resultado["codigoError"] = "SUB_ERROR"
+			e.resultado["mensajeError"] = fmt.Sprintf("Error en subproceso: %v", err)
+		} else {
+			e.resultado = newResultado
+			for k, v := range newAsignaciones {
+				e.asignacionesAplicadas[k] = v
+			}
+			// Verificar si el subproceso retornó error
+			if codigo, ok := e.resultado["codigoError"]; ok && codigo != nil && codigo != "" {
+				e.erroresPorNodo[n.ID] = true
+			}
+		}
+
+	case "splitter":
+		newResultado, newAsignaciones, err := ejecutarNodoSplitter(n, e.resultado, e.canalCodigo)
+		if err != nil {
+			e.erroresPorNodo[n.ID] = true
+			e.resultado["codigoError"] = "SPLITTER_ERROR"
+			e.resultado["mensajeError"] = fmt.Sprintf("Error en splitter: %v", err)
+		} else {
+			e.resultado = newResultado
+			for k, v := range newAsignaciones {
+				e.asignacionesAplicadas[k] = v
+			}
+		}
+
+	case "condicion":
+		cumple, err = ejecutarNodoCondicion(n, e.resultado, e.canalCodigo)
+		if err != nil {
+			return false, "", fmt.Errorf("error en nodo condición: %w", err)
+		}
+
+		campos := "parametrosError"
+		if cumple {
+			campos = "parametrosSalida"
+		}
+		if camposRaw, ok := n.Data[campos]; ok {
+			if camposBytes, err := json.Marshal(camposRaw); err == nil {
+				var camposNodo []estructuras.Campo
+				if err := json.Unmarshal(camposBytes, &camposNodo); err == nil {
+					for _, campo := range camposNodo {
+						if val, ok := e.resultado[campo.Nombre]; ok {
+							e.asignacionesAplicadas[campo.Nombre] = val
+						}
+					}
+				}
+			}
+		}
+
+	case "switch":
+		if caso, err = ejecutarNodoSwitch(n, e.resultado, e.grafo.salientes[n.ID], e.canalCodigo); err != nil {
+			e.erroresPorNodo[n.ID] = true
+			e.resultado["codigoError"] = "SWITCH_ERROR"
+			e.resultado["mensajeError"] = "Error en nodo switch"
+			e.resultado["detalleError"] = err.Error()
+		}
+
+	case "transformar":
+		newAsignaciones, err := ejecutarNodoTransformar(n, e.resultado, e.canalCodigo)
+		if err != nil {
+			e.erroresPorNodo[n.ID] = true
+			e.resultado["codigoError"] = "TRANSFORMAR_ERROR"
+			e.resultado["mensajeError"] = "Error en nodo transformar"
+			e.resultado["detalleError"] = err.Error()
+		} else {
+			for k, v := range newAsignaciones {
+				e.asignacionesAplicadas[k] = v
+			}
+		}
+
+	case "iniciarTransaccion":
+		e.ejecutarIniciarTransaccion(n)
+
+	case "confirmarTransaccion":
+		e.ejecutarConfirmarTransaccion(n)
+
+	case "union", "finIterar":
+		// La fusión de ramas o iteraciones ya la hizo el nodo que abre el bloque; aquí solo se enruta
+		fmt.Printf("🔗 Nodo %s %s alcanzado (error=%v)\n", n.Type, n.ID, e.erroresPorNodo[n.ID])
+	}
+
+	return cumple, caso, nil
+}
+
+// combinarConGlobales agrega las variables globales heredadas que no estén ya definidas
+func combinarConGlobales(valores map[string]interface{}, globales map[string]interface{}) map[string]interface{} {
+	combinado := make(map[string]interface{}, len(valores)+len(globales))
+	for k, v := range globales {
+		combinado[k] = v
+	}
+	for k, v := range valores {
+		combinado[k] = v
+	}
+	return combinado
+}
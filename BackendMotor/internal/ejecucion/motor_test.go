@@ -0,0 +1,54 @@
+package ejecucion
+
+import (
+	"backendmotor/internal/estructuras"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// nodo arma un nodo de prueba con sus datos
+func nodo(id, tipo string, data map[string]interface{}) estructuras.NodoGenerico {
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	return estructuras.NodoGenerico{ID: id, Type: tipo, Data: data}
+}
+
+// estadoDePrueba compila el flujo y arma el estado con el que corre el motor, sin base de datos
+// ni traza; resultado son las variables con las que arranca el recorrido
+func estadoDePrueba(t *testing.T, nodos []estructuras.NodoGenerico, aristas []string, resultado map[string]interface{}) *estadoFlujo {
+	t.Helper()
+	flujo, err := json.Marshal(estructuras.Flujo{Nodes: nodos, Edges: aristasDePrueba(aristas)})
+	if err != nil {
+		t.Fatalf("error serializando el flujo: %v", err)
+	}
+	fc, err := CompilarFlujo(procesoDePrueba(string(flujo)))
+	if err != nil {
+		t.Fatalf("error compilando el flujo: %v", err)
+	}
+	if resultado == nil {
+		resultado = map[string]interface{}{}
+	}
+	return &estadoFlujo{
+		ctx:                   context.Background(),
+		proc:                  fc.Proceso,
+		contexto:              &ContextoSubproceso{ProcesoID: fc.Proceso.ID, TraceID: "prueba", Inicio: time.Now()},
+		inicio:                time.Now(),
+		compilado:             fc,
+		grafo:                 fc.grafo,
+		compensaciones:        &registroCompensaciones{},
+		transacciones:         &registroTransacciones{abiertas: make(map[string]*transaccionFlujo)},
+		resultado:             resultado,
+		asignacionesAplicadas: make(map[string]interface{}),
+		erroresPorNodo:        make(map[string]bool),
+		respuestaFinal:        make(map[string]interface{}),
+		visitados:             make(map[string]bool),
+	}
+}
+
+// recorrerDesde ejecuta el flujo completo a partir de inicio
+func (e *estadoFlujo) recorrerDesde(inicio string) error {
+	return e.recorrer(nuevoPlanificador(e.grafo, inicio))
+}
@@ -26,26 +26,8 @@ func ejecutarNodoCondicion(
 		return false, fmt.Errorf("la propiedad 'condicion' no es un string válido")
 	}
 
-	// 🧠 Paso 2: Construir contexto desde resultado actual
-	ctx := map[string]interface{}{}
-	for k, v := range resultado {
-		ctx[k] = v
-	}
-	ctx["__fecha"] = time.Now()
-	ctx["__usuario"] = "demoUser"
-	ctx["__rol"] = "admin"
-	ctx["__nombreProceso"] = n.Data["label"]
-	ctx["__idFlujo"] = n.ID
-
-	// 🧠 Paso 3: Normalizar tipos según definición de entrada
-	if entradaRaw, ok := n.Data["parametrosEntrada"]; ok {
-		if entradaBytes, err := json.Marshal(entradaRaw); err == nil {
-			var campos []utils.Campo
-			if err := json.Unmarshal(entradaBytes, &campos); err == nil {
-				ctx = utils.NormalizarContextoSegunTipos(campos, ctx)
-			}
-		}
-	}
+	// 🧠 Paso 2 y 3: Construir contexto desde resultado actual, con los tipos de parametrosEntrada
+	ctx := contextoExpresion(n, resultado)
 
 	// ✅ Paso 4: Evaluar la condición lógica
 	cumple, err := utils.EvaluarExpresion(condicionStr, ctx)
@@ -141,3 +123,27 @@ func ejecutarNodoCondicion(
 
 	return cumple, nil
 }
+
+// contextoExpresion arma las variables con las que se evalúan las expresiones de condicion y
+// switch: el resultado actual, las variables de sistema y los tipos declarados en parametrosEntrada
+func contextoExpresion(n estructuras.NodoGenerico, resultado map[string]interface{}) map[string]interface{} {
+	ctx := map[string]interface{}{}
+	for k, v := range resultado {
+		ctx[k] = v
+	}
+	ctx["__fecha"] = time.Now()
+	ctx["__usuario"] = "demoUser"
+	ctx["__rol"] = "admin"
+	ctx["__nombreProceso"] = n.Data["label"]
+	ctx["__idFlujo"] = n.ID
+
+	if entradaRaw, ok := n.Data["parametrosEntrada"]; ok {
+		if entradaBytes, err := json.Marshal(entradaRaw); err == nil {
+			var campos []utils.Campo
+			if err := json.Unmarshal(entradaBytes, &campos); err == nil {
+				ctx = utils.NormalizarContextoSegunTipos(campos, ctx)
+			}
+		}
+	}
+	return ctx
+}
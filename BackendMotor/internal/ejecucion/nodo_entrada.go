@@ -58,16 +58,19 @@ func ejecutarNodoEntrada(n estructuras.NodoGenerico, input map[string]interface{
 		}
 	}
 
-	// 🔄 Ejecutar campos en orden (CLAVE para dependencias)
+	// 🔄 Ejecutar campos en orden (CLAVE para dependencias): las funciones ven el input y los
+	// campos ya resueltos, así un campo puede armarse a partir de otro
+	contextoCampos := superficial(input)
 	for _, campo := range camposEntrada {
 		if campo.Asignacion != nil {
 			// Resolver asignación usando el nuevo sistema (con compatibilidad total)
-			valor, err := resolverAsignacionCampo(*campo.Asignacion, input)
+			valor, err := resolverAsignacionCampo(*campo.Asignacion, input, contextoCampos)
 			if err != nil {
 				return nil, nil, fmt.Errorf("error resolviendo asignación para campo '%s': %w", campo.Nombre, err)
 			}
 			resultado[campo.Nombre] = valor
 			asignaciones[campo.Nombre] = valor
+			contextoCampos[campo.Nombre] = valor
 		} else if val, ok := input[campo.Nombre]; ok {
 			// Comportamiento original: campo directo del input
 			resultado[campo.Nombre] = val
@@ -81,20 +84,20 @@ func ejecutarNodoEntrada(n estructuras.NodoGenerico, input map[string]interface{
 }
 
 // resolverAsignacionCampo resuelve una asignación de campo manteniendo compatibilidad total
-func resolverAsignacionCampo(asig AsignacionEntrada, input map[string]interface{}) (interface{}, error) {
+func resolverAsignacionCampo(asig AsignacionEntrada, input map[string]interface{}, contextoCampos map[string]interface{}) (interface{}, error) {
 
 	// Mantener comportamiento original para tipos existentes (COMPATIBILIDAD TOTAL)
 	switch asig.Tipo {
 	case "literal":
 		return asig.Valor, nil
 	case "campo":
-		if val, ok := input[asig.Valor]; ok {
+		if val, ok := utils.ResolverRuta(input, asig.Valor); ok {
 			return val, nil
 		}
 		return nil, fmt.Errorf("no se encontró el valor '%s' en input", asig.Valor)
-	case "funcion":
-		// Nueva funcionalidad: evaluar función del sistema
-		return resolverFuncionDelSistema(asig.Valor, input)
+	case "funcion", "sistema":
+		// Función o expresión del sistema: Ahora(), SubTexto(cuenta, 0, 4) + "-" + nombre
+		return resolverFuncionDelSistema(asig.Valor, contextoCampos)
 	case "tabla":
 		// Nueva funcionalidad: consultar tabla local
 		return resolverAsignacionTablaLocal(asig, input)
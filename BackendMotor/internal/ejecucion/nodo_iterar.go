@@ -0,0 +1,337 @@
+package ejecucion
+
+import (
+	"backendmotor/internal/estructuras"
+	"backendmotor/internal/utils"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Comportamientos del nodo iterar cuando una iteración falla
+const (
+	IterarDetener   = "detener"   // se cancelan las iteraciones pendientes y el fin sale por error
+	IterarContinuar = "continuar" // se recolectan los errores y el flujo sigue normalmente
+)
+
+// configIterar son los datos del nodo iterar con sus valores por defecto
+type configIterar struct {
+	Variable     string   // arreglo en resultado sobre el que se itera
+	Elemento     string   // variable con el elemento actual
+	Indice       string   // variable con la posición actual (desde 0)
+	Salida       string   // arreglo donde se guardan los resultados de cada iteración
+	Errores      string   // arreglo donde se guardan los errores (modo continuar)
+	Recolectar   []string // variables a guardar por iteración; vacío = todo lo que cambió
+	Concurrencia int
+	AlError      string
+}
+
+// iteracion es una pasada del cuerpo del iterar sobre un elemento. Su estado (una copia
+// superficial de resultado con el elemento y el índice) se arma al tomar un cupo y se suelta al
+// terminar: solo queda lo que se recolecta
+type iteracion struct {
+	indice         int
+	salida         map[string]interface{}
+	visitados      map[string]bool
+	erroresPorNodo map[string]bool
+	asignaciones   map[string]interface{}
+	codigoError    interface{}
+	mensajeError   interface{}
+	err            error
+	omitida        bool
+}
+
+func leerConfigIterar(n estructuras.NodoGenerico) configIterar {
+	cfg := configIterar{
+		Elemento:     "elemento",
+		Indice:       "indice",
+		Salida:       "resultados",
+		Errores:      "errores",
+		Concurrencia: 1,
+		AlError:      IterarDetener,
+	}
+	if v, ok := n.Data["variable"].(string); ok {
+		cfg.Variable = v
+	}
+	for campo, destino := range map[string]*string{"elemento": &cfg.Elemento, "indice": &cfg.Indice, "salida": &cfg.Salida, "errores": &cfg.Errores} {
+		if v, ok := n.Data[campo].(string); ok && v != "" {
+			*destino = v
+		}
+	}
+	if lista, ok := n.Data["recolectar"].([]interface{}); ok {
+		for _, v := range lista {
+			if s, ok := v.(string); ok && s != "" {
+				cfg.Recolectar = append(cfg.Recolectar, s)
+			}
+		}
+	}
+	if c, ok := n.Data["concurrencia"].(float64); ok && c > 1 {
+		cfg.Concurrencia = int(c)
+	}
+	if a, ok := n.Data["alError"].(string); ok && a == IterarContinuar {
+		cfg.AlError = IterarContinuar
+	}
+	return cfg
+}
+
+// ejecutarIterar corre el cuerpo entre el nodo iterar y su finIterar una vez por elemento del
+// arreglo, con el elemento y el índice como variables, y junta lo producido en un arreglo
+func (e *estadoFlujo) ejecutarIterar(n estructuras.NodoGenerico, plan *planificador) error {
+	inicio := time.Now()
+	cfg := leerConfigIterar(n)
+
+	fallar := func(mensaje string, detalle string) {
+		e.erroresPorNodo[n.ID] = true
+		e.resultado["codigoError"] = "ITERAR_ERROR"
+		e.resultado["mensajeError"] = mensaje
+		e.resultado["detalleError"] = detalle
+		plan.completar(n.ID, e.grafo.aristasTomadas(n, true, false))
+	}
+
+	fin, err := e.grafo.buscarCierre(n, "finIterar", "finId")
+	if err != nil {
+		fallar("Nodo iterar sin finIterar", err.Error())
+		return nil
+	}
+
+	valor, _ := utils.ResolverRuta(e.resultado, cfg.Variable)
+	elementos, ok := elementosIterables(valor)
+	if !ok {
+		fallar("Variable a iterar inválida", fmt.Sprintf("la variable '%s' no existe o no es un arreglo", cfg.Variable))
+		return nil
+	}
+
+	region := e.grafo.regionParalela(n.ID, fin)
+	fmt.Printf("🔁 Iterar %s: %d elementos de '%s' hasta %s (concurrencia %d, alError %s)\n", n.ID, len(elementos), cfg.Variable, fin, cfg.Concurrencia, cfg.AlError)
+
+	// 🔁 Paso 1: Ejecutar con a lo sumo cfg.Concurrencia iteraciones a la vez; cada una arma su
+	// estado recién cuando tiene cupo, así nunca hay más copias vivas que la concurrencia
+	ctxIter, cancelar := context.WithCancel(e.ctx)
+	defer cancelar()
+
+	iteraciones := make([]*iteracion, len(elementos))
+	for i := range iteraciones {
+		iteraciones[i] = &iteracion{indice: i}
+	}
+	omitidas := repartirIteraciones(ctxIter, len(elementos), cfg.Concurrencia, func(i int) {
+		it := iteraciones[i]
+
+		estadoIter := e.copiaParaRama()
+		estadoIter.ctx = ctxIter
+		estadoIter.rama = fmt.Sprintf("%s[%d]", n.ID, i)
+		estadoIter.resultado[cfg.Elemento] = elementos[i]
+		estadoIter.resultado[cfg.Indice] = i
+		estadoIter.anotarEscritas([]string{cfg.Elemento, cfg.Indice})
+		planIter := nuevoPlanificadorCuerpo(e.grafo, n.ID, region)
+
+		it.err = estadoIter.recorrer(planIter)
+		if it.err == nil {
+			it.err = errorDeIteracion(planIter, fin)
+		}
+		if it.err != nil && cfg.AlError == IterarDetener {
+			cancelar()
+		}
+
+		// 📦 Quedarse solo con lo que se recolecta; la copia de resultado se libera aquí
+		if it.err == nil {
+			it.salida = salidaDeIteracion(estadoIter.resultado, estadoIter.escritas, cfg)
+		}
+		it.visitados = estadoIter.visitados
+		it.erroresPorNodo = estadoIter.erroresPorNodo
+		it.asignaciones = estadoIter.asignacionesAplicadas
+		it.codigoError = estadoIter.resultado["codigoError"]
+		it.mensajeError = estadoIter.resultado["mensajeError"]
+	})
+	for _, i := range omitidas {
+		iteraciones[i].omitida = true
+	}
+
+	// 🧺 Paso 2: Recolectar salidas y errores en orden de índice
+	salidas := make([]interface{}, 0, len(iteraciones))
+	var errores []interface{}
+	var primerError *iteracion
+	canceladoPorError := ctxIter.Err() != nil && e.ctx.Err() == nil
+
+	for _, it := range iteraciones {
+		if it.omitida {
+			continue
+		}
+		for k, v := range it.visitados {
+			e.visitados[k] = v
+		}
+		for k, v := range it.erroresPorNodo {
+			e.erroresPorNodo[k] = v
+		}
+		for k, v := range it.asignaciones {
+			e.asignacionesAplicadas[k] = v
+		}
+
+		if it.err != nil {
+			// Una iteración interrumpida por el error de otra no cuenta como error propio
+			if canceladoPorError && errors.Is(it.err, context.Canceled) {
+				continue
+			}
+			if primerError == nil {
+				primerError = it
+			}
+			errores = append(errores, map[string]interface{}{
+				"indice":       it.indice,
+				"error":        it.err.Error(),
+				"codigoError":  it.codigoError,
+				"mensajeError": it.mensajeError,
+			})
+			continue
+		}
+
+		salidas = append(salidas, it.salida)
+	}
+
+	e.resultado[cfg.Salida] = salidas
+	if cfg.AlError == IterarContinuar {
+		if errores == nil {
+			errores = []interface{}{}
+		}
+		e.resultado[cfg.Errores] = errores
+	}
+
+	if e.ctx.Err() != nil {
+		return fmt.Errorf("ejecución cancelada: %w", e.ctx.Err())
+	}
+
+	if primerError != nil && cfg.AlError == IterarDetener {
+		e.erroresPorNodo[fin] = true
+		codigo := primerError.codigoError
+		if codigo == nil || codigo == "" {
+			codigo = "ITERAR_ERROR"
+		}
+		e.resultado["codigoError"] = codigo
+		e.resultado["mensajeError"] = fmt.Sprintf("Error en la iteración %d", primerError.indice)
+		e.resultado["detalleError"] = primerError.err.Error()
+	}
+
+	// ✅ Paso 3: Dar el cuerpo por ejecutado y liberar el finIterar en el planificador principal
+	regionCompleta := map[string]bool{n.ID: true}
+	for id := range region {
+		regionCompleta[id] = true
+	}
+	plan.completarRegion(regionCompleta, fin)
+
+	registro := utils.RegistroEjecucion{
+		Timestamp:     time.Now().Format(time.RFC3339),
+		ProcesoId:     e.proc.ID,
+		NombreProceso: e.proc.Nombre,
+		Canal:         e.canalCodigo,
+		TipoObjeto:    "iterar",
+		NombreObjeto:  n.ID,
+		TraceID:       e.contexto.TraceID,
+		Parametros:    map[string]interface{}{"variable": cfg.Variable, "elementos": len(elementos), "concurrencia": cfg.Concurrencia, "alError": cfg.AlError},
+		Resultado:     map[string]interface{}{"iteracionesOk": len(salidas), "errores": len(errores)},
+		Estado:        "exito",
+		DuracionMs:    time.Since(inicio).Milliseconds(),
+	}
+	if primerError != nil {
+		registro.Estado = "error"
+		registro.DetalleError = primerError.err.Error()
+	}
+	utils.RegistrarEjecucionLog(registro)
+
+	return nil
+}
+
+// errorDeIteracion revisa que la pasada haya llegado al finIterar sin salirse del cuerpo
+func errorDeIteracion(plan *planificador, fin string) error {
+	var escapes []string
+	for id := range plan.fugas {
+		if id != fin {
+			escapes = append(escapes, id)
+		}
+	}
+	if len(escapes) > 0 {
+		sort.Strings(escapes)
+		return fmt.Errorf("la iteración terminó fuera del cuerpo (%v)", escapes)
+	}
+	if !plan.fugas[fin] {
+		return fmt.Errorf("la iteración no llegó al nodo %s", fin)
+	}
+	return nil
+}
+
+// repartirIteraciones llama a correr(i) para cada posición con a lo sumo concurrencia llamadas a
+// la vez. Cuando ctx se cancela (una iteración falló en modo detener) ya no se lanzan las que
+// faltan: se devuelven sus posiciones como omitidas
+func repartirIteraciones(ctx context.Context, total int, concurrencia int, correr func(i int)) []int {
+	if concurrencia < 1 {
+		concurrencia = 1
+	}
+	var omitidas []int
+	var wg sync.WaitGroup
+	cupos := make(chan struct{}, concurrencia)
+	for i := 0; i < total; i++ {
+		cupos <- struct{}{}
+		if ctx.Err() != nil {
+			<-cupos
+			omitidas = append(omitidas, i)
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-cupos }()
+			correr(i)
+		}(i)
+	}
+	wg.Wait()
+	return omitidas
+}
+
+// salidaDeIteracion toma las variables indicadas en recolectar o, si no hay, todo lo que la
+// iteración escribió (incluidos el elemento y el índice)
+func salidaDeIteracion(resultado map[string]interface{}, escritas map[string]bool, cfg configIterar) map[string]interface{} {
+	salida := make(map[string]interface{})
+	if len(cfg.Recolectar) > 0 {
+		for _, k := range cfg.Recolectar {
+			salida[k] = resultado[k]
+		}
+		return salida
+	}
+	for k := range escritas {
+		if clavesSistemaParalelo[k] {
+			continue
+		}
+		if v, ok := resultado[k]; ok {
+			salida[k] = v
+		}
+	}
+	return salida
+}
+
+// elementosIterables convierte a []interface{} cualquier arreglo o slice guardado en resultado
+func elementosIterables(valor interface{}) ([]interface{}, bool) {
+	switch v := valor.(type) {
+	case nil:
+		return nil, false
+	case []interface{}:
+		return v, true
+	case []map[string]interface{}:
+		elementos := make([]interface{}, len(v))
+		for i, item := range v {
+			elementos[i] = item
+		}
+		return elementos, true
+	}
+
+	rv := reflect.ValueOf(valor)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, false
+	}
+	elementos := make([]interface{}, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		elementos[i] = rv.Index(i).Interface()
+	}
+	return elementos, true
+}
@@ -0,0 +1,242 @@
+package ejecucion
+
+import (
+	"backendmotor/internal/estructuras"
+	"context"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flujoIterar arma entrada → iterar → cuerpo → finIterar con los datos de iterar indicados
+func flujoIterar(datosIterar map[string]interface{}, cuerpo estructuras.NodoGenerico) ([]estructuras.NodoGenerico, []string) {
+	datosIterar["finId"] = "f"
+	nodos := []estructuras.NodoGenerico{
+		nodo("e", "entrada", nil),
+		nodo("it", "iterar", datosIterar),
+		cuerpo,
+		nodo("f", "finIterar", nil),
+	}
+	return nodos, []string{"e>it", "it>" + cuerpo.ID, cuerpo.ID + ">f"}
+}
+
+func TestSalidaDeIteracion(t *testing.T) {
+	resultado := map[string]interface{}{
+		"elemento":   1,
+		"indice":     0,
+		"doble":      2,
+		"previa":     "sin tocar",
+		"FullOutput": map[string]interface{}{"x": 1},
+	}
+	escritas := map[string]bool{"elemento": true, "indice": true, "doble": true, "FullOutput": true}
+
+	casos := []struct {
+		nombre     string
+		recolectar []string
+		esperado   map[string]interface{}
+	}{
+		{
+			nombre:   "sin recolectar toma solo lo escrito",
+			esperado: map[string]interface{}{"elemento": 1, "indice": 0, "doble": 2},
+		},
+		{
+			nombre:     "recolectar elige las variables",
+			recolectar: []string{"doble", "previa"},
+			esperado:   map[string]interface{}{"doble": 2, "previa": "sin tocar"},
+		},
+		{
+			nombre:     "recolectar una variable inexistente da nil",
+			recolectar: []string{"falta"},
+			esperado:   map[string]interface{}{"falta": nil},
+		},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			obtenido := salidaDeIteracion(resultado, escritas, configIterar{Recolectar: c.recolectar})
+			if !reflect.DeepEqual(obtenido, c.esperado) {
+				t.Fatalf("se esperaba %v y se obtuvo %v", c.esperado, obtenido)
+			}
+		})
+	}
+}
+
+func TestRepartirIteracionesConcurrencia(t *testing.T) {
+	casos := []struct {
+		nombre       string
+		concurrencia int
+		maximo       int32
+	}{
+		{"secuencial", 1, 1},
+		{"sin concurrencia cuenta como uno", 0, 1},
+		{"tres a la vez", 3, 3},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			var activas, maximo int32
+			var corridas sync.Map
+			omitidas := repartirIteraciones(context.Background(), 12, c.concurrencia, func(i int) {
+				n := atomic.AddInt32(&activas, 1)
+				for {
+					m := atomic.LoadInt32(&maximo)
+					if n <= m || atomic.CompareAndSwapInt32(&maximo, m, n) {
+						break
+					}
+				}
+				time.Sleep(5 * time.Millisecond)
+				atomic.AddInt32(&activas, -1)
+				corridas.Store(i, true)
+			})
+
+			if len(omitidas) != 0 {
+				t.Fatalf("no se esperaban omitidas y se obtuvo %v", omitidas)
+			}
+			if maximo != c.maximo {
+				t.Fatalf("se esperaban %d iteraciones a la vez y hubo %d", c.maximo, maximo)
+			}
+			for i := 0; i < 12; i++ {
+				if _, ok := corridas.Load(i); !ok {
+					t.Fatalf("la iteración %d no corrió", i)
+				}
+			}
+		})
+	}
+}
+
+func TestRepartirIteracionesCancelacion(t *testing.T) {
+	ctx, cancelar := context.WithCancel(context.Background())
+	defer cancelar()
+
+	omitidas := repartirIteraciones(ctx, 6, 1, func(i int) {
+		if i == 2 {
+			cancelar()
+		}
+	})
+	if esperado := []int{3, 4, 5}; !reflect.DeepEqual(omitidas, esperado) {
+		t.Fatalf("se esperaban omitidas %v y se obtuvo %v", esperado, omitidas)
+	}
+}
+
+func TestEjecutarIterarRecolecta(t *testing.T) {
+	items := []interface{}{1.0, 2.0, 3.0}
+	cuerpo := nodo("t", "transformar", map[string]interface{}{"plantilla": `"{{ elemento * 2 }}"`, "variableSalida": "doble"})
+
+	casos := []struct {
+		nombre   string
+		datos    map[string]interface{}
+		esperado []interface{}
+	}{
+		{
+			nombre: "todo lo escrito",
+			datos:  map[string]interface{}{"variable": "items"},
+			esperado: []interface{}{
+				map[string]interface{}{"elemento": 1.0, "indice": 0, "doble": 2.0},
+				map[string]interface{}{"elemento": 2.0, "indice": 1, "doble": 4.0},
+				map[string]interface{}{"elemento": 3.0, "indice": 2, "doble": 6.0},
+			},
+		},
+		{
+			nombre: "recolectar y concurrencia",
+			datos:  map[string]interface{}{"variable": "items", "recolectar": []interface{}{"doble"}, "concurrencia": 3.0},
+			esperado: []interface{}{
+				map[string]interface{}{"doble": 2.0},
+				map[string]interface{}{"doble": 4.0},
+				map[string]interface{}{"doble": 6.0},
+			},
+		},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			nodos, aristas := flujoIterar(c.datos, cuerpo)
+			e := estadoDePrueba(t, nodos, aristas, map[string]interface{}{"items": items})
+			if err := e.recorrerDesde("e"); err != nil {
+				t.Fatalf("error inesperado: %v", err)
+			}
+			if !reflect.DeepEqual(e.resultado["resultados"], c.esperado) {
+				t.Fatalf("se esperaba %v y se obtuvo %v", c.esperado, e.resultado["resultados"])
+			}
+			// Lo que escribe cada iteración no se filtra al resultado principal
+			for _, k := range []string{"doble", "elemento", "indice"} {
+				if _, ok := e.resultado[k]; ok {
+					t.Fatalf("la variable %s de la iteración quedó en el resultado principal", k)
+				}
+			}
+			if e.erroresPorNodo["f"] {
+				t.Fatalf("el finIterar no debía salir por error")
+			}
+		})
+	}
+}
+
+func TestEjecutarIterarErrores(t *testing.T) {
+	// El transformar falla al dividir por cero en la segunda iteración y, sin conexión de error,
+	// la iteración no llega al finIterar
+	cuerpo := nodo("t", "transformar", map[string]interface{}{"plantilla": `"{{ 10 / elemento }}"`, "variableSalida": "cociente"})
+	items := []interface{}{1.0, 0.0, 2.0}
+
+	t.Run("detener", func(t *testing.T) {
+		nodos, aristas := flujoIterar(map[string]interface{}{"variable": "items", "recolectar": []interface{}{"indice"}}, cuerpo)
+		e := estadoDePrueba(t, nodos, aristas, map[string]interface{}{"items": items})
+		if err := e.recorrerDesde("e"); err != nil {
+			t.Fatalf("error inesperado: %v", err)
+		}
+		if !e.erroresPorNodo["f"] {
+			t.Fatalf("el finIterar debía salir por error")
+		}
+		if e.resultado["codigoError"] != "TRANSFORMAR_ERROR" || e.resultado["mensajeError"] != "Error en la iteración 1" {
+			t.Fatalf("error inesperado en el resultado: %v / %v", e.resultado["codigoError"], e.resultado["mensajeError"])
+		}
+		// La tercera iteración no llega a correr
+		esperado := []interface{}{map[string]interface{}{"indice": 0}}
+		if !reflect.DeepEqual(e.resultado["resultados"], esperado) {
+			t.Fatalf("se esperaba %v y se obtuvo %v", esperado, e.resultado["resultados"])
+		}
+		if _, ok := e.resultado["errores"]; ok {
+			t.Fatalf("en modo detener no se recolectan errores")
+		}
+	})
+
+	t.Run("continuar", func(t *testing.T) {
+		nodos, aristas := flujoIterar(map[string]interface{}{"variable": "items", "recolectar": []interface{}{"indice"}, "alError": IterarContinuar}, cuerpo)
+		e := estadoDePrueba(t, nodos, aristas, map[string]interface{}{"items": items})
+		if err := e.recorrerDesde("e"); err != nil {
+			t.Fatalf("error inesperado: %v", err)
+		}
+		if e.erroresPorNodo["f"] {
+			t.Fatalf("en modo continuar el finIterar no sale por error")
+		}
+		esperado := []interface{}{map[string]interface{}{"indice": 0}, map[string]interface{}{"indice": 2}}
+		if !reflect.DeepEqual(e.resultado["resultados"], esperado) {
+			t.Fatalf("se esperaba %v y se obtuvo %v", esperado, e.resultado["resultados"])
+		}
+		errores, _ := e.resultado["errores"].([]interface{})
+		if len(errores) != 1 {
+			t.Fatalf("se esperaba un error y se obtuvo %v", e.resultado["errores"])
+		}
+		if detalle := errores[0].(map[string]interface{}); detalle["indice"] != 1 || detalle["codigoError"] != "TRANSFORMAR_ERROR" {
+			t.Fatalf("error recolectado inesperado: %v", detalle)
+		}
+	})
+}
+
+func TestEjecutarIterarNoCopiaElResultado(t *testing.T) {
+	// Las iteraciones comparten los valores del resultado principal en lugar de copiarlos
+	catalogo := map[string]interface{}{"precio": 10.0}
+	cuerpo := nodo("t", "transformar", map[string]interface{}{"plantilla": `"{{ catalogo.precio * elemento }}"`, "variableSalida": "total"})
+	nodos, aristas := flujoIterar(map[string]interface{}{"variable": "items", "recolectar": []interface{}{"total", "catalogo"}}, cuerpo)
+	e := estadoDePrueba(t, nodos, aristas, map[string]interface{}{"items": []interface{}{2.0}, "catalogo": catalogo})
+	if err := e.recorrerDesde("e"); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	salida := e.resultado["resultados"].([]interface{})[0].(map[string]interface{})
+	if salida["total"] != 20.0 {
+		t.Fatalf("se esperaba total 20 y se obtuvo %v", salida["total"])
+	}
+	if !mismoValor(salida["catalogo"], catalogo) {
+		t.Fatalf("la iteración debía ver el mismo mapa del resultado principal, no una copia")
+	}
+}
@@ -0,0 +1,378 @@
+package ejecucion
+
+import (
+	"backendmotor/internal/estructuras"
+	"backendmotor/internal/utils"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Políticas para fusionar en el nodo unión las variables escritas por cada rama
+const (
+	PoliticaUltimoGana     = "ultimoGana"     // la última rama (en orden de conexión) sobrescribe
+	PoliticaError          = "error"          // dos ramas que escriben valores distintos en la misma variable es un error
+	PoliticaEspacioNombres = "espacioNombres" // los cambios de cada rama quedan en resultado[nombreRama]
+)
+
+// Variables que escriben todos los nodos y no se consideran conflicto entre ramas
+var clavesSistemaParalelo = map[string]bool{
+	"FullOutput": true,
+	"fullOutput": true,
+	"cumple":     true,
+	"resultado":  true,
+}
+
+// ramaParalela es una de las ramas que salen de un nodo paralelo
+type ramaParalela struct {
+	nombre string
+	estado *estadoFlujo
+	plan   *planificador
+	err    error
+}
+
+// ejecutarParalelo ejecuta en goroutines las ramas entre un nodo paralelo y su unión,
+// cada una con su propia copia de resultado, y fusiona los cambios al terminar todas
+func (e *estadoFlujo) ejecutarParalelo(n estructuras.NodoGenerico, plan *planificador) error {
+	inicio := time.Now()
+
+	union, err := e.grafo.buscarCierre(n, "union", "unionId")
+	if err != nil {
+		e.erroresPorNodo[n.ID] = true
+		e.resultado["codigoError"] = "PARALELO_ERROR"
+		e.resultado["mensajeError"] = "Nodo paralelo sin unión"
+		e.resultado["detalleError"] = err.Error()
+		plan.completar(n.ID, e.grafo.aristasTomadas(n, true, false))
+		return nil
+	}
+
+	politica := PoliticaUltimoGana
+	if p, ok := n.Data["politicaConflicto"].(string); ok && p != "" {
+		politica = normalizarPoliticaConflicto(p)
+	}
+
+	region := e.grafo.regionParalela(n.ID, union)
+
+	// 🌿 Paso 1: Preparar una rama por cada conexión que sale del paralelo
+	var ramas []*ramaParalela
+	for _, a := range e.grafo.salientes[n.ID] {
+		if a.Type == "error" || !region[a.Target] {
+			continue
+		}
+		nombre := a.SourceHandle
+		if nombre == "" {
+			nombre = a.Target
+		}
+		estadoRama := e.copiaParaRama()
+		estadoRama.rama = nombre
+		ramas = append(ramas, &ramaParalela{
+			nombre: nombre,
+			estado: estadoRama,
+			plan:   nuevoPlanificadorRegion(e.grafo, a.Target, region),
+		})
+	}
+
+	fmt.Printf("🌿 Paralelo %s: %d ramas hasta unión %s (política %s)\n", n.ID, len(ramas), union, politica)
+
+	// 🚀 Paso 2: Ejecutar todas las ramas concurrentemente
+	var wg sync.WaitGroup
+	for _, r := range ramas {
+		wg.Add(1)
+		go func(r *ramaParalela) {
+			defer wg.Done()
+			r.err = r.estado.recorrer(r.plan)
+		}(r)
+	}
+	wg.Wait()
+
+	// 🔗 Paso 3: Fusionar en el resultado, en el orden de las conexiones, solo lo que escribió cada rama
+	fusion := e.resultado
+	escritoPor := make(map[string]string)
+	var fallos []string
+	detalleRamas := make(map[string]interface{})
+
+	for _, r := range ramas {
+		for k, v := range r.estado.visitados {
+			e.visitados[k] = v
+		}
+		for k, v := range r.estado.erroresPorNodo {
+			e.erroresPorNodo[k] = v
+		}
+		for k, v := range r.estado.asignacionesAplicadas {
+			e.asignacionesAplicadas[k] = v
+		}
+
+		llegoAUnion := r.plan.fugas[union]
+		var escapes []string
+		for id := range r.plan.fugas {
+			if id != union {
+				escapes = append(escapes, id)
+			}
+		}
+
+		estadoRama := "ok"
+		switch {
+		case r.err != nil:
+			estadoRama = "error"
+			fallos = append(fallos, fmt.Sprintf("rama %s: %v", r.nombre, r.err))
+		case len(escapes) > 0:
+			estadoRama = "error"
+			fallos = append(fallos, fmt.Sprintf("rama %s terminó fuera de la unión (%v)", r.nombre, escapes))
+		case !llegoAUnion:
+			estadoRama = "error"
+			fallos = append(fallos, fmt.Sprintf("rama %s no llegó a la unión", r.nombre))
+		}
+		detalleRamas[r.nombre] = estadoRama
+
+		cambios := r.estado.cambiosDeRama()
+
+		if estadoRama == "error" {
+			// Conservar los datos de error de la rama fallida para la salidaError
+			for _, k := range []string{"codigoError", "mensajeError", "detalleError"} {
+				if v, ok := r.estado.resultado[k]; ok {
+					fusion[k] = v
+				}
+			}
+		}
+
+		switch politica {
+		case PoliticaEspacioNombres:
+			fusion[r.nombre] = cambios
+		case PoliticaError:
+			for k, v := range cambios {
+				if otra, escrito := escritoPor[k]; escrito && !clavesSistemaParalelo[k] && !reflect.DeepEqual(fusion[k], v) {
+					fallos = append(fallos, fmt.Sprintf("conflicto en '%s' entre ramas %s y %s", k, otra, r.nombre))
+				}
+				fusion[k] = v
+				escritoPor[k] = r.nombre
+			}
+		default:
+			for k, v := range cambios {
+				fusion[k] = v
+			}
+		}
+	}
+
+	if len(fallos) > 0 {
+		e.erroresPorNodo[union] = true
+		if _, ok := e.resultado["codigoError"]; !ok {
+			e.resultado["codigoError"] = "PARALELO_ERROR"
+		}
+		e.resultado["mensajeError"] = "Error en ejecución paralela"
+		e.resultado["detalleError"] = fmt.Sprintf("%v", fallos)
+	}
+
+	// ✅ Paso 4: Dar la región por ejecutada y liberar la unión en el planificador principal
+	regionCompleta := map[string]bool{n.ID: true}
+	for id := range region {
+		regionCompleta[id] = true
+	}
+	plan.completarRegion(regionCompleta, union)
+
+	registro := utils.RegistroEjecucion{
+		Timestamp:     time.Now().Format(time.RFC3339),
+		ProcesoId:     e.proc.ID,
+		NombreProceso: e.proc.Nombre,
+		Canal:         e.canalCodigo,
+		TipoObjeto:    "paralelo",
+		NombreObjeto:  n.ID,
+		Parametros:    map[string]interface{}{"union": union, "politica": politica},
+		Resultado:     detalleRamas,
+		Estado:        "exito",
+		DuracionMs:    time.Since(inicio).Milliseconds(),
+	}
+	if len(fallos) > 0 {
+		registro.Estado = "error"
+		registro.DetalleError = fmt.Sprintf("%v", fallos)
+	}
+	utils.RegistrarEjecucionLog(registro)
+
+	return nil
+}
+
+// copiaParaRama crea el estado independiente con el que corre una rama paralela o una iteración.
+// La copia es superficial: los nodos reemplazan variables en lugar de modificar sus valores, así
+// que las ramas pueden compartir los mapas y arreglos que ya estaban en el resultado
+func (e *estadoFlujo) copiaParaRama() *estadoFlujo {
+	return &estadoFlujo{
+		ctx:                   e.ctx,
+		proc:                  e.proc,
+		contexto:              e.contexto,
+		input:                 e.input,
+		canalCodigo:           e.canalCodigo,
+		inicio:                e.inicio,
+		db:                    e.db,
+		compilado:             e.compilado,
+		grafo:                 e.grafo,
+		traza:                 e.traza,
+		compensaciones:        e.compensaciones,
+		transacciones:         e.transacciones,
+		resultado:             superficial(e.resultado),
+		escritas:              make(map[string]bool),
+		asignacionesAplicadas: make(map[string]interface{}),
+		erroresPorNodo:        make(map[string]bool),
+		respuestaFinal:        make(map[string]interface{}),
+		visitados:             make(map[string]bool),
+	}
+}
+
+// cambiosDeRama devuelve las variables que escribió la rama y los espacios de los nodos que
+// ejecutó, que se publican después de anotar las escritas de cada nodo
+func (e *estadoFlujo) cambiosDeRama() map[string]interface{} {
+	cambios := make(map[string]interface{}, len(e.escritas))
+	for k := range e.escritas {
+		if v, ok := e.resultado[k]; ok {
+			cambios[k] = v
+		}
+	}
+	for id := range e.visitados {
+		if espacio, ok := e.resultado[id]; ok {
+			cambios[id] = espacio
+		}
+	}
+	return cambios
+}
+
+// buscarCierre obtiene el nodo que cierra un bloque (la unión de un paralelo, el fin de un iterar):
+// el indicado en data[campoData] o, si no hay, el primero del tipo dado (en orden topológico)
+// alcanzable desde todas las conexiones que salen del nodo
+func (g *grafoFlujo) buscarCierre(n estructuras.NodoGenerico, tipoCierre string, campoData string) (string, error) {
+	if id, ok := n.Data[campoData].(string); ok && id != "" {
+		if u, existe := g.nodos[id]; existe && u.Type == tipoCierre {
+			return id, nil
+		}
+		return "", fmt.Errorf("el nodo '%s' indicado en %s del nodo %s no existe o no es de tipo %s", id, campoData, n.ID, tipoCierre)
+	}
+
+	var candidatas map[string]bool
+	for _, a := range g.salientes[n.ID] {
+		if a.Type == "error" {
+			continue
+		}
+		alcanzadas := make(map[string]bool)
+		for id := range g.alcanzablesDesde(a.Target, "") {
+			if g.nodos[id].Type == tipoCierre {
+				alcanzadas[id] = true
+			}
+		}
+		if candidatas == nil {
+			candidatas = alcanzadas
+			continue
+		}
+		for id := range candidatas {
+			if !alcanzadas[id] {
+				delete(candidatas, id)
+			}
+		}
+	}
+
+	union := ""
+	for id := range candidatas {
+		if union == "" || g.orden[id] < g.orden[union] {
+			union = id
+		}
+	}
+	if union == "" {
+		return "", fmt.Errorf("no se encontró un nodo %s común a todas las ramas del nodo %s", tipoCierre, n.ID)
+	}
+	return union, nil
+}
+
+// regionParalela devuelve los nodos que están en algún camino entre el paralelo y su unión
+func (g *grafoFlujo) regionParalela(paralelo string, union string) map[string]bool {
+	adelante := make(map[string]bool)
+	for _, a := range g.salientes[paralelo] {
+		if a.Type == "error" {
+			continue
+		}
+		for id := range g.alcanzablesDesde(a.Target, union) {
+			adelante[id] = true
+		}
+	}
+
+	// Recorrido hacia atrás desde la unión sin cruzar el paralelo
+	atras := make(map[string]bool)
+	cola := []string{union}
+	for len(cola) > 0 {
+		id := cola[0]
+		cola = cola[1:]
+		for _, a := range g.entrantes[id] {
+			if a.Source == paralelo || atras[a.Source] {
+				continue
+			}
+			atras[a.Source] = true
+			cola = append(cola, a.Source)
+		}
+	}
+
+	region := make(map[string]bool)
+	for id := range adelante {
+		if atras[id] && id != union && id != paralelo {
+			region[id] = true
+		}
+	}
+	return region
+}
+
+// alcanzablesDesde recorre el grafo hacia adelante; no continúa más allá del nodo limite
+func (g *grafoFlujo) alcanzablesDesde(inicio string, limite string) map[string]bool {
+	vistos := map[string]bool{inicio: true}
+	cola := []string{inicio}
+	for len(cola) > 0 {
+		id := cola[0]
+		cola = cola[1:]
+		if id == limite {
+			continue
+		}
+		for _, a := range g.salientes[id] {
+			if !vistos[a.Target] {
+				vistos[a.Target] = true
+				cola = append(cola, a.Target)
+			}
+		}
+	}
+	return vistos
+}
+
+// normalizarPoliticaConflicto acepta también los nombres en inglés que usa el diseñador
+func normalizarPoliticaConflicto(p string) string {
+	switch p {
+	case PoliticaError:
+		return PoliticaError
+	case PoliticaEspacioNombres, "namespaced", "namespace":
+		return PoliticaEspacioNombres
+	default:
+		return PoliticaUltimoGana
+	}
+}
+
+// copiarMapa hace una copia profunda de mapas y arreglos
+func copiarMapa(origen map[string]interface{}) map[string]interface{} {
+	copia := make(map[string]interface{}, len(origen))
+	for k, v := range origen {
+		copia[k] = copiarValor(v)
+	}
+	return copia
+}
+
+func copiarValor(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		return copiarMapa(t)
+	case []interface{}:
+		copia := make([]interface{}, len(t))
+		for i, item := range t {
+			copia[i] = copiarValor(item)
+		}
+		return copia
+	case []map[string]interface{}:
+		copia := make([]map[string]interface{}, len(t))
+		for i, item := range t {
+			copia[i] = copiarMapa(item)
+		}
+		return copia
+	default:
+		return v
+	}
+}
@@ -0,0 +1,135 @@
+package ejecucion
+
+import (
+	"backendmotor/internal/estructuras"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// flujoParalelo arma e → p → (a | b) → u con las dos ramas nombradas ramaA y ramaB
+func flujoParalelo(politica string, a, b estructuras.NodoGenerico) ([]estructuras.NodoGenerico, []string) {
+	a.ID, b.ID = "a", "b"
+	nodos := []estructuras.NodoGenerico{
+		nodo("e", "entrada", nil),
+		nodo("p", "paralelo", map[string]interface{}{"politicaConflicto": politica}),
+		a,
+		b,
+		nodo("u", "union", nil),
+	}
+	return nodos, []string{"e>p", "p>a:ramaA", "p>b:ramaB", "a>u", "b>u"}
+}
+
+func escribe(variable, plantilla string) estructuras.NodoGenerico {
+	return nodo("", "transformar", map[string]interface{}{"plantilla": plantilla, "variableSalida": variable})
+}
+
+func TestEjecutarParaleloUltimoGana(t *testing.T) {
+	catalogo := map[string]interface{}{"precio": 2.0}
+	nodos, aristas := flujoParalelo(PoliticaUltimoGana, escribe("x", `"{{ 1 }}"`), escribe("x", `"{{ catalogo.precio * 3 }}"`))
+	e := estadoDePrueba(t, nodos, aristas, map[string]interface{}{"x": 0.0, "catalogo": catalogo})
+	if err := e.recorrerDesde("e"); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+
+	if e.erroresPorNodo["u"] {
+		t.Fatalf("no se esperaba error en la unión: %v", e.resultado["detalleError"])
+	}
+	if e.resultado["x"] != 6.0 {
+		t.Fatalf("la rama b, la última conectada, debía ganar: x=%v", e.resultado["x"])
+	}
+	// Las variables que ninguna rama escribió no se copian
+	if !mismoValor(e.resultado["catalogo"], catalogo) {
+		t.Fatalf("catalogo no se escribió y debía conservar el mismo valor")
+	}
+	// Los espacios de los nodos de cada rama pasan al resultado
+	for id, x := range map[string]float64{"a": 1, "b": 6} {
+		if esperado := map[string]interface{}{"x": x}; !reflect.DeepEqual(e.resultado[id], esperado) {
+			t.Fatalf("espacio de %s: se esperaba %v y se obtuvo %v", id, esperado, e.resultado[id])
+		}
+	}
+	if !e.visitados["a"] || !e.visitados["b"] || !e.visitados["u"] {
+		t.Fatalf("se esperaba visitar las dos ramas y la unión: %v", e.visitados)
+	}
+}
+
+func TestEjecutarParaleloPoliticaError(t *testing.T) {
+	casos := []struct {
+		nombre    string
+		a, b      estructuras.NodoGenerico
+		conflicto string
+	}{
+		{"variables distintas", escribe("x", `"{{ 1 }}"`), escribe("y", `"{{ 2 }}"`), ""},
+		{"mismo valor en las dos ramas", escribe("x", `"{{ 1 }}"`), escribe("x", `"{{ 1 }}"`), ""},
+		{"valores distintos", escribe("x", `"{{ 1 }}"`), escribe("x", `"{{ 2 }}"`), "x"},
+		{"variable del sistema", escribe("resultado", `"{{ 1 }}"`), escribe("resultado", `"{{ 2 }}"`), ""},
+		{"otra variable del sistema", escribe("fullOutput", `"uno"`), escribe("fullOutput", `"dos"`), ""},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			nodos, aristas := flujoParalelo(PoliticaError, c.a, c.b)
+			e := estadoDePrueba(t, nodos, aristas, nil)
+			if err := e.recorrerDesde("e"); err != nil {
+				t.Fatalf("error inesperado: %v", err)
+			}
+
+			if c.conflicto == "" {
+				if e.erroresPorNodo["u"] {
+					t.Fatalf("no se esperaba conflicto: %v", e.resultado["detalleError"])
+				}
+				return
+			}
+			if !e.erroresPorNodo["u"] || e.resultado["codigoError"] != "PARALELO_ERROR" {
+				t.Fatalf("se esperaba PARALELO_ERROR en la unión y se obtuvo %v", e.resultado["codigoError"])
+			}
+			if detalle := fmt.Sprint(e.resultado["detalleError"]); !strings.Contains(detalle, "conflicto en '"+c.conflicto+"' entre ramas ramaA y ramaB") {
+				t.Fatalf("detalle inesperado: %s", detalle)
+			}
+		})
+	}
+}
+
+func TestEjecutarParaleloEspacioNombres(t *testing.T) {
+	nodos, aristas := flujoParalelo(PoliticaEspacioNombres, escribe("x", `"{{ 1 }}"`), escribe("x", `"{{ 2 }}"`))
+	e := estadoDePrueba(t, nodos, aristas, map[string]interface{}{"x": 0.0, "previo": "sin tocar"})
+	if err := e.recorrerDesde("e"); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+
+	if e.resultado["x"] != 0.0 {
+		t.Fatalf("con espacioNombres las ramas no escriben variables planas: x=%v", e.resultado["x"])
+	}
+	for rama, x := range map[string]float64{"ramaA": 1, "ramaB": 2} {
+		cambios, ok := e.resultado[rama].(map[string]interface{})
+		if !ok || cambios["x"] != x {
+			t.Fatalf("%s: se esperaba x=%v y se obtuvo %v", rama, x, e.resultado[rama])
+		}
+		if _, ok := cambios["previo"]; ok {
+			t.Fatalf("%s: solo debían quedar los cambios de la rama y quedó %v", rama, cambios)
+		}
+	}
+}
+
+func TestEjecutarParaleloRamaConError(t *testing.T) {
+	nodos, aristas := flujoParalelo(PoliticaUltimoGana, escribe("x", `"{{ 10 / cero }}"`), escribe("y", `"{{ 2 }}"`))
+	e := estadoDePrueba(t, nodos, aristas, map[string]interface{}{"cero": 0.0})
+	if err := e.recorrerDesde("e"); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+
+	if !e.erroresPorNodo["a"] || !e.erroresPorNodo["u"] {
+		t.Fatalf("se esperaba error en la rama y en la unión: %v", e.erroresPorNodo)
+	}
+	// Se conserva el código de la rama que falló y el detalle nombra la rama
+	if e.resultado["codigoError"] != "TRANSFORMAR_ERROR" {
+		t.Fatalf("se esperaba el código de la rama fallida y se obtuvo %v", e.resultado["codigoError"])
+	}
+	if detalle := fmt.Sprint(e.resultado["detalleError"]); !strings.Contains(detalle, "rama ramaA") {
+		t.Fatalf("detalle inesperado: %s", detalle)
+	}
+	if e.resultado["y"] != 2.0 {
+		t.Fatalf("la rama sin error debía fusionarse: y=%v", e.resultado["y"])
+	}
+}
@@ -1,7 +1,9 @@
 package ejecucion
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -20,7 +22,7 @@ import (
 type NodoProceso struct {
 	Label             string      `json:"label"`
 	ServidorID        string      `json:"servidorId"`
-	TipoObjeto        string      `json:"tipoObjeto"` // plpgsql_function, plpgsql_procedure, etc.
+	TipoObjeto        string      `json:"tipoObjeto"` // plpgsql_function, plpgsql_procedure, consulta, etc.
 	Objeto            string      `json:"objeto"`     // nombre real del SP o función
 	ParametrosEntrada []Parametro `json:"parametrosEntrada"`
 	ParametrosSalida  []Parametro `json:"parametrosSalida"`
@@ -38,6 +40,7 @@ type Parametro struct {
 // 🧠 Función principal para ejecutar un nodo tipo "proceso"
 
 func ejecutarNodoProceso(
+	ctx context.Context,
 	n estructuras.NodoGenerico,
 	resultado map[string]interface{},
 	input map[string]interface{},
@@ -45,6 +48,8 @@ func ejecutarNodoProceso(
 	canalCodigo string,
 	proc models.Proceso,
 	inicio time.Time,
+	cfg *configNodoProceso,
+	contexto *ContextoSubproceso,
 ) (
 	map[string]interface{},
 	map[string]interface{},
@@ -57,19 +62,28 @@ func ejecutarNodoProceso(
 	estadoFinal := 0
 	mensajeFinal := "Ejecución completada"
 
-	// 🔍 Paso 1: Parsear los datos internos del nodo proceso
-	var nodo NodoProceso
-	jsonBytes, _ := json.Marshal(n.Data)
-	json.Unmarshal(jsonBytes, &nodo)
+	// 🔍 Paso 1: Tomar los datos internos del nodo proceso (pre-decodificados si el flujo está compilado)
+	if cfg == nil {
+		cfg = compilarConfigProceso(n)
+	}
+	nodo := cfg.Nodo
+
+	// El mapa Data es compartido por el flujo en caché: si se va a reescribir, se trabaja sobre una copia
+	if cfg.ParsearFullOutput {
+		n.Data = copiarMapa(n.Data)
+	}
 
-	// 🔌 Paso 2: Buscar el servidor correspondiente desde la base de datos
+	// 🔌 Paso 2: Buscar el servidor correspondiente desde la base de datos (no hace falta si se simula)
+	simulada, simulando := contexto.respuestaSimulada(n.ID, nodo)
 	var servidor models.Servidor
-	if err := db.First(&servidor, "id = ?", nodo.ServidorID).Error; err != nil {
-		return resultado, fullOutput, asignaciones, 99, "Servidor no encontrado", fmt.Errorf("servidor no encontrado: %w", err)
+	if !simulando {
+		if err := db.WithContext(ctx).First(&servidor, "id = ?", nodo.ServidorID).Error; err != nil {
+			return resultado, fullOutput, asignaciones, 99, "Servidor no encontrado", fmt.Errorf("servidor no encontrado: %w", err)
+		}
 	}
 
 	// 📋 Paso 2.5: Procesar asignaciones de parámetros de entrada
-	parametrosResueltos, err := procesarAsignacionesParametros(n, resultado)
+	parametrosResueltos, err := resolverAsignacionesProceso(cfg.Asignaciones, resultado)
 	if err != nil {
 		return resultado, fullOutput, asignaciones, 99, "Error procesando asignaciones", fmt.Errorf("error procesando asignaciones: %w", err)
 	}
@@ -83,19 +97,92 @@ func ejecutarNodoProceso(
 	var fullOutputStr string
 	var execErr error
 	tipoServidor := strings.ToLower(servidor.Tipo)
+	if simulando {
+		tipoServidor = "simulado"
+	}
 
+	var invocar func(context.Context) (string, error)
 	switch tipoServidor {
+	case "simulado":
+		invocar = func(context.Context) (string, error) {
+			if simulada == nil {
+				return "", fmt.Errorf("sin respuesta simulada para el nodo %s (servidor %s, objeto %s)", n.ID, nodo.ServidorID, nodo.Objeto)
+			}
+			fmt.Printf("🧪 Nodo %s simulado (servidor %s, objeto %s)\n", n.ID, nodo.ServidorID, nodo.Objeto)
+			return simulada.salida()
+		}
 	case "postgresql":
-		fullOutputStr, execErr = ejecutores.EjecutarPostgreSQL(n, resultado, servidor)
+		invocar = func(ctx context.Context) (string, error) {
+			return ejecutores.EjecutarPostgreSQL(ctx, n, resultado, servidor)
+		}
+	case "mysql", "mariadb":
+		invocar = func(ctx context.Context) (string, error) {
+			return ejecutores.EjecutarMySQL(ctx, n, resultado, servidor)
+		}
+	case "sqlite":
+		invocar = func(ctx context.Context) (string, error) {
+			return ejecutores.EjecutarSQLite(ctx, n, resultado, servidor)
+		}
 	case "rest":
-		fullOutputStr, execErr = ejecutores.EjecutarREST(n, resultado, servidor)
+		invocar = func(ctx context.Context) (string, error) {
+			return ejecutores.EjecutarREST(ctx, n, resultado, servidor)
+		}
 	case "soap":
-		fullOutputStr, execErr = ejecutores.EjecutarSOAP(n, resultado, servidor, proc.ID)
+		invocar = func(ctx context.Context) (string, error) {
+			return ejecutores.EjecutarSOAP(ctx, n, resultado, servidor, proc.ID)
+		}
 	default:
 		execErr = fmt.Errorf("tipo de servidor no soportado: %s", servidor.Tipo)
 		return resultado, fullOutput, asignaciones, 99, "Tipo de servidor no soportado", execErr
 	}
 
+	// ⚡ Paso 3.2: Pasar cada intento por el circuit breaker del servidor
+	if !simulando {
+		invocar = circuitoPara(servidor).proteger(invocar)
+	}
+
+	// 🔁 Paso 3.5: Reintentar fallas transitorias según la política del nodo / servidor
+	politica := politicaParaLlamada(ctx, n, servidor, simulando)
+	traceID := ""
+	if contexto != nil {
+		traceID = contexto.TraceID
+	}
+	registrarIntento := func(intento int, falla string, err error, espera time.Duration, ultimo bool) {
+		estadoIntento := "reintento"
+		if ultimo {
+			estadoIntento = "error"
+		}
+		fmt.Printf("🔁 Nodo %s intento %d/%d falló (%s): %v\n", n.ID, intento, politica.MaxIntentos, falla, err)
+		utils.RegistrarEjecucionLog(utils.RegistroEjecucion{
+			Timestamp:     time.Now().Format(time.RFC3339),
+			ProcesoId:     proc.ID,
+			NombreProceso: proc.Nombre,
+			Canal:         canalCodigo,
+			TipoObjeto:    nodo.TipoObjeto,
+			NombreObjeto:  nodo.Objeto,
+			TraceID:       traceID,
+			Parametros: map[string]interface{}{
+				"nodoId":      n.ID,
+				"intento":     intento,
+				"maxIntentos": politica.MaxIntentos,
+			},
+			Resultado: map[string]interface{}{
+				"falla":    falla,
+				"esperaMs": espera.Milliseconds(),
+			},
+			Estado:       estadoIntento,
+			DetalleError: err.Error(),
+		})
+	}
+	fullOutputStr, execErr = ejecutarConReintentos(ctx, politica, invocar, registrarIntento)
+
+	// 🌐 Un REST que responde 5xx / 429 sin política que lo reintente sigue como antes: la
+	// respuesta queda en FullOutput y el flujo continúa (el circuit breaker ya contó la falla)
+	var errHTTP *ejecutores.ErrorHTTP
+	if tipoServidor == "rest" && errors.As(execErr, &errHTTP) && !politica.reintentaHTTP() {
+		execErr = nil
+	}
+
 	// 📦 Paso 4: Guardar FullOutput en resultado
 	resultado["FullOutput"] = fullOutputStr
 	json.Unmarshal([]byte(fullOutputStr), &fullOutput)
@@ -103,44 +190,29 @@ func ejecutarNodoProceso(
 	resultado["fullOutput"] = fullOutput // compatibilidad
 
 	// ⚙️ Paso 5: Auto-generar parametrosSalida si parsearFullOutput == true
-	if parsear, ok := n.Data["parsearFullOutput"].(bool); ok && parsear {
-		tipoRespuesta := fmt.Sprint(n.Data["tipoRespuesta"])
-		tagPadre := fmt.Sprint(n.Data["tagPadre"])
-
-		existentesRaw, tieneExistentes := n.Data["parametrosSalida"]
-		existentes := []estructuras.Campo{}
-		if tieneExistentes {
-			if existentesBytes, err := json.Marshal(existentesRaw); err == nil {
-				_ = json.Unmarshal(existentesBytes, &existentes)
-			}
-		}
-
-		nuevos, err := estructuras.MapearCamposDesdeFullOutput(fullOutputStr, tipoRespuesta, tagPadre, existentes)
+	camposSalida := cfg.CamposSalida
+	if cfg.ParsearFullOutput {
+		nuevos, err := estructuras.MapearCamposDesdeFullOutput(fullOutputStr, cfg.TipoRespuesta, cfg.TagPadre, cfg.CamposSalida)
 		if err == nil && len(nuevos) > 0 {
 			n.Data["parametrosSalida"] = nuevos
+			camposSalida = nuevos
 		}
 
 		n.Data["parsearFullOutput"] = false
-		if proc.ID != "" && len(proc.ID) > 0 {
+		if proc.ID != "" && len(proc.ID) > 0 && !simulando {
 			n.ProcesoID = proc.ID
 			_ = database.ActualizarNodoEnFlujo(n)
+			InvalidarFlujo(proc.ID)
 		}
 	}
 
 	// 🧠 Paso 6: Si hay parametrosSalida definidos, intentar extraer valores reales
-	if salidaRaw, ok := n.Data["parametrosSalida"]; ok {
-		if salidaBytes, err := json.Marshal(salidaRaw); err == nil {
-			var camposSalida []estructuras.Campo
-			if err := json.Unmarshal(salidaBytes, &camposSalida); err == nil {
-				tipoRespuesta := fmt.Sprint(n.Data["tipoRespuesta"])
-				tagPadre := fmt.Sprint(n.Data["tagPadre"])
-				valores, err := estructuras.ExtraerValoresDesdeFullOutput(fullOutputStr, tipoRespuesta, tagPadre, camposSalida)
-				if err == nil {
-					for _, campo := range camposSalida {
-						if val, ok := valores[campo.Nombre]; ok {
-							resultado[campo.Nombre] = val
-						}
-					}
+	if len(camposSalida) > 0 {
+		valores, err := estructuras.ExtraerValoresDesdeFullOutput(fullOutputStr, cfg.TipoRespuesta, cfg.TagPadre, camposSalida)
+		if err == nil {
+			for _, campo := range camposSalida {
+				if val, ok := valores[campo.Nombre]; ok {
+					resultado[campo.Nombre] = val
 				}
 			}
 		}
@@ -151,6 +223,11 @@ func ejecutarNodoProceso(
 		estadoFinal = 99
 		mensajeFinal = "Error en ejecución"
 		resultado["codigoError"] = "99"
+		var errCircuito *ErrCircuitoAbierto
+		if errors.As(execErr, &errCircuito) {
+			mensajeFinal = "Servidor no disponible (circuito abierto)"
+			resultado["codigoError"] = CodigoErrorCircuitoAbierto
+		}
 		resultado["mensajeError"] = mensajeFinal
 		resultado["detalleError"] = execErr.Error()
 	}
@@ -232,19 +309,21 @@ func getParametrosParaServidor(parametros []Parametro) []Parametro {
 	return resultado
 }
 
-// procesarAsignacionesParametros procesa las asignaciones de parámetros antes de ejecutar el SP
-func procesarAsignacionesParametros(n estructuras.NodoGenerico, contexto map[string]interface{}) (map[string]interface{}, error) {
-	// 🧠 Paso 1: Extraer el bloque de asignaciones del nodo
+// decodificarAsignacionesProceso extrae el bloque de asignaciones del nodo agrupadas por nodo fuente
+func decodificarAsignacionesProceso(n estructuras.NodoGenerico) map[string][]AsignacionProceso {
 	asignacionesJSON, _ := json.Marshal(n.Data["asignaciones"])
 
-	// 🧠 Paso 2: Mapear asignaciones agrupadas por nodo fuente
 	var asignaciones map[string][]AsignacionProceso
 	json.Unmarshal(asignacionesJSON, &asignaciones)
+	return asignaciones
+}
 
+// resolverAsignacionesProceso procesa las asignaciones de parámetros (ya decodificadas) antes de ejecutar el SP
+func resolverAsignacionesProceso(asignaciones map[string][]AsignacionProceso, contexto map[string]interface{}) (map[string]interface{}, error) {
 	// 📦 Estructura para parámetros resueltos
 	parametrosResueltos := make(map[string]interface{})
 
-	// 🔄 Paso 3: Recorrer todas las asignaciones
+	// 🔄 Recorrer todas las asignaciones
 	for nodoFuente, asigns := range asignaciones {
 		fmt.Printf("🔗 Procesando asignaciones desde nodo: %s\n", nodoFuente)
 		
@@ -253,7 +332,7 @@ func procesarAsignacionesParametros(n estructuras.NodoGenerico, contexto map[str
 
 			// 🔁 Tipo: campo → copiar desde variable existente en contexto
 			if asign.Tipo == "campo" {
-				if val, ok := contexto[asign.Valor]; ok {
+				if val, ok := utils.ResolverRuta(contexto, asign.Valor); ok {
 					parametrosResueltos[asign.Destino] = val
 					fmt.Printf("✅ Campo resuelto: %s = %v\n", asign.Destino, val)
 				} else {
@@ -266,7 +345,7 @@ func procesarAsignacionesParametros(n estructuras.NodoGenerico, contexto map[str
 				fmt.Printf("✅ Literal asignado: %s = %s\n", asign.Destino, asign.Valor)
 
 			// 🚀 Tipo: sistema → ejecutar función del sistema
-			} else if asign.Tipo == "sistema" {
+			} else if asign.Tipo == "sistema" || asign.Tipo == "funcion" {
 				valor, err := resolverFuncionSistemaEnProceso(asign.Valor, contexto)
 				if err != nil {
 					fmt.Printf("❌ Error ejecutando función %s: %v\n", asign.Valor, err)
@@ -317,7 +396,7 @@ func resolverTablaEnProceso(asign AsignacionProceso, contexto map[string]interfa
 	
 	// Resolver la clave si es variable (viene del contexto)
 	if asign.EsClaveVariable {
-		if val, exists := contexto[clave]; exists {
+		if val, exists := utils.ResolverRuta(contexto, clave); exists {
 			clave = fmt.Sprintf("%v", val)
 			fmt.Printf("🔄 [nodo_proceso.go] Clave variable resuelta: %s → %s\n", asign.Clave, clave)
 		} else {
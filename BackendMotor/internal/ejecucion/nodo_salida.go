@@ -42,6 +42,23 @@ func ejecutarNodoSalida(
 	respuestaFinal := make(map[string]interface{})
 	asignacionesAplicadas := make(map[string]interface{})
 
+	// 📦 Paso 2.5: data.cuerpo nombra una variable (por ejemplo la salida de un nodo transformar)
+	// que se usa completa como respuesta; las asignaciones se agregan encima
+	if nombre, ok := n.Data["cuerpo"].(string); ok && nombre != "" {
+		valor, existe := utils.ResolverRuta(resultado, nombre)
+		if !existe {
+			return nil, nil, fmt.Errorf("la variable '%s' indicada como cuerpo no existe", nombre)
+		}
+		cuerpo, ok := valor.(map[string]interface{})
+		if !ok {
+			return nil, nil, fmt.Errorf("la variable '%s' indicada como cuerpo debe ser un objeto", nombre)
+		}
+		for k, v := range cuerpo {
+			respuestaFinal[k] = v
+		}
+		asignacionesAplicadas[nombre] = valor
+	}
+
 	// 🔄 Paso 3: Recopilar y ordenar todas las asignaciones
 	var todasLasAsignaciones []AsignacionSalida
 	for _, asigns := range asignaciones {
@@ -72,7 +89,7 @@ func ejecutarNodoSalida(
 
 			// 🔁 Tipo: campo → copiar desde variable existente en resultado
 			if asign.Tipo == "campo" {
-				if val, ok := resultado[asign.Valor]; ok {
+				if val, ok := utils.ResolverRuta(resultado, asign.Valor); ok {
 					respuestaFinal[asign.Destino] = val
 					asignacionesAplicadas[asign.Destino] = val
 				} else {
@@ -85,7 +102,7 @@ func ejecutarNodoSalida(
 				asignacionesAplicadas[asign.Destino] = asign.Valor
 
 				// 🚪 Tipo: sistema → ejecutar función del sistema
-			} else if asign.Tipo == "sistema" {
+			} else if asign.Tipo == "sistema" || asign.Tipo == "funcion" {
 				valor, err := resolverFuncionSistemaEnSalida(asign.Valor, resultado)
 				if err != nil {
 					fmt.Printf("❌ Error ejecutando función %s: %v\n", asign.Valor, err)
@@ -140,7 +157,7 @@ func resolverTablaEnSalida(asign AsignacionSalida, contexto map[string]interface
 	
 	// Resolver la clave si es variable (viene del contexto)
 	if asign.EsClaveVariable {
-		if val, exists := contexto[clave]; exists {
+		if val, exists := utils.ResolverRuta(contexto, clave); exists {
 			clave = fmt.Sprintf("%v", val)
 			fmt.Printf("🔄 [nodo_salida.go] Clave variable resuelta: %s → %s\n", asign.Clave, clave)
 		} else {
@@ -879,7 +879,7 @@ func procesarAsignacionesParametrosSplitter(n estructuras.NodoGenerico, contexto
 			switch asign.Tipo {
 			case "campo":
 				// Copiar desde variable existente en contexto
-				if val, ok := contexto[asign.Valor]; ok {
+				if val, ok := utils.ResolverRuta(contexto, asign.Valor); ok {
 					parametrosResueltos[asign.Destino] = val
 					fmt.Printf("✅ [DEBUG] Campo resuelto: %s = %v\n", asign.Destino, val)
 				} else {
@@ -891,6 +891,15 @@ func procesarAsignacionesParametrosSplitter(n estructuras.NodoGenerico, contexto
 				parametrosResueltos[asign.Destino] = asign.Valor
 				fmt.Printf("✅ [DEBUG] Literal asignado: %s = %s\n", asign.Destino, asign.Valor)
 
+			case "sistema", "funcion":
+				// Función o expresión del sistema, con el mismo evaluador que entrada, proceso y salida
+				valor, err := utils.EjecutarFuncionSistema(asign.Valor, contexto)
+				if err != nil {
+					return nil, fmt.Errorf("error ejecutando función %s: %w", asign.Valor, err)
+				}
+				parametrosResueltos[asign.Destino] = valor
+				fmt.Printf("✅ [DEBUG] Función ejecutada: %s = %v\n", asign.Destino, valor)
+
 			default:
 				fmt.Printf("⚠️ [DEBUG] Tipo de asignación no soportado en splitter: %s\n", asign.Tipo)
 			}
@@ -1,9 +1,9 @@
 package ejecucion
 
 import (
-	"backendmotor/internal/database"
+	"context"
+	"errors"
 	"backendmotor/internal/estructuras"
-	"backendmotor/internal/models"
 	"backendmotor/internal/monitoring"
 	"backendmotor/internal/utils"
 	"fmt"
@@ -14,15 +14,19 @@ import (
 
 // ContextoSubproceso mantiene el contexto de ejecución anidada
 type ContextoSubproceso struct {
-	ProcesoID       string                   // ID del proceso actual
-	ParentProcesoID string                   // ID del proceso padre
-	Variables       map[string]interface{}   // Variables del contexto actual
-	Globales        map[string]interface{}   // Variables globales heredadas
-	Depth           int                      // Profundidad de anidación
-	CallStack       []string                 // Pila de llamadas para detectar recursión
-	TraceID         string                   // ID de traza para logging
-	Timeout         time.Duration            // Timeout para este subproceso
-	Inicio          time.Time                // Tiempo de inicio
+	ProcesoID         string                 // ID del proceso actual
+	ParentProcesoID   string                 // ID del proceso padre
+	Variables         map[string]interface{} // Variables del contexto actual
+	Globales          map[string]interface{} // Variables globales heredadas
+	Depth             int                    // Profundidad de anidación
+	CallStack         []string               // Pila de llamadas para detectar recursión
+	TraceID           string                 // ID de traza para logging
+	EjecucionID       string                 // ID de la ejecución persistida (tabla ejecuciones)
+	ParentEjecucionID string                 // ID de la ejecución del proceso padre
+	Timeout           time.Duration          // Timeout para este subproceso
+	Inicio            time.Time              // Tiempo de inicio
+	Opciones          *OpcionesEjecucion     // Opciones de la ejecución raíz (depuración, simulación)
+	durable           *ejecucionDurable      // cola durable (solo en la raíz de ejecuciones encoladas)
 }
 
 // ResultadoSubproceso contiene el resultado de ejecutar un subproceso
@@ -42,6 +46,7 @@ const (
 
 // ejecutarNodoSubproceso ejecuta otro proceso como subproceso del flujo actual
 func ejecutarNodoSubproceso(
+	ctx context.Context,
 	n estructuras.NodoGenerico,
 	resultado map[string]interface{},
 	contexto *ContextoSubproceso,
@@ -49,7 +54,16 @@ func ejecutarNodoSubproceso(
 ) (map[string]interface{}, map[string]interface{}, error) {
 	inicio := time.Now()
 	nodoID := n.ID
-	
+
+	if contexto == nil {
+		contexto = &ContextoSubproceso{
+			Depth:     0,
+			CallStack: []string{},
+			Globales:  make(map[string]interface{}),
+			Inicio:    inicio,
+		}
+	}
+
 	// Registrar inicio de ejecución
 	utils.RegistrarEjecucionLog(utils.RegistroEjecucion{
 		Timestamp:     time.Now().Format(time.RFC3339),
@@ -58,7 +72,8 @@ func ejecutarNodoSubproceso(
 		Canal:         canalCodigo,
 		TipoObjeto:    "subproceso",
 		NombreObjeto:  nodoID,
-		Parametros:    map[string]interface{}{"depth": contexto.Depth, "parent": contexto.ParentProcesoID},
+		TraceID:       contexto.TraceID,
+		Parametros:    map[string]interface{}{"depth": contexto.Depth, "parent": contexto.ParentProcesoID, "callStack": contexto.CallStack},
 		Estado:        "iniciando",
 	})
 
@@ -73,6 +88,7 @@ func ejecutarNodoSubproceso(
 			Canal:         canalCodigo,
 			TipoObjeto:    "subproceso",
 			NombreObjeto:  nodoID,
+			TraceID:       contexto.TraceID,
 			Parametros:    map[string]interface{}{"error": "procesoId no definido"},
 			Estado:        "error",
 		})
@@ -82,23 +98,14 @@ func ejecutarNodoSubproceso(
 	fmt.Printf("🔄 Ejecutando subproceso: %s desde nodo: %s\n", procesoID, nodoID)
 
 	// 2. Validar profundidad máxima
-	if contexto == nil {
-		contexto = &ContextoSubproceso{
-			Depth:     0,
-			CallStack: []string{},
-			Globales:  make(map[string]interface{}),
-			Inicio:    inicio,
-		}
-	}
-	
 	if contexto.Depth >= MaxDepth {
 		err := fmt.Errorf("profundidad máxima alcanzada (%d) en subproceso %s", MaxDepth, procesoID)
 		monitoring.SubprocessErrors.Inc()
 		return resultado, nil, err
 	}
 
-	// 3. Detectar recursión (ciclos)
-	for _, pid := range contexto.CallStack {
+	// 3. Detectar recursión (ciclos), incluyendo la llamada a sí mismo
+	for _, pid := range append(contexto.CallStack, contexto.ProcesoID) {
 		if pid == procesoID {
 			err := fmt.Errorf("recursión detectada: proceso %s ya está en la pila de llamadas", procesoID)
 			monitoring.SubprocessErrors.Inc()
@@ -119,7 +126,7 @@ func ejecutarNodoSubproceso(
 						
 						if tipo == "campo" {
 							// Mapear desde el resultado actual
-							if val, exists := resultado[valor.(string)]; exists {
+							if val, exists := utils.ResolverRuta(resultado, valor.(string)); exists {
 								parametrosEntrada[destino] = val
 							}
 						} else if tipo == "valor" {
@@ -139,17 +146,37 @@ func ejecutarNodoSubproceso(
 		}
 	}
 
-	// 6. Crear contexto hijo
+	// 5.1 El hijo nunca puede tener más tiempo del que le queda al padre
+	timeout := time.Duration(timeoutMs) * time.Millisecond
+	if deadline, ok := ctx.Deadline(); ok {
+		restante := time.Until(deadline)
+		if restante <= 0 {
+			err := fmt.Errorf("tiempo agotado antes de iniciar subproceso %s", procesoID)
+			monitoring.SubprocessErrors.Inc()
+			return resultado, nil, err
+		}
+		if restante < timeout {
+			timeout = restante
+		}
+	}
+
+	// 6. Crear contexto hijo (pila y globales copiadas para no compartir memoria con el padre)
+	callStack := make([]string, 0, len(contexto.CallStack)+1)
+	callStack = append(callStack, contexto.CallStack...)
+	callStack = append(callStack, contexto.ProcesoID)
+
 	contextoHijo := &ContextoSubproceso{
-		ProcesoID:       procesoID,
-		ParentProcesoID: contexto.ProcesoID,
-		Variables:       parametrosEntrada,
-		Globales:        contexto.Globales, // Heredar globales
-		Depth:           contexto.Depth + 1,
-		CallStack:       append(contexto.CallStack, contexto.ProcesoID),
-		TraceID:         contexto.TraceID,
-		Timeout:         time.Duration(timeoutMs) * time.Millisecond,
-		Inicio:          time.Now(),
+		ProcesoID:         procesoID,
+		ParentProcesoID:   contexto.ProcesoID,
+		Variables:         parametrosEntrada,
+		Globales:          copiarMapa(contexto.Globales), // Heredar globales
+		Depth:             contexto.Depth + 1,
+		CallStack:         callStack,
+		TraceID:           contexto.TraceID,
+		ParentEjecucionID: contexto.EjecucionID,
+		Timeout:           timeout,
+		Inicio:            time.Now(),
+		Opciones:          contexto.Opciones,
 	}
 
 	// Agregar variables globales estándar si no existen
@@ -162,7 +189,7 @@ func ejecutarNodoSubproceso(
 
 	// 7. Ejecutar el subproceso
 	fmt.Printf("🚀 Iniciando ejecución del subproceso %s con contexto depth=%d\n", procesoID, contextoHijo.Depth)
-	resultadoSub, err := ejecutarSubprocesoInterno(contextoHijo, canalCodigo)
+	resultadoSub, err := ejecutarSubprocesoInterno(ctx, contextoHijo, canalCodigo)
 	
 	// Registrar métricas
 	duracion := time.Since(inicio).Seconds()
@@ -186,6 +213,7 @@ func ejecutarNodoSubproceso(
 			Canal:         canalCodigo,
 			TipoObjeto:    "subproceso",
 			NombreObjeto:  nodoID,
+			TraceID:       contexto.TraceID,
 			Parametros:    parametrosEntrada,
 			Resultado:     map[string]interface{}{"error": err.Error()},
 			Estado:        "error",
@@ -217,6 +245,7 @@ func ejecutarNodoSubproceso(
 		Canal:         canalCodigo,
 		TipoObjeto:    "subproceso",
 		NombreObjeto:  nodoID,
+		TraceID:       contexto.TraceID,
 		Parametros:    parametrosEntrada,
 		Resultado:     resultadoSub.Salidas,
 		Estado:        "completado",
@@ -232,80 +261,55 @@ func ejecutarNodoSubproceso(
 	return resultado, asignaciones, nil
 }
 
-// ejecutarSubprocesoInterno ejecuta el proceso hijo y retorna su resultado
-func ejecutarSubprocesoInterno(contexto *ContextoSubproceso, canalCodigo string) (*ResultadoSubproceso, error) {
+// ejecutarSubprocesoInterno ejecuta el proceso hijo y retorna su resultado. El timeout se aplica
+// con un context derivado, así que al vencer se cancelan también las llamadas externas del hijo
+func ejecutarSubprocesoInterno(ctx context.Context, contexto *ContextoSubproceso, canalCodigo string) (*ResultadoSubproceso, error) {
 	inicio := time.Now()
-	
-	// Canal para resultado con timeout
-	resultChan := make(chan *ResultadoSubproceso, 1)
-	errChan := make(chan error, 1)
-	
-	go func() {
-		// Cargar el proceso hijo desde la base de datos
-		var proc models.Proceso
-		db := database.DBGORM
-		if err := db.First(&proc, "id = ?", contexto.ProcesoID).Error; err != nil {
-			errChan <- fmt.Errorf("error cargando subproceso %s: %w", contexto.ProcesoID, err)
-			return
-		}
 
-		// Ejecutar el flujo del subproceso
-		resultadoEjecucion, err := EjecutarFlujoConContexto(
-			contexto.ProcesoID,
-			contexto.Variables,
-			canalCodigo,
-			"subproceso",
-			contexto,
-		)
-		
-		if err != nil {
-			errChan <- err
-			return
-		}
-
-		// Convertir resultado a formato esperado
-		resultado := &ResultadoSubproceso{
-			Salidas:    resultadoEjecucion.Datos,
-			Estado:     resultadoEjecucion.Estado,
-			DuracionMs: time.Since(inicio).Milliseconds(),
-		}
+	ctx, cancel := context.WithTimeout(ctx, contexto.Timeout)
+	defer cancel()
 
-		// Si hay error, extraer campos de error
-		if resultadoEjecucion.Estado == 99 || resultadoEjecucion.Estado == 98 {
-			if codigo, ok := resultadoEjecucion.Datos["codigoError"].(string); ok {
-				resultado.CodigoError = codigo
-			}
-			if mensaje, ok := resultadoEjecucion.Datos["mensajeError"].(string); ok {
-				resultado.MensajeError = mensaje
-			}
-			if detalle, ok := resultadoEjecucion.Datos["detalleError"].(string); ok {
-				resultado.DetalleError = detalle
-			}
-		}
+	// Verificar que el proceso hijo existe (queda compilado en caché para la ejecución)
+	if _, err := obtenerFlujoCompilado(ctx, contexto.ProcesoID); err != nil {
+		return nil, fmt.Errorf("error cargando subproceso %s: %w", contexto.ProcesoID, err)
+	}
 
-		resultChan <- resultado
-	}()
+	// Ejecutar el flujo del subproceso
+	resultadoEjecucion, err := EjecutarFlujoConContexto(
+		ctx,
+		contexto.ProcesoID,
+		contexto.Variables,
+		canalCodigo,
+		"subproceso",
+		contexto,
+	)
 
-	// Esperar con timeout
-	select {
-	case resultado := <-resultChan:
-		return resultado, nil
-	case err := <-errChan:
-		return nil, err
-	case <-time.After(contexto.Timeout):
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
 		return nil, fmt.Errorf("timeout ejecutando subproceso %s después de %v", contexto.ProcesoID, contexto.Timeout)
 	}
-}
+	if err != nil {
+		return nil, err
+	}
 
-// EjecutarFlujoConContexto es una versión extendida de EjecutarFlujo que acepta contexto
-func EjecutarFlujoConContexto(
-	procesoID string,
-	input map[string]interface{},
-	canalCodigo string,
-	trigger string,
-	contexto *ContextoSubproceso,
-) (ResultadoEjecucion, error) {
-	// Por ahora, delegar a la función original
-	// TODO: Modificar EjecutarFlujo para aceptar contexto opcional
-	return EjecutarFlujo(procesoID, input, canalCodigo, trigger)
+	// Convertir resultado a formato esperado
+	resultado := &ResultadoSubproceso{
+		Salidas:    resultadoEjecucion.Datos,
+		Estado:     resultadoEjecucion.Estado,
+		DuracionMs: time.Since(inicio).Milliseconds(),
+	}
+
+	// Si hay error, extraer campos de error
+	if resultadoEjecucion.Estado == 99 || resultadoEjecucion.Estado == 98 {
+		if codigo, ok := resultadoEjecucion.Datos["codigoError"].(string); ok {
+			resultado.CodigoError = codigo
+		}
+		if mensaje, ok := resultadoEjecucion.Datos["mensajeError"].(string); ok {
+			resultado.MensajeError = mensaje
+		}
+		if detalle, ok := resultadoEjecucion.Datos["detalleError"].(string); ok {
+			resultado.DetalleError = detalle
+		}
+	}
+
+	return resultado, nil
 }
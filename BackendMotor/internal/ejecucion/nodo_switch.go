@@ -0,0 +1,223 @@
+package ejecucion
+
+import (
+	"backendmotor/internal/estructuras"
+	"backendmotor/internal/utils"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HandleSwitchDefault es la salida que se toma cuando ningún caso coincide con el valor
+const HandleSwitchDefault = "default"
+
+// casoSwitch es un caso explícito de data.casos. Se evalúan en orden y gana el primero que
+// coincide; un caso puede comparar por igualdad (valor), por rango numérico inclusivo
+// (desde / hasta, cualquiera de los dos opcional) o por expresión regular
+type casoSwitch struct {
+	Handle string      `json:"handle"` // salida a tomar; si falta se usa el valor
+	Valor  interface{} `json:"valor,omitempty"`
+	Desde  *float64    `json:"desde,omitempty"`
+	Hasta  *float64    `json:"hasta,omitempty"`
+	Regex  string      `json:"regex,omitempty"`
+}
+
+// Las expresiones regulares de los casos se compilan una sola vez
+var regexCasos sync.Map
+
+// ejecutarNodoSwitch evalúa data.expresion y devuelve el handle de la salida a seguir: el del
+// primer caso que coincide, la salida cuyo handle es el valor mismo o "default"
+func ejecutarNodoSwitch(
+	n estructuras.NodoGenerico,
+	resultado map[string]interface{},
+	salidas []aristaFlujo,
+	codigoCanal string,
+) (string, error) {
+	inicio := time.Now()
+	label := fmt.Sprintf("%v", n.Data["label"])
+
+	// 🧠 Paso 1: Obtener la expresión y los casos
+	expresion, _ := n.Data["expresion"].(string)
+	if strings.TrimSpace(expresion) == "" {
+		return "", fmt.Errorf("el nodo switch %s no tiene la propiedad 'expresion'", n.ID)
+	}
+	var casos []casoSwitch
+	if casosRaw, ok := n.Data["casos"]; ok {
+		casosBytes, _ := json.Marshal(casosRaw)
+		if err := json.Unmarshal(casosBytes, &casos); err != nil {
+			return "", fmt.Errorf("casos inválidos en el nodo switch %s: %w", n.ID, err)
+		}
+	}
+
+	// 🧠 Paso 2: Evaluar la expresión con el mismo motor que las condiciones
+	ctx := contextoExpresion(n, resultado)
+	valor, err := utils.EvaluarValor(expresion, ctx)
+	if err != nil {
+		return "", fmt.Errorf("error al evaluar switch: %w", err)
+	}
+
+	// 🔀 Paso 3: Elegir la salida
+	handle, err := elegirCasoSwitch(valor, casos, salidas)
+	if err != nil {
+		return "", err
+	}
+
+	// ✅ Paso 4: Dejar el valor y el caso elegido en el resultado
+	fullOutput := map[string]interface{}{
+		"valor": valor,
+		"caso":  handle,
+	}
+	resultado["valor_"+n.ID] = valor
+	resultado["fullOutput"] = fullOutput
+	resultado["fullOutput_"+n.ID] = fullOutput
+
+	// 📋 Paso 5: Registro de ejecución
+	utils.RegistrarEjecucionLog(utils.RegistroEjecucion{
+		Timestamp:     time.Now().Format(time.RFC3339),
+		ProcesoId:     n.ID,
+		NombreProceso: label,
+		Canal:         codigoCanal,
+		TipoObjeto:    "switch",
+		NombreObjeto:  expresion,
+		Parametros:    ctx,
+		Resultado:     map[string]interface{}{"valor": valor, "caso": handle},
+		FullOutput:    fullOutput,
+		DuracionMs:    time.Since(inicio).Milliseconds(),
+		Estado:        "exito",
+	})
+
+	fmt.Printf("🔀 Switch %s: %s = %v → %s\n", n.ID, expresion, valor, handle)
+	return handle, nil
+}
+
+// elegirCasoSwitch aplica los casos explícitos en orden; si ninguno coincide busca una salida
+// con el valor como handle. Si el caso elegido no tiene conexión, o no hubo caso, se toma la
+// salida default
+func elegirCasoSwitch(valor interface{}, casos []casoSwitch, salidas []aristaFlujo) (string, error) {
+	texto := textoCaso(valor)
+
+	elegido := texto
+	for _, c := range casos {
+		coincide, err := c.coincide(valor, texto)
+		if err != nil {
+			return "", err
+		}
+		if !coincide {
+			continue
+		}
+		elegido = c.Handle
+		if elegido == "" {
+			elegido = textoCaso(c.Valor)
+		}
+		break
+	}
+
+	if tieneSalida(salidas, elegido) {
+		return elegido, nil
+	}
+	if tieneSalida(salidas, HandleSwitchDefault) {
+		return HandleSwitchDefault, nil
+	}
+	if elegido != texto {
+		return "", fmt.Errorf("el caso '%s' elegido para el valor '%s' no tiene conexión y el switch no tiene salida default", elegido, texto)
+	}
+	return "", fmt.Errorf("ningún caso coincide con el valor '%s' y el switch no tiene salida default", texto)
+}
+
+// tieneSalida indica si alguna conexión (que no sea de error) sale por el handle
+func tieneSalida(salidas []aristaFlujo, handle string) bool {
+	for _, a := range salidas {
+		if a.Type != "error" && a.SourceHandle == handle {
+			return true
+		}
+	}
+	return false
+}
+
+func (c casoSwitch) coincide(valor interface{}, texto string) (bool, error) {
+	switch {
+	case c.Regex != "":
+		re, err := regexCaso(c.Regex)
+		if err != nil {
+			return false, err
+		}
+		return re.MatchString(texto), nil
+
+	case c.Desde != nil || c.Hasta != nil:
+		numero, ok := numeroCaso(valor)
+		if !ok {
+			return false, nil
+		}
+		return (c.Desde == nil || numero >= *c.Desde) && (c.Hasta == nil || numero <= *c.Hasta), nil
+
+	case c.Valor != nil:
+		// Se compara como texto: el caso "200" coincide con el número 200
+		return textoCaso(c.Valor) == texto, nil
+	}
+	return false, nil
+}
+
+func regexCaso(patron string) (*regexp.Regexp, error) {
+	if re, ok := regexCasos.Load(patron); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(patron)
+	if err != nil {
+		return nil, fmt.Errorf("regex inválida en caso de switch '%s': %w", patron, err)
+	}
+	regexCasos.Store(patron, re)
+	return re, nil
+}
+
+// textoCaso convierte el valor al texto con el que se compara contra los handles; los números
+// enteros quedan sin decimales (las expresiones devuelven los números como float64)
+func textoCaso(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(x), 'f', -1, 32)
+	}
+	return fmt.Sprint(v)
+}
+
+func numeroCaso(v interface{}) (float64, bool) {
+	switch x := v.(type) {
+	case float64:
+		return x, true
+	case float32:
+		return float64(x), true
+	case int:
+		return float64(x), true
+	case int64:
+		return float64(x), true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(x), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// aristasDelCaso activa las conexiones del switch cuyo handle es el caso elegido; con error
+// solo se siguen las conexiones de tipo error
+func (g *grafoFlujo) aristasDelCaso(n estructuras.NodoGenerico, conError bool, caso string) []aristaFlujo {
+	var tomadas []aristaFlujo
+	for _, a := range g.salientes[n.ID] {
+		if conError {
+			if a.Type == "error" {
+				tomadas = append(tomadas, a)
+			}
+		} else if a.Type != "error" && a.SourceHandle == caso {
+			tomadas = append(tomadas, a)
+		}
+	}
+	return tomadas
+}
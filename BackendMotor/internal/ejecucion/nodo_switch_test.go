@@ -0,0 +1,86 @@
+package ejecucion
+
+import (
+	"backendmotor/internal/estructuras"
+	"strings"
+	"testing"
+)
+
+func TestEjecutarNodoSwitch(t *testing.T) {
+	casos := []struct {
+		nombre  string
+		estado  interface{}
+		aristas []string
+		visita  string // nodo al que llega el switch ("" si sale por error)
+		falla   string
+	}{
+		{
+			nombre:  "caso que coincide",
+			estado:  200.0,
+			aristas: []string{"e>sw", "sw>ok:ok", "sw>otro:default"},
+			visita:  "ok",
+		},
+		{
+			nombre:  "sin coincidencia toma default",
+			estado:  500.0,
+			aristas: []string{"e>sw", "sw>ok:ok", "sw>otro:default"},
+			visita:  "otro",
+		},
+		{
+			// El caso "no" coincide pero nadie conectó esa salida
+			nombre:  "caso sin conexión toma default",
+			estado:  404.0,
+			aristas: []string{"e>sw", "sw>ok:ok", "sw>otro:default"},
+			visita:  "otro",
+		},
+		{
+			nombre:  "sin coincidencia ni default",
+			estado:  500.0,
+			aristas: []string{"e>sw", "sw>ok:ok"},
+			falla:   "ningún caso coincide con el valor '500'",
+		},
+		{
+			nombre:  "caso sin conexión ni default",
+			estado:  404.0,
+			aristas: []string{"e>sw", "sw>ok:ok"},
+			falla:   "el caso 'no' elegido para el valor '404' no tiene conexión",
+		},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			sw := nodo("sw", "switch", map[string]interface{}{
+				"expresion": "estado",
+				"casos": []interface{}{
+					map[string]interface{}{"valor": "200", "handle": "ok"},
+					map[string]interface{}{"valor": 404.0, "handle": "no"},
+				},
+			})
+			nodos := []estructuras.NodoGenerico{nodo("e", "entrada", nil), sw, nodo("ok", "union", nil), nodo("otro", "union", nil)}
+			e := estadoDePrueba(t, nodos, c.aristas, map[string]interface{}{"estado": c.estado})
+			if err := e.recorrerDesde("e"); err != nil {
+				t.Fatalf("error inesperado: %v", err)
+			}
+
+			for _, id := range []string{"ok", "otro"} {
+				if e.visitados[id] != (id == c.visita) {
+					t.Fatalf("se esperaba llegar a %q y visitados es %v", c.visita, e.visitados)
+				}
+			}
+			if c.falla != "" {
+				if !e.erroresPorNodo["sw"] || e.resultado["codigoError"] != "SWITCH_ERROR" {
+					t.Fatalf("el switch debía salir por error: %v", e.resultado)
+				}
+				if detalle, _ := e.resultado["detalleError"].(string); !strings.Contains(detalle, c.falla) {
+					t.Fatalf("se esperaba el error %q y se obtuvo %q", c.falla, detalle)
+				}
+			}
+			// El caso elegido se pasa al planificador, no queda como variable
+			for k := range e.resultado {
+				if strings.HasPrefix(k, "caso_") {
+					t.Fatalf("el resultado no debía tener la variable %s", k)
+				}
+			}
+		})
+	}
+}
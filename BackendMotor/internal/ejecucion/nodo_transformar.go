@@ -0,0 +1,291 @@
+package ejecucion
+
+import (
+	"backendmotor/internal/estructuras"
+	"backendmotor/internal/utils"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// VariableTransformarDefecto es donde queda el resultado si el nodo no define variableSalida
+const VariableTransformarDefecto = "transformado"
+
+// Directivas de la plantilla del nodo transformar. Un objeto que tiene alguna de estas claves no
+// se copia tal cual sino que se resuelve:
+//
+//	{"$para": "cliente.direcciones", "$como": "dir", "$indice": "i", "$filtro": "dir.activa",
+//	 "$plantilla": {"ciudad": "{{ dir.ciudad }}"}}          → arreglo, una entrada por elemento
+//	{"$si": "monto > 1000", "$entonces": "ALTO", "$sino": "NORMAL"} → sin $sino la clave se omite
+//	{"$valor": "cliente.apodo", "$defecto": "{{ cliente.nombre }}"} → si no existe o es nulo
+//
+// Los textos "{{ expresion }}" se reemplazan por el valor de la expresión con su tipo original
+// (número, objeto, arreglo...); si el texto tiene más contenido se interpola como texto
+const (
+	directivaPara      = "$para"
+	directivaComo      = "$como"
+	directivaIndice    = "$indice"
+	directivaFiltro    = "$filtro"
+	directivaPlantilla = "$plantilla"
+	directivaSi        = "$si"
+	directivaEntonces  = "$entonces"
+	directivaSino      = "$sino"
+	directivaValor     = "$valor"
+	directivaDefecto   = "$defecto"
+)
+
+// ejecutarNodoTransformar arma data.plantilla contra el resultado y guarda la salida en
+// data.variableSalida
+func ejecutarNodoTransformar(n estructuras.NodoGenerico, resultado map[string]interface{}, codigoCanal string) (map[string]interface{}, error) {
+	inicio := time.Now()
+
+	// 🧠 Paso 1: Leer plantilla y variable de salida (la plantilla puede venir como texto JSON)
+	plantilla, ok := n.Data["plantilla"]
+	if !ok || plantilla == nil {
+		return nil, fmt.Errorf("el nodo transformar %s no tiene plantilla", n.ID)
+	}
+	if texto, ok := plantilla.(string); ok {
+		var decodificada interface{}
+		if err := json.Unmarshal([]byte(texto), &decodificada); err != nil {
+			return nil, fmt.Errorf("la plantilla del nodo %s no es un JSON válido: %w", n.ID, err)
+		}
+		plantilla = decodificada
+	}
+	variable, _ := n.Data["variableSalida"].(string)
+	if variable == "" {
+		variable = VariableTransformarDefecto
+	}
+
+	// 🧩 Paso 2: Resolver la plantilla
+	r := &renderizadorPlantilla{ambito: superficial(resultado)}
+	salida, incluir, err := r.resolver(plantilla, "$")
+	if err != nil {
+		return nil, err
+	}
+	if !incluir {
+		salida = nil
+	}
+
+	// ✅ Paso 3: Guardar el resultado
+	resultado[variable] = salida
+
+	utils.RegistrarEjecucionLog(utils.RegistroEjecucion{
+		Timestamp:     time.Now().Format(time.RFC3339),
+		ProcesoId:     n.ID,
+		NombreProceso: fmt.Sprintf("%v", n.Data["label"]),
+		Canal:         codigoCanal,
+		TipoObjeto:    "transformar",
+		NombreObjeto:  variable,
+		Resultado:     map[string]interface{}{variable: salida},
+		DuracionMs:    time.Since(inicio).Milliseconds(),
+		Estado:        "exito",
+	})
+
+	return map[string]interface{}{variable: salida}, nil
+}
+
+// renderizadorPlantilla resuelve una plantilla; ambito son las variables visibles, a las que
+// $para agrega temporalmente el elemento y el índice de cada vuelta
+type renderizadorPlantilla struct {
+	ambito map[string]interface{}
+}
+
+// resolver devuelve el valor de un nodo de la plantilla; incluir es false cuando un $si sin
+// $sino no se cumple y la clave o el elemento deben omitirse. ruta se usa en los errores
+func (r *renderizadorPlantilla) resolver(plantilla interface{}, ruta string) (interface{}, bool, error) {
+	switch p := plantilla.(type) {
+	case string:
+		v, err := r.texto(p, ruta)
+		return v, true, err
+
+	case []interface{}:
+		lista := make([]interface{}, 0, len(p))
+		for i, item := range p {
+			v, incluir, err := r.resolver(item, fmt.Sprintf("%s[%d]", ruta, i))
+			if err != nil {
+				return nil, false, err
+			}
+			if incluir {
+				lista = append(lista, v)
+			}
+		}
+		return lista, true, nil
+
+	case map[string]interface{}:
+		if _, ok := p[directivaPara]; ok {
+			v, err := r.para(p, ruta)
+			return v, true, err
+		}
+		if _, ok := p[directivaSi]; ok {
+			return r.si(p, ruta)
+		}
+		if _, ok := p[directivaValor]; ok {
+			return r.valorConDefecto(p, ruta)
+		}
+
+		objeto := make(map[string]interface{}, len(p))
+		for k, item := range p {
+			v, incluir, err := r.resolver(item, ruta+"."+k)
+			if err != nil {
+				return nil, false, err
+			}
+			if incluir {
+				objeto[k] = v
+			}
+		}
+		return objeto, true, nil
+	}
+
+	// Números, booleanos y null se copian tal cual
+	return plantilla, true, nil
+}
+
+// texto resuelve "{{ expr }}" conservando el tipo, o interpola varias expresiones en un texto
+func (r *renderizadorPlantilla) texto(s string, ruta string) (interface{}, error) {
+	if !strings.Contains(s, "{{") {
+		return s, nil
+	}
+
+	recortado := strings.TrimSpace(s)
+	if strings.HasPrefix(recortado, "{{") && strings.HasSuffix(recortado, "}}") && strings.Count(recortado, "{{") == 1 {
+		return r.evaluar(recortado[2:len(recortado)-2], ruta)
+	}
+
+	var sb strings.Builder
+	resto := s
+	for {
+		ini := strings.Index(resto, "{{")
+		if ini < 0 {
+			sb.WriteString(resto)
+			break
+		}
+		fin := strings.Index(resto[ini:], "}}")
+		if fin < 0 {
+			return nil, fmt.Errorf("plantilla %s: falta '}}' en '%s'", ruta, s)
+		}
+		sb.WriteString(resto[:ini])
+		v, err := r.evaluar(resto[ini+2:ini+fin], ruta)
+		if err != nil {
+			return nil, err
+		}
+		sb.WriteString(textoCaso(v))
+		resto = resto[ini+fin+2:]
+	}
+	return sb.String(), nil
+}
+
+// evaluar resuelve primero como variable o ruta (devuelve objetos y arreglos completos) y si
+// no, como expresión del motor de condiciones
+func (r *renderizadorPlantilla) evaluar(expr string, ruta string) (interface{}, error) {
+	expr = strings.TrimSpace(expr)
+	if v, ok := utils.ResolverRuta(r.ambito, expr); ok {
+		return copiarValor(v), nil
+	}
+	v, err := utils.EvaluarValor(expr, r.ambito)
+	if err != nil {
+		return nil, fmt.Errorf("plantilla %s: %w", ruta, err)
+	}
+	return v, nil
+}
+
+// para arma un arreglo resolviendo $plantilla una vez por elemento de la colección
+func (r *renderizadorPlantilla) para(p map[string]interface{}, ruta string) (interface{}, error) {
+	expr, _ := p[directivaPara].(string)
+	coleccion, err := r.evaluar(expr, ruta)
+	if err != nil {
+		return nil, err
+	}
+	if coleccion == nil {
+		return []interface{}{}, nil
+	}
+	elementos, ok := elementosIterables(coleccion)
+	if !ok {
+		return nil, fmt.Errorf("plantilla %s: '%s' no es un arreglo", ruta, expr)
+	}
+
+	como, _ := p[directivaComo].(string)
+	if como == "" {
+		como = "item"
+	}
+	indice, _ := p[directivaIndice].(string)
+	if indice == "" {
+		indice = "indice"
+	}
+	filtro, _ := p[directivaFiltro].(string)
+
+	// Las variables de la vuelta tapan a las del flujo solo mientras dura el $para
+	previoComo, teniaComo := r.ambito[como]
+	previoIndice, teniaIndice := r.ambito[indice]
+	defer func() {
+		restaurarAmbito(r.ambito, como, previoComo, teniaComo)
+		restaurarAmbito(r.ambito, indice, previoIndice, teniaIndice)
+	}()
+
+	lista := make([]interface{}, 0, len(elementos))
+	for i, elemento := range elementos {
+		r.ambito[como] = elemento
+		r.ambito[indice] = i
+		rutaElemento := fmt.Sprintf("%s[%d]", ruta, i)
+
+		if filtro != "" {
+			pasa, err := r.evaluar(filtro, rutaElemento)
+			if err != nil {
+				return nil, err
+			}
+			if b, ok := pasa.(bool); !ok || !b {
+				continue
+			}
+		}
+
+		v, incluir, err := r.resolver(p[directivaPlantilla], rutaElemento)
+		if err != nil {
+			return nil, err
+		}
+		if incluir {
+			lista = append(lista, v)
+		}
+	}
+	return lista, nil
+}
+
+// si resuelve $entonces o $sino según la condición; sin $sino y con condición falsa se omite
+func (r *renderizadorPlantilla) si(p map[string]interface{}, ruta string) (interface{}, bool, error) {
+	expr, _ := p[directivaSi].(string)
+	v, err := r.evaluar(expr, ruta)
+	if err != nil {
+		return nil, false, err
+	}
+	cumple, ok := v.(bool)
+	if !ok {
+		return nil, false, fmt.Errorf("plantilla %s: la condición '%s' no devolvió un booleano", ruta, expr)
+	}
+
+	if cumple {
+		return r.resolver(p[directivaEntonces], ruta)
+	}
+	if sino, ok := p[directivaSino]; ok {
+		return r.resolver(sino, ruta)
+	}
+	return nil, false, nil
+}
+
+// valorConDefecto usa $defecto si la expresión falla o da nulo
+func (r *renderizadorPlantilla) valorConDefecto(p map[string]interface{}, ruta string) (interface{}, bool, error) {
+	expr, _ := p[directivaValor].(string)
+	if v, err := r.evaluar(expr, ruta); err == nil && v != nil {
+		return v, true, nil
+	}
+	if defecto, ok := p[directivaDefecto]; ok {
+		return r.resolver(defecto, ruta)
+	}
+	return nil, true, nil
+}
+
+func restaurarAmbito(ambito map[string]interface{}, clave string, previo interface{}, tenia bool) {
+	if tenia {
+		ambito[clave] = previo
+	} else {
+		delete(ambito, clave)
+	}
+}
@@ -1,6 +1,7 @@
 package ejecucion
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"time"
@@ -168,9 +169,9 @@ func EjecutarCanal(procesoID string, input map[string]interface{}, canalCodigo s
 			var fullOutputStr string
 			var execErr error
 			if nodoProceso.TipoObjeto == "plpgsql_function" {
-				fullOutputStr, execErr = ejecutor.EjecutarFuncion(nodoProceso.Objeto, params)
+				fullOutputStr, execErr = ejecutor.EjecutarFuncion(context.Background(), nodoProceso.Objeto, params)
 			} else if nodoProceso.TipoObjeto == "plpgsql_procedure" {
-				fullOutputStr, execErr = ejecutor.EjecutarProcedimiento(nodoProceso.Objeto, params)
+				fullOutputStr, execErr = ejecutor.EjecutarProcedimiento(context.Background(), nodoProceso.Objeto, params)
 			} else {
 				return ResultadoEjecucion{}, fmt.Errorf("tipo de objeto no soportado: %s", nodoProceso.TipoObjeto)
 			}
@@ -0,0 +1,445 @@
+package ejecucion
+
+import (
+	"backendmotor/internal/estructuras"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// aristaFlujo es una conexión del flujo con su posición original en el JSON
+type aristaFlujo struct {
+	Indice       int
+	ID           string
+	Source       string
+	Target       string
+	SourceHandle string
+	TargetHandle string
+	Type         string
+}
+
+// grafoFlujo mantiene los nodos y conexiones del flujo indexados para el planificador
+type grafoFlujo struct {
+	nodos     map[string]estructuras.NodoGenerico
+	aristas   []aristaFlujo
+	salientes map[string][]aristaFlujo // conexiones que salen de cada nodo, en orden de declaración
+	entrantes map[string][]aristaFlujo // conexiones que llegan a cada nodo, en orden de declaración
+	orden     map[string]int           // posición del nodo en el orden topológico
+}
+
+// construirGrafoFlujo indexa nodos y conexiones y calcula un orden topológico estable. Un flujo
+// con ciclos no se puede ejecutar (un nodo esperaría por sí mismo): el grafo se devuelve igual,
+// para que el validador pueda seguir revisándolo, junto con el error que describe el ciclo
+func construirGrafoFlujo(nodos []estructuras.NodoGenerico, aristas []estructuras.EdgeGenerico) (*grafoFlujo, error) {
+	g := &grafoFlujo{
+		nodos:     make(map[string]estructuras.NodoGenerico),
+		salientes: make(map[string][]aristaFlujo),
+		entrantes: make(map[string][]aristaFlujo),
+		orden:     make(map[string]int),
+	}
+
+	declaracion := make(map[string]int)
+	for i, n := range nodos {
+		if _, existe := g.nodos[n.ID]; existe {
+			continue
+		}
+		g.nodos[n.ID] = n
+		declaracion[n.ID] = i
+	}
+
+	for i, e := range aristas {
+		a := aristaFlujo{
+			Indice:       i,
+			ID:           e.ID,
+			Source:       e.Source,
+			Target:       e.Target,
+			SourceHandle: e.SourceHandle,
+			TargetHandle: e.TargetHandle,
+			Type:         e.Type,
+		}
+		g.aristas = append(g.aristas, a)
+		g.salientes[a.Source] = append(g.salientes[a.Source], a)
+		g.entrantes[a.Target] = append(g.entrantes[a.Target], a)
+	}
+
+	// 🧮 Kahn: entre los nodos disponibles siempre se toma el primero declarado
+	gradoEntrada := make(map[string]int)
+	for id := range g.nodos {
+		for _, a := range g.entrantes[id] {
+			if _, existe := g.nodos[a.Source]; existe {
+				gradoEntrada[id]++
+			}
+		}
+	}
+
+	pendientes := make(map[string]bool)
+	for id := range g.nodos {
+		pendientes[id] = true
+	}
+
+	posicion := 0
+	var ciclo []string
+	for len(pendientes) > 0 {
+		elegido := ""
+		for id := range pendientes {
+			if gradoEntrada[id] > 0 {
+				continue
+			}
+			if elegido == "" || declaracion[id] < declaracion[elegido] {
+				elegido = id
+			}
+		}
+
+		// Con un ciclo el orden se completa tomando el primer nodo declarado que quede
+		if elegido == "" {
+			if ciclo == nil {
+				ciclo = g.buscarCiclo(pendientes, declaracion)
+			}
+			for id := range pendientes {
+				if elegido == "" || declaracion[id] < declaracion[elegido] {
+					elegido = id
+				}
+			}
+		}
+
+		delete(pendientes, elegido)
+		g.orden[elegido] = posicion
+		posicion++
+
+		for _, a := range g.salientes[elegido] {
+			if pendientes[a.Target] {
+				gradoEntrada[a.Target]--
+			}
+		}
+	}
+
+	if ciclo != nil {
+		return g, fmt.Errorf("el flujo tiene un ciclo: %s", strings.Join(ciclo, " → "))
+	}
+	return g, nil
+}
+
+// buscarCiclo devuelve un ciclo entre los nodos pendientes del orden topológico, empezando y
+// terminando en el mismo nodo (por ejemplo a → b → a). Se recorre en orden de declaración para
+// que el mensaje sea siempre el mismo
+func (g *grafoFlujo) buscarCiclo(pendientes map[string]bool, declaracion map[string]int) []string {
+	ids := make([]string, 0, len(pendientes))
+	for id := range pendientes {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return declaracion[ids[i]] < declaracion[ids[j]] })
+
+	// 0 = sin visitar, 1 = en el camino actual, 2 = terminado
+	marca := make(map[string]int)
+	var camino []string
+	var visitar func(id string) []string
+	visitar = func(id string) []string {
+		marca[id] = 1
+		camino = append(camino, id)
+		for _, a := range g.salientes[id] {
+			if !pendientes[a.Target] {
+				continue
+			}
+			switch marca[a.Target] {
+			case 1:
+				for i, c := range camino {
+					if c == a.Target {
+						return append(append([]string{}, camino[i:]...), a.Target)
+					}
+				}
+			case 0:
+				if ciclo := visitar(a.Target); ciclo != nil {
+					return ciclo
+				}
+			}
+		}
+		camino = camino[:len(camino)-1]
+		marca[id] = 2
+		return nil
+	}
+
+	for _, id := range ids {
+		if marca[id] == 0 {
+			if ciclo := visitar(id); ciclo != nil {
+				return ciclo
+			}
+		}
+	}
+	return ids
+}
+
+// aristasTomadas decide qué conexiones salientes de un nodo se activan según su resultado
+func (g *grafoFlujo) aristasTomadas(n estructuras.NodoGenerico, conError bool, cumple bool) []aristaFlujo {
+	var tomadas []aristaFlujo
+
+	for _, a := range g.salientes[n.ID] {
+		switch n.Type {
+		case "condicion":
+			if (cumple && a.SourceHandle == "true") || (!cumple && a.SourceHandle == "false") {
+				tomadas = append(tomadas, a)
+			}
+
+		case "subproceso":
+			// Si hay error solo se sigue hacia salidaError; si no, hacia todo lo demás
+			destino := g.nodos[a.Target]
+			if conError && destino.Type == "salidaError" {
+				tomadas = append(tomadas, a)
+			} else if !conError && destino.Type != "salidaError" {
+				tomadas = append(tomadas, a)
+			}
+
+		default:
+			if conError && a.Type == "error" {
+				tomadas = append(tomadas, a)
+			} else if !conError && a.Type != "error" {
+				tomadas = append(tomadas, a)
+			}
+		}
+	}
+
+	return tomadas
+}
+
+// planificador recorre el grafo en orden determinista y espera en los puntos de unión
+// hasta que todas las ramas entrantes de un nodo estén resueltas (activadas o descartadas)
+type planificador struct {
+	grafo      *grafoFlujo
+	activada   []bool // la conexión fue tomada por su nodo origen
+	resuelta   []bool // la conexión ya no puede cambiar de estado
+	ejecutados map[string]bool
+	listos     map[string]bool
+	permitidos map[string]bool // si no es nil, solo estos nodos pueden ejecutarse (ramas paralelas)
+	fugas      map[string]bool // nodos fuera de la región que recibieron una conexión activa
+}
+
+// nuevoPlanificador prepara el recorrido a partir del nodo de entrada
+func nuevoPlanificador(g *grafoFlujo, nodoInicial string) *planificador {
+	p := &planificador{
+		grafo:      g,
+		activada:   make([]bool, len(g.aristas)),
+		resuelta:   make([]bool, len(g.aristas)),
+		ejecutados: make(map[string]bool),
+		listos:     make(map[string]bool),
+	}
+
+	// Las conexiones que salen de nodos inalcanzables nunca se activarán
+	alcanzables := map[string]bool{nodoInicial: true}
+	cola := []string{nodoInicial}
+	for len(cola) > 0 {
+		id := cola[0]
+		cola = cola[1:]
+		for _, a := range g.salientes[id] {
+			if !alcanzables[a.Target] {
+				alcanzables[a.Target] = true
+				cola = append(cola, a.Target)
+			}
+		}
+	}
+	for _, a := range g.aristas {
+		if !alcanzables[a.Source] {
+			p.resuelta[a.Indice] = true
+		}
+	}
+
+	p.listos[nodoInicial] = true
+	return p
+}
+
+// nuevoPlanificadorRegion prepara el recorrido de una rama que solo puede ejecutar los
+// nodos de la región; las conexiones activadas hacia afuera quedan registradas como fugas
+func nuevoPlanificadorRegion(g *grafoFlujo, nodoInicial string, region map[string]bool) *planificador {
+	p := nuevoPlanificador(g, nodoInicial)
+	p.permitidos = region
+	p.fugas = make(map[string]bool)
+
+	// Solo cuentan las conexiones internas: lo que entra desde fuera de la región ya está resuelto
+	for _, a := range g.aristas {
+		if !region[a.Source] {
+			p.resuelta[a.Indice] = true
+		}
+	}
+	return p
+}
+
+// nuevoPlanificadorCuerpo prepara una pasada por el cuerpo de un bloque (por ejemplo, una
+// iteración): el nodo origen se da por ejecutado y se activan todas sus conexiones hacia la región
+func nuevoPlanificadorCuerpo(g *grafoFlujo, origen string, region map[string]bool) *planificador {
+	p := nuevoPlanificadorRegion(g, origen, region)
+	delete(p.listos, origen)
+	p.ejecutados[origen] = true
+
+	for _, a := range g.salientes[origen] {
+		if a.Type == "error" || !region[a.Target] {
+			continue
+		}
+		p.activada[a.Indice] = true
+		p.evaluarDestino(a.Target)
+	}
+	return p
+}
+
+// completarRegion da por ejecutada una región (paralelo → ramas → unión) y deja lista la unión;
+// las conexiones que salían de la región hacia otros nodos se descartan
+func (p *planificador) completarRegion(region map[string]bool, union string) {
+	for id := range region {
+		p.ejecutados[id] = true
+		delete(p.listos, id)
+	}
+
+	var destinos []string
+	for _, a := range p.grafo.aristas {
+		if !region[a.Source] || p.resuelta[a.Indice] {
+			continue
+		}
+		p.resuelta[a.Indice] = true
+		if a.Target == union {
+			p.activada[a.Indice] = true
+		}
+		destinos = append(destinos, a.Target)
+	}
+
+	for _, id := range destinos {
+		if !region[id] {
+			p.evaluarDestino(id)
+		}
+	}
+}
+
+// siguiente devuelve el próximo nodo listo según el orden topológico. El flujo compilado no
+// tiene ciclos, así que ningún nodo activado puede quedar esperando por sí mismo
+func (p *planificador) siguiente() (string, bool) {
+	elegido := ""
+	for id := range p.listos {
+		if elegido == "" || p.grafo.orden[id] < p.grafo.orden[elegido] {
+			elegido = id
+		}
+	}
+
+	if elegido == "" {
+		return "", false
+	}
+
+	delete(p.listos, elegido)
+	p.ejecutados[elegido] = true
+	return elegido, true
+}
+
+// completar registra las conexiones tomadas por un nodo y descarta las demás
+func (p *planificador) completar(nodoID string, tomadas []aristaFlujo) {
+	activar := make(map[int]bool)
+	for _, a := range tomadas {
+		activar[a.Indice] = true
+	}
+
+	for _, a := range p.grafo.salientes[nodoID] {
+		if p.resuelta[a.Indice] {
+			continue
+		}
+		p.resuelta[a.Indice] = true
+		if activar[a.Indice] {
+			p.activada[a.Indice] = true
+			fmt.Printf("➡️ Activando conexión %s → %s\n", nodoID, a.Target)
+		}
+		p.evaluarDestino(a.Target)
+	}
+}
+
+// evaluarDestino marca un nodo como listo cuando todas sus entradas están resueltas;
+// si ninguna fue activada, el nodo se descarta y propaga el descarte a sus salidas
+func (p *planificador) evaluarDestino(nodoID string) {
+	if p.ejecutados[nodoID] || p.listos[nodoID] {
+		return
+	}
+
+	for _, a := range p.grafo.entrantes[nodoID] {
+		if !p.resuelta[a.Indice] {
+			return
+		}
+	}
+
+	if p.tieneEntradaActiva(nodoID) {
+		if p.permitidos != nil && !p.permitidos[nodoID] {
+			p.fugas[nodoID] = true
+			return
+		}
+		if _, existe := p.grafo.nodos[nodoID]; existe {
+			p.listos[nodoID] = true
+		}
+		return
+	}
+
+	p.completar(nodoID, nil)
+}
+
+// tieneEntradaActiva indica si alguna conexión entrante del nodo fue tomada
+func (p *planificador) tieneEntradaActiva(nodoID string) bool {
+	for _, a := range p.grafo.entrantes[nodoID] {
+		if p.activada[a.Indice] {
+			return true
+		}
+	}
+	return false
+}
+
+// estadoPlanificador es la forma serializable del planificador principal (checkpoints)
+type estadoPlanificador struct {
+	Aristas    int      `json:"aristas"` // cantidad de conexiones del flujo al guardar
+	Activadas  []int    `json:"activadas"`
+	Resueltas  []int    `json:"resueltas"`
+	Ejecutados []string `json:"ejecutados"`
+	Listos     []string `json:"listos"`
+}
+
+// exportar toma una foto del recorrido para poder retomarlo
+func (p *planificador) exportar() estadoPlanificador {
+	e := estadoPlanificador{Aristas: len(p.grafo.aristas)}
+	for i := range p.grafo.aristas {
+		if p.activada[i] {
+			e.Activadas = append(e.Activadas, i)
+		}
+		if p.resuelta[i] {
+			e.Resueltas = append(e.Resueltas, i)
+		}
+	}
+	for id := range p.ejecutados {
+		e.Ejecutados = append(e.Ejecutados, id)
+	}
+	for id := range p.listos {
+		e.Listos = append(e.Listos, id)
+	}
+	return e
+}
+
+// restaurar vuelve el planificador al estado exportado; falla si el flujo cambió desde entonces
+func (p *planificador) restaurar(e estadoPlanificador) error {
+	if e.Aristas != len(p.grafo.aristas) {
+		return fmt.Errorf("el flujo tenía %d conexiones y ahora tiene %d", e.Aristas, len(p.grafo.aristas))
+	}
+	for _, id := range append(append([]string{}, e.Ejecutados...), e.Listos...) {
+		if _, existe := p.grafo.nodos[id]; !existe {
+			return fmt.Errorf("el nodo %s ya no existe en el flujo", id)
+		}
+	}
+
+	p.activada = make([]bool, len(p.grafo.aristas))
+	p.resuelta = make([]bool, len(p.grafo.aristas))
+	p.ejecutados = make(map[string]bool)
+	p.listos = make(map[string]bool)
+	for _, i := range e.Activadas {
+		if i >= 0 && i < len(p.activada) {
+			p.activada[i] = true
+		}
+	}
+	for _, i := range e.Resueltas {
+		if i >= 0 && i < len(p.resuelta) {
+			p.resuelta[i] = true
+		}
+	}
+	for _, id := range e.Ejecutados {
+		p.ejecutados[id] = true
+	}
+	for _, id := range e.Listos {
+		p.listos[id] = true
+	}
+	return nil
+}
@@ -0,0 +1,278 @@
+package ejecucion
+
+import (
+	"backendmotor/internal/estructuras"
+	"backendmotor/internal/models"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// grafoDePrueba arma un grafo con nodos "id:tipo" (tipo "proceso" si falta) y conexiones
+// "origen>destino" u "origen>destino:handle"; con "origen>destino!" la conexión es de error
+func grafoDePrueba(t *testing.T, nodos []string, aristas []string) *grafoFlujo {
+	t.Helper()
+	g, err := construirGrafoFlujo(nodosDePrueba(nodos), aristasDePrueba(aristas))
+	if err != nil {
+		t.Fatalf("error inesperado al construir el grafo: %v", err)
+	}
+	return g
+}
+
+func nodosDePrueba(nodos []string) []estructuras.NodoGenerico {
+	var lista []estructuras.NodoGenerico
+	for _, n := range nodos {
+		id, tipo, ok := strings.Cut(n, ":")
+		if !ok {
+			tipo = "proceso"
+		}
+		lista = append(lista, estructuras.NodoGenerico{ID: id, Type: tipo, Data: map[string]interface{}{}})
+	}
+	return lista
+}
+
+func aristasDePrueba(aristas []string) []estructuras.EdgeGenerico {
+	var lista []estructuras.EdgeGenerico
+	for _, a := range aristas {
+		origen, resto, _ := strings.Cut(a, ">")
+		destino, handle, _ := strings.Cut(resto, ":")
+		arista := estructuras.EdgeGenerico{ID: a, Source: origen, Target: destino, SourceHandle: handle}
+		if destino, ok := strings.CutSuffix(destino, "!"); ok {
+			arista.Target, arista.Type = destino, "error"
+		}
+		lista = append(lista, arista)
+	}
+	return lista
+}
+
+func procesoDePrueba(flujo string) models.Proceso {
+	return models.Proceso{ID: "proceso-prueba", Nombre: "prueba", Flujo: flujo}
+}
+
+func tieneProblema(res ResultadoValidacion, codigo string) bool {
+	for _, p := range res.Problemas {
+		if p.Codigo == codigo {
+			return true
+		}
+	}
+	return false
+}
+
+// recorridoDePrueba ejecuta el planificador tomando las conexiones que tomaría cada nodo sin
+// error; las condiciones dan el valor de cumple. Devuelve los nodos en el orden en que corrieron
+func recorridoDePrueba(g *grafoFlujo, inicio string, cumple map[string]bool) []string {
+	p := nuevoPlanificador(g, inicio)
+	var orden []string
+	for {
+		id, ok := p.siguiente()
+		if !ok {
+			return orden
+		}
+		orden = append(orden, id)
+		p.completar(id, g.aristasTomadas(g.nodos[id], false, cumple[id]))
+	}
+}
+
+func TestConstruirGrafoFlujoOrden(t *testing.T) {
+	casos := []struct {
+		nombre   string
+		nodos    []string
+		aristas  []string
+		esperado []string
+	}{
+		{
+			nombre:   "lineal",
+			nodos:    []string{"e:entrada", "a", "s:salida"},
+			aristas:  []string{"e>a", "a>s"},
+			esperado: []string{"e", "a", "s"},
+		},
+		{
+			// Los nodos disponibles a la vez se ordenan por su posición en el JSON, no por
+			// el orden de las conexiones
+			nombre:   "varias salidas",
+			nodos:    []string{"e:entrada", "c", "b", "a", "s:salida"},
+			aristas:  []string{"e>a", "e>b", "e>c", "a>s", "b>s", "c>s"},
+			esperado: []string{"e", "c", "b", "a", "s"},
+		},
+		{
+			// Un nodo declarado antes espera igual a sus predecesores
+			nombre:   "declarado antes que su origen",
+			nodos:    []string{"s:salida", "b", "a", "e:entrada"},
+			aristas:  []string{"e>a", "a>b", "b>s"},
+			esperado: []string{"e", "a", "b", "s"},
+		},
+		{
+			nombre:   "conexión a un nodo desconocido",
+			nodos:    []string{"e:entrada", "a"},
+			aristas:  []string{"e>a", "a>fantasma"},
+			esperado: []string{"e", "a"},
+		},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			g := grafoDePrueba(t, c.nodos, c.aristas)
+			obtenido := make([]string, len(g.orden))
+			for id, pos := range g.orden {
+				obtenido[pos] = id
+			}
+			if !reflect.DeepEqual(obtenido, c.esperado) {
+				t.Fatalf("se esperaba el orden %v y se obtuvo %v", c.esperado, obtenido)
+			}
+		})
+	}
+}
+
+func TestConstruirGrafoFlujoCiclo(t *testing.T) {
+	casos := []struct {
+		nombre  string
+		nodos   []string
+		aristas []string
+		ciclo   string
+	}{
+		{"a sí mismo", []string{"e:entrada", "a"}, []string{"e>a", "a>a"}, "a → a"},
+		{"dos nodos", []string{"e:entrada", "a", "b", "s:salida"}, []string{"e>a", "a>b", "b>a", "b>s"}, "a → b → a"},
+		{"tras una condición", []string{"e:entrada", "c:condicion", "a", "s:salida"}, []string{"e>c", "c>a:true", "c>s:false", "a>c"}, "c → a → c"},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			g, err := construirGrafoFlujo(nodosDePrueba(c.nodos), aristasDePrueba(c.aristas))
+			if err == nil {
+				t.Fatalf("se esperaba un error por el ciclo %s", c.ciclo)
+			}
+			if !strings.Contains(err.Error(), c.ciclo) {
+				t.Fatalf("se esperaba el ciclo %s en el error y se obtuvo %v", c.ciclo, err)
+			}
+			// El grafo queda armado para que el validador siga revisando el resto
+			if len(g.orden) != len(c.nodos) {
+				t.Fatalf("se esperaban %d nodos ordenados y hay %d", len(c.nodos), len(g.orden))
+			}
+		})
+	}
+}
+
+func TestCompilarFlujoRechazaCiclos(t *testing.T) {
+	flujo := `{"nodes":[{"id":"e","type":"entrada"},{"id":"a","type":"proceso"},{"id":"b","type":"proceso"}],
+		"edges":[{"id":"1","source":"e","target":"a"},{"id":"2","source":"a","target":"b"},{"id":"3","source":"b","target":"a"}]}`
+	if _, err := CompilarFlujo(procesoDePrueba(flujo)); err == nil || !strings.Contains(err.Error(), "ciclo") {
+		t.Fatalf("se esperaba un error de compilación por el ciclo y se obtuvo %v", err)
+	}
+
+	res := ValidarProceso(context.Background(), procesoDePrueba(flujo))
+	if !tieneProblema(res, ProblemaCicloFlujo) {
+		t.Fatalf("el validador no reportó el ciclo: %+v", res.Problemas)
+	}
+}
+
+func TestPlanificadorRecorrido(t *testing.T) {
+	casos := []struct {
+		nombre   string
+		nodos    []string
+		aristas  []string
+		cumple   map[string]bool
+		esperado []string
+	}{
+		{
+			nombre:   "varias salidas en orden de declaración",
+			nodos:    []string{"e:entrada", "b", "a", "c", "s:salida"},
+			aristas:  []string{"e>a", "e>b", "e>c", "a>s", "b>s", "c>s"},
+			esperado: []string{"e", "b", "a", "c", "s"},
+		},
+		{
+			// La unión espera a la rama larga aunque la corta termine antes
+			nombre:   "unión espera todas las ramas vivas",
+			nodos:    []string{"e:entrada", "a", "b", "c", "j:union", "s:salida"},
+			aristas:  []string{"e>a", "e>b", "a>j", "b>c", "c>j", "j>s"},
+			esperado: []string{"e", "a", "b", "c", "j", "s"},
+		},
+		{
+			// La rama false se descarta y la unión no la espera
+			nombre:   "camino muerto tras la condición",
+			nodos:    []string{"e:entrada", "c:condicion", "x", "y", "z", "j", "s:salida"},
+			aristas:  []string{"e>c", "c>x:true", "c>y:false", "y>z", "x>j", "z>j", "j>s"},
+			cumple:   map[string]bool{"c": true},
+			esperado: []string{"e", "c", "x", "j", "s"},
+		},
+		{
+			// Si ninguna entrada de la unión se activa, el descarte sigue hasta el final
+			nombre:   "descarte en cadena",
+			nodos:    []string{"e:entrada", "c:condicion", "x", "j", "s:salida", "f:salidaError"},
+			aristas:  []string{"e>c", "c>x:true", "c>f:false", "x>j", "j>s"},
+			cumple:   map[string]bool{"c": false},
+			esperado: []string{"e", "c", "f"},
+		},
+		{
+			nombre:   "nodos inalcanzables no bloquean la unión",
+			nodos:    []string{"e:entrada", "a", "suelto", "j", "s:salida"},
+			aristas:  []string{"e>a", "a>j", "suelto>j", "j>s"},
+			esperado: []string{"e", "a", "j", "s"},
+		},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			g := grafoDePrueba(t, c.nodos, c.aristas)
+			// El orden no puede depender del recorrido de los mapas
+			for i := 0; i < 20; i++ {
+				if obtenido := recorridoDePrueba(g, "e", c.cumple); !reflect.DeepEqual(obtenido, c.esperado) {
+					t.Fatalf("se esperaba %v y se obtuvo %v", c.esperado, obtenido)
+				}
+			}
+		})
+	}
+}
+
+func TestPlanificadorUnionNoSeLiberaAntes(t *testing.T) {
+	g := grafoDePrueba(t,
+		[]string{"e:entrada", "a", "b", "c", "j:union"},
+		[]string{"e>a", "e>b", "a>j", "b>c", "c>j"})
+	p := nuevoPlanificador(g, "e")
+
+	pasos := []struct {
+		nodo       string
+		unionLista bool
+	}{
+		{"e", false},
+		{"a", false},
+		{"b", false},
+		{"c", true},
+	}
+	for _, paso := range pasos {
+		if id, ok := p.siguiente(); !ok || id != paso.nodo {
+			t.Fatalf("se esperaba ejecutar %s y se obtuvo %q", paso.nodo, id)
+		}
+		p.completar(paso.nodo, g.aristasTomadas(g.nodos[paso.nodo], false, false))
+		if p.listos["j"] != paso.unionLista {
+			t.Fatalf("tras %s la unión debía estar lista=%v", paso.nodo, paso.unionLista)
+		}
+	}
+}
+
+func TestEvaluarDestinoDescarte(t *testing.T) {
+	g := grafoDePrueba(t,
+		[]string{"e:entrada", "c:condicion", "x", "y", "j"},
+		[]string{"e>c", "c>x:true", "c>y:false", "x>j", "y>j"})
+	p := nuevoPlanificador(g, "e")
+	p.siguiente()
+	p.completar("e", g.aristasTomadas(g.nodos["e"], false, false))
+	p.siguiente()
+	p.completar("c", g.aristasTomadas(g.nodos["c"], false, false))
+
+	// x queda descartado sin ejecutarse: su salida hacia j ya está resuelta e inactiva
+	if p.listos["x"] || p.ejecutados["x"] {
+		t.Fatalf("x no debía quedar listo con cumple=false")
+	}
+	if p.ejecutados["y"] || !p.listos["y"] {
+		t.Fatalf("y debía quedar listo para ejecutarse")
+	}
+	for _, a := range g.salientes["x"] {
+		if !p.resuelta[a.Indice] || p.activada[a.Indice] {
+			t.Fatalf("la conexión %s debía quedar resuelta e inactiva", a.ID)
+		}
+	}
+	if p.listos["j"] {
+		t.Fatalf("j no debía estar lista antes de ejecutar y")
+	}
+}
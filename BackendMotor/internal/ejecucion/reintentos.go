@@ -0,0 +1,224 @@
+package ejecucion
+
+import (
+	"backendmotor/internal/ejecucion/ejecutores"
+	"backendmotor/internal/estructuras"
+	"backendmotor/internal/models"
+	"backendmotor/internal/utils"
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Tipos de falla que se pueden reintentar (valores de reintentarEn)
+const (
+	FallaTimeout   = "timeout"
+	FallaConexion  = "conexion"
+	FallaHTTP5xx   = "http5xx"
+	FallaHTTP429   = "http429"
+	FallaSOAPFault = "soapFault"
+)
+
+// PoliticaReintentos define cuántas veces y cada cuánto se repite la llamada de un nodo proceso.
+// Se lee de data.reintentos del nodo; lo que falte se hereda de Servidor.Extras["reintentos"]
+type PoliticaReintentos struct {
+	MaxIntentos      int
+	BackoffInicial   time.Duration
+	BackoffMaximo    time.Duration
+	Multiplicador    float64
+	Jitter           float64 // fracción de la espera que se randomiza (0 a 1)
+	ReintentarEn     map[string]bool
+	CodigosSOAPFault map[string]bool // faultcodes reintentables; vacío = ninguno
+}
+
+// politicaPorDefecto no reintenta: un solo intento, como antes de existir la política
+func politicaPorDefecto() PoliticaReintentos {
+	return PoliticaReintentos{
+		MaxIntentos:    1,
+		BackoffInicial: 200 * time.Millisecond,
+		BackoffMaximo:  5 * time.Second,
+		Multiplicador:  2,
+		Jitter:         0.2,
+		ReintentarEn: map[string]bool{
+			FallaTimeout:  true,
+			FallaConexion: true,
+			FallaHTTP5xx:  true,
+			FallaHTTP429:  true,
+		},
+		CodigosSOAPFault: map[string]bool{},
+	}
+}
+
+// reintentaHTTP indica si la política repite las respuestas 5xx / 429. Sin eso, un REST que
+// responde así no hace fallar al nodo (como antes de existir la política)
+func (p PoliticaReintentos) reintentaHTTP() bool {
+	return p.MaxIntentos > 1 && (p.ReintentarEn[FallaHTTP5xx] || p.ReintentarEn[FallaHTTP429])
+}
+
+// politicaReintentos combina los valores del servidor con los del nodo (el nodo tiene prioridad)
+func politicaReintentos(n estructuras.NodoGenerico, servidor models.Servidor) PoliticaReintentos {
+	p := politicaPorDefecto()
+	if extras, ok := servidor.Extras["reintentos"].(map[string]interface{}); ok {
+		p.aplicar(extras)
+	}
+	if datos, ok := n.Data["reintentos"].(map[string]interface{}); ok {
+		p.aplicar(datos)
+	}
+	if p.MaxIntentos < 1 {
+		p.MaxIntentos = 1
+	}
+	return p
+}
+
+// politicaParaLlamada es la política de una llamada concreta: al simular o dentro de una
+// transacción del flujo hay un solo intento, porque tras un error la transacción queda abortada
+// y repetir la sentencia dentro de ella no sirve
+func politicaParaLlamada(ctx context.Context, n estructuras.NodoGenerico, servidor models.Servidor, simulando bool) PoliticaReintentos {
+	p := politicaReintentos(n, servidor)
+	if simulando || ejecutores.EnTransaccion(ctx, servidor.ID) {
+		p.MaxIntentos = 1
+	}
+	return p
+}
+
+func (p *PoliticaReintentos) aplicar(cfg map[string]interface{}) {
+	if v, ok := utils.NumeroConfig(cfg["maxIntentos"]); ok {
+		p.MaxIntentos = int(v)
+	}
+	if v, ok := utils.NumeroConfig(cfg["backoffInicialMs"]); ok {
+		p.BackoffInicial = time.Duration(v) * time.Millisecond
+	}
+	if v, ok := utils.NumeroConfig(cfg["backoffMaximoMs"]); ok {
+		p.BackoffMaximo = time.Duration(v) * time.Millisecond
+	}
+	if v, ok := utils.NumeroConfig(cfg["multiplicador"]); ok && v >= 1 {
+		p.Multiplicador = v
+	}
+	if v, ok := utils.NumeroConfig(cfg["jitter"]); ok {
+		p.Jitter = math.Max(0, math.Min(1, v))
+	}
+	if lista, ok := cfg["reintentarEn"].([]interface{}); ok {
+		p.ReintentarEn = make(map[string]bool)
+		for _, v := range lista {
+			p.ReintentarEn[fmt.Sprint(v)] = true
+		}
+	}
+	if lista, ok := cfg["codigosSOAPFault"].([]interface{}); ok {
+		p.CodigosSOAPFault = make(map[string]bool)
+		for _, v := range lista {
+			p.CodigosSOAPFault[fmt.Sprint(v)] = true
+		}
+		if len(p.CodigosSOAPFault) > 0 {
+			p.ReintentarEn[FallaSOAPFault] = true
+		}
+	}
+}
+
+// espera calcula el backoff exponencial antes del intento siguiente, con jitter
+func (p PoliticaReintentos) espera(intento int) time.Duration {
+	base := float64(p.BackoffInicial) * math.Pow(p.Multiplicador, float64(intento-1))
+	if max := float64(p.BackoffMaximo); p.BackoffMaximo > 0 && base > max {
+		base = max
+	}
+	if p.Jitter > 0 {
+		base -= base * p.Jitter * rand.Float64()
+	}
+	return time.Duration(base)
+}
+
+// clasificarFalla devuelve el tipo de falla ("" si no es de las reintentables)
+func clasificarFalla(err error) string {
+	var errHTTP *ejecutores.ErrorHTTP
+	if errors.As(err, &errHTTP) {
+		switch {
+		case errHTTP.Codigo == 429:
+			return FallaHTTP429
+		case errHTTP.Codigo >= 500:
+			return FallaHTTP5xx
+		}
+		return ""
+	}
+
+	var fault *ejecutores.SOAPFault
+	if errors.As(err, &fault) {
+		return FallaSOAPFault
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return FallaTimeout
+	}
+	var errRed net.Error
+	if errors.As(err, &errRed) && errRed.Timeout() {
+		return FallaTimeout
+	}
+
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, driver.ErrBadConn) {
+		return FallaConexion
+	}
+	var errOp *net.OpError
+	if errors.As(err, &errOp) && errOp.Op == "dial" {
+		return FallaConexion
+	}
+	// Algunos drivers no envuelven el error original
+	if strings.Contains(strings.ToLower(err.Error()), "connection refused") {
+		return FallaConexion
+	}
+	return ""
+}
+
+// esReintentable indica si la falla está cubierta por la política
+func (p PoliticaReintentos) esReintentable(err error) (string, bool) {
+	falla := clasificarFalla(err)
+	if falla == "" || !p.ReintentarEn[falla] {
+		return falla, false
+	}
+	if falla == FallaSOAPFault {
+		var fault *ejecutores.SOAPFault
+		errors.As(err, &fault)
+		return falla, p.CodigosSOAPFault[fault.Code]
+	}
+	return falla, true
+}
+
+// ejecutarConReintentos llama a invocar hasta que funcione, la falla no sea reintentable o se
+// agoten los intentos. registrar se llama después de cada intento fallido
+func ejecutarConReintentos(
+	ctx context.Context,
+	p PoliticaReintentos,
+	invocar func(context.Context) (string, error),
+	registrar func(intento int, falla string, err error, espera time.Duration, ultimo bool),
+) (string, error) {
+	var salida string
+	var err error
+
+	for intento := 1; ; intento++ {
+		salida, err = invocar(ctx)
+		if err == nil {
+			return salida, nil
+		}
+
+		falla, reintentable := p.esReintentable(err)
+		if !reintentable || intento >= p.MaxIntentos || ctx.Err() != nil {
+			if p.MaxIntentos > 1 {
+				registrar(intento, falla, err, 0, true)
+			}
+			return salida, err
+		}
+
+		espera := p.espera(intento)
+		registrar(intento, falla, err, espera, false)
+
+		select {
+		case <-time.After(espera):
+		case <-ctx.Done():
+			return salida, err
+		}
+	}
+}
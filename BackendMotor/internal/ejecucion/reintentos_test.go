@@ -0,0 +1,201 @@
+package ejecucion
+
+import (
+	"backendmotor/internal/ejecucion/ejecutores"
+	"backendmotor/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"syscall"
+	"testing"
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// politicaSinJitter reintenta las fallas por defecto con esperas exactas
+func politicaSinJitter(intentos int, inicial, maximo time.Duration) PoliticaReintentos {
+	p := politicaPorDefecto()
+	p.MaxIntentos = intentos
+	p.BackoffInicial = inicial
+	p.BackoffMaximo = maximo
+	p.Jitter = 0
+	return p
+}
+
+func TestEsperaCreceHastaElMaximo(t *testing.T) {
+	p := politicaSinJitter(10, 100*time.Millisecond, time.Second)
+	esperados := []time.Duration{100, 200, 400, 800, 1000, 1000}
+	for i, esperado := range esperados {
+		if obtenida := p.espera(i + 1); obtenida != esperado*time.Millisecond {
+			t.Fatalf("intento %d: se esperaba %v y se obtuvo %v", i+1, esperado*time.Millisecond, obtenida)
+		}
+	}
+}
+
+func TestEsperaConJitter(t *testing.T) {
+	p := politicaSinJitter(10, time.Second, 0)
+	p.Jitter = 0.2
+
+	// El jitter solo acorta la espera, hasta la fracción configurada
+	minima, maxima := time.Duration(1<<62), time.Duration(0)
+	for i := 0; i < 1000; i++ {
+		e := p.espera(1)
+		if e < 800*time.Millisecond || e > time.Second {
+			t.Fatalf("la espera %v quedó fuera de [800ms, 1s]", e)
+		}
+		minima, maxima = min(minima, e), max(maxima, e)
+	}
+	if minima == maxima {
+		t.Fatalf("con jitter las esperas debían variar y siempre fueron %v", minima)
+	}
+}
+
+func TestReintentaHTTP(t *testing.T) {
+	casos := []struct {
+		nombre   string
+		datos    map[string]interface{}
+		esperado bool
+	}{
+		{"sin política", nil, false},
+		{"un solo intento", map[string]interface{}{"maxIntentos": 1.0}, false},
+		{"fallas por defecto", map[string]interface{}{"maxIntentos": 3.0}, true},
+		{"solo 429", map[string]interface{}{"maxIntentos": 3.0, "reintentarEn": []interface{}{FallaHTTP429}}, true},
+		{"sin fallas HTTP", map[string]interface{}{"maxIntentos": 3.0, "reintentarEn": []interface{}{FallaTimeout, FallaConexion}}, false},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			n := nodo("p", "proceso", nil)
+			if c.datos != nil {
+				n.Data["reintentos"] = c.datos
+			}
+			if obtenido := politicaReintentos(n, models.Servidor{}).reintentaHTTP(); obtenido != c.esperado {
+				t.Fatalf("se esperaba %v y se obtuvo %v", c.esperado, obtenido)
+			}
+		})
+	}
+}
+
+func TestPoliticaReintentosHeredaDelServidor(t *testing.T) {
+	servidor := models.Servidor{Extras: datatypes.JSONMap{"reintentos": map[string]interface{}{"maxIntentos": 4.0, "backoffInicialMs": 50.0}}}
+	n := nodo("p", "proceso", map[string]interface{}{"reintentos": map[string]interface{}{"backoffInicialMs": "10", "jitter": 5.0}})
+
+	p := politicaReintentos(n, servidor)
+	if p.MaxIntentos != 4 || p.BackoffInicial != 10*time.Millisecond || p.Jitter != 1 {
+		t.Fatalf("se esperaba 4 intentos, 10ms y jitter 1 y se obtuvo %d, %v y %v", p.MaxIntentos, p.BackoffInicial, p.Jitter)
+	}
+}
+
+func TestPoliticaParaLlamadaSinReintentosEnTransaccion(t *testing.T) {
+	servidor := models.Servidor{ID: "srv-1"}
+	n := nodo("p", "proceso", map[string]interface{}{"reintentos": map[string]interface{}{"maxIntentos": 3.0}})
+	enTransaccion := ejecutores.ConTransaccion(context.Background(), servidor.ID, &sql.Tx{})
+	enOtroServidor := ejecutores.ConTransaccion(context.Background(), "srv-2", &sql.Tx{})
+
+	casos := []struct {
+		nombre    string
+		ctx       context.Context
+		simulando bool
+		esperado  int
+	}{
+		{"fuera de transacción", context.Background(), false, 3},
+		{"dentro de una transacción del servidor", enTransaccion, false, 1},
+		{"con una transacción de otro servidor", enOtroServidor, false, 3},
+		{"simulando", context.Background(), true, 1},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			if obtenido := politicaParaLlamada(c.ctx, n, servidor, c.simulando).MaxIntentos; obtenido != c.esperado {
+				t.Fatalf("se esperaban %d intentos y se obtuvieron %d", c.esperado, obtenido)
+			}
+		})
+	}
+}
+
+func TestClasificarFalla(t *testing.T) {
+	casos := []struct {
+		err      error
+		esperado string
+	}{
+		{&ejecutores.ErrorHTTP{Codigo: 503}, FallaHTTP5xx},
+		{&ejecutores.ErrorHTTP{Codigo: 429}, FallaHTTP429},
+		{&ejecutores.ErrorHTTP{Codigo: 404}, ""},
+		{fmt.Errorf("consulta: %w", context.DeadlineExceeded), FallaTimeout},
+		{fmt.Errorf("dial: %w", syscall.ECONNREFUSED), FallaConexion},
+		{errors.New("dial tcp 127.0.0.1:5432: connection refused"), FallaConexion},
+		{errors.New("violación de llave única"), ""},
+	}
+
+	for _, c := range casos {
+		if obtenida := clasificarFalla(c.err); obtenida != c.esperado {
+			t.Errorf("%v: se esperaba %q y se obtuvo %q", c.err, c.esperado, obtenida)
+		}
+	}
+}
+
+func TestEjecutarConReintentos(t *testing.T) {
+	casos := []struct {
+		nombre      string
+		fallas      []error // error de cada intento; después de la lista el intento funciona
+		intentos    int
+		esperadas   int
+		conError    bool
+		registrados []bool // ultimo de cada llamada a registrar
+	}{
+		{"funciona al primer intento", nil, 3, 1, false, nil},
+		{"se recupera al segundo", []error{errCaido}, 3, 2, false, []bool{false}},
+		{"se agotan los intentos", []error{errCaido, errCaido, errCaido}, 3, 3, true, []bool{false, false, true}},
+		{"error de negocio no se reintenta", []error{&ejecutores.ErrorHTTP{Codigo: 404}}, 3, 1, true, []bool{true}},
+		{"sin política no se registra", []error{errCaido}, 1, 1, true, nil},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			llamadas := 0
+			invocar := func(context.Context) (string, error) {
+				llamadas++
+				if llamadas <= len(c.fallas) {
+					return "", c.fallas[llamadas-1]
+				}
+				return "ok", nil
+			}
+			var registrados []bool
+			registrar := func(_ int, _ string, _ error, _ time.Duration, ultimo bool) {
+				registrados = append(registrados, ultimo)
+			}
+
+			salida, err := ejecutarConReintentos(context.Background(), politicaSinJitter(c.intentos, time.Millisecond, 0), invocar, registrar)
+			if llamadas != c.esperadas || (err != nil) != c.conError {
+				t.Fatalf("se esperaban %d llamadas (error=%v) y hubo %d (salida=%q, err=%v)", c.esperadas, c.conError, llamadas, salida, err)
+			}
+			if fmt.Sprint(registrados) != fmt.Sprint(c.registrados) {
+				t.Fatalf("se esperaban los registros %v y hubo %v", c.registrados, registrados)
+			}
+		})
+	}
+}
+
+func TestEjecutarConReintentosCancelaLaEspera(t *testing.T) {
+	ctx, cancelar := context.WithCancel(context.Background())
+	llamadas := 0
+	invocar := func(context.Context) (string, error) {
+		llamadas++
+		return "", errCaido
+	}
+	registrar := func(int, string, error, time.Duration, bool) {
+		// La cancelación llega mientras se espera el backoff de un minuto
+		time.AfterFunc(10*time.Millisecond, cancelar)
+	}
+
+	inicio := time.Now()
+	_, err := ejecutarConReintentos(ctx, politicaSinJitter(3, time.Minute, 0), invocar, registrar)
+	if transcurrido := time.Since(inicio); transcurrido > 5*time.Second {
+		t.Fatalf("la cancelación debía cortar la espera y tardó %v", transcurrido)
+	}
+	if llamadas != 1 || !errors.Is(err, errCaido) {
+		t.Fatalf("se esperaba una sola llamada con el error del intento y hubo %d (err=%v)", llamadas, err)
+	}
+}
@@ -0,0 +1,117 @@
+package ejecucion
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Simulacion define las respuestas enlatadas de una ejecución en seco: ningún nodo proceso
+// contacta a su servidor, el FullOutput sale de aquí y sigue el camino normal de parseo y ruteo
+type Simulacion struct {
+	PorNodo     map[string]RespuestaSimulada `json:"porNodo"`     // por ID de nodo proceso (solo flujo raíz)
+	PorServidor []RespuestaServidorSimulada  `json:"porServidor"` // por servidorId + objeto (también en subprocesos)
+}
+
+// RespuestaSimulada es lo que "devuelve" el servidor: un FullOutput (texto tal cual, o cualquier
+// valor JSON que se serializa) o un error que se trata como falla de ejecución del nodo
+type RespuestaSimulada struct {
+	FullOutput interface{} `json:"fullOutput"`
+	Error      string      `json:"error,omitempty"`
+}
+
+// RespuestaServidorSimulada asocia una respuesta a un servidor; con Objeto vacío aplica a todos sus objetos
+type RespuestaServidorSimulada struct {
+	ServidorID string `json:"servidorId"`
+	Objeto     string `json:"objeto"`
+	RespuestaSimulada
+}
+
+// PasoCamino es un nodo del camino recorrido que se devuelve en las ejecuciones simuladas
+type PasoCamino struct {
+	NodoID   string   `json:"nodoId"`
+	TipoNodo string   `json:"tipoNodo"`
+	Rama     string   `json:"rama,omitempty"`
+	Estado   string   `json:"estado"`
+	Destinos []string `json:"destinos,omitempty"` // nodos hacia los que se activó una conexión
+}
+
+// simulacion devuelve la simulación activa de la ejecución (nil en ejecuciones reales)
+func (c *ContextoSubproceso) simulacion() *Simulacion {
+	if c == nil || c.Opciones == nil {
+		return nil
+	}
+	return c.Opciones.Simulacion
+}
+
+// respuestaSimulada busca la respuesta para un nodo proceso. El segundo valor indica si la
+// ejecución es simulada: en ese caso nunca se llama al servidor real, haya o no respuesta
+func (c *ContextoSubproceso) respuestaSimulada(nodoID string, nodo NodoProceso) (*RespuestaSimulada, bool) {
+	sim := c.simulacion()
+	if sim == nil {
+		return nil, false
+	}
+
+	if c.Depth == 0 {
+		if r, ok := sim.PorNodo[nodoID]; ok {
+			return &r, true
+		}
+	}
+
+	var generica *RespuestaSimulada
+	for i := range sim.PorServidor {
+		r := &sim.PorServidor[i]
+		if r.ServidorID != nodo.ServidorID {
+			continue
+		}
+		if r.Objeto == nodo.Objeto {
+			return &r.RespuestaSimulada, true
+		}
+		if r.Objeto == "" && generica == nil {
+			generica = &r.RespuestaSimulada
+		}
+	}
+	return generica, true
+}
+
+// salida convierte la respuesta enlatada en el FullOutput y error que daría un ejecutor
+func (r *RespuestaSimulada) salida() (string, error) {
+	if r.Error != "" {
+		return "", errors.New(r.Error)
+	}
+	switch v := r.FullOutput.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return "", fmt.Errorf("fullOutput simulado inválido: %w", err)
+		}
+		return string(b), nil
+	}
+}
+
+// camino arma el recorrido a partir de los pasos registrados en la traza
+func (t *trazaEjecucion) camino() []PasoCamino {
+	if t == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	camino := make([]PasoCamino, 0, len(t.pasos))
+	for _, p := range t.pasos {
+		var aristas []aristaTomada
+		_ = json.Unmarshal(p.AristasTomadas, &aristas)
+
+		paso := PasoCamino{NodoID: p.NodoID, TipoNodo: p.TipoNodo, Rama: p.Rama, Estado: p.Estado}
+		for _, a := range aristas {
+			paso.Destinos = append(paso.Destinos, a.Destino)
+		}
+		camino = append(camino, paso)
+	}
+	return camino
+}
@@ -2,11 +2,22 @@ package ejecucion
 
 // ResultadoEjecucion representa la salida final de la ejecución del flujo
 type ResultadoEjecucion struct {
-	Estado    int                    `json:"estado"`
-	Mensaje   string                 `json:"mensaje"`
-	Datos     map[string]interface{} `json:"data,omitempty"`
-	ProcesoID string                 `json:"procesoId"`
-	Trigger   string                 `json:"trigger"`
+	Estado         int                     `json:"estado"`
+	Mensaje        string                  `json:"mensaje"`
+	Datos          map[string]interface{}  `json:"data,omitempty"`
+	ProcesoID      string                  `json:"procesoId"`
+	Trigger        string                  `json:"trigger"`
+	TraceID        string                  `json:"traceId,omitempty"`
+	EjecucionID    string                  `json:"ejecucionId,omitempty"`    // fila en la tabla ejecuciones
+	Camino         []PasoCamino            `json:"camino,omitempty"`         // nodos recorridos (solo en simulación)
+	Compensaciones []ResultadoCompensacion `json:"compensaciones,omitempty"` // acciones de saga al terminar en salidaError
+}
+
+// OpcionesEjecucion agrupa los modos especiales con los que se puede lanzar una ejecución;
+// viajan en el ContextoSubproceso para que los subprocesos también las vean
+type OpcionesEjecucion struct {
+	Depuracion *SesionDepuracion // pausa en breakpoints (solo en el flujo raíz)
+	Simulacion *Simulacion       // respuestas enlatadas en lugar de llamar a los servidores
 }
 
 // NodoGenerico es la representación base de un nodo en el flujo visual
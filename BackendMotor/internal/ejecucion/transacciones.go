@@ -0,0 +1,270 @@
+package ejecucion
+
+import (
+	"backendmotor/internal/ejecucion/ejecutores"
+	"backendmotor/internal/estructuras"
+	"backendmotor/internal/models"
+	"backendmotor/internal/utils"
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Un nodo iniciarTransaccion abre una transacción en un servidor de base de datos y un
+// confirmarTransaccion la confirma. Los nodos proceso de ese servidor que corren en medio usan el
+// mismo *sql.Tx. Si algún nodo sale por una conexión de error o el flujo llega a salidaError, la
+// transacción se deshace; lo que quede abierto al terminar el recorrido también se deshace.
+// Los subprocesos son otra ejecución: no ven las transacciones del flujo que los invoca. Mientras
+// haya una transacción abierta no se guardan checkpoints, así que una ejecución reanudada repite
+// la transacción desde su nodo iniciarTransaccion
+
+// CodigoErrorTransaccion es el codigoError de los nodos de transacción que fallan
+const CodigoErrorTransaccion = "TRANSACCION_ERROR"
+
+// transaccionFlujo es una transacción abierta durante la ejecución
+type transaccionFlujo struct {
+	nodoInicio string // nodo iniciarTransaccion que la abrió
+	servidorID string
+	tx         *sql.Tx // nil al simular
+	inicio     time.Time
+	uso        sync.Mutex      // una sola consulta a la vez aunque la usen ramas paralelas
+	nodos      map[string]bool // nodos proceso que escribieron en ella
+}
+
+// registroTransacciones lo comparten el recorrido principal y sus ramas / iteraciones; hay a lo
+// sumo una transacción abierta por servidor
+type registroTransacciones struct {
+	mu       sync.Mutex
+	abiertas map[string]*transaccionFlujo
+}
+
+func (r *registroTransacciones) abierta(servidorID string) *transaccionFlujo {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.abiertas[servidorID]
+}
+
+// abrir deja abierta la transacción salvo que otra rama ya haya abierto una en el mismo
+// servidor; en ese caso devuelve false y no toca el registro
+func (r *registroTransacciones) abrir(t *transaccionFlujo) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.abiertas[t.servidorID] != nil {
+		return false
+	}
+	r.abiertas[t.servidorID] = t
+	return true
+}
+
+// quitar saca la transacción del registro; devuelve nil si otra rama ya la cerró
+func (r *registroTransacciones) quitar(servidorID string) *transaccionFlujo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t := r.abiertas[servidorID]
+	delete(r.abiertas, servidorID)
+	return t
+}
+
+// hayAbiertas indica si queda alguna transacción abierta
+func (r *registroTransacciones) hayAbiertas() bool {
+	if r == nil {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.abiertas) > 0
+}
+
+// todas saca del registro todas las transacciones abiertas
+func (r *registroTransacciones) todas() []*transaccionFlujo {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	lista := make([]*transaccionFlujo, 0, len(r.abiertas))
+	for id, t := range r.abiertas {
+		lista = append(lista, t)
+		delete(r.abiertas, id)
+	}
+	return lista
+}
+
+// ejecutarIniciarTransaccion abre la transacción en el servidor de data.servidorId
+// (data.aislamiento: read_committed, repeatable_read o serializable; data.soloLectura)
+func (e *estadoFlujo) ejecutarIniciarTransaccion(n estructuras.NodoGenerico) {
+	servidorID, _ := n.Data["servidorId"].(string)
+	aislamiento, _ := n.Data["aislamiento"].(string)
+	soloLectura, _ := n.Data["soloLectura"].(bool)
+	if servidorID == "" {
+		e.fallarTransaccion(n, "servidorId no definido en el nodo", nil)
+		return
+	}
+	if e.transacciones.abierta(servidorID) != nil {
+		e.fallarTransaccion(n, fmt.Sprintf("ya hay una transacción abierta en el servidor %s", servidorID), nil)
+		return
+	}
+
+	t := &transaccionFlujo{nodoInicio: n.ID, servidorID: servidorID, inicio: time.Now(), nodos: make(map[string]bool)}
+	if e.contexto.simulacion() == nil {
+		var servidor models.Servidor
+		if e.db == nil {
+			e.fallarTransaccion(n, "Servidor no encontrado", fmt.Errorf("sin conexión a la base de configuración"))
+			return
+		}
+		if err := e.db.WithContext(e.ctx).First(&servidor, "id = ?", servidorID).Error; err != nil {
+			e.fallarTransaccion(n, "Servidor no encontrado", fmt.Errorf("servidor no encontrado: %w", err))
+			return
+		}
+		tx, err := ejecutores.IniciarTransaccion(e.ctx, servidor, aislamiento, soloLectura)
+		if err != nil {
+			e.fallarTransaccion(n, "No se pudo iniciar la transacción", err)
+			return
+		}
+		t.tx = tx
+	}
+
+	// Entre la verificación de arriba y este punto otra rama paralela pudo abrir la suya
+	if !e.transacciones.abrir(t) {
+		if t.tx != nil {
+			t.tx.Rollback()
+		}
+		e.fallarTransaccion(n, fmt.Sprintf("ya hay una transacción abierta en el servidor %s", servidorID), nil)
+		return
+	}
+	fmt.Printf("🔒 Transacción iniciada por %s en el servidor %s\n", n.ID, servidorID)
+	e.registrarTransaccion(t, "iniciada", "", nil)
+}
+
+// ejecutarConfirmarTransaccion confirma la transacción del servidor de data.servidorId o, si no
+// hay, la abierta por el nodo data.inicioId
+func (e *estadoFlujo) ejecutarConfirmarTransaccion(n estructuras.NodoGenerico) {
+	servidorID, _ := n.Data["servidorId"].(string)
+	if servidorID == "" {
+		if inicio, ok := e.grafo.nodos[fmt.Sprint(n.Data["inicioId"])]; ok {
+			servidorID, _ = inicio.Data["servidorId"].(string)
+		}
+	}
+	t := e.transacciones.quitar(servidorID)
+	if t == nil {
+		e.fallarTransaccion(n, fmt.Sprintf("no hay una transacción abierta en el servidor '%s' (no se inició o ya se deshizo)", servidorID), nil)
+		return
+	}
+
+	t.uso.Lock()
+	defer t.uso.Unlock()
+	if t.tx != nil {
+		if err := t.tx.Commit(); err != nil {
+			e.descartarCompensaciones(t)
+			e.fallarTransaccion(n, "No se pudo confirmar la transacción", err)
+			e.registrarTransaccion(t, "error", "", err)
+			return
+		}
+	}
+	fmt.Printf("✅ Transacción de %s confirmada por %s\n", t.nodoInicio, n.ID)
+	e.registrarTransaccion(t, "confirmada", "", nil)
+}
+
+// deshacerTransacciones hace rollback de todo lo abierto; sus nodos ya no se compensan
+func (e *estadoFlujo) deshacerTransacciones(motivo string) {
+	for _, t := range e.transacciones.todas() {
+		t.uso.Lock()
+		var err error
+		if t.tx != nil {
+			err = t.tx.Rollback()
+			if err == sql.ErrTxDone {
+				// El contexto de la ejecución se canceló y database/sql ya la deshizo
+				err = nil
+			}
+		}
+		t.uso.Unlock()
+		e.descartarCompensaciones(t)
+		fmt.Printf("↩️ Transacción de %s deshecha: %s\n", t.nodoInicio, motivo)
+		if err != nil {
+			fmt.Printf("⚠️ Error deshaciendo la transacción de %s: %v\n", t.nodoInicio, err)
+		}
+		e.registrarTransaccion(t, "deshecha", motivo, err)
+	}
+}
+
+// contextoTransaccion devuelve el contexto con el que debe correr un nodo proceso del servidor
+// y, si hay una transacción abierta, la deja tomada hasta que se llame a liberar
+func (e *estadoFlujo) contextoTransaccion(n estructuras.NodoGenerico, servidorID string) (ctx context.Context, enTransaccion bool, liberar func()) {
+	t := e.transacciones.abierta(servidorID)
+	if t == nil {
+		return e.ctx, false, func() {}
+	}
+	t.uso.Lock()
+	t.nodos[n.ID] = true
+	if t.tx == nil {
+		return e.ctx, true, t.uso.Unlock
+	}
+	return ejecutores.ConTransaccion(e.ctx, servidorID, t.tx), true, t.uso.Unlock
+}
+
+// descartarCompensaciones quita las compensaciones de los nodos que escribieron dentro de la
+// transacción: al deshacerla ya no queda nada que compensar
+func (e *estadoFlujo) descartarCompensaciones(t *transaccionFlujo) {
+	if e.compensaciones == nil {
+		return
+	}
+	e.compensaciones.mu.Lock()
+	defer e.compensaciones.mu.Unlock()
+	vigentes := e.compensaciones.pendientes[:0]
+	for _, p := range e.compensaciones.pendientes {
+		if !t.nodos[p.nodo] {
+			vigentes = append(vigentes, p)
+		}
+	}
+	e.compensaciones.pendientes = vigentes
+}
+
+// saleConError indica si el nodo terminó enrutando hacia una conexión de error o un salidaError
+func (e *estadoFlujo) saleConError(n estructuras.NodoGenerico, tomadas []aristaFlujo) bool {
+	if n.Type == "salidaError" {
+		return true
+	}
+	for _, a := range tomadas {
+		if a.Type == "error" || e.grafo.nodos[a.Target].Type == "salidaError" {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *estadoFlujo) fallarTransaccion(n estructuras.NodoGenerico, mensaje string, err error) {
+	e.erroresPorNodo[n.ID] = true
+	e.resultado["codigoError"] = CodigoErrorTransaccion
+	e.resultado["mensajeError"] = mensaje
+	e.resultado["detalleError"] = mensaje
+	if err != nil {
+		e.resultado["detalleError"] = err.Error()
+	}
+	fmt.Printf("❌ Nodo %s %s: %s\n", n.Type, n.ID, e.resultado["detalleError"])
+}
+
+func (e *estadoFlujo) registrarTransaccion(t *transaccionFlujo, estado string, motivo string, err error) {
+	registro := utils.RegistroEjecucion{
+		Timestamp:     time.Now().Format(time.RFC3339),
+		ProcesoId:     e.proc.ID,
+		NombreProceso: e.proc.Nombre,
+		Canal:         e.canalCodigo,
+		TipoObjeto:    "transaccion",
+		NombreObjeto:  t.nodoInicio,
+		TraceID:       e.contexto.TraceID,
+		Parametros:    map[string]interface{}{"servidorId": t.servidorID, "nodos": len(t.nodos)},
+		Resultado:     map[string]interface{}{"estado": estado, "motivo": motivo},
+		Estado:        "exito",
+		DuracionMs:    time.Since(t.inicio).Milliseconds(),
+	}
+	if err != nil {
+		registro.Estado = "error"
+		registro.DetalleError = err.Error()
+	}
+	utils.RegistrarEjecucionLog(registro)
+}
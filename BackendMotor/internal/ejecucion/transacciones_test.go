@@ -0,0 +1,116 @@
+//go:build cgo
+
+package ejecucion
+
+import (
+	"backendmotor/internal/ejecucion/ejecutores"
+	"backendmotor/internal/estructuras"
+	"backendmotor/internal/models"
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+// baseDePagos crea un archivo SQLite con la tabla pagos y lo da de alta como servidor sqlite;
+// devuelve una conexión directa al archivo para revisar lo que quedó confirmado
+func baseDePagos(t *testing.T) (models.Servidor, *sql.DB) {
+	t.Helper()
+	archivo := filepath.Join(t.TempDir(), "pagos.db")
+	directa, err := sql.Open("sqlite3", archivo)
+	if err != nil {
+		t.Fatalf("no se pudo abrir SQLite: %v", err)
+	}
+	t.Cleanup(func() { directa.Close() })
+	if _, err := directa.Exec("CREATE TABLE pagos (id INTEGER PRIMARY KEY)"); err != nil {
+		t.Fatalf("no se pudo crear la tabla: %v", err)
+	}
+
+	servidor := models.Servidor{ID: "base-" + t.Name(), Tipo: "sqlite", Host: archivo}
+	t.Cleanup(func() { ejecutores.CerrarPool(servidor.ID) })
+	return servidor, directa
+}
+
+func consultaEn(id, servidorID, consulta string) estructuras.NodoGenerico {
+	return nodo(id, "proceso", map[string]interface{}{"servidorId": servidorID, "tipoObjeto": "consulta", "consulta": consulta})
+}
+
+func TestTransaccionSeDeshaceAlSalirPorError(t *testing.T) {
+	casos := []struct {
+		nombre  string
+		nodos   func(servidorID string) []estructuras.NodoGenerico
+		aristas []string
+		pagos   int
+	}{
+		{
+			// f falla: solo se toma su conexión de error y c nunca confirma
+			nombre: "conexión de error",
+			nodos: func(servidorID string) []estructuras.NodoGenerico {
+				return []estructuras.NodoGenerico{
+					consultaEn("f", servidorID, "INSERT INTO no_existe VALUES (1)"),
+					nodo("c", "confirmarTransaccion", map[string]interface{}{"servidorId": servidorID}),
+					nodo("h", "salida", nil),
+				}
+			},
+			aristas: []string{"w>f", "f>c", "f>h!"},
+		},
+		{
+			nombre: "salidaError",
+			nodos: func(string) []estructuras.NodoGenerico {
+				return []estructuras.NodoGenerico{nodo("x", "salidaError", nil)}
+			},
+			aristas: []string{"w>x"},
+		},
+		{
+			nombre: "confirmada",
+			nodos: func(servidorID string) []estructuras.NodoGenerico {
+				return []estructuras.NodoGenerico{
+					nodo("c", "confirmarTransaccion", map[string]interface{}{"servidorId": servidorID}),
+					nodo("s", "salida", nil),
+				}
+			},
+			aristas: []string{"w>c", "c>s"},
+			pagos:   1,
+		},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			servidor, directa := baseDePagos(t)
+
+			// e → ini → w; w escribe dentro de la transacción y sabe compensarse
+			escribe := consultaEn("w", servidor.ID, "INSERT INTO pagos (id) VALUES (1)")
+			escribe.Data["compensacion"] = map[string]interface{}{"servidorId": servidor.ID, "tipoObjeto": "consulta", "consulta": "DELETE FROM pagos"}
+			nodos := append([]estructuras.NodoGenerico{
+				nodo("e", "entrada", nil),
+				nodo("ini", "iniciarTransaccion", map[string]interface{}{"servidorId": servidor.ID}),
+				escribe,
+			}, c.nodos(servidor.ID)...)
+
+			e := estadoDePrueba(t, nodos, append([]string{"e>ini", "ini>w"}, c.aristas...), nil)
+			e.db = servidoresDePrueba(t, servidor)
+			if err := e.recorrerDesde("e"); err != nil {
+				t.Fatalf("error inesperado: %v", err)
+			}
+			if e.erroresPorNodo["ini"] || e.erroresPorNodo["w"] {
+				t.Fatalf("la transacción debía abrirse y w escribir en ella: %v", e.resultado["detalleError"])
+			}
+
+			// El recorrido ya cerró la transacción, sin esperar al final de la ejecución
+			if e.transacciones.hayAbiertas() {
+				t.Fatalf("no debía quedar ninguna transacción abierta")
+			}
+			var pagos int
+			if err := directa.QueryRow("SELECT COUNT(*) FROM pagos").Scan(&pagos); err != nil {
+				t.Fatalf("no se pudo contar los pagos: %v", err)
+			}
+			if pagos != c.pagos {
+				t.Fatalf("se esperaban %d pagos confirmados y hay %d", c.pagos, pagos)
+			}
+
+			// Lo deshecho con la transacción ya no se compensa; lo confirmado sí
+			if pendientes := len(e.compensaciones.guardadas()); pendientes != c.pagos {
+				t.Fatalf("se esperaban %d compensaciones pendientes y hay %d", c.pagos, pendientes)
+			}
+		})
+	}
+}
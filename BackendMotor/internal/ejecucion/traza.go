@@ -0,0 +1,275 @@
+package ejecucion
+
+import (
+	"backendmotor/internal/database"
+	"backendmotor/internal/estructuras"
+	"backendmotor/internal/models"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// Estados con los que queda una ejecución en la tabla ejecuciones
+const (
+	EstadoEjecucionEjecutando = "ejecutando"
+	EstadoEjecucionExitoso    = "exitoso"
+	EstadoEjecucionError      = "error"
+	EstadoEjecucionIncompleto = "incompleto"
+	EstadoEjecucionAbandonada = "abandonada" // el motor se reinició y la política del proceso no la reanuda
+)
+
+// trazaEjecucion acumula los pasos de una ejecución y los persiste en ejecuciones / ejecucion_pasos
+// a través de escritorTrazas. Las ramas paralelas comparten la misma traza, por eso el registro de
+// pasos va con mutex
+type trazaEjecucion struct {
+	mu        sync.Mutex
+	registro  models.Ejecucion
+	pasos     []models.EjecucionPaso
+	secuencia int
+	guardados int // pasos ya escritos en la base (las ejecuciones durables guardan en cada checkpoint)
+}
+
+// aristaTomada es lo que se guarda de cada conexión activada por un nodo
+type aristaTomada struct {
+	ID      string `json:"id"`
+	Destino string `json:"destino"`
+	Handle  string `json:"handle,omitempty"`
+}
+
+// iniciarTraza crea la fila de la ejecución en estado "ejecutando"; si la base no responde
+// la ejecución sigue igual y solo se pierde la traza
+func iniciarTraza(ctx context.Context, proc models.Proceso, contexto *ContextoSubproceso, input map[string]interface{}, canalCodigo, trigger string) *trazaEjecucion {
+	if contexto.durable != nil && contexto.durable.checkpoint != nil {
+		return reanudarTraza(ctx, contexto)
+	}
+
+	// Las ejecuciones asíncronas ya traen el ID que se devolvió al encolarlas
+	id := contexto.EjecucionID
+	if id == "" {
+		id = uuid.New().String()
+	}
+
+	t := &trazaEjecucion{
+		registro: models.Ejecucion{
+			ID:            id,
+			ProcesoID:     proc.ID,
+			NombreProceso: proc.Nombre,
+			Canal:         canalCodigo,
+			Trigger:       trigger,
+			TraceID:       contexto.TraceID,
+			Profundidad:   contexto.Depth,
+			Propietario:   instanciaMotor,
+			Estado:        EstadoEjecucionEjecutando,
+			Entrada:       instantaneaTraza(input),
+			FechaInicio:   time.Now(),
+		},
+	}
+	if contexto.ParentEjecucionID != "" {
+		padre := contexto.ParentEjecucionID
+		t.registro.EjecucionPadreID = &padre
+	}
+
+	if database.DBGORM == nil {
+		return t
+	}
+	iniciarRetencionTraza()
+
+	// Una ejecución durable que se repite desde el principio ya puede tener su fila
+	db := database.DBGORM.WithContext(context.WithoutCancel(ctx))
+	guardar := db.Create
+	if contexto.durable != nil {
+		guardar = db.Save
+	}
+	registro := t.registro
+	escritorTrazas.encolar(func() {
+		if err := guardar(&registro).Error; err != nil {
+			fmt.Printf("⚠️ No se pudo registrar la ejecución %s: %v\n", registro.ID, err)
+		}
+	})
+	return t
+}
+
+// reanudarTraza retoma la fila de una ejecución interrumpida; los pasos hasta el checkpoint ya
+// están guardados, así que la secuencia sigue desde ahí
+func reanudarTraza(ctx context.Context, contexto *ContextoSubproceso) *trazaEjecucion {
+	t := &trazaEjecucion{
+		registro:  models.Ejecucion{ID: contexto.EjecucionID, FechaInicio: time.Now()},
+		secuencia: contexto.durable.checkpoint.Secuencia,
+	}
+	if database.DBGORM == nil {
+		return t
+	}
+
+	db := database.DBGORM.WithContext(context.WithoutCancel(ctx))
+	if err := db.First(&t.registro, "id = ?", contexto.EjecucionID).Error; err != nil {
+		fmt.Printf("⚠️ No se encontró la ejecución %s a reanudar: %v\n", contexto.EjecucionID, err)
+		return t
+	}
+	db.Model(&models.Ejecucion{}).Where("id = ?", t.registro.ID).Updates(map[string]interface{}{"estado": EstadoEjecucionEjecutando, "propietario": instanciaMotor})
+	return t
+}
+
+// registrarPaso agrega un nodo ejecutado a la traza. El paso guarda solo lo que el nodo cambió:
+// en la entrada el valor previo de cada variable escrita y en la salida el nuevo
+func (t *trazaEjecucion) registrarPaso(n estructuras.NodoGenerico, rama string, antes, despues map[string]interface{}, escritas []string, tomadas []aristaFlujo, conError bool, inicio time.Time) {
+	if t == nil {
+		return
+	}
+
+	entrada := make(map[string]interface{}, len(escritas))
+	salida := make(map[string]interface{}, len(escritas))
+	for _, k := range escritas {
+		if v, ok := antes[k]; ok {
+			entrada[k] = v
+		}
+		salida[k] = despues[k]
+	}
+
+	aristas := make([]aristaTomada, 0, len(tomadas))
+	for _, a := range tomadas {
+		aristas = append(aristas, aristaTomada{ID: a.ID, Destino: a.Target, Handle: a.SourceHandle})
+	}
+	aristasJSON, _ := json.Marshal(aristas)
+
+	paso := models.EjecucionPaso{
+		ID:             uuid.New().String(),
+		EjecucionID:    t.registro.ID,
+		NodoID:         n.ID,
+		TipoNodo:       n.Type,
+		Rama:           rama,
+		Entrada:        instantaneaTraza(entrada),
+		Salida:         instantaneaTraza(salida),
+		AristasTomadas: datatypes.JSON(aristasJSON),
+		Estado:         EstadoEjecucionExitoso,
+		FechaInicio:    inicio,
+		DuracionMs:     time.Since(inicio).Milliseconds(),
+	}
+	if conError {
+		paso.Estado = EstadoEjecucionError
+		paso.DetalleError = detalleErrorPaso(despues)
+	}
+
+	t.mu.Lock()
+	t.secuencia++
+	paso.Secuencia = t.secuencia
+	t.pasos = append(t.pasos, paso)
+	t.mu.Unlock()
+}
+
+// finalizar cierra la ejecución con su resultado y guarda todos los pasos
+func (t *trazaEjecucion) finalizar(ctx context.Context, res ResultadoEjecucion, err error, terminoEnError bool) {
+	if t == nil {
+		return
+	}
+
+	fin := time.Now()
+	t.registro.FechaFin = &fin
+	t.registro.DuracionMs = fin.Sub(t.registro.FechaInicio).Milliseconds()
+	t.registro.CodigoResultado = res.Estado
+	t.registro.Mensaje = res.Mensaje
+	t.registro.Salida = instantaneaTraza(res.Datos)
+
+	switch {
+	case err != nil:
+		t.registro.Estado = EstadoEjecucionError
+		t.registro.CodigoResultado = 99
+		t.registro.DetalleError = err.Error()
+	case res.Estado == 98:
+		t.registro.Estado = EstadoEjecucionIncompleto
+	case res.Estado != 0 || terminoEnError:
+		t.registro.Estado = EstadoEjecucionError
+		t.registro.DetalleError = detalleErrorPaso(res.Datos)
+	default:
+		t.registro.Estado = EstadoEjecucionExitoso
+	}
+
+	if database.DBGORM == nil {
+		return
+	}
+
+	// La traza se guarda aunque el request ya haya sido cancelado
+	db := database.DBGORM.WithContext(context.WithoutCancel(ctx))
+
+	t.guardarPasos(db)
+	id := t.registro.ID
+	cierre := map[string]interface{}{
+		"estado":           t.registro.Estado,
+		"codigo_resultado": t.registro.CodigoResultado,
+		"mensaje":          t.registro.Mensaje,
+		"salida":           t.registro.Salida,
+		"detalle_error":    t.registro.DetalleError,
+		"fecha_fin":        t.registro.FechaFin,
+		"duracion_ms":      t.registro.DuracionMs,
+	}
+	escritorTrazas.encolar(func() {
+		if errEjec := db.Model(&models.Ejecucion{}).Where("id = ?", id).Updates(cierre).Error; errEjec != nil {
+			fmt.Printf("⚠️ No se pudo cerrar la ejecución %s: %v\n", id, errEjec)
+		}
+	})
+}
+
+// guardarPasos encola la escritura de los pasos que todavía no están en la base
+func (t *trazaEjecucion) guardarPasos(db *gorm.DB) {
+	t.mu.Lock()
+	pasos := append([]models.EjecucionPaso(nil), t.pasos[t.guardados:]...)
+	t.guardados = len(t.pasos)
+	t.mu.Unlock()
+
+	if len(pasos) == 0 {
+		return
+	}
+	id := t.registro.ID
+	escritorTrazas.encolar(func() {
+		if errPasos := db.CreateInBatches(pasos, 100).Error; errPasos != nil {
+			fmt.Printf("⚠️ No se pudieron guardar los pasos de la ejecución %s: %v\n", id, errPasos)
+		}
+	})
+}
+
+// id devuelve el ID de la ejecución (vacío si no hay traza)
+func (t *trazaEjecucion) id() string {
+	if t == nil {
+		return ""
+	}
+	return t.registro.ID
+}
+
+// instantanea copia las variables en un formato serializable; lo que no se puede
+// convertir a JSON se guarda como texto para no perder el paso completo
+func instantanea(valores map[string]interface{}) datatypes.JSONMap {
+	if valores == nil {
+		return nil
+	}
+	copia := make(datatypes.JSONMap, len(valores))
+	for k, v := range valores {
+		if _, err := json.Marshal(v); err != nil {
+			copia[k] = fmt.Sprintf("%v", v)
+			continue
+		}
+		copia[k] = copiarValor(v)
+	}
+	return copia
+}
+
+// detalleErrorPaso arma el detalle del error a partir de las variables estándar de error
+func detalleErrorPaso(valores map[string]interface{}) string {
+	if valores == nil {
+		return ""
+	}
+	detalle := ""
+	for _, k := range []string{"codigoError", "mensajeError", "detalleError"} {
+		if v, ok := valores[k]; ok && v != nil && fmt.Sprint(v) != "" {
+			if detalle != "" {
+				detalle += " | "
+			}
+			detalle += fmt.Sprintf("%s=%v", k, v)
+		}
+	}
+	return detalle
+}
@@ -0,0 +1,689 @@
+package ejecucion
+
+import (
+	"backendmotor/internal/database"
+	"backendmotor/internal/ejecucion/ejecutores"
+	"backendmotor/internal/estructuras"
+	"backendmotor/internal/models"
+	"backendmotor/internal/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Severidades de los problemas que encuentra el validador de flujos
+const (
+	SeveridadError       = "error"       // el flujo fallaría o no terminaría al ejecutarse
+	SeveridadAdvertencia = "advertencia" // probablemente es un descuido, pero el flujo puede correr
+)
+
+// Códigos de los problemas que reporta ValidarProceso
+const (
+	ProblemaFlujoInvalido           = "FLUJO_INVALIDO"
+	ProblemaFlujoVacio              = "FLUJO_VACIO"
+	ProblemaEntradaFaltante         = "ENTRADA_FALTANTE"
+	ProblemaEntradaDuplicada        = "ENTRADA_DUPLICADA"
+	ProblemaNodoDuplicado           = "NODO_DUPLICADO"
+	ProblemaNodoInalcanzable        = "NODO_INALCANZABLE"
+	ProblemaConexionSinNodo         = "CONEXION_NODO_DESCONOCIDO"
+	ProblemaCicloFlujo              = "CICLO_FLUJO"
+	ProblemaServidorNoDefinido      = "SERVIDOR_NO_DEFINIDO"
+	ProblemaServidorInexistente     = "SERVIDOR_INEXISTENTE"
+	ProblemaConsultaVacia           = "CONSULTA_VACIA"
+	ProblemaTransaccionInvalida     = "TRANSACCION_INVALIDA"
+	ProblemaTransaccionSinConfirmar = "TRANSACCION_SIN_CONFIRMAR"
+	ProblemaCondicionIncompleta     = "CONDICION_INCOMPLETA"
+	ProblemaExpresionInvalida       = "EXPRESION_INVALIDA"
+	ProblemaSwitchInvalido          = "SWITCH_INVALIDO"
+	ProblemaSwitchSinDefault        = "SWITCH_SIN_DEFAULT"
+	ProblemaTransformarSinPlantilla = "TRANSFORMAR_SIN_PLANTILLA"
+	ProblemaSubprocesoNoDefinido    = "SUBPROCESO_NO_DEFINIDO"
+	ProblemaSubprocesoInexistente   = "SUBPROCESO_INEXISTENTE"
+	ProblemaSubprocesoCiclo         = "SUBPROCESO_CICLO"
+	ProblemaVariableNoProducida     = "VARIABLE_NO_PRODUCIDA"
+)
+
+// problemasEstructurales impiden compilar el flujo: el motor no podría ni empezar a recorrerlo.
+// Solo estos errores impiden guardar; los de configuración de un nodo se informan, pero no
+// bloquean el guardado de un flujo a medio armar o de uno que ya estaba guardado así
+var problemasEstructurales = map[string]bool{
+	ProblemaFlujoInvalido:   true,
+	ProblemaEntradaFaltante: true,
+	ProblemaNodoDuplicado:   true,
+	ProblemaConexionSinNodo: true,
+	ProblemaCicloFlujo:      true,
+}
+
+// variablesEstandar existen siempre en el resultado (errores de nodos y globales de subprocesos)
+var variablesEstandar = []string{"codigoError", "mensajeError", "detalleError", "Usuario", "Fecha"}
+
+// ProblemaFlujo es un hallazgo del validador sobre un nodo o una conexión del flujo
+type ProblemaFlujo struct {
+	Severidad string `json:"severidad"`
+	Codigo    string `json:"codigo"`
+	NodoID    string `json:"nodoId,omitempty"`
+	AristaID  string `json:"aristaId,omitempty"`
+	Mensaje   string `json:"mensaje"`
+}
+
+// ResultadoValidacion es la respuesta de POST /procesos/validar; Valido es false si hay algún
+// error y Guardable, solo si alguno es estructural
+type ResultadoValidacion struct {
+	Valido       bool            `json:"valido"`
+	Guardable    bool            `json:"guardable"`
+	Errores      int             `json:"errores"`
+	Advertencias int             `json:"advertencias"`
+	Problemas    []ProblemaFlujo `json:"problemas"`
+}
+
+// catalogoValidacion consulta en la base los servidores y procesos que referencia el flujo.
+// Sin base (o si la consulta falla) esas verificaciones se omiten: nunca se reporta un
+// problema que no se pudo comprobar
+type catalogoValidacion struct {
+	ctx        context.Context
+	db         *gorm.DB
+	servidores map[string]bool
+	flujos     map[string]*estructuras.Flujo // nil = el proceso no existe
+	sinCiclo   map[string]bool               // procesos ya recorridos completos sin encontrar ciclos
+}
+
+// ValidarProceso revisa el flujo de un proceso antes de guardarlo o ejecutarlo y devuelve
+// todos los problemas encontrados, ordenados por severidad
+func ValidarProceso(ctx context.Context, proc models.Proceso) ResultadoValidacion {
+	cat := &catalogoValidacion{
+		ctx:        ctx,
+		db:         database.DBGORM,
+		servidores: make(map[string]bool),
+		flujos:     make(map[string]*estructuras.Flujo),
+		sinCiclo:   make(map[string]bool),
+	}
+	return validarProceso(proc, cat)
+}
+
+func validarProceso(proc models.Proceso, cat *catalogoValidacion) ResultadoValidacion {
+	v := &validadorFlujo{cat: cat, procesoID: proc.ID}
+
+	// 🧠 Paso 1: Parsear el flujo
+	if strings.TrimSpace(proc.Flujo) == "" {
+		v.agregar(SeveridadAdvertencia, ProblemaFlujoVacio, "", "", "El proceso no tiene flujo definido")
+		return v.resultado()
+	}
+	var flujo estructuras.Flujo
+	if err := json.Unmarshal([]byte(proc.Flujo), &flujo); err != nil {
+		v.agregar(SeveridadError, ProblemaFlujoInvalido, "", "", fmt.Sprintf("El flujo no es un JSON válido: %v", err))
+		return v.resultado()
+	}
+	v.flujo = flujo
+	grafo, errCiclo := construirGrafoFlujo(flujo.Nodes, flujo.Edges)
+	v.grafo = grafo
+
+	// 🔍 Paso 2: Estructura del grafo
+	entrada := v.validarNodos()
+	v.validarConexiones()
+	if errCiclo != nil {
+		v.agregar(SeveridadError, ProblemaCicloFlujo, "", "", fmt.Sprintf("El flujo no se puede ejecutar: %v", errCiclo))
+	}
+	if entrada != "" {
+		v.validarAlcance(entrada)
+	}
+
+	// 🔌 Paso 3: Configuración de cada nodo
+	for _, n := range flujo.Nodes {
+		switch n.Type {
+		case "proceso":
+			v.validarServidor(n, n.ID)
+			if esNodoConsulta(n) && ejecutores.ConsultaDelNodo(n) == "" {
+				v.agregar(SeveridadError, ProblemaConsultaVacia, n.ID, "", fmt.Sprintf("El nodo proceso %s es de tipo consulta y no tiene SQL", n.ID))
+			}
+			if accion, ok := nodoCompensacion(n); ok {
+				if accion.Type == "proceso" {
+					v.validarServidor(accion, n.ID)
+				} else {
+					v.validarSubproceso(accion, n.ID)
+				}
+			}
+		case "condicion":
+			v.validarCondicion(n)
+		case "switch":
+			v.validarSwitch(n)
+		case "subproceso":
+			v.validarSubproceso(n, n.ID)
+		case "iniciarTransaccion":
+			v.validarServidor(n, n.ID)
+		case "transformar":
+			if n.Data["plantilla"] == nil {
+				v.agregar(SeveridadError, ProblemaTransformarSinPlantilla, n.ID, "", fmt.Sprintf("El nodo transformar %s no tiene plantilla", n.ID))
+			}
+		}
+	}
+
+	v.validarTransacciones()
+
+	// 📋 Paso 4: Variables leídas por las asignaciones
+	v.validarVariables()
+
+	return v.resultado()
+}
+
+// validadorFlujo acumula los problemas de una validación
+type validadorFlujo struct {
+	cat       *catalogoValidacion
+	procesoID string
+	flujo     estructuras.Flujo
+	grafo     *grafoFlujo
+	problemas []ProblemaFlujo
+}
+
+func (v *validadorFlujo) agregar(severidad, codigo, nodoID, aristaID, mensaje string) {
+	v.problemas = append(v.problemas, ProblemaFlujo{Severidad: severidad, Codigo: codigo, NodoID: nodoID, AristaID: aristaID, Mensaje: mensaje})
+}
+
+func (v *validadorFlujo) resultado() ResultadoValidacion {
+	res := ResultadoValidacion{Problemas: v.problemas}
+	if res.Problemas == nil {
+		res.Problemas = []ProblemaFlujo{}
+	}
+	// Primero los errores; dentro de cada severidad se respeta el orden en que se encontraron
+	sort.SliceStable(res.Problemas, func(i, j int) bool {
+		return res.Problemas[i].Severidad == SeveridadError && res.Problemas[j].Severidad != SeveridadError
+	})
+	res.Guardable = true
+	for _, p := range res.Problemas {
+		if p.Severidad == SeveridadError {
+			res.Errores++
+			if problemasEstructurales[p.Codigo] {
+				res.Guardable = false
+			}
+		} else {
+			res.Advertencias++
+		}
+	}
+	res.Valido = res.Errores == 0
+	return res
+}
+
+// validarNodos revisa IDs duplicados y que haya exactamente un nodo entrada; devuelve su ID
+func (v *validadorFlujo) validarNodos() string {
+	vistos := make(map[string]bool)
+	entrada := ""
+	for _, n := range v.flujo.Nodes {
+		if vistos[n.ID] {
+			v.agregar(SeveridadError, ProblemaNodoDuplicado, n.ID, "", fmt.Sprintf("Hay más de un nodo con el ID %s", n.ID))
+		}
+		vistos[n.ID] = true
+
+		if n.Type != "entrada" {
+			continue
+		}
+		if entrada == "" {
+			entrada = n.ID
+		} else {
+			v.agregar(SeveridadError, ProblemaEntradaDuplicada, n.ID, "", fmt.Sprintf("El flujo ya tiene el nodo entrada %s; solo se ejecuta el primero", entrada))
+		}
+	}
+	if entrada == "" {
+		v.agregar(SeveridadError, ProblemaEntradaFaltante, "", "", "El flujo no tiene nodo entrada")
+	}
+	return entrada
+}
+
+// validarConexiones reporta las conexiones cuyo origen o destino no es un nodo del flujo
+func (v *validadorFlujo) validarConexiones() {
+	for _, a := range v.grafo.aristas {
+		for _, extremo := range []struct{ rol, id string }{{"origen", a.Source}, {"destino", a.Target}} {
+			if _, ok := v.grafo.nodos[extremo.id]; !ok {
+				v.agregar(SeveridadError, ProblemaConexionSinNodo, "", a.ID, fmt.Sprintf("La conexión %s tiene como %s el nodo desconocido '%s'", a.ID, extremo.rol, extremo.id))
+			}
+		}
+	}
+}
+
+// validarAlcance reporta los nodos a los que no llega ningún camino desde la entrada
+func (v *validadorFlujo) validarAlcance(entrada string) {
+	alcanzables := v.grafo.alcanzablesDesde(entrada, "")
+	for _, n := range v.flujo.Nodes {
+		if !alcanzables[n.ID] {
+			v.agregar(SeveridadAdvertencia, ProblemaNodoInalcanzable, n.ID, "", fmt.Sprintf("El nodo %s (%s) no se alcanza desde la entrada y nunca se ejecuta", n.ID, n.Type))
+		}
+	}
+}
+
+// validarServidor revisa el servidorId de un nodo proceso (o de su compensación, reportada en nodoID)
+func (v *validadorFlujo) validarServidor(n estructuras.NodoGenerico, nodoID string) {
+	que := "El nodo"
+	if n.ID != nodoID {
+		que = "La compensación del nodo"
+	}
+	servidorID, _ := n.Data["servidorId"].(string)
+	if servidorID == "" {
+		v.agregar(SeveridadError, ProblemaServidorNoDefinido, nodoID, "", fmt.Sprintf("%s %s %s no tiene servidorId", que, n.Type, nodoID))
+		return
+	}
+	if existe, comprobado := v.cat.existeServidor(servidorID); comprobado && !existe {
+		v.agregar(SeveridadError, ProblemaServidorInexistente, nodoID, "", fmt.Sprintf("%s %s %s usa el servidor '%s', que no existe", que, n.Type, nodoID, servidorID))
+	}
+}
+
+// validarTransacciones revisa que cada confirmarTransaccion sepa qué servidor confirma y que
+// cada iniciarTransaccion tenga su confirmación (si no, la transacción siempre se deshace)
+func (v *validadorFlujo) validarTransacciones() {
+	confirmados := make(map[string]bool)
+	for _, n := range v.flujo.Nodes {
+		if n.Type != "confirmarTransaccion" {
+			continue
+		}
+		servidorID, _ := n.Data["servidorId"].(string)
+		if servidorID == "" {
+			inicioID, _ := n.Data["inicioId"].(string)
+			inicio, ok := v.grafo.nodos[inicioID]
+			if !ok || inicio.Type != "iniciarTransaccion" {
+				v.agregar(SeveridadError, ProblemaTransaccionInvalida, n.ID, "", fmt.Sprintf("El nodo confirmarTransaccion %s no tiene servidorId ni un inicioId válido", n.ID))
+				continue
+			}
+			servidorID, _ = inicio.Data["servidorId"].(string)
+		}
+		confirmados[servidorID] = true
+	}
+
+	for _, n := range v.flujo.Nodes {
+		servidorID, _ := n.Data["servidorId"].(string)
+		if n.Type == "iniciarTransaccion" && servidorID != "" && !confirmados[servidorID] {
+			v.agregar(SeveridadAdvertencia, ProblemaTransaccionSinConfirmar, n.ID, "", fmt.Sprintf("La transacción que abre %s en el servidor '%s' no se confirma en ningún nodo", n.ID, servidorID))
+		}
+	}
+}
+
+// validarCondicion exige una salida por cada resultado posible de la condición
+func (v *validadorFlujo) validarCondicion(n estructuras.NodoGenerico) {
+	if condicion, ok := n.Data["condicion"].(string); ok {
+		v.validarSintaxis(n, condicion)
+	} else {
+		v.agregar(SeveridadError, ProblemaExpresionInvalida, n.ID, "", fmt.Sprintf("La condición %s no tiene expresión", n.ID))
+	}
+
+	handles := make(map[string]bool)
+	for _, a := range v.grafo.salientes[n.ID] {
+		handles[a.SourceHandle] = true
+	}
+	for _, h := range []string{"true", "false"} {
+		if !handles[h] {
+			v.agregar(SeveridadError, ProblemaCondicionIncompleta, n.ID, "", fmt.Sprintf("La condición %s no tiene conexión para el resultado '%s'", n.ID, h))
+		}
+	}
+}
+
+// validarSintaxis parsea la expresión del nodo; el mensaje lleva la línea y columna del error.
+// Si la expresión ya estaba así en el flujo guardado solo se avisa: un proceso que se guardó con
+// la sintaxis anterior se puede seguir editando
+func (v *validadorFlujo) validarSintaxis(n estructuras.NodoGenerico, expresion string) {
+	resultado := utils.ValidarExpresion(expresion, nil)
+	if resultado.Error == nil {
+		return
+	}
+	severidad := SeveridadError
+	if v.expresionGuardada(n.ID, expresion) {
+		severidad = SeveridadAdvertencia
+	}
+	v.agregar(severidad, ProblemaExpresionInvalida, n.ID, "", fmt.Sprintf("La expresión del nodo %s no es válida: %s", n.ID, resultado.Error.Error()))
+}
+
+// expresionGuardada indica si el nodo ya tenía esa condición o expresión en la versión guardada
+// del proceso
+func (v *validadorFlujo) expresionGuardada(nodoID, expresion string) bool {
+	if v.procesoID == "" {
+		return false
+	}
+	guardado, _ := v.cat.flujoProceso(v.procesoID)
+	if guardado == nil {
+		return false
+	}
+	for _, n := range guardado.Nodes {
+		if n.ID != nodoID {
+			continue
+		}
+		for _, clave := range []string{"condicion", "expresion"} {
+			if texto, ok := n.Data[clave].(string); ok && texto == expresion {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// validarSwitch revisa la expresión y los casos; sin salida default un valor no previsto
+// termina el nodo en error
+func (v *validadorFlujo) validarSwitch(n estructuras.NodoGenerico) {
+	if expresion, _ := n.Data["expresion"].(string); strings.TrimSpace(expresion) == "" {
+		v.agregar(SeveridadError, ProblemaSwitchInvalido, n.ID, "", fmt.Sprintf("El switch %s no tiene expresión", n.ID))
+	} else {
+		v.validarSintaxis(n, expresion)
+	}
+
+	var casos []casoSwitch
+	if casosRaw, ok := n.Data["casos"]; ok {
+		casosBytes, _ := json.Marshal(casosRaw)
+		if err := json.Unmarshal(casosBytes, &casos); err != nil {
+			v.agregar(SeveridadError, ProblemaSwitchInvalido, n.ID, "", fmt.Sprintf("Los casos del switch %s no son válidos: %v", n.ID, err))
+		}
+	}
+	for _, c := range casos {
+		if c.Regex == "" {
+			continue
+		}
+		if _, err := regexCaso(c.Regex); err != nil {
+			v.agregar(SeveridadError, ProblemaSwitchInvalido, n.ID, "", err.Error())
+		}
+	}
+
+	for _, a := range v.grafo.salientes[n.ID] {
+		if a.SourceHandle == HandleSwitchDefault {
+			return
+		}
+	}
+	v.agregar(SeveridadAdvertencia, ProblemaSwitchSinDefault, n.ID, "", fmt.Sprintf("El switch %s no tiene salida '%s'", n.ID, HandleSwitchDefault))
+}
+
+// validarSubproceso revisa que el proceso invocado exista y que no vuelva a llamar a este
+func (v *validadorFlujo) validarSubproceso(n estructuras.NodoGenerico, nodoID string) {
+	procesoID, _ := n.Data["procesoId"].(string)
+	if procesoID == "" {
+		v.agregar(SeveridadError, ProblemaSubprocesoNoDefinido, nodoID, "", fmt.Sprintf("El subproceso del nodo %s no tiene procesoId", nodoID))
+		return
+	}
+
+	if procesoID == v.procesoID {
+		v.agregar(SeveridadError, ProblemaSubprocesoCiclo, nodoID, "", fmt.Sprintf("El nodo %s invoca a su propio proceso", nodoID))
+		return
+	}
+
+	flujo, comprobado := v.cat.flujoProceso(procesoID)
+	if !comprobado {
+		return
+	}
+	if flujo == nil {
+		v.agregar(SeveridadError, ProblemaSubprocesoInexistente, nodoID, "", fmt.Sprintf("El nodo %s invoca al proceso '%s', que no existe", nodoID, procesoID))
+		return
+	}
+
+	if ciclo := v.cat.cicloDesde(procesoID, []string{v.procesoID}); ciclo != nil {
+		v.agregar(SeveridadError, ProblemaSubprocesoCiclo, nodoID, "", fmt.Sprintf("El nodo %s forma un ciclo de subprocesos: %s", nodoID, strings.Join(ciclo, " → ")))
+	}
+}
+
+// validarVariables revisa que las asignaciones tipo campo lean variables que algún nodo anterior
+// produce (o el espacio de un nodo anterior, con rutas como nodo_3.cliente). Si antes del nodo hay uno cuyas salidas no se conocen de antemano (subproceso,
+// splitter, proceso que parsea su FullOutput...) el nodo no se revisa
+func (v *validadorFlujo) validarVariables() {
+	producidas := make(map[string][]string)
+	abiertos := make(map[string]bool)
+	for _, n := range v.flujo.Nodes {
+		vars, conocidas := variablesProducidas(n)
+		producidas[n.ID] = vars
+		abiertos[n.ID] = !conocidas
+	}
+
+	for _, n := range v.flujo.Nodes {
+		lecturas := variablesLeidas(n)
+		if len(lecturas) == 0 {
+			continue
+		}
+
+		disponibles := make(map[string]bool)
+		for _, nombre := range variablesEstandar {
+			disponibles[nombre] = true
+		}
+		revisable := true
+		anteriores := v.grafo.anterioresA(n.ID)
+		for anterior := range anteriores {
+			if abiertos[anterior] {
+				revisable = false
+			}
+			for _, nombre := range producidas[anterior] {
+				disponibles[nombre] = true
+			}
+		}
+
+		for _, nombre := range lecturas {
+			// nodo_3.cliente.nombre lee del espacio de nodo_3: basta con que sea un nodo anterior
+			raiz := utils.RaizRuta(nombre)
+			if _, esNodo := v.grafo.nodos[raiz]; esNodo {
+				if !anteriores[raiz] {
+					v.agregar(SeveridadAdvertencia, ProblemaVariableNoProducida, n.ID, "", fmt.Sprintf("El nodo %s lee '%s', pero %s no se ejecuta antes que él", n.ID, nombre, raiz))
+				}
+				continue
+			}
+			if revisable && !disponibles[nombre] && !disponibles[raiz] {
+				v.agregar(SeveridadAdvertencia, ProblemaVariableNoProducida, n.ID, "", fmt.Sprintf("El nodo %s asigna la variable '%s', que ningún nodo anterior produce", n.ID, nombre))
+			}
+		}
+	}
+}
+
+// anterioresA devuelve todos los nodos desde los que se puede llegar a id (sin incluirlo)
+func (g *grafoFlujo) anterioresA(id string) map[string]bool {
+	vistos := make(map[string]bool)
+	cola := []string{id}
+	for len(cola) > 0 {
+		actual := cola[0]
+		cola = cola[1:]
+		for _, a := range g.entrantes[actual] {
+			if !vistos[a.Source] && a.Source != id {
+				vistos[a.Source] = true
+				cola = append(cola, a.Source)
+			}
+		}
+	}
+	return vistos
+}
+
+// variablesProducidas lista las variables que un nodo deja en el resultado; false si no se
+// pueden conocer sin ejecutarlo
+func variablesProducidas(n estructuras.NodoGenerico) ([]string, bool) {
+	switch n.Type {
+	case "entrada":
+		return nombresCampos(n.Data["campos"]), true
+
+	case "proceso":
+		cfg := compilarConfigProceso(n)
+		if cfg.ParsearFullOutput {
+			return nil, false
+		}
+		vars := []string{"FullOutput", "fullOutput", "fullOutput_" + n.ID}
+		vars = append(vars, nombresCampos(n.Data["parametrosSalida"])...)
+		for _, asigns := range cfg.Asignaciones {
+			for _, a := range asigns {
+				vars = append(vars, a.Destino)
+			}
+		}
+		return vars, true
+
+	case "iterar":
+		cfg := leerConfigIterar(n)
+		return []string{cfg.Elemento, cfg.Indice, cfg.Salida, cfg.Errores}, true
+
+	case "condicion":
+		return []string{"cumple", "resultado", "resultado_" + n.ID, "fullOutput", "fullOutput_" + n.ID}, true
+
+	case "switch":
+		return []string{"valor_" + n.ID, "fullOutput", "fullOutput_" + n.ID}, true
+
+	case "transformar":
+		variable, _ := n.Data["variableSalida"].(string)
+		if variable == "" {
+			variable = VariableTransformarDefecto
+		}
+		return []string{variable}, true
+
+	case "salida", "salidaError", "paralelo", "union", "finIterar", "iniciarTransaccion", "confirmarTransaccion":
+		return nil, true
+	}
+
+	// subproceso, splitter y tipos nuevos: sus salidas dependen de la ejecución
+	return nil, false
+}
+
+// variablesLeidas lista las variables del contexto que leen las asignaciones del nodo
+func variablesLeidas(n estructuras.NodoGenerico) []string {
+	var leidas []string
+	// proceso REST y salida pueden enviar una variable completa como cuerpo
+	if cuerpo, _ := n.Data["cuerpo"].(string); cuerpo != "" && (n.Type == "proceso" || n.Type == "salida") {
+		leidas = append(leidas, cuerpo)
+	}
+	switch n.Type {
+	case "proceso":
+		for _, asigns := range decodificarAsignacionesProceso(n) {
+			for _, a := range asigns {
+				switch {
+				case a.Tipo == "campo" && a.Valor != "":
+					leidas = append(leidas, a.Valor)
+				case a.Tipo == "tabla" && a.EsClaveVariable && a.Clave != "":
+					leidas = append(leidas, a.Clave)
+				}
+			}
+		}
+		// Los :parametros de una consulta se enlazan con variables del resultado
+		if esNodoConsulta(n) {
+			leidas = append(leidas, ejecutores.ParametrosConsulta(ejecutores.ConsultaDelNodo(n))...)
+		}
+
+	case "subproceso":
+		asignaciones, _ := n.Data["asignaciones"].(map[string]interface{})
+		for _, mapeos := range asignaciones {
+			lista, _ := mapeos.([]interface{})
+			for _, mapeo := range lista {
+				m, _ := mapeo.(map[string]interface{})
+				if tipo, _ := m["tipo"].(string); tipo != "campo" {
+					continue
+				}
+				if valor, _ := m["valor"].(string); valor != "" {
+					leidas = append(leidas, valor)
+				}
+			}
+		}
+	}
+	return leidas
+}
+
+// esNodoConsulta indica si el nodo proceso ejecuta SQL escrito en el propio nodo
+func esNodoConsulta(n estructuras.NodoGenerico) bool {
+	tipo, _ := n.Data["tipoObjeto"].(string)
+	return strings.EqualFold(tipo, "consulta") || strings.EqualFold(tipo, "sql")
+}
+
+// nombresCampos saca los nombres de una lista de campos ([{nombre, tipo}, ...])
+func nombresCampos(raw interface{}) []string {
+	var campos []estructuras.Campo
+	if bytes, err := json.Marshal(raw); err == nil {
+		_ = json.Unmarshal(bytes, &campos)
+	}
+	nombres := make([]string, 0, len(campos))
+	for _, c := range campos {
+		if c.Nombre != "" {
+			nombres = append(nombres, c.Nombre)
+		}
+	}
+	return nombres
+}
+
+// existeServidor devuelve si el servidor existe y si se pudo comprobar
+func (c *catalogoValidacion) existeServidor(id string) (bool, bool) {
+	if existe, ok := c.servidores[id]; ok {
+		return existe, true
+	}
+	if c.db == nil {
+		return false, false
+	}
+	var total int64
+	if err := c.db.WithContext(c.ctx).Model(&models.Servidor{}).Where("id = ?", id).Count(&total).Error; err != nil {
+		fmt.Printf("⚠️ No se pudo comprobar el servidor %s: %v\n", id, err)
+		return false, false
+	}
+	c.servidores[id] = total > 0
+	return total > 0, true
+}
+
+// flujoProceso carga el flujo guardado de un proceso; nil si no existe. El segundo valor es false
+// cuando no se pudo comprobar
+func (c *catalogoValidacion) flujoProceso(id string) (*estructuras.Flujo, bool) {
+	if flujo, ok := c.flujos[id]; ok {
+		return flujo, true
+	}
+	// procesos.id es uuid: un valor que no lo es no puede existir (y Postgres rechazaría la consulta)
+	if _, err := uuid.Parse(id); err != nil {
+		c.flujos[id] = nil
+		return nil, true
+	}
+	if c.db == nil {
+		return nil, false
+	}
+
+	var proc models.Proceso
+	err := c.db.WithContext(c.ctx).First(&proc, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.flujos[id] = nil
+		return nil, true
+	}
+	if err != nil {
+		fmt.Printf("⚠️ No se pudo comprobar el proceso %s: %v\n", id, err)
+		return nil, false
+	}
+
+	// Un flujo guardado que no parsea no invoca subprocesos: para el ciclo cuenta como vacío
+	flujo := &estructuras.Flujo{}
+	_ = json.Unmarshal([]byte(proc.Flujo), flujo)
+	c.flujos[id] = flujo
+	return flujo, true
+}
+
+// cicloDesde sigue los subprocesos que invoca procesoID; si alguno ya está en la pila devuelve
+// el camino que forma el ciclo
+func (c *catalogoValidacion) cicloDesde(procesoID string, pila []string) []string {
+	for i, id := range pila {
+		if id == procesoID {
+			return append(append([]string{}, pila[i:]...), procesoID)
+		}
+	}
+
+	if c.sinCiclo[procesoID] {
+		return nil
+	}
+	flujo, comprobado := c.flujoProceso(procesoID)
+	if !comprobado || flujo == nil {
+		return nil
+	}
+
+	pila = append(pila, procesoID)
+	for _, hijo := range subprocesosInvocados(*flujo) {
+		if ciclo := c.cicloDesde(hijo, pila); ciclo != nil {
+			return ciclo
+		}
+	}
+	c.sinCiclo[procesoID] = true
+	return nil
+}
+
+// subprocesosInvocados lista los procesos que un flujo invoca, incluidas las compensaciones
+func subprocesosInvocados(flujo estructuras.Flujo) []string {
+	var ids []string
+	for _, n := range flujo.Nodes {
+		switch n.Type {
+		case "subproceso":
+			if id, _ := n.Data["procesoId"].(string); id != "" {
+				ids = append(ids, id)
+			}
+		case "proceso":
+			if accion, ok := nodoCompensacion(n); ok && accion.Type == "subproceso" {
+				if id, _ := accion.Data["procesoId"].(string); id != "" {
+					ids = append(ids, id)
+				}
+			}
+		}
+	}
+	return ids
+}
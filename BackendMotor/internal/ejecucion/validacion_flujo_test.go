@@ -0,0 +1,202 @@
+package ejecucion
+
+import (
+	"backendmotor/internal/estructuras"
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+// validarDePrueba valida el flujo como procesoDePrueba con un catálogo armado a mano: el servidor
+// "srv" existe, "srv-caido" no, y el proceso "…0001" invoca de vuelta al proceso de prueba
+func validarDePrueba(t *testing.T, flujo string) ResultadoValidacion {
+	t.Helper()
+	cat := &catalogoValidacion{
+		ctx:        context.Background(),
+		servidores: map[string]bool{"srv": true, "srv-caido": false},
+		flujos: map[string]*estructuras.Flujo{
+			"00000000-0000-0000-0000-000000000001": {Nodes: []estructuras.NodoGenerico{nodo("s", "subproceso", map[string]interface{}{"procesoId": "proceso-prueba"})}},
+		},
+		sinCiclo: make(map[string]bool),
+	}
+	return validarProceso(procesoDePrueba(flujo), cat)
+}
+
+func flujoDePrueba(t *testing.T, nodos []estructuras.NodoGenerico, aristas []string) string {
+	t.Helper()
+	flujo, err := json.Marshal(estructuras.Flujo{Nodes: nodos, Edges: aristasDePrueba(aristas)})
+	if err != nil {
+		t.Fatalf("error serializando el flujo: %v", err)
+	}
+	return string(flujo)
+}
+
+func TestValidarProceso(t *testing.T) {
+	entrada := nodo("e", "entrada", map[string]interface{}{"campos": []interface{}{map[string]interface{}{"nombre": "a"}}})
+	fin := nodo("f", "salida", nil)
+	// conFin arma e → nodo → f
+	conFin := func(n estructuras.NodoGenerico) ([]estructuras.NodoGenerico, []string) {
+		return []estructuras.NodoGenerico{entrada, n, fin}, []string{"e>" + n.ID, n.ID + ">f"}
+	}
+
+	casos := []struct {
+		nombre    string
+		flujo     string // si no está vacío se usa tal cual en lugar de nodos y aristas
+		nodos     []estructuras.NodoGenerico
+		aristas   []string
+		codigo    string
+		severidad string
+		guardable bool
+	}{
+		{nombre: "flujo vacío", flujo: " ", codigo: ProblemaFlujoVacio, severidad: SeveridadAdvertencia, guardable: true},
+		{nombre: "flujo que no es JSON", flujo: "{", codigo: ProblemaFlujoInvalido, severidad: SeveridadError},
+		{
+			nombre: "sin entrada",
+			nodos:  []estructuras.NodoGenerico{fin},
+			codigo: ProblemaEntradaFaltante, severidad: SeveridadError,
+		},
+		{
+			nombre:  "dos entradas",
+			nodos:   []estructuras.NodoGenerico{entrada, nodo("e2", "entrada", nil), fin},
+			aristas: []string{"e>f", "e2>f"},
+			codigo:  ProblemaEntradaDuplicada, severidad: SeveridadError, guardable: true,
+		},
+		{
+			nombre:  "nodo duplicado",
+			nodos:   []estructuras.NodoGenerico{entrada, fin, nodo("f", "salida", nil)},
+			aristas: []string{"e>f"},
+			codigo:  ProblemaNodoDuplicado, severidad: SeveridadError,
+		},
+		{
+			nombre: "nodo inalcanzable",
+			nodos:  []estructuras.NodoGenerico{entrada, fin},
+			codigo: ProblemaNodoInalcanzable, severidad: SeveridadAdvertencia, guardable: true,
+		},
+		{
+			nombre:  "conexión a un nodo desconocido",
+			nodos:   []estructuras.NodoGenerico{entrada, fin},
+			aristas: []string{"e>f", "e>fantasma"},
+			codigo:  ProblemaConexionSinNodo, severidad: SeveridadError,
+		},
+		{
+			nombre:  "ciclo",
+			nodos:   []estructuras.NodoGenerico{entrada, nodo("a", "union", nil), nodo("b", "union", nil)},
+			aristas: []string{"e>a", "a>b", "b>a"},
+			codigo:  ProblemaCicloFlujo, severidad: SeveridadError,
+		},
+		{
+			nombre: "proceso sin servidor",
+			nodos:  []estructuras.NodoGenerico{entrada, nodo("p", "proceso", nil)}, aristas: []string{"e>p"},
+			codigo: ProblemaServidorNoDefinido, severidad: SeveridadError, guardable: true,
+		},
+		{
+			nombre: "servidor que no existe",
+			nodos:  []estructuras.NodoGenerico{entrada, nodo("p", "proceso", map[string]interface{}{"servidorId": "srv-caido"})}, aristas: []string{"e>p"},
+			codigo: ProblemaServidorInexistente, severidad: SeveridadError, guardable: true,
+		},
+		{
+			nombre: "consulta sin SQL",
+			nodos:  []estructuras.NodoGenerico{entrada, nodo("p", "proceso", map[string]interface{}{"servidorId": "srv", "tipoObjeto": "consulta"})}, aristas: []string{"e>p"},
+			codigo: ProblemaConsultaVacia, severidad: SeveridadError, guardable: true,
+		},
+		{
+			nombre: "confirmación sin servidor",
+			nodos:  []estructuras.NodoGenerico{entrada, nodo("c", "confirmarTransaccion", nil)}, aristas: []string{"e>c"},
+			codigo: ProblemaTransaccionInvalida, severidad: SeveridadError, guardable: true,
+		},
+		{
+			nombre: "transacción sin confirmar",
+			nodos:  []estructuras.NodoGenerico{entrada, nodo("ini", "iniciarTransaccion", map[string]interface{}{"servidorId": "srv"})}, aristas: []string{"e>ini"},
+			codigo: ProblemaTransaccionSinConfirmar, severidad: SeveridadAdvertencia, guardable: true,
+		},
+		{
+			nombre:  "condición sin salida false",
+			nodos:   []estructuras.NodoGenerico{entrada, nodo("c", "condicion", map[string]interface{}{"condicion": "a > 1"}), fin},
+			aristas: []string{"e>c", "c>f:true"},
+			codigo:  ProblemaCondicionIncompleta, severidad: SeveridadError, guardable: true,
+		},
+		{
+			nombre:  "expresión con error de sintaxis",
+			nodos:   []estructuras.NodoGenerico{entrada, nodo("c", "condicion", map[string]interface{}{"condicion": "a >"}), fin},
+			aristas: []string{"e>c", "c>f:true", "c>f:false"},
+			codigo:  ProblemaExpresionInvalida, severidad: SeveridadError, guardable: true,
+		},
+		{
+			nombre: "switch sin expresión",
+			nodos:  []estructuras.NodoGenerico{entrada, nodo("s", "switch", nil), fin}, aristas: []string{"e>s", "s>f:" + HandleSwitchDefault},
+			codigo: ProblemaSwitchInvalido, severidad: SeveridadError, guardable: true,
+		},
+		{
+			nombre: "switch sin default",
+			nodos:  []estructuras.NodoGenerico{entrada, nodo("s", "switch", map[string]interface{}{"expresion": "a"}), fin}, aristas: []string{"e>s", "s>f:caso_1"},
+			codigo: ProblemaSwitchSinDefault, severidad: SeveridadAdvertencia, guardable: true,
+		},
+		{
+			nombre: "transformar sin plantilla",
+			nodos:  []estructuras.NodoGenerico{entrada, nodo("t", "transformar", map[string]interface{}{"variableSalida": "x"}), fin}, aristas: []string{"e>t", "t>f"},
+			codigo: ProblemaTransformarSinPlantilla, severidad: SeveridadError, guardable: true,
+		},
+		{
+			nombre: "subproceso sin proceso",
+			nodos:  []estructuras.NodoGenerico{entrada, nodo("s", "subproceso", nil)}, aristas: []string{"e>s"},
+			codigo: ProblemaSubprocesoNoDefinido, severidad: SeveridadError, guardable: true,
+		},
+		{
+			nombre: "subproceso que no existe",
+			nodos:  []estructuras.NodoGenerico{entrada, nodo("s", "subproceso", map[string]interface{}{"procesoId": "no-existe"})}, aristas: []string{"e>s"},
+			codigo: ProblemaSubprocesoInexistente, severidad: SeveridadError, guardable: true,
+		},
+		{
+			nombre: "subproceso que se invoca a sí mismo",
+			nodos:  []estructuras.NodoGenerico{entrada, nodo("s", "subproceso", map[string]interface{}{"procesoId": "proceso-prueba"})}, aristas: []string{"e>s"},
+			codigo: ProblemaSubprocesoCiclo, severidad: SeveridadError, guardable: true,
+		},
+		{
+			nombre: "ciclo a través de otro proceso",
+			nodos:  []estructuras.NodoGenerico{entrada, nodo("s", "subproceso", map[string]interface{}{"procesoId": "00000000-0000-0000-0000-000000000001"})}, aristas: []string{"e>s"},
+			codigo: ProblemaSubprocesoCiclo, severidad: SeveridadError, guardable: true,
+		},
+		{
+			nombre:  "variable que nadie produce",
+			nodos:   []estructuras.NodoGenerico{entrada, nodo("f", "salida", map[string]interface{}{"cuerpo": "b"})},
+			aristas: []string{"e>f"},
+			codigo:  ProblemaVariableNoProducida, severidad: SeveridadAdvertencia, guardable: true,
+		},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			flujo := c.flujo
+			if flujo == "" {
+				flujo = flujoDePrueba(t, c.nodos, c.aristas)
+			}
+			res := validarDePrueba(t, flujo)
+
+			var encontrado *ProblemaFlujo
+			for i, p := range res.Problemas {
+				if p.Codigo == c.codigo {
+					encontrado = &res.Problemas[i]
+					break
+				}
+			}
+			if encontrado == nil {
+				t.Fatalf("se esperaba el problema %s y se obtuvo %+v", c.codigo, res.Problemas)
+			}
+			if encontrado.Severidad != c.severidad {
+				t.Fatalf("%s: se esperaba severidad %s y se obtuvo %s", c.codigo, c.severidad, encontrado.Severidad)
+			}
+			if res.Guardable != c.guardable {
+				t.Fatalf("%s: se esperaba guardable=%v y se obtuvo %v (%+v)", c.codigo, c.guardable, res.Guardable, res.Problemas)
+			}
+			if res.Valido != (res.Errores == 0) {
+				t.Fatalf("valido=%v no corresponde a %d errores", res.Valido, res.Errores)
+			}
+		})
+	}
+
+	// Un flujo completo no reporta nada
+	nodos, aristas := conFin(nodo("t", "transformar", map[string]interface{}{"plantilla": `"{{ a }}"`, "variableSalida": "x"}))
+	if res := validarDePrueba(t, flujoDePrueba(t, nodos, aristas)); !res.Valido || !res.Guardable || len(res.Problemas) != 0 {
+		t.Fatalf("se esperaba un flujo sin problemas y se obtuvo %+v", res.Problemas)
+	}
+}
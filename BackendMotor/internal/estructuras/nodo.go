@@ -23,8 +23,12 @@ type EdgeGenerico struct {
 }
 
 type Flujo struct {
-	ID     string         `json:"id"`
-	Nombre string         `json:"nombre"`
-	Nodes  []NodoGenerico `json:"nodes"`
-	Edges  []EdgeGenerico `json:"edges"`
+	ID        string         `json:"id"`
+	Nombre    string         `json:"nombre"`
+	Nodes     []NodoGenerico `json:"nodes"`
+	Edges     []EdgeGenerico `json:"edges"`
+	TimeoutMs int            `json:"timeoutMs,omitempty"` // deadline de la ejecución completa (0 = sin límite)
+	// Qué hacer si el motor se reinicia a mitad de una ejecución durable: "reanudar" desde el
+	// último nodo completado o "abandonar" (por defecto)
+	Recuperacion string `json:"recuperacion,omitempty"`
 }
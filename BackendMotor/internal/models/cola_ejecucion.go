@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// EjecucionEncolada es una ejecución aceptada por la cola durable (asíncrona o programada).
+// Se escribe antes de empezar y guarda un checkpoint después de cada nodo, para que el motor
+// pueda reanudarla o marcarla como abandonada si se reinicia a mitad de camino. El motor
+// propietario renueva LeaseHasta mientras la tiene; otro motor solo la toma con el lease vencido
+type EjecucionEncolada struct {
+	ID              string            `gorm:"type:uuid;primaryKey" json:"id"` // mismo ID que en ejecuciones
+	ProcesoID       string            `gorm:"not null" json:"procesoId"`
+	Canal           string            `json:"canal"`
+	Trigger         string            `json:"trigger"`
+	Origen          string            `gorm:"not null" json:"origen"`        // "asincrono", "scheduler"
+	ReferenciaID    *string           `gorm:"type:uuid" json:"referenciaId"` // ejecuciones_tareas.id en origen scheduler
+	CallbackURL     string            `json:"callbackUrl"`
+	Entrada         datatypes.JSONMap `json:"entrada"`
+	Simulacion      datatypes.JSON    `json:"simulacion"`
+	Estado          string            `gorm:"not null" json:"estado"` // "pendiente", "ejecutando", "completada", "error", "abandonada"
+	Intentos        int               `json:"intentos"`               // veces que un worker la tomó (1 + reanudaciones)
+	Propietario     string            `json:"propietario"`            // MOTOR_INSTANCIA (u hostname) del motor que la tiene
+	LeaseHasta      *time.Time        `json:"leaseHasta"`
+	Checkpoint      datatypes.JSON    `json:"checkpoint,omitempty"`
+	NodoCheckpoint  string            `json:"nodoCheckpoint"`
+	MensajeError    string            `json:"mensajeError"`
+	FechaEncolado   time.Time         `json:"fechaEncolado"`
+	FechaInicio     *time.Time        `json:"fechaInicio"`
+	FechaCheckpoint *time.Time        `json:"fechaCheckpoint"`
+	FechaFin        *time.Time        `json:"fechaFin"`
+}
+
+func (EjecucionEncolada) TableName() string {
+	return "cola_ejecuciones"
+}
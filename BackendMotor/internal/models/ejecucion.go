@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// Ejecucion es el registro persistido de una ejecución de un proceso (una por request o subproceso)
+type Ejecucion struct {
+	ID               string            `gorm:"type:uuid;primaryKey" json:"id"`
+	ProcesoID        string            `gorm:"not null" json:"procesoId"`
+	NombreProceso    string            `json:"nombreProceso"`
+	Canal            string            `json:"canal"`
+	Trigger          string            `json:"trigger"`
+	TraceID          string            `json:"traceId"`
+	EjecucionPadreID *string           `gorm:"type:uuid" json:"ejecucionPadreId"` // solo en subprocesos
+	Profundidad      int               `json:"profundidad"`
+	Propietario      string            `json:"propietario"`            // motor que la corre (ver cola_ejecuciones)
+	Estado           string            `gorm:"not null" json:"estado"` // "ejecutando", "exitoso", "error", "incompleto", "abandonada"
+	CodigoResultado  int               `json:"codigoResultado"`        // 0, 98, 99 como en ResultadoEjecucion
+	Mensaje          string            `json:"mensaje"`
+	Entrada          datatypes.JSONMap `json:"entrada"`
+	Salida           datatypes.JSONMap `json:"salida"`
+	DetalleError     string            `json:"detalleError"`
+	FechaInicio      time.Time         `json:"fechaInicio"`
+	FechaFin         *time.Time        `json:"fechaFin"`
+	DuracionMs       int64             `json:"duracionMs"`
+	Pasos            []EjecucionPaso   `gorm:"foreignKey:EjecucionID" json:"pasos,omitempty"`
+}
+
+func (Ejecucion) TableName() string {
+	return "ejecuciones"
+}
+
+// EjecucionPaso guarda lo que pasó en un nodo: las variables que escribió (su valor antes y
+// después), conexiones tomadas y error
+type EjecucionPaso struct {
+	ID             string            `gorm:"type:uuid;primaryKey" json:"id"`
+	EjecucionID    string            `gorm:"type:uuid;not null" json:"ejecucionId"`
+	Secuencia      int               `json:"secuencia"`
+	NodoID         string            `json:"nodoId"`
+	TipoNodo       string            `json:"tipoNodo"`
+	Rama           string            `json:"rama,omitempty"` // rama paralela en la que corrió el nodo
+	Entrada        datatypes.JSONMap `json:"entrada"`
+	Salida         datatypes.JSONMap `json:"salida"`
+	AristasTomadas datatypes.JSON    `json:"aristasTomadas"`
+	Estado         string            `json:"estado"` // "exitoso", "error"
+	DetalleError   string            `json:"detalleError"`
+	FechaInicio    time.Time         `json:"fechaInicio"`
+	DuracionMs     int64             `json:"duracionMs"`
+}
+
+func (EjecucionPaso) TableName() string {
+	return "ejecucion_pasos"
+}
@@ -28,7 +28,7 @@ type EjecucionTarea struct {
 	ID             string            `gorm:"type:uuid;primaryKey" json:"id"`
 	TareaProgramadaID string         `gorm:"not null" json:"tareaProgramadaId"`
 	FechaEjecucion time.Time         `gorm:"autoCreateTime" json:"fechaEjecucion"`
-	Estado         string            `gorm:"not null" json:"estado"` // "exitoso", "error", "ejecutando"
+	Estado         string            `gorm:"not null" json:"estado"` // "exitoso", "error", "ejecutando", "abandonada"
 	DuracionMs     int64             `json:"duracionMs"`
 	Resultado      datatypes.JSONMap `json:"resultado"`
 	MensajeError   string            `json:"mensajeError"`
@@ -45,6 +45,74 @@ var (
 			Buckets: prometheus.DefBuckets,
 		},
 	)
+
+	// Aciertos y fallos de la caché de flujos compilados
+	FlujoCacheTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "flujo_cache_total",
+			Help: "Consultas a la caché de flujos compilados por resultado (hit/miss)",
+		},
+		[]string{"resultado"},
+	)
+
+	// Estado del circuit breaker por servidor (0 cerrado, 1 semiabierto, 2 abierto)
+	CircuitoEstado = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "circuito_servidor_estado",
+			Help: "Estado del circuit breaker de cada servidor (0 cerrado, 1 semiabierto, 2 abierto)",
+		},
+		[]string{"servidor"},
+	)
+
+	// Llamadas rechazadas sin contactar al servidor por tener el circuito abierto
+	CircuitoRechazosTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "circuito_servidor_rechazos_total",
+			Help: "Llamadas rechazadas por el circuit breaker de cada servidor",
+		},
+		[]string{"servidor"},
+	)
+
+	// Pools de conexiones a bases de datos por servidor (se refrescan desde sql.DBStats)
+	PoolConexionesAbiertas = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "pool_servidor_conexiones_abiertas",
+			Help: "Conexiones abiertas (en uso + inactivas) del pool de cada servidor",
+		},
+		[]string{"servidor"},
+	)
+
+	PoolConexionesEnUso = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "pool_servidor_conexiones_en_uso",
+			Help: "Conexiones ocupadas por una consulta en el pool de cada servidor",
+		},
+		[]string{"servidor"},
+	)
+
+	PoolConexionesInactivas = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "pool_servidor_conexiones_inactivas",
+			Help: "Conexiones abiertas sin usar en el pool de cada servidor",
+		},
+		[]string{"servidor"},
+	)
+
+	PoolConexionesMaximas = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "pool_servidor_conexiones_maximas",
+			Help: "Límite de conexiones abiertas configurado para el pool de cada servidor",
+		},
+		[]string{"servidor"},
+	)
+
+	PoolEsperas = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "pool_servidor_esperas",
+			Help: "Veces que una consulta tuvo que esperar una conexión libre desde que se creó el pool",
+		},
+		[]string{"servidor"},
+	)
 )
 
 func InitMetrics() {
@@ -53,4 +121,12 @@ func InitMetrics() {
 	prometheus.MustRegister(SubprocessTotal)
 	prometheus.MustRegister(SubprocessErrors)
 	prometheus.MustRegister(SubprocessDuration)
+	prometheus.MustRegister(FlujoCacheTotal)
+	prometheus.MustRegister(CircuitoEstado)
+	prometheus.MustRegister(CircuitoRechazosTotal)
+	prometheus.MustRegister(PoolConexionesAbiertas)
+	prometheus.MustRegister(PoolConexionesEnUso)
+	prometheus.MustRegister(PoolConexionesInactivas)
+	prometheus.MustRegister(PoolConexionesMaximas)
+	prometheus.MustRegister(PoolEsperas)
 }
@@ -3,6 +3,7 @@ package publicador
 import (
 	"backendmotor/internal/ejecucion"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -84,8 +85,25 @@ func GinMotorHandler(c *gin.Context) {
 		_ = c.BindJSON(&input)
 	}
 
-	// 👉 Acá usamos el NUEVO motor
-	resultado, err := ejecucion.EjecutarFlujo(metodo.ProcesoID, input, canal.Codigo, trigger)
+	// ⏳ Modo asíncrono (header X-Ejecucion-Asincrona o ?asincrono=true): 202 con el ID de la ejecución
+	if ejecucion.SolicitaEjecucionAsincrona(c.Request) {
+		id, err := ejecucion.EncolarEjecucion(metodo.ProcesoID, input, canal.Codigo, trigger, nil, ejecucion.CallbackSolicitado(c.Request))
+		if errors.Is(err, ejecucion.ErrColaAsincronaLlena) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		urlEstado := "/ejecuciones/" + id + "/estado"
+		c.Header("Location", urlEstado)
+		c.JSON(http.StatusAccepted, gin.H{"ejecucionId": id, "estado": ejecucion.AsincronaPendiente, "urlEstado": urlEstado})
+		return
+	}
+
+	// 👉 Acá usamos el NUEVO motor; si el cliente se desconecta se cancela la ejecución
+	resultado, err := ejecucion.EjecutarFlujo(c.Request.Context(), metodo.ProcesoID, input, canal.Codigo, trigger)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
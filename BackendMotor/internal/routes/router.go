@@ -28,16 +28,36 @@ func SetupRouter() *gin.Engine {
 	router.POST("/servidores", controllers.CreateServidor)
 	router.PUT("/servidores/:id", controllers.UpdateServidor)
 	router.DELETE("/servidores/:id", controllers.DeleteServidor)
+	router.GET("/servidores-circuitos", controllers.GetCircuitosServidores)
+	router.POST("/servidores-circuitos/:id/reiniciar", controllers.ReiniciarCircuitoServidor)
 
 	// Rutas de procesos
 	router.GET("/procesos", controllers.GetProcesos)
 	router.POST("/procesos", controllers.CreateProceso)
+	router.POST("/procesos/validar", controllers.ValidarProceso)
 	router.PUT("/procesos/:id", controllers.UpdateProceso)
 	router.DELETE("/procesos/:id", controllers.DeleteProceso)
+	router.DELETE("/procesos-cache", controllers.LimpiarCacheFlujos)
 	
+	// Validación de expresiones (condiciones, switch y asignaciones)
+	router.POST("/expresiones/validar", controllers.ValidarExpresion)
+
 	// Ejecución de procesos
 	router.POST("/ejecutar-proceso", controllers.EjecutarProceso)
 
+	// Depuración paso a paso (se inicia con "depuracion" en POST /ejecutar-proceso)
+	router.GET("/depuracion/:id", controllers.GetSesionDepuracion)
+	router.POST("/depuracion/:id/continuar", controllers.ContinuarDepuracion)
+	router.POST("/depuracion/:id/paso", controllers.PasoDepuracion)
+	router.PUT("/depuracion/:id/variables", controllers.EditarVariablesDepuracion)
+	router.PUT("/depuracion/:id/breakpoints", controllers.DefinirBreakpointsDepuracion)
+	router.DELETE("/depuracion/:id", controllers.AbortarDepuracion)
+
+	// Traza de ejecuciones
+	router.GET("/ejecuciones", controllers.GetEjecuciones)
+	router.GET("/ejecuciones/:id", controllers.GetEjecucion)
+	router.GET("/ejecuciones/:id/estado", controllers.GetEstadoEjecucion)
+
 	// Rutas de canal_procesos
 	router.GET("/canal-procesos", controllers.GetCanalProcesos)
 	router.POST("/canal-procesos", controllers.CreateCanalProceso)
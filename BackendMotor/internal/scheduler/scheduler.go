@@ -2,6 +2,7 @@ package scheduler
 
 import (
 	"backendmotor/internal/database"
+	"context"
 	"backendmotor/internal/ejecucion"
 	"backendmotor/internal/utils"
 	"log"
@@ -15,6 +16,8 @@ import (
 )
 
 type Scheduler struct {
+	ctx          context.Context    // se cancela al detener el scheduler
+	cancelar     context.CancelFunc
 	tareas       map[string]*TareaProgramada
 	mutex        sync.RWMutex
 	ticker       *time.Ticker
@@ -57,7 +60,10 @@ func (EjecucionTarea) TableName() string {
 
 // Nueva instancia del scheduler
 func NuevoScheduler() *Scheduler {
+	ctx, cancelar := context.WithCancel(context.Background())
 	return &Scheduler{
+		ctx:         ctx,
+		cancelar:    cancelar,
 		tareas:      make(map[string]*TareaProgramada),
 		intervalo:   time.Minute, // Revisar cada minuto
 		stopChannel: make(chan bool),
@@ -100,6 +106,7 @@ func (s *Scheduler) Detener() {
 	if !s.ejecutando {
 		return
 	}
+	s.cancelar() // Cancela las ejecuciones de tareas en curso
 	s.stopChannel <- true
 }
 
@@ -196,7 +203,8 @@ func (s *Scheduler) ejecutarTarea(tarea *TareaProgramada) {
 	if tarea.CanalCodigo != "" {
 		canalEjecucion = tarea.CanalCodigo
 	}
-	resultado, err := ejecucion.EjecutarFlujo(tarea.ProcesoID, tarea.ParametrosEntrada, canalEjecucion, "scheduler")
+	// La ejecución queda en la cola durable: si el motor se reinicia se reanuda o se marca abandonada
+	resultado, err := ejecucion.EjecutarFlujoDurable(s.ctx, tarea.ProcesoID, tarea.ParametrosEntrada, canalEjecucion, "scheduler", registroEjecucion.ID)
 	
 	// Actualizar registro de ejecución
 	registroEjecucion.DuracionMs = time.Since(inicioEjecucion).Milliseconds()
@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"container/list"
+	"sync"
+)
+
+// cacheAcotada guarda hasta limite valores por clave; al llenarse descarta el que hace más tiempo
+// que no se usa
+type cacheAcotada struct {
+	mu       sync.Mutex
+	limite   int
+	orden    *list.List // del más usado al menos usado
+	entradas map[string]*list.Element
+}
+
+type entradaCache struct {
+	clave string
+	valor interface{}
+}
+
+func nuevaCacheAcotada(limite int) *cacheAcotada {
+	return &cacheAcotada{limite: limite, orden: list.New(), entradas: make(map[string]*list.Element)}
+}
+
+func (c *cacheAcotada) obtener(clave string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entradas[clave]
+	if !ok {
+		return nil, false
+	}
+	c.orden.MoveToFront(e)
+	return e.Value.(*entradaCache).valor, true
+}
+
+func (c *cacheAcotada) guardar(clave string, valor interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entradas[clave]; ok {
+		e.Value.(*entradaCache).valor = valor
+		c.orden.MoveToFront(e)
+		return
+	}
+	c.entradas[clave] = c.orden.PushFront(&entradaCache{clave: clave, valor: valor})
+	if c.orden.Len() > c.limite {
+		ultima := c.orden.Back()
+		c.orden.Remove(ultima)
+		delete(c.entradas, ultima.Value.(*entradaCache).clave)
+	}
+}
+
+func (c *cacheAcotada) largo() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.orden.Len()
+}
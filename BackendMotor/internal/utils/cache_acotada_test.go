@@ -0,0 +1,39 @@
+package utils
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestCacheAcotada(t *testing.T) {
+	c := nuevaCacheAcotada(2)
+	c.guardar("a", 1)
+	c.guardar("b", 2)
+	c.obtener("a") // b pasa a ser el menos usado
+	c.guardar("c", 3)
+
+	if _, ok := c.obtener("b"); ok {
+		t.Fatalf("b debía descartarse al llenarse la caché")
+	}
+	for clave, esperado := range map[string]int{"a": 1, "c": 3} {
+		if v, ok := c.obtener(clave); !ok || v != esperado {
+			t.Fatalf("%s: se esperaba %d y se obtuvo %v", clave, esperado, v)
+		}
+	}
+
+	c.guardar("a", 10)
+	if v, _ := c.obtener("a"); v != 10 || c.largo() != 2 {
+		t.Fatalf("guardar una clave existente debía reemplazar el valor: %v (largo %d)", v, c.largo())
+	}
+}
+
+func TestCompilarConCacheTieneTope(t *testing.T) {
+	for i := 0; i < 5000; i++ {
+		if _, err := compilarConCache(fmt.Sprintf("edad > %d", i)); err != nil {
+			t.Fatalf("error inesperado: %v", err)
+		}
+	}
+	if largo := expresionesCompiladas.largo(); largo > 4096 {
+		t.Fatalf("la caché de expresiones creció hasta %d", largo)
+	}
+}
@@ -0,0 +1,443 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Evaluación del árbol que arma parser_expresiones.go. Los números se operan como float64, el +
+// con un texto concatena y && / || cortan apenas conocen el resultado.
+//
+// Las comparaciones difieren de govaluate en dos casos, a propósito:
+//   - Un número de cualquier tipo (el int que devuelve DiaSemana(), un int64 de la base) se compara
+//     por valor: DiaSemana() == 7 es verdadero, en govaluate era falso o un error.
+//   - Al ordenar (>, <, >=, <=) un número contra un texto numérico, el texto se lee como número:
+//     edad > "18" compara 20 con 18; en govaluate era un error.
+//
+// Texto contra texto sigue como en govaluate: == es exacto ("01" no es "1") y el orden es el
+// lexicográfico ("10" < "9"). Un número nunca es igual a un texto (1 == "1" es falso)
+
+// nodoExpresion es un nodo del árbol de la expresión
+type nodoExpresion interface {
+	evaluar(c *contextoEvaluacion) (interface{}, error)
+	posicion() posicion
+	hijos() []nodoExpresion
+}
+
+// contextoEvaluacion son las variables del flujo y las funciones del sistema ligadas a ellas
+type contextoEvaluacion struct {
+	valores   map[string]interface{}
+	funciones map[string]FuncionExpresion
+}
+
+type nodoLiteral struct {
+	pos   posicion
+	valor interface{}
+}
+
+// nodoVariable es una variable o una ruta fija (cliente.direcciones[0].ciudad)
+type nodoVariable struct {
+	pos    posicion
+	ruta   string
+	nombre string
+}
+
+// nodoIndice es un acceso calculado al evaluar: lista[i], Funcion()["clave"]
+type nodoIndice struct {
+	pos    posicion
+	base   nodoExpresion
+	indice nodoExpresion
+}
+
+type nodoLlamada struct {
+	pos        posicion
+	nombre     string
+	argumentos []nodoExpresion
+}
+
+type nodoUnario struct {
+	pos      posicion
+	op       string
+	operando nodoExpresion
+}
+
+type nodoBinario struct {
+	pos      posicion
+	op       string
+	izq, der nodoExpresion
+}
+
+// nodoTernario es condicion ? si : sino
+type nodoTernario struct {
+	pos                 posicion
+	condicion, si, sino nodoExpresion
+}
+
+// nodoEn es valor in (opciones); una opción que es un arreglo aporta cada uno de sus elementos
+type nodoEn struct {
+	pos      posicion
+	valor    nodoExpresion
+	opciones []nodoExpresion
+}
+
+func (n *nodoLiteral) posicion() posicion  { return n.pos }
+func (n *nodoVariable) posicion() posicion { return n.pos }
+func (n *nodoIndice) posicion() posicion   { return n.pos }
+func (n *nodoLlamada) posicion() posicion  { return n.pos }
+func (n *nodoUnario) posicion() posicion   { return n.pos }
+func (n *nodoBinario) posicion() posicion  { return n.pos }
+func (n *nodoTernario) posicion() posicion { return n.pos }
+func (n *nodoEn) posicion() posicion       { return n.pos }
+
+func (n *nodoLiteral) hijos() []nodoExpresion  { return nil }
+func (n *nodoVariable) hijos() []nodoExpresion { return nil }
+func (n *nodoIndice) hijos() []nodoExpresion   { return []nodoExpresion{n.base, n.indice} }
+func (n *nodoLlamada) hijos() []nodoExpresion  { return n.argumentos }
+func (n *nodoUnario) hijos() []nodoExpresion   { return []nodoExpresion{n.operando} }
+func (n *nodoBinario) hijos() []nodoExpresion  { return []nodoExpresion{n.izq, n.der} }
+func (n *nodoTernario) hijos() []nodoExpresion { return []nodoExpresion{n.condicion, n.si, n.sino} }
+func (n *nodoEn) hijos() []nodoExpresion {
+	return append([]nodoExpresion{n.valor}, n.opciones...)
+}
+
+// recorrerExpresion visita el nodo y todos sus descendientes
+func recorrerExpresion(n nodoExpresion, visitar func(nodoExpresion)) {
+	visitar(n)
+	for _, h := range n.hijos() {
+		recorrerExpresion(h, visitar)
+	}
+}
+
+// Evaluar calcula la expresión con las variables del contexto
+func (c *ExpresionCompilada) Evaluar(contexto map[string]interface{}) (interface{}, error) {
+	resultado, err := c.raiz.evaluar(&contextoEvaluacion{valores: contexto, funciones: funcionesSistema(contexto)})
+	if err != nil {
+		return nil, fmt.Errorf("error al evaluar expresión: %w", err)
+	}
+	return resultado, nil
+}
+
+func errorEvaluacion(pos posicion, formato string, args ...interface{}) error {
+	return fmt.Errorf("línea %d, columna %d: %s", pos.Linea, pos.Columna, fmt.Sprintf(formato, args...))
+}
+
+func (n *nodoLiteral) evaluar(c *contextoEvaluacion) (interface{}, error) {
+	return n.valor, nil
+}
+
+func (n *nodoVariable) evaluar(c *contextoEvaluacion) (interface{}, error) {
+	if v, ok := ResolverRuta(c.valores, n.ruta); ok {
+		return v, nil
+	}
+	// Una variable suelta que no existe es un error; una ruta que no llega a nada vale nulo
+	if n.ruta == n.nombre {
+		return nil, errorEvaluacion(n.pos, "la variable '%s' no existe", n.nombre)
+	}
+	return nil, nil
+}
+
+func (n *nodoIndice) evaluar(c *contextoEvaluacion) (interface{}, error) {
+	base, err := n.base.evaluar(c)
+	if err != nil {
+		return nil, err
+	}
+	indice, err := n.indice.evaluar(c)
+	if err != nil {
+		return nil, err
+	}
+	paso := segmentoRuta{Clave: textoArgumento(indice)}
+	if numero, ok := numeroExpresion(indice); ok {
+		paso = segmentoRuta{Indice: int(numero), EsIdx: true}
+	}
+	v, _ := pasoRuta(base, paso)
+	return v, nil
+}
+
+func (n *nodoLlamada) evaluar(c *contextoEvaluacion) (interface{}, error) {
+	funcion, ok := c.funciones[n.nombre]
+	if !ok {
+		return nil, errorEvaluacion(n.pos, "función desconocida '%s'", n.nombre)
+	}
+	argumentos := make([]interface{}, len(n.argumentos))
+	for i, a := range n.argumentos {
+		v, err := a.evaluar(c)
+		if err != nil {
+			return nil, err
+		}
+		argumentos[i] = v
+	}
+	resultado, err := funcion(argumentos...)
+	if err != nil {
+		return nil, errorEvaluacion(n.pos, "%s: %v", n.nombre, err)
+	}
+	return resultado, nil
+}
+
+func (n *nodoUnario) evaluar(c *contextoEvaluacion) (interface{}, error) {
+	v, err := n.operando.evaluar(c)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "!":
+		b, ok := v.(bool)
+		if !ok {
+			return nil, errorEvaluacion(n.pos, "la negación necesita un booleano y recibió '%v'", v)
+		}
+		return !b, nil
+	case "-":
+		numero, ok := numeroExpresion(v)
+		if !ok {
+			return nil, errorEvaluacion(n.pos, "'%v' no es un número", v)
+		}
+		return -numero, nil
+	}
+	return nil, errorEvaluacion(n.pos, "operador desconocido '%s'", n.op)
+}
+
+func (n *nodoTernario) evaluar(c *contextoEvaluacion) (interface{}, error) {
+	v, err := n.condicion.evaluar(c)
+	if err != nil {
+		return nil, err
+	}
+	condicion, ok := v.(bool)
+	if !ok {
+		return nil, errorEvaluacion(n.pos, "'?' necesita una condición booleana y recibió '%v'", v)
+	}
+	if condicion {
+		return n.si.evaluar(c)
+	}
+	return n.sino.evaluar(c)
+}
+
+func (n *nodoEn) evaluar(c *contextoEvaluacion) (interface{}, error) {
+	valor, err := n.valor.evaluar(c)
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range n.opciones {
+		opcion, err := o.evaluar(c)
+		if err != nil {
+			return nil, err
+		}
+		rv := reflect.ValueOf(opcion)
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			if igualesExpresion(valor, opcion) {
+				return true, nil
+			}
+			continue
+		}
+		for i := 0; i < rv.Len(); i++ {
+			if igualesExpresion(valor, rv.Index(i).Interface()) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func (n *nodoBinario) evaluar(c *contextoEvaluacion) (interface{}, error) {
+	// a ?? b vale b solo si a no existe o es nulo
+	if n.op == "??" {
+		if izq, err := n.izq.evaluar(c); err == nil && izq != nil {
+			return izq, nil
+		}
+		return n.der.evaluar(c)
+	}
+
+	izq, err := n.izq.evaluar(c)
+	if err != nil {
+		return nil, err
+	}
+
+	// && y || no evalúan el lado derecho si no hace falta
+	if n.op == "&&" || n.op == "||" {
+		a, ok := izq.(bool)
+		if !ok {
+			return nil, errorEvaluacion(n.pos, "'%s' necesita booleanos y recibió '%v'", n.op, izq)
+		}
+		if (n.op == "&&" && !a) || (n.op == "||" && a) {
+			return a, nil
+		}
+		der, err := n.der.evaluar(c)
+		if err != nil {
+			return nil, err
+		}
+		b, ok := der.(bool)
+		if !ok {
+			return nil, errorEvaluacion(n.pos, "'%s' necesita booleanos y recibió '%v'", n.op, der)
+		}
+		return b, nil
+	}
+
+	der, err := n.der.evaluar(c)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "==":
+		return igualesExpresion(izq, der), nil
+	case "!=":
+		return !igualesExpresion(izq, der), nil
+	case ">", "<", ">=", "<=":
+		return compararExpresion(n.pos, n.op, izq, der)
+	case "=~", "!~":
+		patron, ok := der.(string)
+		if !ok {
+			return nil, errorEvaluacion(n.pos, "'%s' necesita una expresión regular de texto", n.op)
+		}
+		re, err := regexExpresion(patron)
+		if err != nil {
+			return nil, errorEvaluacion(n.pos, "regex inválida '%s': %v", patron, err)
+		}
+		coincide := re.MatchString(textoArgumento(izq))
+		return coincide == (n.op == "=~"), nil
+	case "+":
+		a, okA := numeroExpresion(izq)
+		b, okB := numeroExpresion(der)
+		if okA && okB {
+			return a + b, nil
+		}
+		_, textoA := izq.(string)
+		_, textoB := der.(string)
+		if textoA || textoB {
+			return textoArgumento(izq) + textoArgumento(der), nil
+		}
+		return nil, errorEvaluacion(n.pos, "no se puede sumar '%v' y '%v'", izq, der)
+	case "-", "*", "/", "%":
+		a, okA := numeroExpresion(izq)
+		b, okB := numeroExpresion(der)
+		if !okA || !okB {
+			return nil, errorEvaluacion(n.pos, "'%s' necesita números y recibió '%v' y '%v'", n.op, izq, der)
+		}
+		switch n.op {
+		case "-":
+			return a - b, nil
+		case "*":
+			return a * b, nil
+		case "/":
+			if b == 0 {
+				return nil, errorEvaluacion(n.pos, "división por cero")
+			}
+			return a / b, nil
+		default:
+			if b == 0 {
+				return nil, errorEvaluacion(n.pos, "división por cero")
+			}
+			return math.Mod(a, b), nil
+		}
+	}
+	return nil, errorEvaluacion(n.pos, "operador desconocido '%s'", n.op)
+}
+
+// numeroExpresion acepta cualquier número de Go; los textos no se convierten solos
+func numeroExpresion(v interface{}) (float64, bool) {
+	switch x := v.(type) {
+	case float64:
+		return x, true
+	case float32:
+		return float64(x), true
+	case int:
+		return float64(x), true
+	case int64:
+		return float64(x), true
+	case int32:
+		return float64(x), true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+// numeroTexto lee un texto numérico ("2", " 3.5 ")
+func numeroTexto(texto string) (float64, bool) {
+	numero, err := strconv.ParseFloat(strings.TrimSpace(texto), 64)
+	return numero, err == nil
+}
+
+// igualesExpresion compara números por valor (el 3 del flujo es igual al 3.0 de la expresión)
+func igualesExpresion(a, b interface{}) bool {
+	if x, ok := numeroExpresion(a); ok {
+		if y, ok := numeroExpresion(b); ok {
+			return x == y
+		}
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func compararExpresion(pos posicion, op string, izq, der interface{}) (interface{}, error) {
+	var comparacion int
+	a, okA := numeroExpresion(izq)
+	b, okB := numeroExpresion(der)
+	ta, esTextoA := izq.(string)
+	tb, esTextoB := der.(string)
+	fa, esFechaA := izq.(time.Time)
+	fb, esFechaB := der.(time.Time)
+
+	// edad > "18": el texto numérico se compara como número
+	if okA && esTextoB {
+		b, okB = numeroTexto(tb)
+	} else if esTextoA && okB {
+		a, okA = numeroTexto(ta)
+	}
+
+	switch {
+	case okA && okB:
+		comparacion = compararNumeros(a, b)
+	case esTextoA && esTextoB:
+		comparacion = strings.Compare(ta, tb)
+	case esFechaA && esFechaB:
+		comparacion = fa.Compare(fb)
+	default:
+		return nil, errorEvaluacion(pos, "no se puede comparar '%v' %s '%v'", izq, op, der)
+	}
+
+	switch op {
+	case ">":
+		return comparacion > 0, nil
+	case "<":
+		return comparacion < 0, nil
+	case ">=":
+		return comparacion >= 0, nil
+	default:
+		return comparacion <= 0, nil
+	}
+}
+
+func compararNumeros(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// Las regex de =~ se compilan una sola vez; la caché guarda las más usadas
+var regexExpresiones = nuevaCacheAcotada(1024)
+
+func regexExpresion(patron string) (*regexp.Regexp, error) {
+	if re, ok := regexExpresiones.obtener(patron); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(patron)
+	if err != nil {
+		return nil, err
+	}
+	regexExpresiones.guardar(patron, re)
+	return re, nil
+}
@@ -9,205 +9,14 @@ import (
 
 	"backendmotor/internal/functions"
 
-	"github.com/Knetic/govaluate"
 	"github.com/google/uuid"
 )
 
 // EvaluarExpresion evalúa una expresión lógica con soporte de funciones y operadores personalizados
 func EvaluarExpresion(expr string, contexto map[string]interface{}) (bool, error) {
-	// 🔄 Reemplazo textual para operadores lógicos visuales
-	expr = strings.ReplaceAll(expr, " Y ", " AND ")
-	expr = strings.ReplaceAll(expr, " O ", " OR ")
-	expr = strings.ReplaceAll(expr, " y ", " AND ")
-	expr = strings.ReplaceAll(expr, " o ", " OR ")
-	// 🔄 Reemplazo textual para operadores lógicos visuales
-	expr = strings.ReplaceAll(expr, " AND ", " && ")
-	expr = strings.ReplaceAll(expr, " and ", " && ")
-	expr = strings.ReplaceAll(expr, " OR ", " || ")
-	expr = strings.ReplaceAll(expr, " or ", " || ")
-
-	// 🛠️ Asegurar que los operadores básicos estén correctamente representados
-	expr = strings.ReplaceAll(expr, "==", "==")
-	expr = strings.ReplaceAll(expr, "!=", "!=")
-	expr = strings.ReplaceAll(expr, ">=", ">=")
-	expr = strings.ReplaceAll(expr, "<=", "<=")
-	expr = strings.ReplaceAll(expr, ">", ">")
-	expr = strings.ReplaceAll(expr, "<", "<")
-
-	// 🔍 Reemplazo textual para funciones que necesitan paréntesis
-	expr = strings.ReplaceAll(expr, "incluye", "incluye(")
-	expr = strings.ReplaceAll(expr, "empiezaCon", "empiezaCon(")
-	expr = strings.ReplaceAll(expr, "terminaCon", "terminaCon(")
-
-	// 📦 Definición de funciones del sistema
-	funciones := map[string]govaluate.ExpressionFunction{
-		// 📅 FECHA Y HORA
-		"Ahora": func(args ...interface{}) (interface{}, error) {
-			resultado := time.Now().Format("02/01/2006 15:04:05")
-			fmt.Printf("🕐 Función Ahora() ejecutada -> %s\n", resultado)
-			return resultado, nil
-		},
-		"Hoy": func(args ...interface{}) (interface{}, error) {
-			resultado := time.Now().Format("02/01/2006")
-			fmt.Printf("📅 Función Hoy() ejecutada -> %s\n", resultado)
-			return resultado, nil
-		},
-		"DiaSemana": func(args ...interface{}) (interface{}, error) {
-			dia := int(time.Now().Weekday())
-			fmt.Printf("📆 DiaSemana: Go weekday original = %d", dia)
-			if dia == 0 {
-				dia = 7
-			}
-			fmt.Printf(" -> Resultado final = %d\n", dia)
-			return dia, nil
-		},
-
-		"MesActual": func(args ...interface{}) (interface{}, error) {
-			resultado := int(time.Now().Month())
-			fmt.Printf("🗓️  Función MesActual() ejecutada -> %d\n", resultado)
-			return resultado, nil
-		},
-		"AnoActual": func(args ...interface{}) (interface{}, error) {
-			return time.Now().Year(), nil
-		},
-
-		// 👤 USUARIO Y SESIÓN
-		"UsuarioActual": func(args ...interface{}) (interface{}, error) {
-			if val, ok := contexto["__usuario"]; ok {
-				return val, nil
-			}
-			return "", nil
-		},
-		"RolActual": func(args ...interface{}) (interface{}, error) {
-			if val, ok := contexto["__rol"]; ok {
-				return val, nil
-			}
-			return "", nil
-		},
-
-		// ⚙️ SISTEMA
-		"NombreProceso": func(args ...interface{}) (interface{}, error) {
-			if val, ok := contexto["__nombreProceso"]; ok {
-				return val, nil
-			}
-			return "", nil
-		},
-		"IDFlujo": func(args ...interface{}) (interface{}, error) {
-			if val, ok := contexto["__idFlujo"]; ok {
-				return val, nil
-			}
-			return "", nil
-		},
-
-		// 🔄 UTILIDAD
-		"UUID": func(args ...interface{}) (interface{}, error) {
-			return uuid.New().String(), nil
-		},
-		"Random": func(args ...interface{}) (interface{}, error) {
-			return rand.Float64(), nil
-		},
-
-		// 📝 TEXTO
-		"SubTexto": func(args ...interface{}) (interface{}, error) {
-			if len(args) != 3 {
-				return "", fmt.Errorf("SubTexto requiere 3 parámetros")
-			}
-			texto, _ := args[0].(string)
-			inicio, _ := args[1].(float64)
-			longitud, _ := args[2].(float64)
-			r := []rune(texto)
-			ini := int(inicio)
-			lon := int(longitud)
-			if ini < 0 || ini+lon > len(r) {
-				return "", nil
-			}
-			return string(r[ini : ini+lon]), nil
-		},
-		"Longitud": func(args ...interface{}) (interface{}, error) {
-			if len(args) != 1 {
-				return 0, fmt.Errorf("longitud requiere 1 parámetro")
-			}
-			texto, _ := args[0].(string)
-			return len([]rune(texto)), nil
-		},
-		"TextoEnMayusculas": func(args ...interface{}) (interface{}, error) {
-			if len(args) != 1 {
-				return "", fmt.Errorf("TextoEnMayusculas requiere 1 parámetro")
-			}
-			texto, _ := args[0].(string)
-			return strings.ToUpper(texto), nil
-		},
-
-		// 🔍 FUNCIONES TIPO CONTAINS
-		"incluye": func(args ...interface{}) (interface{}, error) {
-			if len(args) != 2 {
-				return false, fmt.Errorf("incluye necesita 2 argumentos")
-			}
-			s, ok1 := args[0].(string)
-			sub, ok2 := args[1].(string)
-			return ok1 && ok2 && strings.Contains(s, sub), nil
-		},
-		"empiezaCon": func(args ...interface{}) (interface{}, error) {
-			if len(args) != 2 {
-				return false, fmt.Errorf("empiezaCon necesita 2 argumentos")
-			}
-			s, ok1 := args[0].(string)
-			prefix, ok2 := args[1].(string)
-			return ok1 && ok2 && strings.HasPrefix(s, prefix), nil
-		},
-		"terminaCon": func(args ...interface{}) (interface{}, error) {
-			if len(args) != 2 {
-				return false, fmt.Errorf("terminaCon necesita 2 argumentos")
-			}
-			s, ok1 := args[0].(string)
-			suffix, ok2 := args[1].(string)
-			return ok1 && ok2 && strings.HasSuffix(s, suffix), nil
-		},
-
-		// 🗃️ FUNCIÓN TABLA (NUEVA FUNCIONALIDAD)
-		// Uso: TablaValor("nombre_tabla", "clave", "campo")
-		// Ejemplo: TablaValor("Estados", "01", "Descripcion") == "Activo"
-		"TablaValor": func(args ...interface{}) (interface{}, error) {
-			if len(args) != 3 {
-				return nil, fmt.Errorf("TablaValor requiere exactamente 3 argumentos: nombre_tabla, clave, campo")
-			}
-
-			// Validar tipos de argumentos
-			nombreTabla, ok1 := args[0].(string)
-			if !ok1 {
-				return nil, fmt.Errorf("primer argumento de TablaValor (nombre_tabla) debe ser string")
-			}
-
-			claveOriginal, ok2 := args[1].(string)
-			if !ok2 {
-				return nil, fmt.Errorf("segundo argumento de TablaValor (clave) debe ser string")
-			}
-
-			campo, ok3 := args[2].(string)
-			if !ok3 {
-				return nil, fmt.Errorf("tercer argumento de TablaValor (campo) debe ser string")
-			}
-
-			// Usar el resolver para obtener el valor
-			resolver := functions.NewResolver(contexto)
-			valor, err := resolver.ResolverTablaConClaveVariable(nombreTabla, claveOriginal, campo)
-			if err != nil {
-				return nil, fmt.Errorf("error en TablaValor: %w", err)
-			}
-
-			return valor, nil
-		},
-	}
-
-	// ⚙️ Compilar y evaluar la expresión
-	expresion, err := govaluate.NewEvaluableExpressionWithFunctions(expr, funciones)
-	if err != nil {
-		return false, fmt.Errorf("error al compilar expresión: %w", err)
-	}
-
-	resultado, err := expresion.Evaluate(contexto)
+	resultado, err := EvaluarValor(expr, contexto)
 	if err != nil {
-		return false, fmt.Errorf("error al evaluar expresión: %w", err)
+		return false, err
 	}
 
 	booleano, ok := resultado.(bool)
@@ -218,6 +27,18 @@ func EvaluarExpresion(expr string, contexto map[string]interface{}) (bool, error
 	return booleano, nil
 }
 
+// EvaluarValor evalúa una expresión con las mismas funciones y operadores que EvaluarExpresion,
+// pero devuelve el valor tal cual (texto, número, booleano, fecha, objeto o arreglo); la usan el
+// nodo switch, las plantillas y las asignaciones de tipo sistema/funcion
+func EvaluarValor(expr string, contexto map[string]interface{}) (interface{}, error) {
+	// ⚙️ Parsear (una vez por texto) y evaluar; los errores de sintaxis traen línea y columna
+	compilada, err := compilarConCache(expr)
+	if err != nil {
+		return nil, fmt.Errorf("error al compilar expresión: %w", err)
+	}
+	return compilada.Evaluar(contexto)
+}
+
 // Campo representa los campos definidos en el nodo
 type Campo struct {
 	Nombre string `json:"nombre"`
@@ -277,12 +98,28 @@ func GenerarUUID() interface{} {
 	return uuid.New().String()
 }
 
-// EjecutarFuncionSistema ejecuta una función del sistema individual (para usar en nodos)
+// EjecutarFuncionSistema resuelve las asignaciones de tipo sistema/funcion: acepta el nombre de
+// una función ("Ahora" o "Ahora()") o cualquier expresión con argumentos y operadores
 func EjecutarFuncionSistema(funcion string, contexto map[string]interface{}) (interface{}, error) {
 	fmt.Printf("🚀 [utils/expresiones.go] Ejecutando función: %s\n", funcion)
 	
-	// Reutilizar las funciones ya definidas en el map de EvaluarExpresion
-	funciones := map[string]govaluate.ExpressionFunction{
+	// Compatibilidad: el nombre solo ("Ahora" o "Ahora()") llama directo a la función
+	nombreFuncion := strings.TrimSuffix(strings.TrimSpace(funcion), "()")
+	if funcionImpl, existe := funcionesSistema(contexto)[nombreFuncion]; existe {
+		return funcionImpl()
+	}
+
+	// Cualquier otra cosa es una expresión completa: SubTexto(cuenta, 0, 4) + "-" + TextoEnMayusculas(nombre)
+	return EvaluarValor(funcion, contexto)
+}
+
+// FuncionExpresion es una función que se puede llamar desde las expresiones
+type FuncionExpresion func(args ...interface{}) (interface{}, error)
+
+// funcionesSistema son las funciones disponibles en condiciones y asignaciones; las que leen
+// __usuario, __rol, etc. los toman del contexto de la evaluación
+func funcionesSistema(contexto map[string]interface{}) map[string]FuncionExpresion {
+	funciones := map[string]FuncionExpresion{
 		// 📅 FECHA Y HORA
 		"Ahora": func(args ...interface{}) (interface{}, error) {
 			resultado := time.Now().Format("02/01/2006 15:04:05")
@@ -303,63 +140,50 @@ func EjecutarFuncionSistema(funcion string, contexto map[string]interface{}) (in
 			fmt.Printf(" -> Resultado final = %d\n", dia)
 			return dia, nil
 		},
+
 		"MesActual": func(args ...interface{}) (interface{}, error) {
 			resultado := int(time.Now().Month())
 			fmt.Printf("🗓️  Función MesActual() ejecutada -> %d\n", resultado)
 			return resultado, nil
 		},
 		"AnoActual": func(args ...interface{}) (interface{}, error) {
-			resultado := time.Now().Year()
-			fmt.Printf("📆 Función AnoActual() ejecutada -> %d\n", resultado)
-			return resultado, nil
+			return time.Now().Year(), nil
 		},
 
 		// 👤 USUARIO Y SESIÓN
 		"UsuarioActual": func(args ...interface{}) (interface{}, error) {
 			if val, ok := contexto["__usuario"]; ok {
-				fmt.Printf("👤 Función UsuarioActual() ejecutada -> %v\n", val)
 				return val, nil
 			}
-			fmt.Printf("👤 Función UsuarioActual() ejecutada -> (vacío)\n")
 			return "", nil
 		},
 		"RolActual": func(args ...interface{}) (interface{}, error) {
 			if val, ok := contexto["__rol"]; ok {
-				fmt.Printf("🔐 Función RolActual() ejecutada -> %v\n", val)
 				return val, nil
 			}
-			fmt.Printf("🔐 Función RolActual() ejecutada -> (vacío)\n")
 			return "", nil
 		},
 
 		// ⚙️ SISTEMA
 		"NombreProceso": func(args ...interface{}) (interface{}, error) {
 			if val, ok := contexto["__nombreProceso"]; ok {
-				fmt.Printf("⚙️ Función NombreProceso() ejecutada -> %v\n", val)
 				return val, nil
 			}
-			fmt.Printf("⚙️ Función NombreProceso() ejecutada -> (vacío)\n")
 			return "", nil
 		},
 		"IDFlujo": func(args ...interface{}) (interface{}, error) {
 			if val, ok := contexto["__idFlujo"]; ok {
-				fmt.Printf("🆔 Función IDFlujo() ejecutada -> %v\n", val)
 				return val, nil
 			}
-			fmt.Printf("🆔 Función IDFlujo() ejecutada -> (vacío)\n")
 			return "", nil
 		},
 
 		// 🔄 UTILIDAD
 		"UUID": func(args ...interface{}) (interface{}, error) {
-			resultado := uuid.New().String()
-			fmt.Printf("🆔 Función UUID() ejecutada -> %s\n", resultado)
-			return resultado, nil
+			return uuid.New().String(), nil
 		},
 		"Random": func(args ...interface{}) (interface{}, error) {
-			resultado := rand.Float64()
-			fmt.Printf("🎲 Función Random() ejecutada -> %f\n", resultado)
-			return resultado, nil
+			return rand.Float64(), nil
 		},
 
 		// 📝 TEXTO
@@ -367,36 +191,25 @@ func EjecutarFuncionSistema(funcion string, contexto map[string]interface{}) (in
 			if len(args) != 3 {
 				return "", fmt.Errorf("SubTexto requiere 3 parámetros")
 			}
-			texto, _ := args[0].(string)
-			inicio, _ := args[1].(float64)
-			longitud, _ := args[2].(float64)
-			r := []rune(texto)
-			ini := int(inicio)
-			lon := int(longitud)
+			r := []rune(textoArgumento(args[0]))
+			ini := enteroArgumento(args[1])
+			lon := enteroArgumento(args[2])
 			if ini < 0 || ini+lon > len(r) {
 				return "", nil
 			}
-			resultado := string(r[ini : ini+lon])
-			fmt.Printf("✂️ Función SubTexto() ejecutada -> %s\n", resultado)
-			return resultado, nil
+			return string(r[ini : ini+lon]), nil
 		},
 		"Longitud": func(args ...interface{}) (interface{}, error) {
 			if len(args) != 1 {
 				return 0, fmt.Errorf("longitud requiere 1 parámetro")
 			}
-			texto, _ := args[0].(string)
-			resultado := len([]rune(texto))
-			fmt.Printf("📏 Función Longitud() ejecutada -> %d\n", resultado)
-			return resultado, nil
+			return len([]rune(textoArgumento(args[0]))), nil
 		},
 		"TextoEnMayusculas": func(args ...interface{}) (interface{}, error) {
 			if len(args) != 1 {
 				return "", fmt.Errorf("TextoEnMayusculas requiere 1 parámetro")
 			}
-			texto, _ := args[0].(string)
-			resultado := strings.ToUpper(texto)
-			fmt.Printf("🔤 Función TextoEnMayusculas() ejecutada -> %s\n", resultado)
-			return resultado, nil
+			return strings.ToUpper(textoArgumento(args[0])), nil
 		},
 
 		// 🔍 FUNCIONES TIPO CONTAINS
@@ -406,9 +219,7 @@ func EjecutarFuncionSistema(funcion string, contexto map[string]interface{}) (in
 			}
 			s, ok1 := args[0].(string)
 			sub, ok2 := args[1].(string)
-			resultado := ok1 && ok2 && strings.Contains(s, sub)
-			fmt.Printf("🔍 Función incluye() ejecutada -> %v\n", resultado)
-			return resultado, nil
+			return ok1 && ok2 && strings.Contains(s, sub), nil
 		},
 		"empiezaCon": func(args ...interface{}) (interface{}, error) {
 			if len(args) != 2 {
@@ -416,9 +227,7 @@ func EjecutarFuncionSistema(funcion string, contexto map[string]interface{}) (in
 			}
 			s, ok1 := args[0].(string)
 			prefix, ok2 := args[1].(string)
-			resultado := ok1 && ok2 && strings.HasPrefix(s, prefix)
-			fmt.Printf("🔍 Función empiezaCon() ejecutada -> %v\n", resultado)
-			return resultado, nil
+			return ok1 && ok2 && strings.HasPrefix(s, prefix), nil
 		},
 		"terminaCon": func(args ...interface{}) (interface{}, error) {
 			if len(args) != 2 {
@@ -426,17 +235,18 @@ func EjecutarFuncionSistema(funcion string, contexto map[string]interface{}) (in
 			}
 			s, ok1 := args[0].(string)
 			suffix, ok2 := args[1].(string)
-			resultado := ok1 && ok2 && strings.HasSuffix(s, suffix)
-			fmt.Printf("🔍 Función terminaCon() ejecutada -> %v\n", resultado)
-			return resultado, nil
+			return ok1 && ok2 && strings.HasSuffix(s, suffix), nil
 		},
 
-		// 🗃️ FUNCIÓN TABLA
+		// 🗃️ FUNCIÓN TABLA (NUEVA FUNCIONALIDAD)
+		// Uso: TablaValor("nombre_tabla", "clave", "campo")
+		// Ejemplo: TablaValor("Estados", "01", "Descripcion") == "Activo"
 		"TablaValor": func(args ...interface{}) (interface{}, error) {
 			if len(args) != 3 {
 				return nil, fmt.Errorf("TablaValor requiere exactamente 3 argumentos: nombre_tabla, clave, campo")
 			}
 
+			// Validar tipos de argumentos
 			nombreTabla, ok1 := args[0].(string)
 			if !ok1 {
 				return nil, fmt.Errorf("primer argumento de TablaValor (nombre_tabla) debe ser string")
@@ -452,22 +262,64 @@ func EjecutarFuncionSistema(funcion string, contexto map[string]interface{}) (in
 				return nil, fmt.Errorf("tercer argumento de TablaValor (campo) debe ser string")
 			}
 
+			// Usar el resolver para obtener el valor
 			resolver := functions.NewResolver(contexto)
 			valor, err := resolver.ResolverTablaConClaveVariable(nombreTabla, claveOriginal, campo)
 			if err != nil {
 				return nil, fmt.Errorf("error en TablaValor: %w", err)
 			}
-			fmt.Printf("🗃️ Función TablaValor() ejecutada -> %v\n", valor)
+
 			return valor, nil
 		},
 	}
 
-	// Buscar la función sin paréntesis
-	nombreFuncion := strings.TrimSuffix(funcion, "()")
-	if funcionImpl, existe := funciones[nombreFuncion]; existe {
-		// Ejecutar la función (sin parámetros para funciones simples)
-		return funcionImpl()
+	// Los números de las expresiones son float64: los enteros que devuelven las funciones se
+	// entregan igual, así DiaSemana() vale lo mismo en una condición que en una asignación
+	for nombre, funcion := range funciones {
+		funcion := funcion
+		funciones[nombre] = func(args ...interface{}) (interface{}, error) {
+			resultado, err := funcion(args...)
+			if entero, ok := resultado.(int); ok {
+				return float64(entero), err
+			}
+			return resultado, err
+		}
+	}
+	return funciones
+}
+
+// textoArgumento convierte un argumento a texto; los números enteros quedan sin decimales para
+// que SubTexto(cuenta, 0, 4) funcione aunque cuenta venga como número
+func textoArgumento(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(x), 'f', -1, 32)
+	case time.Time:
+		return x.Format("02/01/2006 15:04:05")
 	}
+	return fmt.Sprint(v)
+}
 
-	return nil, fmt.Errorf("función del sistema no encontrada: %s", funcion)
+// enteroArgumento convierte un argumento numérico (float64 de la expresión, int del contexto o texto)
+func enteroArgumento(v interface{}) int {
+	switch x := v.(type) {
+	case float64:
+		return int(x)
+	case float32:
+		return int(x)
+	case int:
+		return x
+	case int64:
+		return int(x)
+	case string:
+		n, _ := strconv.Atoi(strings.TrimSpace(x))
+		return n
+	}
+	return 0
 }
@@ -0,0 +1,139 @@
+package utils
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestEvaluarValor(t *testing.T) {
+	contexto := map[string]interface{}{
+		"descripcion":  "Y O",
+		"incluyeIVA":   true,
+		"estado":       "A",
+		"edad":         20,
+		"monto":        "150.5",
+		"no":           1,
+		"estados":      []interface{}{"A", "B"},
+		"cliente":      map[string]interface{}{"nombre": "Ana", "tags": []interface{}{"vip"}},
+		"nodo_3":       map[string]interface{}{"items": []interface{}{1.0, 2.0}},
+		"codigo":       "01",
+		"codigoNumero": int64(1),
+	}
+
+	casos := []struct {
+		expr     string
+		esperado interface{}
+	}{
+		// Los textos y los nombres parecidos a palabras clave no se tocan
+		{`descripcion == "Y O"`, true},
+		{`descripcion == "Y O" Y incluyeIVA`, true},
+		{`incluyeIVA`, true},
+		{`no == 1`, true},
+		{`[descripcion] incluye "O"`, true},
+
+		// Operadores en español y en inglés
+		{`edad > 18 Y estado == "A"`, true},
+		{`edad > 18 AND estado == "B"`, false},
+		{`edad < 18 O estado == "A"`, true},
+		{`edad < 18 or estado == "B"`, false},
+		{`NO incluyeIVA`, false},
+		{`not (edad < 18)`, true},
+		{`cliente.nombre startsWith "A"`, true},
+		{`cliente.nombre.includes("n")`, true},
+
+		// Sintaxis de govaluate que usan los flujos guardados
+		{`edad > 18 ? "mayor" : "menor"`, "mayor"},
+		{`edad < 18 ? 1 : edad < 30 ? 2 : 3`, 2.0},
+		{`edad < 18 ? 1`, nil},
+		{`estado in ("A")`, true},
+		{`estado IN ("B", "C")`, false},
+		{`estado in estados`, true},
+		{`edad in (10, 20)`, true},
+		{`sinDefinir ?? "x"`, "x"},
+		{`estado ?? "x"`, "A"},
+
+		// Texto contra texto se compara como texto, igual que en govaluate
+		{`codigo == "01"`, true},
+		{`codigo == "1"`, false},
+		{`codigo < "1"`, true},
+		{`"10" < "9"`, true},
+		{`"150" < monto`, true},
+
+		// Un número nunca es igual a un texto, aunque el texto sea numérico
+		{`codigo == 1`, false},
+		{`codigoNumero == "01"`, false},
+
+		// Diferencias con govaluate: cualquier número se compara por valor y, al ordenar, un
+		// texto numérico frente a un número se lee como número
+		{`Longitud("abc") == 3`, true},
+		{`codigoNumero == 1.0`, true},
+		{`edad > "2"`, true},
+		{`Longitud("abc") > "2"`, true},
+		{`codigo > 0`, true},
+
+		// Resultados tipados
+		{`edad * 2`, 40.0},
+		{`"a" + 1`, "a1"},
+		{`cliente.tags[0]`, "vip"},
+		{`nodo_3.items[1]`, 2.0},
+		{`cliente.nombre`, "Ana"},
+		{`cliente.inexistente`, nil},
+	}
+
+	for _, c := range casos {
+		t.Run(c.expr, func(t *testing.T) {
+			obtenido, err := EvaluarValor(c.expr, contexto)
+			if err != nil {
+				t.Fatalf("error inesperado: %v", err)
+			}
+			if !reflect.DeepEqual(obtenido, c.esperado) {
+				t.Fatalf("se esperaba %#v y se obtuvo %#v", c.esperado, obtenido)
+			}
+		})
+	}
+}
+
+func TestCompilarExpresionErrores(t *testing.T) {
+	casos := []struct {
+		expr    string
+		linea   int
+		columna int
+	}{
+		{``, 1, 1},
+		{`a = 1`, 1, 3},
+		{`a == "abc`, 1, 6},
+		{"a == 1 Y\n  (b > ", 2, 8},
+		{`a == 1 b`, 1, 8},
+		{`Inexistente(1)`, 1, 1},
+		{`a ? 1 : `, 1, 9},
+	}
+
+	for _, c := range casos {
+		t.Run(c.expr, func(t *testing.T) {
+			_, err := CompilarExpresion(c.expr)
+			var errSintaxis *ErrorSintaxis
+			if !errors.As(err, &errSintaxis) {
+				t.Fatalf("se esperaba un ErrorSintaxis y se obtuvo %v", err)
+			}
+			if errSintaxis.Linea != c.linea || errSintaxis.Columna != c.columna {
+				t.Fatalf("se esperaba línea %d, columna %d y se obtuvo %v", c.linea, c.columna, errSintaxis)
+			}
+		})
+	}
+}
+
+func TestEvaluarValorErrores(t *testing.T) {
+	contexto := map[string]interface{}{"edad": 20, "nombre": "Ana"}
+	for _, expr := range []string{
+		`sinDefinir == 1`,
+		`edad > "abc"`,
+		`edad ? 1 : 2`,
+		`edad / 0`,
+		`!nombre`,
+	} {
+		if _, err := EvaluarValor(expr, contexto); err == nil {
+			t.Errorf("%s: se esperaba un error", expr)
+		}
+	}
+}
@@ -23,6 +23,7 @@ type RegistroEjecucion struct {
 	DetalleError  string                 `json:"detalleError,omitempty"`
 	Asignaciones  map[string]interface{} `json:"asignaciones,omitempty"` // lo que se usó para invocar
 	Salidas       map[string]interface{} `json:"salidas,omitempty"`      // lo que salió (del nodo salida)
+	TraceID       string                 `json:"traceId,omitempty"`      // agrupa todo el árbol de subprocesos
 }
 
 func RegistrarEjecucionLog(registro RegistroEjecucion) error {
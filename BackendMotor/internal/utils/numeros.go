@@ -0,0 +1,23 @@
+package utils
+
+import (
+	"strconv"
+	"strings"
+)
+
+// NumeroConfig lee un número de una configuración JSON (float64, int o texto), como las
+// políticas de los nodos o Servidor.Extras
+func NumeroConfig(v interface{}) (float64, bool) {
+	switch t := v.(type) {
+	case float64:
+		return t, true
+	case int:
+		return float64(t), true
+	case int64:
+		return float64(t), true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(t), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
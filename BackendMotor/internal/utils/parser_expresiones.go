@@ -0,0 +1,798 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Lenguaje de expresiones de DIV (condiciones, switch, plantillas y asignaciones sistema/funcion).
+//
+//	expresion   := coalescer [ "?" expresion [ ":" expresion ] ]
+//	coalescer   := o { "??" o }
+//	o           := y { ("||" | "O" | "o" | "OR" | "or") y }
+//	y           := negacion { ("&&" | "Y" | "y" | "AND" | "and") negacion }
+//	negacion    := ("!" | "NO" | "no" | "NOT" | "not") negacion | comparacion
+//	comparacion := suma [ ("==" | "!=" | ">" | "<" | ">=" | "<=" | "=~" | "!~" |
+//	                       "incluye" | "empiezaCon" | "terminaCon") suma
+//	                     | ("in" | "IN") ( "(" argumentos ")" | suma ) ]
+//	suma        := producto { ("+" | "-") producto }
+//	producto    := unario { ("*" | "/" | "%") unario }
+//	unario      := "-" unario | postfijo
+//	postfijo    := primario { "." nombre [ "(" argumentos ")" ] | "[" expresion "]" }
+//	primario    := numero | texto | verdadero | falso | nulo | nombre [ "(" argumentos ")" ]
+//	             | "[" nombre con espacios "]" | "(" expresion ")"
+//
+// Las palabras clave solo son operadores en la posición que les corresponde: una variable puede
+// llamarse incluyeIVA, o, y, no... y un texto "Y O" nunca se toca. x.incluye("a") equivale a
+// incluye(x, "a"), igual que x.includes('a') como lo genera el editor de condiciones.
+// El ternario, el ?? y el in son los de govaluate, con los que se guardaron los flujos anteriores
+
+// ErrorSintaxis es un error de la expresión con su posición (línea y columna desde 1)
+type ErrorSintaxis struct {
+	Linea   int    `json:"linea"`
+	Columna int    `json:"columna"`
+	Mensaje string `json:"mensaje"`
+}
+
+func (e *ErrorSintaxis) Error() string {
+	return fmt.Sprintf("línea %d, columna %d: %s", e.Linea, e.Columna, e.Mensaje)
+}
+
+// posicion es el lugar de un token o nodo dentro del texto original
+type posicion struct {
+	Linea   int
+	Columna int
+}
+
+type tipoToken int
+
+const (
+	tokFin tipoToken = iota
+	tokNumero
+	tokTexto
+	tokNombre
+	tokNombreCorchetes // [variable con espacios]
+	tokOperador
+)
+
+type token struct {
+	Tipo  tipoToken
+	Texto string
+	Valor interface{}
+	Pos   posicion
+}
+
+// aliasFunciones traduce los nombres en inglés (o los que genera el editor) a las funciones del sistema
+var aliasFunciones = map[string]string{
+	"contiene":   "incluye",
+	"contains":   "incluye",
+	"includes":   "incluye",
+	"startsWith": "empiezaCon",
+	"endsWith":   "terminaCon",
+}
+
+var (
+	palabrasO        = map[string]bool{"O": true, "o": true, "OR": true, "or": true}
+	palabrasY        = map[string]bool{"Y": true, "y": true, "AND": true, "and": true}
+	palabrasNo       = map[string]bool{"NO": true, "no": true, "NOT": true, "not": true}
+	palabrasVerdad   = map[string]bool{"verdadero": true, "true": true}
+	palabrasFalso    = map[string]bool{"falso": true, "false": true}
+	palabrasNulo     = map[string]bool{"nulo": true, "null": true, "nil": true}
+	palabrasEn       = map[string]bool{"in": true, "IN": true}
+	operadoresTexto  = map[string]bool{"incluye": true, "empiezaCon": true, "terminaCon": true}
+	operadoresSimbol = []string{"==", "!=", ">=", "<=", "=~", "!~", "&&", "||", "??", "?", ":", ">", "<", "+", "-", "*", "/", "%", "!", "(", ")", ",", ".", "[", "]"}
+)
+
+// Nombres de las funciones del sistema, para avisar de funciones desconocidas al parsear
+var (
+	nombresFunciones     map[string]bool
+	nombresFuncionesOnce sync.Once
+)
+
+func esFuncionSistema(nombre string) bool {
+	nombresFuncionesOnce.Do(func() {
+		nombresFunciones = make(map[string]bool)
+		for nombre := range funcionesSistema(nil) {
+			nombresFunciones[nombre] = true
+		}
+	})
+	return nombresFunciones[nombre]
+}
+
+// funcionConAlias devuelve el nombre real de una función (resolviendo alias) y si existe
+func funcionConAlias(nombre string) (string, bool) {
+	if real, ok := aliasFunciones[nombre]; ok {
+		nombre = real
+	}
+	return nombre, esFuncionSistema(nombre)
+}
+
+// ExpresionCompilada es una expresión ya parseada, lista para evaluarse muchas veces
+type ExpresionCompilada struct {
+	Texto     string
+	raiz      nodoExpresion
+	variables []string
+	funciones []string
+}
+
+// Variables devuelve las variables y rutas que lee la expresión, sin repetir y en orden
+func (c *ExpresionCompilada) Variables() []string {
+	return append([]string(nil), c.variables...)
+}
+
+// Funciones devuelve las funciones del sistema que llama la expresión
+func (c *ExpresionCompilada) Funciones() []string {
+	return append([]string(nil), c.funciones...)
+}
+
+// CompilarExpresion tokeniza y parsea una expresión; los errores son *ErrorSintaxis
+func CompilarExpresion(expr string) (*ExpresionCompilada, error) {
+	tokens, err := tokenizarExpresion(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &parserExpresion{tokens: tokens}
+	if p.actual().Tipo == tokFin {
+		return nil, &ErrorSintaxis{Linea: 1, Columna: 1, Mensaje: "la expresión está vacía"}
+	}
+	raiz, err := p.expresion()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.actual(); t.Tipo != tokFin {
+		return nil, p.errorEn(t, fmt.Sprintf("se esperaba un operador y se encontró '%s'", t.Texto))
+	}
+	compilada := &ExpresionCompilada{Texto: expr, raiz: raiz}
+	vistas, funciones := map[string]bool{}, map[string]bool{}
+	recorrerExpresion(raiz, func(n nodoExpresion) {
+		switch x := n.(type) {
+		case *nodoVariable:
+			if !vistas[x.ruta] {
+				vistas[x.ruta] = true
+				compilada.variables = append(compilada.variables, x.ruta)
+			}
+		case *nodoLlamada:
+			funciones[x.nombre] = true
+		}
+	})
+	compilada.funciones = ordenadas(funciones)
+	return compilada, nil
+}
+
+// Las expresiones de los flujos se repiten en cada ejecución; se parsean una sola vez. La caché
+// tiene tope porque las expresiones armadas con datos de cada request no se repiten nunca
+var expresionesCompiladas = nuevaCacheAcotada(4096)
+
+func compilarConCache(expr string) (*ExpresionCompilada, error) {
+	if c, ok := expresionesCompiladas.obtener(expr); ok {
+		return c.(*ExpresionCompilada), nil
+	}
+	c, err := CompilarExpresion(expr)
+	if err != nil {
+		return nil, err
+	}
+	expresionesCompiladas.guardar(expr, c)
+	return c, nil
+}
+
+func ordenadas(conjunto map[string]bool) []string {
+	lista := make([]string, 0, len(conjunto))
+	for k := range conjunto {
+		lista = append(lista, k)
+	}
+	sort.Strings(lista)
+	return lista
+}
+
+// tokenizarExpresion separa la expresión en números, textos, nombres y operadores
+func tokenizarExpresion(expr string) ([]token, error) {
+	r := []rune(expr)
+	var tokens []token
+	linea, columna := 1, 1
+	i := 0
+
+	avanzar := func(n int) {
+		for k := 0; k < n && i < len(r); k++ {
+			if r[i] == '\n' {
+				linea++
+				columna = 1
+			} else {
+				columna++
+			}
+			i++
+		}
+	}
+	// Un "[" después de un operando es un índice; en cualquier otro lugar es un nombre entre corchetes
+	despuesDeOperando := func() bool {
+		if len(tokens) == 0 {
+			return false
+		}
+		ultimo := tokens[len(tokens)-1]
+		switch ultimo.Tipo {
+		case tokNombre:
+			// Después de "Y", "incluye"... viene un operando nuevo, no un índice
+			funcion, _ := funcionConAlias(ultimo.Texto)
+			return !palabrasO[ultimo.Texto] && !palabrasY[ultimo.Texto] && !palabrasNo[ultimo.Texto] && !palabrasEn[ultimo.Texto] && !operadoresTexto[funcion]
+		case tokNumero, tokTexto, tokNombreCorchetes:
+			return true
+		case tokOperador:
+			return ultimo.Texto == ")" || ultimo.Texto == "]"
+		}
+		return false
+	}
+
+	for i < len(r) {
+		c := r[i]
+		pos := posicion{Linea: linea, Columna: columna}
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			avanzar(1)
+
+		case c == '"' || c == '\'':
+			var sb strings.Builder
+			fin := i + 1
+			cerrado := false
+			for fin < len(r) {
+				if r[fin] == c {
+					cerrado = true
+					break
+				}
+				if r[fin] == '\\' && fin+1 < len(r) {
+					fin++
+					switch r[fin] {
+					case 'n':
+						sb.WriteRune('\n')
+					case 't':
+						sb.WriteRune('\t')
+					case 'r':
+						sb.WriteRune('\r')
+					default:
+						sb.WriteRune(r[fin])
+					}
+					fin++
+					continue
+				}
+				sb.WriteRune(r[fin])
+				fin++
+			}
+			if !cerrado {
+				return nil, &ErrorSintaxis{Linea: pos.Linea, Columna: pos.Columna, Mensaje: "texto sin cerrar: falta " + string(c)}
+			}
+			tokens = append(tokens, token{Tipo: tokTexto, Texto: string(r[i : fin+1]), Valor: sb.String(), Pos: pos})
+			avanzar(fin + 1 - i)
+
+		case c >= '0' && c <= '9':
+			fin := i
+			for fin < len(r) && r[fin] >= '0' && r[fin] <= '9' {
+				fin++
+			}
+			if fin+1 < len(r) && r[fin] == '.' && r[fin+1] >= '0' && r[fin+1] <= '9' {
+				fin++
+				for fin < len(r) && r[fin] >= '0' && r[fin] <= '9' {
+					fin++
+				}
+			}
+			texto := string(r[i:fin])
+			numero, err := strconv.ParseFloat(texto, 64)
+			if err != nil {
+				return nil, &ErrorSintaxis{Linea: pos.Linea, Columna: pos.Columna, Mensaje: fmt.Sprintf("número inválido '%s'", texto)}
+			}
+			tokens = append(tokens, token{Tipo: tokNumero, Texto: texto, Valor: numero, Pos: pos})
+			avanzar(fin - i)
+
+		case esInicioIdentificador(c):
+			fin := i
+			for fin < len(r) && esParteIdentificador(r[fin]) {
+				fin++
+			}
+			tokens = append(tokens, token{Tipo: tokNombre, Texto: string(r[i:fin]), Pos: pos})
+			avanzar(fin - i)
+
+		case c == '[' && !despuesDeOperando():
+			fin := i + 1
+			for fin < len(r) && r[fin] != ']' {
+				fin++
+			}
+			if fin >= len(r) {
+				return nil, &ErrorSintaxis{Linea: pos.Linea, Columna: pos.Columna, Mensaje: "falta ']'"}
+			}
+			nombre := strings.TrimSpace(string(r[i+1 : fin]))
+			if nombre == "" {
+				return nil, &ErrorSintaxis{Linea: pos.Linea, Columna: pos.Columna, Mensaje: "nombre vacío entre corchetes"}
+			}
+			tokens = append(tokens, token{Tipo: tokNombreCorchetes, Texto: nombre, Pos: pos})
+			avanzar(fin + 1 - i)
+
+		default:
+			operador := ""
+			for _, op := range operadoresSimbol {
+				if strings.HasPrefix(string(r[i:min(i+len(op), len(r))]), op) {
+					operador = op
+					break
+				}
+			}
+			if operador == "" {
+				mensaje := fmt.Sprintf("carácter inesperado '%c'", c)
+				switch c {
+				case '=':
+					mensaje = "operador '=' inválido, para comparar use '=='"
+				case '&':
+					mensaje = "operador '&' inválido, use '&&' o Y"
+				case '|':
+					mensaje = "operador '|' inválido, use '||' u O"
+				}
+				return nil, &ErrorSintaxis{Linea: pos.Linea, Columna: pos.Columna, Mensaje: mensaje}
+			}
+			tokens = append(tokens, token{Tipo: tokOperador, Texto: operador, Pos: pos})
+			avanzar(len([]rune(operador)))
+		}
+	}
+
+	tokens = append(tokens, token{Tipo: tokFin, Texto: "fin de la expresión", Pos: posicion{Linea: linea, Columna: columna}})
+	return tokens, nil
+}
+
+// parserExpresion es un parser descendente recursivo sobre los tokens
+type parserExpresion struct {
+	tokens []token
+	i      int
+}
+
+func (p *parserExpresion) actual() token {
+	return p.tokens[p.i]
+}
+
+func (p *parserExpresion) siguiente() token {
+	if p.i+1 < len(p.tokens) {
+		return p.tokens[p.i+1]
+	}
+	return p.tokens[len(p.tokens)-1]
+}
+
+func (p *parserExpresion) consumir() token {
+	t := p.tokens[p.i]
+	if t.Tipo != tokFin {
+		p.i++
+	}
+	return t
+}
+
+func (p *parserExpresion) esOperador(texto string) bool {
+	t := p.actual()
+	return t.Tipo == tokOperador && t.Texto == texto
+}
+
+func (p *parserExpresion) esPalabra(palabras map[string]bool) bool {
+	t := p.actual()
+	return t.Tipo == tokNombre && palabras[t.Texto]
+}
+
+func (p *parserExpresion) errorEn(t token, mensaje string) *ErrorSintaxis {
+	return &ErrorSintaxis{Linea: t.Pos.Linea, Columna: t.Pos.Columna, Mensaje: mensaje}
+}
+
+func (p *parserExpresion) esperar(texto string) (token, error) {
+	t := p.actual()
+	if t.Tipo != tokOperador || t.Texto != texto {
+		return t, p.errorEn(t, fmt.Sprintf("se esperaba '%s' y se encontró '%s'", texto, t.Texto))
+	}
+	return p.consumir(), nil
+}
+
+func (p *parserExpresion) expresion() (nodoExpresion, error) {
+	condicion, err := p.coalescer()
+	if err != nil {
+		return nil, err
+	}
+	if !p.esOperador("?") {
+		return condicion, nil
+	}
+
+	// a ? b : c; sin ":" vale nulo cuando la condición es falsa, como en govaluate
+	t := p.consumir()
+	si, err := p.expresion()
+	if err != nil {
+		return nil, err
+	}
+	var sino nodoExpresion = &nodoLiteral{pos: t.Pos, valor: nil}
+	if p.esOperador(":") {
+		p.consumir()
+		if sino, err = p.expresion(); err != nil {
+			return nil, err
+		}
+	}
+	return &nodoTernario{pos: t.Pos, condicion: condicion, si: si, sino: sino}, nil
+}
+
+func (p *parserExpresion) coalescer() (nodoExpresion, error) {
+	izq, err := p.o()
+	if err != nil {
+		return nil, err
+	}
+	for p.esOperador("??") {
+		t := p.consumir()
+		der, err := p.o()
+		if err != nil {
+			return nil, err
+		}
+		izq = &nodoBinario{pos: t.Pos, op: "??", izq: izq, der: der}
+	}
+	return izq, nil
+}
+
+func (p *parserExpresion) o() (nodoExpresion, error) {
+	izq, err := p.y()
+	if err != nil {
+		return nil, err
+	}
+	for p.esOperador("||") || p.esPalabra(palabrasO) {
+		t := p.consumir()
+		der, err := p.y()
+		if err != nil {
+			return nil, err
+		}
+		izq = &nodoBinario{pos: t.Pos, op: "||", izq: izq, der: der}
+	}
+	return izq, nil
+}
+
+func (p *parserExpresion) y() (nodoExpresion, error) {
+	izq, err := p.negacion()
+	if err != nil {
+		return nil, err
+	}
+	for p.esOperador("&&") || p.esPalabra(palabrasY) {
+		t := p.consumir()
+		der, err := p.negacion()
+		if err != nil {
+			return nil, err
+		}
+		izq = &nodoBinario{pos: t.Pos, op: "&&", izq: izq, der: der}
+	}
+	return izq, nil
+}
+
+func (p *parserExpresion) negacion() (nodoExpresion, error) {
+	// NO/no solo niegan si les sigue un operando; "no == 1" compara una variable llamada no
+	if p.esOperador("!") || (p.esPalabra(palabrasNo) && iniciaOperando(p.siguiente())) {
+		t := p.consumir()
+		operando, err := p.negacion()
+		if err != nil {
+			return nil, err
+		}
+		return &nodoUnario{pos: t.Pos, op: "!", operando: operando}, nil
+	}
+	return p.comparacion()
+}
+
+func (p *parserExpresion) comparacion() (nodoExpresion, error) {
+	izq, err := p.suma()
+	if err != nil {
+		return nil, err
+	}
+
+	t := p.actual()
+	switch {
+	case t.Tipo == tokOperador && (t.Texto == "==" || t.Texto == "!=" || t.Texto == ">" || t.Texto == "<" ||
+		t.Texto == ">=" || t.Texto == "<=" || t.Texto == "=~" || t.Texto == "!~"):
+		p.consumir()
+		der, err := p.suma()
+		if err != nil {
+			return nil, err
+		}
+		return &nodoBinario{pos: t.Pos, op: t.Texto, izq: izq, der: der}, nil
+
+	case t.Tipo == tokNombre && palabrasEn[t.Texto]:
+		// estado in ("A", "B") / estado in listaEstados
+		p.consumir()
+		if p.esOperador("(") {
+			opciones, err := p.argumentos()
+			if err != nil {
+				return nil, err
+			}
+			return &nodoEn{pos: t.Pos, valor: izq, opciones: opciones}, nil
+		}
+		der, err := p.suma()
+		if err != nil {
+			return nil, err
+		}
+		return &nodoEn{pos: t.Pos, valor: izq, opciones: []nodoExpresion{der}}, nil
+
+	case t.Tipo == tokNombre:
+		// nombre incluye "ana" / nombre startsWith "A"
+		funcion, existe := funcionConAlias(t.Texto)
+		if !existe || !operadoresTexto[funcion] {
+			return izq, nil
+		}
+		p.consumir()
+		der, err := p.suma()
+		if err != nil {
+			return nil, err
+		}
+		return &nodoLlamada{pos: t.Pos, nombre: funcion, argumentos: []nodoExpresion{izq, der}}, nil
+	}
+	return izq, nil
+}
+
+func (p *parserExpresion) suma() (nodoExpresion, error) {
+	izq, err := p.producto()
+	if err != nil {
+		return nil, err
+	}
+	for p.esOperador("+") || p.esOperador("-") {
+		t := p.consumir()
+		der, err := p.producto()
+		if err != nil {
+			return nil, err
+		}
+		izq = &nodoBinario{pos: t.Pos, op: t.Texto, izq: izq, der: der}
+	}
+	return izq, nil
+}
+
+func (p *parserExpresion) producto() (nodoExpresion, error) {
+	izq, err := p.unario()
+	if err != nil {
+		return nil, err
+	}
+	for p.esOperador("*") || p.esOperador("/") || p.esOperador("%") {
+		t := p.consumir()
+		der, err := p.unario()
+		if err != nil {
+			return nil, err
+		}
+		izq = &nodoBinario{pos: t.Pos, op: t.Texto, izq: izq, der: der}
+	}
+	return izq, nil
+}
+
+func (p *parserExpresion) unario() (nodoExpresion, error) {
+	if p.esOperador("-") {
+		t := p.consumir()
+		operando, err := p.unario()
+		if err != nil {
+			return nil, err
+		}
+		return &nodoUnario{pos: t.Pos, op: "-", operando: operando}, nil
+	}
+	if p.esOperador("!") {
+		t := p.consumir()
+		operando, err := p.unario()
+		if err != nil {
+			return nil, err
+		}
+		return &nodoUnario{pos: t.Pos, op: "!", operando: operando}, nil
+	}
+	return p.postfijo()
+}
+
+// postfijo arma rutas (cliente.direcciones[0].ciudad), índices calculados y llamadas con punto
+func (p *parserExpresion) postfijo() (nodoExpresion, error) {
+	base, err := p.primario()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		switch {
+		case p.esOperador("."):
+			p.consumir()
+			t := p.actual()
+			if t.Tipo != tokNombre {
+				return nil, p.errorEn(t, fmt.Sprintf("se esperaba un nombre después de '.' y se encontró '%s'", t.Texto))
+			}
+			p.consumir()
+
+			// x.incluye("a") → incluye(x, "a")
+			if p.esOperador("(") {
+				funcion, existe := funcionConAlias(t.Texto)
+				if !existe {
+					return nil, p.errorEn(t, fmt.Sprintf("función desconocida '%s'", t.Texto))
+				}
+				argumentos, err := p.argumentos()
+				if err != nil {
+					return nil, err
+				}
+				base = &nodoLlamada{pos: t.Pos, nombre: funcion, argumentos: append([]nodoExpresion{base}, argumentos...)}
+				continue
+			}
+			base = p.extenderRuta(base, t.Texto, false)
+
+		case p.esOperador("["):
+			abre := p.consumir()
+			indice, err := p.expresion()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := p.esperar("]"); err != nil {
+				return nil, err
+			}
+			// Un índice fijo sigue siendo parte de la ruta; uno calculado se resuelve al evaluar
+			if lit, ok := indice.(*nodoLiteral); ok {
+				switch v := lit.valor.(type) {
+				case float64:
+					if v == float64(int(v)) {
+						base = p.extenderRuta(base, strconv.Itoa(int(v)), true)
+						continue
+					}
+				case string:
+					base = p.extenderRuta(base, v, false)
+					continue
+				}
+			}
+			base = &nodoIndice{pos: abre.Pos, base: base, indice: indice}
+
+		default:
+			return base, nil
+		}
+	}
+}
+
+// extenderRuta agrega un paso a una variable con ruta; si la base no es una variable (una llamada,
+// un paréntesis) el paso se aplica sobre su valor
+func (p *parserExpresion) extenderRuta(base nodoExpresion, clave string, esIndice bool) nodoExpresion {
+	if v, ok := base.(*nodoVariable); ok {
+		ruta := v.ruta
+		if esIndice {
+			ruta += "[" + clave + "]"
+		} else if esParteDeRuta(clave) {
+			ruta += "." + clave
+		} else {
+			ruta += "[" + strconv.Quote(clave) + "]"
+		}
+		return &nodoVariable{pos: v.pos, ruta: ruta, nombre: v.nombre}
+	}
+	var indice nodoExpresion = &nodoLiteral{valor: clave}
+	if esIndice {
+		n, _ := strconv.Atoi(clave)
+		indice = &nodoLiteral{valor: float64(n)}
+	}
+	return &nodoIndice{pos: base.posicion(), base: base, indice: indice}
+}
+
+func esParteDeRuta(clave string) bool {
+	for i, c := range clave {
+		if (i == 0 && !esInicioIdentificador(c)) || !esParteIdentificador(c) {
+			return false
+		}
+	}
+	return clave != ""
+}
+
+func (p *parserExpresion) primario() (nodoExpresion, error) {
+	t := p.actual()
+	switch t.Tipo {
+	case tokNumero, tokTexto:
+		p.consumir()
+		return &nodoLiteral{pos: t.Pos, valor: t.Valor}, nil
+
+	case tokNombreCorchetes:
+		p.consumir()
+		return &nodoVariable{pos: t.Pos, ruta: t.Texto, nombre: t.Texto}, nil
+
+	case tokNombre:
+		p.consumir()
+		switch {
+		case p.esOperador("("):
+			funcion, existe := funcionConAlias(t.Texto)
+			if !existe {
+				return nil, p.errorEn(t, fmt.Sprintf("función desconocida '%s'", t.Texto))
+			}
+			argumentos, err := p.argumentos()
+			if err != nil {
+				return nil, err
+			}
+			return &nodoLlamada{pos: t.Pos, nombre: funcion, argumentos: argumentos}, nil
+		case palabrasVerdad[t.Texto]:
+			return &nodoLiteral{pos: t.Pos, valor: true}, nil
+		case palabrasFalso[t.Texto]:
+			return &nodoLiteral{pos: t.Pos, valor: false}, nil
+		case palabrasNulo[t.Texto]:
+			return &nodoLiteral{pos: t.Pos, valor: nil}, nil
+		}
+		return &nodoVariable{pos: t.Pos, ruta: t.Texto, nombre: t.Texto}, nil
+
+	case tokOperador:
+		if t.Texto == "(" {
+			p.consumir()
+			interna, err := p.expresion()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := p.esperar(")"); err != nil {
+				return nil, err
+			}
+			return interna, nil
+		}
+	}
+
+	if t.Tipo == tokFin {
+		return nil, p.errorEn(t, "la expresión termina de forma incompleta")
+	}
+	return nil, p.errorEn(t, fmt.Sprintf("se esperaba un valor y se encontró '%s'", t.Texto))
+}
+
+func (p *parserExpresion) argumentos() ([]nodoExpresion, error) {
+	if _, err := p.esperar("("); err != nil {
+		return nil, err
+	}
+	var argumentos []nodoExpresion
+	if p.esOperador(")") {
+		p.consumir()
+		return argumentos, nil
+	}
+	for {
+		arg, err := p.expresion()
+		if err != nil {
+			return nil, err
+		}
+		argumentos = append(argumentos, arg)
+		if p.esOperador(",") {
+			p.consumir()
+			continue
+		}
+		if _, err := p.esperar(")"); err != nil {
+			return nil, err
+		}
+		return argumentos, nil
+	}
+}
+
+// iniciaOperando indica si el token puede empezar un valor (para distinguir NO operador de no variable)
+func iniciaOperando(t token) bool {
+	switch t.Tipo {
+	case tokNumero, tokTexto, tokNombre, tokNombreCorchetes:
+		return true
+	case tokOperador:
+		return t.Texto == "(" || t.Texto == "!" || t.Texto == "-"
+	}
+	return false
+}
+
+// ResultadoExpresion es la respuesta de ValidarExpresion para el diseñador
+type ResultadoExpresion struct {
+	Valida       bool           `json:"valida"`
+	Error        *ErrorSintaxis `json:"error,omitempty"`
+	Variables    []string       `json:"variables"`
+	Funciones    []string       `json:"funciones"`
+	Advertencias []string       `json:"advertencias"`
+}
+
+// ValidarExpresion revisa la sintaxis sin evaluar. Si se pasan las variables disponibles, avisa de
+// las que la expresión lee y no existen (se compara la raíz de cada ruta)
+func ValidarExpresion(expr string, variablesDisponibles []string) ResultadoExpresion {
+	resultado := ResultadoExpresion{Variables: []string{}, Funciones: []string{}, Advertencias: []string{}}
+
+	compilada, err := CompilarExpresion(expr)
+	if err != nil {
+		var errSintaxis *ErrorSintaxis
+		if !errors.As(err, &errSintaxis) {
+			errSintaxis = &ErrorSintaxis{Linea: 1, Columna: 1, Mensaje: err.Error()}
+		}
+		resultado.Error = errSintaxis
+		return resultado
+	}
+
+	resultado.Valida = true
+	resultado.Variables = compilada.Variables()
+	resultado.Funciones = compilada.Funciones()
+	if variablesDisponibles == nil {
+		return resultado
+	}
+
+	disponibles := make(map[string]bool, len(variablesDisponibles))
+	for _, v := range variablesDisponibles {
+		disponibles[v] = true
+	}
+	for _, v := range resultado.Variables {
+		if !disponibles[v] && !disponibles[RaizRuta(v)] {
+			resultado.Advertencias = append(resultado.Advertencias, fmt.Sprintf("La variable '%s' no está disponible", v))
+		}
+	}
+	return resultado
+}
@@ -0,0 +1,144 @@
+package utils
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// segmentoRuta es un paso de una ruta: una clave de mapa o una posición de arreglo
+type segmentoRuta struct {
+	Clave  string
+	Indice int
+	EsIdx  bool
+}
+
+// ResolverRuta busca un valor por nombre plano o por ruta, por ejemplo
+// nodo_3.cliente.direcciones[0].ciudad o respuesta["tipo-doc"]. Si existe una variable con el
+// nombre exacto se devuelve esa, así los flujos con nombres planos siguen igual. Lo que no está en
+// el espacio de un nodo se busca en su FullOutput: nodo_3.campo cae en fullOutput_nodo_3.campo
+func ResolverRuta(valores map[string]interface{}, ruta string) (interface{}, bool) {
+	if v, ok := valores[ruta]; ok {
+		return v, true
+	}
+	segmentos, err := parsearRuta(ruta)
+	if err != nil || len(segmentos) < 2 {
+		return nil, false
+	}
+
+	if v, ok := recorrerRuta(valores, segmentos); ok {
+		return v, true
+	}
+	if segmentos[0].EsIdx {
+		return nil, false
+	}
+	fullOutput, ok := valores["fullOutput_"+segmentos[0].Clave]
+	if !ok {
+		return nil, false
+	}
+	return recorrerRuta(fullOutput, segmentos[1:])
+}
+
+// recorrerRuta baja por los segmentos desde actual
+func recorrerRuta(actual interface{}, segmentos []segmentoRuta) (interface{}, bool) {
+	for _, s := range segmentos {
+		siguiente, ok := pasoRuta(actual, s)
+		if !ok {
+			return nil, false
+		}
+		actual = siguiente
+	}
+	return actual, true
+}
+
+// RaizRuta devuelve la primera clave de una ruta (nodo_3 en nodo_3.cliente.nombre)
+func RaizRuta(ruta string) string {
+	segmentos, err := parsearRuta(ruta)
+	if err != nil || len(segmentos) == 0 || segmentos[0].EsIdx {
+		return ruta
+	}
+	return segmentos[0].Clave
+}
+
+// parsearRuta separa una ruta en claves (.clave o ["clave"]) e índices ([n])
+func parsearRuta(ruta string) ([]segmentoRuta, error) {
+	var segmentos []segmentoRuta
+	r := []rune(ruta)
+	i := 0
+	for i < len(r) {
+		switch r[i] {
+		case '.':
+			i++
+			continue
+		case '[':
+			fin := i + 1
+			for fin < len(r) && r[fin] != ']' {
+				fin++
+			}
+			if fin >= len(r) {
+				return nil, fmt.Errorf("falta ']' en la ruta '%s'", ruta)
+			}
+			contenido := strings.TrimSpace(string(r[i+1 : fin]))
+			if n, err := strconv.Atoi(contenido); err == nil {
+				segmentos = append(segmentos, segmentoRuta{Indice: n, EsIdx: true})
+			} else {
+				segmentos = append(segmentos, segmentoRuta{Clave: strings.Trim(contenido, `"'`)})
+			}
+			i = fin + 1
+		default:
+			fin := i
+			for fin < len(r) && r[fin] != '.' && r[fin] != '[' {
+				fin++
+			}
+			segmentos = append(segmentos, segmentoRuta{Clave: string(r[i:fin])})
+			i = fin
+		}
+	}
+	return segmentos, nil
+}
+
+// pasoRuta baja un nivel en mapas o arreglos de cualquier tipo (JSON decodificado, JSONMap, []map...)
+func pasoRuta(actual interface{}, s segmentoRuta) (interface{}, bool) {
+	switch x := actual.(type) {
+	case map[string]interface{}:
+		if s.EsIdx {
+			return nil, false
+		}
+		v, ok := x[s.Clave]
+		return v, ok
+	case []interface{}:
+		if !s.EsIdx || s.Indice < 0 || s.Indice >= len(x) {
+			return nil, false
+		}
+		return x[s.Indice], true
+	}
+
+	v := reflect.ValueOf(actual)
+	switch v.Kind() {
+	case reflect.Map:
+		if s.EsIdx || v.Type().Key().Kind() != reflect.String {
+			return nil, false
+		}
+		elem := v.MapIndex(reflect.ValueOf(s.Clave).Convert(v.Type().Key()))
+		if !elem.IsValid() {
+			return nil, false
+		}
+		return elem.Interface(), true
+	case reflect.Slice, reflect.Array:
+		if !s.EsIdx || s.Indice < 0 || s.Indice >= v.Len() {
+			return nil, false
+		}
+		return v.Index(s.Indice).Interface(), true
+	}
+	return nil, false
+}
+
+func esInicioIdentificador(c rune) bool {
+	return c == '_' || unicode.IsLetter(c)
+}
+
+func esParteIdentificador(c rune) bool {
+	return c == '_' || unicode.IsLetter(c) || unicode.IsDigit(c)
+}
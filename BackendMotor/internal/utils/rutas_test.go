@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestResolverRuta(t *testing.T) {
+	valores := map[string]interface{}{
+		"codigo":            "00",
+		"cliente.id":        "plano",
+		"respuesta":         map[string]interface{}{"tipo-doc": "CC", "items": []interface{}{"a", "b"}},
+		"filas":             []map[string]interface{}{{"id": 1}, {"id": 2}},
+		"nodo_3":            map[string]interface{}{"codigo": "01", "cliente": map[string]interface{}{"direcciones": []interface{}{map[string]interface{}{"ciudad": "Lima"}}}},
+		"fullOutput_nodo_3": map[string]interface{}{"codigo": "99", "total": 3.0, "detalle": map[string]interface{}{"lineas": []interface{}{"x"}}},
+		"fullOutput_nodo_4": map[string]interface{}{"estado": "OK"},
+	}
+
+	casos := []struct {
+		nombre   string
+		ruta     string
+		esperado interface{}
+		existe   bool
+	}{
+		{"nombre plano", "codigo", "00", true},
+		{"nombre plano con punto gana a la ruta", "cliente.id", "plano", true},
+		{"clave entre corchetes", `respuesta["tipo-doc"]`, "CC", true},
+		{"índice en arreglo", "respuesta.items[1]", "b", true},
+		{"índice en arreglo tipado", "filas[1].id", 2, true},
+		{"ruta profunda en el espacio", "nodo_3.cliente.direcciones[0].ciudad", "Lima", true},
+		{"índice fuera de rango", "respuesta.items[5]", nil, false},
+		{"índice sobre un mapa", "respuesta[0]", nil, false},
+		{"segmento que falta", "respuesta.noExiste", nil, false},
+		{"raíz que falta", "otro.campo", nil, false},
+		{"segmento bajo un escalar", "codigo.largo", nil, false},
+
+		// El espacio del nodo gana; lo que no tiene se busca en su FullOutput
+		{"espacio antes que FullOutput", "nodo_3.codigo", "01", true},
+		{"FullOutput del nodo", "nodo_3.total", 3.0, true},
+		{"ruta dentro del FullOutput", "nodo_3.detalle.lineas[0]", "x", true},
+		{"nodo sin espacio", "nodo_4.estado", "OK", true},
+		{"falta también en el FullOutput", "nodo_4.otro", nil, false},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			v, ok := ResolverRuta(valores, c.ruta)
+			if ok != c.existe || !reflect.DeepEqual(v, c.esperado) {
+				t.Fatalf("%s: se esperaba (%v, %v) y se obtuvo (%v, %v)", c.ruta, c.esperado, c.existe, v, ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+-- Migración: Cola durable de ejecuciones del motor
+-- Fecha: 2026-10-18
+-- Propósito: Registrar las ejecuciones asíncronas y programadas antes de empezar y guardar un
+--            checkpoint por nodo, para reanudarlas o marcarlas abandonadas tras un reinicio.
+--            Cada fila tiene un motor propietario que renueva su lease mientras está vivo; otro
+--            motor solo la retoma cuando el lease venció
+
+-- 1. Tabla de la cola (una fila por ejecución aceptada)
+CREATE TABLE IF NOT EXISTS cola_ejecuciones (
+    id               UUID PRIMARY KEY,
+    proceso_id       VARCHAR NOT NULL,
+    canal            VARCHAR,
+    trigger          VARCHAR,
+    origen           VARCHAR(20) NOT NULL,
+    referencia_id    UUID,
+    callback_url     TEXT,
+    entrada          JSONB,
+    simulacion       JSONB,
+    estado           VARCHAR(20) NOT NULL,
+    intentos         INTEGER DEFAULT 0,
+    propietario      VARCHAR,
+    lease_hasta      TIMESTAMPTZ,
+    checkpoint       JSONB,
+    nodo_checkpoint  VARCHAR,
+    mensaje_error    TEXT,
+    fecha_encolado   TIMESTAMPTZ NOT NULL,
+    fecha_inicio     TIMESTAMPTZ,
+    fecha_checkpoint TIMESTAMPTZ,
+    fecha_fin        TIMESTAMPTZ
+);
+
+-- 2. Índice para la recuperación al arrancar (solo se buscan las no terminadas)
+CREATE INDEX IF NOT EXISTS idx_cola_ejecuciones_pendientes
+    ON cola_ejecuciones(fecha_encolado)
+    WHERE estado IN ('pendiente', 'ejecutando');
+
+-- 3. Motor que corre cada ejecución síncrona, para cerrar solo las propias al reiniciar
+ALTER TABLE ejecuciones ADD COLUMN IF NOT EXISTS propietario VARCHAR;
+
+-- 4. Las tareas programadas y las ejecuciones pueden quedar marcadas como abandonadas
+COMMENT ON COLUMN ejecuciones_tareas.estado IS 'exitoso, error, ejecutando o abandonada';
+COMMENT ON COLUMN ejecuciones.estado IS 'ejecutando, exitoso, error, incompleto o abandonada';
+
+-- Verificar la migración
+SELECT 'Tabla de cola:' as info;
+SELECT table_name
+FROM information_schema.tables
+WHERE table_name = 'cola_ejecuciones';
@@ -0,0 +1,60 @@
+-- Migración: Traza persistente de ejecuciones del motor
+-- Fecha: 2026-10-18
+-- Propósito: Guardar cada ejecución y cada paso por nodo para poder consultarlos por API
+--            (GET /ejecuciones y GET /ejecuciones/:id) sin revisar los archivos de log
+
+-- 1. Tabla de ejecuciones (una fila por request o subproceso)
+CREATE TABLE IF NOT EXISTS ejecuciones (
+    id                 UUID PRIMARY KEY,
+    proceso_id         VARCHAR NOT NULL,
+    nombre_proceso     VARCHAR,
+    canal              VARCHAR,
+    trigger            VARCHAR,
+    trace_id           VARCHAR,
+    ejecucion_padre_id UUID REFERENCES ejecuciones(id) ON DELETE SET NULL,
+    profundidad        INTEGER DEFAULT 0,
+    estado             VARCHAR(20) NOT NULL,
+    codigo_resultado   INTEGER DEFAULT 0,
+    mensaje            TEXT,
+    entrada            JSONB,
+    salida             JSONB,
+    detalle_error      TEXT,
+    fecha_inicio       TIMESTAMPTZ NOT NULL,
+    fecha_fin          TIMESTAMPTZ,
+    duracion_ms        BIGINT DEFAULT 0
+);
+
+-- 2. Tabla de pasos (un nodo ejecutado dentro de una ejecución)
+CREATE TABLE IF NOT EXISTS ejecucion_pasos (
+    id              UUID PRIMARY KEY,
+    ejecucion_id    UUID NOT NULL REFERENCES ejecuciones(id) ON DELETE CASCADE,
+    secuencia       INTEGER NOT NULL,
+    nodo_id         VARCHAR NOT NULL,
+    tipo_nodo       VARCHAR,
+    rama            VARCHAR,
+    entrada         JSONB,
+    salida          JSONB,
+    aristas_tomadas JSONB,
+    estado          VARCHAR(20),
+    detalle_error   TEXT,
+    fecha_inicio    TIMESTAMPTZ NOT NULL,
+    duracion_ms     BIGINT DEFAULT 0
+);
+
+-- 3. Índices para los filtros de la API
+CREATE INDEX IF NOT EXISTS idx_ejecuciones_proceso ON ejecuciones(proceso_id);
+CREATE INDEX IF NOT EXISTS idx_ejecuciones_canal ON ejecuciones(canal);
+CREATE INDEX IF NOT EXISTS idx_ejecuciones_estado ON ejecuciones(estado);
+CREATE INDEX IF NOT EXISTS idx_ejecuciones_fecha ON ejecuciones(fecha_inicio DESC);
+CREATE INDEX IF NOT EXISTS idx_ejecuciones_trace ON ejecuciones(trace_id);
+CREATE INDEX IF NOT EXISTS idx_ejecucion_pasos_ejecucion ON ejecucion_pasos(ejecucion_id, secuencia);
+
+-- 4. Retención: el motor borra cada hora los pasos más viejos que MOTOR_TRAZA_RETENCION_DIAS
+--    (30 por defecto, 0 = conservarlos siempre); las filas de ejecuciones se conservan
+CREATE INDEX IF NOT EXISTS idx_ejecucion_pasos_fecha ON ejecucion_pasos(fecha_inicio);
+
+-- Verificar la migración
+SELECT 'Tablas de traza:' as info;
+SELECT table_name
+FROM information_schema.tables
+WHERE table_name IN ('ejecuciones', 'ejecucion_pasos');